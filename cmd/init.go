@@ -1,35 +1,39 @@
 package cmd
 
 import (
+	"context"
 	"database/sql"
-	"io/ioutil"
+	"fmt"
 	"os"
 	"time"
 
 	"boiler/pkg/service"
+	"boiler/pkg/store"
 	"boiler/pkg/store/config"
 	"boiler/pkg/store/database"
+	"boiler/pkg/store/mailer"
+	"boiler/pkg/store/memory"
+	"boiler/pkg/store/migration"
+	"boiler/pkg/store/queue"
+	"boiler/pkg/store/throttle"
 
-	"github.com/gocraft/work"
 	"github.com/gomodule/redigo/redis"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
+// NewDB open the database
+func NewDB(conf config.Database) (*sql.DB, database.Dialect, error) {
+	dialect, err := database.DialectByDriver(conf.Driver)
+	if err != nil {
+		return nil, nil, err
 	}
-	return !info.IsDir()
-}
-
-func newDB(path string) (*sql.DB, error) {
-	createTable := !fileExists(path)
 
-	db, err := sql.Open("sqlite3", path)
+	db, err := sql.Open(dialect.Driver(), conf.DSN)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	db.SetMaxIdleConns(2)
@@ -39,48 +43,138 @@ func newDB(path string) (*sql.DB, error) {
 	err = db.Ping()
 	if err != nil {
 		db.Close()
+		return nil, nil, err
+	}
+
+	return db, dialect, nil
+}
+
+// NewMigrator return the migrator of the database
+func NewMigrator(db *sql.DB, dialect database.Dialect) (*migration.Migrator, error) {
+	m, err := migration.New(db, dialect.Migrations())
+	if err != nil {
 		return nil, err
 	}
 
-	if createTable {
-		log.Info().Msg("Creating DB")
+	m.Rebind = dialect.Rebind
+	return m, nil
+}
 
-		content, err := ioutil.ReadFile("pkg/store/database/schema.sql")
-		if err != nil {
-			db.Close()
-			return nil, err
-		}
+// NewStore return the store selected by the config, migrating it if needed
+func NewStore(conf config.Database) (store.Interface, error) {
+	if conf.Driver == "memory" {
+		log.Warn().Msg("using the memory store, data will be lost on exit")
+		return memory.New(), nil
+	}
 
-		if _, err := db.Exec(string(content)); err != nil {
-			db.Close()
-			return nil, err
-		}
+	db, dialect, err := NewDB(conf)
+	if err != nil {
+		return nil, fmt.Errorf("could not start DB; %w", err)
+	}
+
+	migrator, err := NewMigrator(db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not load migrations; %w", err)
 	}
 
-	return db, nil
+	if err := migrator.Up(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate DB; %w", err)
+	}
+
+	return database.NewWithDialect(db, dialect), nil
 }
 
-func New(conf *config.Config) (service.Interface, *redis.Pool) {
+// NewMailer return the mailer selected by the config
+func NewMailer(conf config.Mailer) (mailer.Mailer, error) {
+	switch conf.Driver {
+	case "log":
+		return mailer.Log{}, nil
+	case "smtp":
+		return mailer.NewSMTP(conf.SMTP.Addr, conf.SMTP.Username, conf.SMTP.Password, conf.From)
+	case "maildir":
+		return mailer.NewMaildir(conf.Maildir, conf.From)
+	}
 
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	return nil, fmt.Errorf("unknown mailer %s", conf.Driver)
+}
+
+// NewThrottle return the throttle selected by the config
+func NewThrottle(conf config.Throttle, pool *redis.Pool) (throttle.Throttle, error) {
+	switch conf.Driver {
+	case "memory":
+		return throttle.NewMemory(), nil
+	case "redis":
+		return throttle.WithFallback(throttle.NewRedis(pool, "throttle:"), throttle.NewMemory()), nil
+	}
 
-	var redisPool = &redis.Pool{
-		MaxActive: conf.Worker.Redis.MaxActive,
-		MaxIdle:   conf.Worker.Redis.MaxIdle,
+	return nil, fmt.Errorf("unknown throttle %s", conf.Driver)
+}
+
+// NewRedisPool return the pool of the redis server of the config
+func NewRedisPool(conf config.Redis) *redis.Pool {
+	return &redis.Pool{
+		MaxActive: conf.MaxActive,
+		MaxIdle:   conf.MaxIdle,
 		Wait:      true,
 		Dial: func() (redis.Conn, error) {
-			return redis.Dial("tcp", conf.Worker.Redis.Address)
+			return redis.Dial("tcp", conf.Address)
 		},
 	}
+}
+
+// NewQueue return the job queue selected by the config, the database queue shares the database of st
+func NewQueue(conf config.Worker, st store.Interface, pool *redis.Pool) (queue.Queue, error) {
+	switch conf.Queue.Driver {
+	case "redis":
+		return queue.NewRedis("all", pool), nil
+	case "database":
+		db, ok := st.(*database.Database)
+		if !ok {
+			return nil, fmt.Errorf("the database queue needs a sqlite3 or postgres database")
+		}
+
+		q := queue.NewSQL(db.DB())
+		q.Rebind = db.Dialect().Rebind
+		q.Visibility = conf.Queue.Visibility
+		return q, nil
+	}
+
+	return nil, fmt.Errorf("unknown queue %s", conf.Queue.Driver)
+}
 
-	sql, err := newDB(conf.Sqlite3)
+// New return the service and the job queue selected by the config
+func New(conf *config.Config) (service.Interface, queue.Queue) {
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	redisPool := NewRedisPool(conf.Worker.Redis)
+
+	st, err := NewStore(conf.Database)
 	if err != nil {
-		log.Fatal().Err(err).Msg("could not start DB")
+		log.Fatal().Err(err).Msg("could not start store")
 	}
 
-	st := database.New(sql)
+	mail, err := NewMailer(conf.Mailer)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not start mailer")
+	}
 
-	enqueuer := work.NewEnqueuer("all", redisPool)
+	thr, err := NewThrottle(conf.Throttle, redisPool)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not start throttle")
+	}
+
+	q, err := NewQueue(conf.Worker, st, redisPool)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not start queue")
+	}
 
-	return service.New(conf, st, enqueuer), redisPool
+	return service.New(conf, st, service.Options{
+		Enqueuer: q,
+		Mailer:   mail,
+		Throttle: thr,
+		DeadJobs: q.NewClient(),
+	}), q
 }
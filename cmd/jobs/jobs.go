@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"boiler/cmd"
+	"boiler/pkg/store/config"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(),
+		"Usage: %s [flags] dead|retry <died_at> <id>|retry-all|delete <died_at> <id>|delete-all\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var page = flag.Uint("page", 1, "page of 20 dead jobs listed by dead")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	args := map[string]int{"dead": 1, "retry": 3, "retry-all": 1, "delete": 3, "delete-all": 1}
+	if n, ok := args[flag.Arg(0)]; !ok || flag.NArg() != n {
+		usage()
+		os.Exit(2)
+	}
+
+	var diedAt int64
+	if flag.NArg() == 3 {
+		var err error
+		diedAt, err = strconv.ParseInt(flag.Arg(1), 10, 64)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid died_at")
+		}
+	}
+
+	cfg := config.New()
+	st, err := cmd.NewStore(cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not start store")
+	}
+
+	q, err := cmd.NewQueue(cfg.Worker, st, cmd.NewRedisPool(cfg.Worker.Redis))
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not start queue")
+	}
+	client := q.NewClient()
+
+	switch flag.Arg(0) {
+	case "dead":
+		jobs, total, err := client.DeadJobs(*page)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not list dead jobs")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "DIED AT\tID\tNAME\tFAILS\tERROR")
+		for _, j := range jobs {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", j.DiedAt, j.ID, j.Name, j.Fails, j.LastErr)
+		}
+		w.Flush()
+		fmt.Printf("page %d, %d dead jobs\n", *page, total)
+		return
+	case "retry":
+		err = client.RetryDeadJob(diedAt, flag.Arg(2))
+	case "retry-all":
+		err = client.RetryAllDeadJobs()
+	case "delete":
+		err = client.DeleteDeadJob(diedAt, flag.Arg(2))
+	case "delete-all":
+		err = client.DeleteAllDeadJobs()
+	}
+
+	if err != nil {
+		log.Fatal().Err(err).Msgf("could not %s", flag.Arg(0))
+	}
+
+	log.Info().Msg("done")
+}
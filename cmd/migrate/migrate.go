@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"boiler/cmd"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/database"
+	"boiler/pkg/store/migration"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up|down|status|create <name>\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var steps = flag.Int("steps", 1, "number of migrations to revert on down")
+	var dir = flag.String("dir", "pkg/store/database/migrations", "migrations directory used by create")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	if flag.Arg(0) == "create" {
+		if flag.NArg() != 2 {
+			usage()
+			os.Exit(2)
+		}
+
+		// every dialect has its own copy of the migrations
+		for _, dialect := range []database.Dialect{database.Sqlite3, database.Postgres} {
+			up, down, err := migration.Create(filepath.Join(*dir, dialect.Driver()), flag.Arg(1))
+			if err != nil {
+				log.Fatal().Err(err).Msg("could not create migration")
+			}
+
+			log.Info().Str("up", up).Str("down", down).Msg("created")
+		}
+		return
+	}
+
+	cfg := config.New()
+	db, dialect, err := cmd.NewDB(cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not start DB")
+	}
+	defer db.Close()
+
+	migrator, err := cmd.NewMigrator(db, dialect)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not load migrations")
+	}
+
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx, *steps)
+	case "status":
+		var status []migration.Status
+		if err = migrator.Status(ctx, &status); err == nil {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+			for _, s := range status {
+				applied := "pending"
+				if s.IsApplied() {
+					applied = s.Applied.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+			}
+			err = w.Flush()
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal().Err(err).Str("command", flag.Arg(0)).Msg("migration failed")
+	}
+}
@@ -0,0 +1,25 @@
+package graphql
+
+import (
+	"context"
+
+	"boiler/cmd/server/internal/graphql/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// HasRole implement the @hasRole directive, the field is resolved only for the users with role
+func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role entity.Role) (interface{}, error) {
+	viewer, ok := service.Viewer(ctx)
+	if !ok {
+		return nil, errors.ErrUnauthorized
+	}
+
+	if !viewer.HasRole(role.Name()) {
+		return nil, errors.ErrForbidden
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,46 @@
+package graphql_test
+
+import (
+	"context"
+	"testing"
+
+	"boiler/cmd/server/internal/graphql"
+	gentity "boiler/cmd/server/internal/graphql/entity"
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasRole(t *testing.T) {
+	next := func(ctx context.Context) (interface{}, error) {
+		return "ok", nil
+	}
+
+	as := func(roles ...string) context.Context {
+		return context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: 4, Roles: roles})
+	}
+
+	// succeed
+	{
+		res, err := graphql.HasRole(as(entity.RoleMember, entity.RoleAdmin), nil, next, gentity.RoleAdmin)
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", res)
+	}
+
+	// fails without the role
+	{
+		res, err := graphql.HasRole(as(entity.RoleSupport), nil, next, gentity.RoleAdmin)
+		assert.Nil(t, res)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if anonymous
+	{
+		res, err := graphql.HasRole(context.Background(), nil, next, gentity.RoleAdmin)
+		assert.Nil(t, res)
+		assert.Equal(t, errors.ErrUnauthorized, err)
+	}
+}
@@ -2,42 +2,418 @@
 
 package entity
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type APIKey struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Prefix   string     `json:"prefix"`
+	Scopes   []string   `json:"scopes"`
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires"`
+	LastUsed *time.Time `json:"lastUsed"`
+	Revoked  bool       `json:"revoked"`
+}
+
+type AddAPIKeyResponse struct {
+	APIKey *APIKey `json:"apiKey"`
+	Key    string  `json:"key"`
+}
+
 type AuthUserResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        *string `json:"token"`
+	RefreshToken *string `json:"refreshToken"`
+	Challenge    *string `json:"challenge"`
+	User         *User   `json:"user"`
+}
+
+type ConfirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type DeleteUserResponse struct {
+	Job *Job `json:"job"`
 }
 
 type Email struct {
-	ID      string `json:"id"`
-	Address string `json:"address"`
-	User    *User  `json:"user"`
+	ID         string     `json:"id"`
+	Address    string     `json:"address"`
+	VerifiedAt *time.Time `json:"verifiedAt"`
+	User       *User      `json:"user"`
+	DeletedAt  *time.Time `json:"deletedAt"`
+}
+
+type EmailConnection struct {
+	Edges    []*EmailEdge `json:"edges"`
+	PageInfo *PageInfo    `json:"pageInfo"`
+}
+
+type EmailEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Email `json:"node"`
 }
 
 type EmailResponse struct {
 	Email *Email `json:"email"`
 }
 
+type Job struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Status  JobStatus `json:"status"`
+	Error   *string   `json:"error"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+type Member struct {
+	User    *User            `json:"user"`
+	Role    OrganizationRole `json:"role"`
+	Created time.Time        `json:"created"`
+}
+
+type MemberResponse struct {
+	Member *Member `json:"member"`
+}
+
+type Organization struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Members []*Member `json:"members"`
+}
+
+type OrganizationResponse struct {
+	Organization *Organization `json:"organization"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+type Schedule struct {
+	Job        string     `json:"job"`
+	Spec       string     `json:"spec"`
+	LastRun    *time.Time `json:"lastRun"`
+	LastStatus *JobStatus `json:"lastStatus"`
+	NextRun    time.Time  `json:"nextRun"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
 type User struct {
-	ID     string   `json:"id"`
-	Name   string   `json:"name"`
-	Emails []*Email `json:"emails"`
+	ID            string           `json:"id"`
+	Name          string           `json:"name"`
+	Created       time.Time        `json:"created"`
+	Updated       time.Time        `json:"updated"`
+	Emails        *EmailConnection `json:"emails"`
+	Roles         []Role           `json:"roles"`
+	APIKeys       []*APIKey        `json:"apiKeys"`
+	Organizations []*Organization  `json:"organizations"`
+	DeletedAt     *time.Time       `json:"deletedAt"`
+}
+
+type UserConnection struct {
+	Edges    []*UserEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
 }
 
 type UserResponse struct {
 	User *User `json:"user"`
 }
 
+type AcceptInvitationInput struct {
+	Token string `json:"token"`
+}
+
+type AddAPIKeyInput struct {
+	UserID  string     `json:"userID"`
+	Name    string     `json:"name"`
+	Scopes  []string   `json:"scopes"`
+	Expires *time.Time `json:"expires"`
+}
+
 type AddEmailInput struct {
 	UserID  string `json:"userID"`
 	Address string `json:"address"`
 }
 
+type AddOrganizationInput struct {
+	Name string `json:"name"`
+}
+
 type AddUserInput struct {
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Name     string  `json:"name"`
+	Password string  `json:"password"`
+	Email    *string `json:"email"`
 }
 
 type AuthUserInput struct {
-	Email    string `json:"email"`
+	Email     *string `json:"email"`
+	Password  *string `json:"password"`
+	Challenge *string `json:"challenge"`
+	Code      *string `json:"code"`
+}
+
+type ChangePasswordInput struct {
+	UserID          string `json:"userID"`
+	CurrentPassword string `json:"currentPassword"`
+	Password        string `json:"password"`
+}
+
+type ConfirmTOTPInput struct {
+	UserID string `json:"userID"`
+	Code   string `json:"code"`
+}
+
+type DeleteUserInput struct {
+	UserID string `json:"userID"`
+}
+
+type EnrollTOTPInput struct {
+	UserID string `json:"userID"`
+}
+
+type InviteMemberInput struct {
+	OrganizationID string           `json:"organizationID"`
+	Email          string           `json:"email"`
+	Role           OrganizationRole `json:"role"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RequestPasswordResetInput struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token"`
 	Password string `json:"password"`
 }
+
+type RestoreEmailInput struct {
+	EmailID string `json:"emailID"`
+}
+
+type RestoreUserInput struct {
+	UserID string `json:"userID"`
+}
+
+type RevokeAPIKeyInput struct {
+	UserID   string `json:"userID"`
+	APIKeyID string `json:"apiKeyID"`
+}
+
+type SetUserRolesInput struct {
+	UserID string `json:"userID"`
+	Roles  []Role `json:"roles"`
+}
+
+type SwitchOrganizationInput struct {
+	RefreshToken   string `json:"refreshToken"`
+	OrganizationID string `json:"organizationID"`
+}
+
+type UpdateUserInput struct {
+	UserID  string    `json:"userID"`
+	Name    *string   `json:"name"`
+	Updated time.Time `json:"updated"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token"`
+}
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "QUEUED"
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusSucceeded JobStatus = "SUCCEEDED"
+	JobStatusFailed    JobStatus = "FAILED"
+)
+
+var AllJobStatus = []JobStatus{
+	JobStatusQueued,
+	JobStatusRunning,
+	JobStatusSucceeded,
+	JobStatusFailed,
+}
+
+func (e JobStatus) IsValid() bool {
+	switch e {
+	case JobStatusQueued, JobStatusRunning, JobStatusSucceeded, JobStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e JobStatus) String() string {
+	return string(e)
+}
+
+func (e *JobStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = JobStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid JobStatus", str)
+	}
+	return nil
+}
+
+func (e JobStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrganizationRole string
+
+const (
+	OrganizationRoleOwner  OrganizationRole = "OWNER"
+	OrganizationRoleAdmin  OrganizationRole = "ADMIN"
+	OrganizationRoleMember OrganizationRole = "MEMBER"
+)
+
+var AllOrganizationRole = []OrganizationRole{
+	OrganizationRoleOwner,
+	OrganizationRoleAdmin,
+	OrganizationRoleMember,
+}
+
+func (e OrganizationRole) IsValid() bool {
+	switch e {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	}
+	return false
+}
+
+func (e OrganizationRole) String() string {
+	return string(e)
+}
+
+func (e *OrganizationRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrganizationRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrganizationRole", str)
+	}
+	return nil
+}
+
+func (e OrganizationRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleAdmin   Role = "ADMIN"
+	RoleSupport Role = "SUPPORT"
+	RoleMember  Role = "MEMBER"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleSupport,
+	RoleMember,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleSupport, RoleMember:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SortBy string
+
+const (
+	SortByID      SortBy = "ID"
+	SortByCreated SortBy = "CREATED"
+)
+
+var AllSortBy = []SortBy{
+	SortByID,
+	SortByCreated,
+}
+
+func (e SortBy) IsValid() bool {
+	switch e {
+	case SortByID, SortByCreated:
+		return true
+	}
+	return false
+}
+
+func (e SortBy) String() string {
+	return string(e)
+}
+
+func (e *SortBy) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortBy(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortBy", str)
+	}
+	return nil
+}
+
+func (e SortBy) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
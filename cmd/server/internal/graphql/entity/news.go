@@ -2,23 +2,129 @@ package entity
 
 import (
 	"strconv"
+	"strings"
 
 	"boiler/pkg/entity"
+	"boiler/pkg/store"
 )
 
 // NewUser return a new User entity
 func NewUser(u *entity.User) *User {
 	return &User{
-		ID:   strconv.FormatInt(u.ID, 10),
-		Name: u.Name,
+		ID:        strconv.FormatInt(u.ID, 10),
+		Name:      u.Name,
+		Created:   u.Created,
+		Updated:   u.Updated,
+		DeletedAt: u.DeletedAt,
 	}
 }
 
 // NewEmail return a new Email entity
 func NewEmail(e *entity.Email) *Email {
 	return &Email{
-		ID:      strconv.FormatInt(e.ID, 10),
-		Address: e.Address,
-		User:    &User{ID: strconv.FormatInt(e.UserID, 10)},
+		ID:         strconv.FormatInt(e.ID, 10),
+		Address:    e.Address,
+		VerifiedAt: e.VerifiedAt,
+		User:       &User{ID: strconv.FormatInt(e.UserID, 10)},
+		DeletedAt:  e.DeletedAt,
 	}
 }
+
+// NewAPIKey return a new APIKey entity
+func NewAPIKey(k *entity.APIKey) *APIKey {
+	return &APIKey{
+		ID:       strconv.FormatInt(k.ID, 10),
+		Name:     k.Name,
+		Prefix:   k.Prefix,
+		Scopes:   k.Scopes,
+		Created:  k.Created,
+		Expires:  k.Expires,
+		LastUsed: k.LastUsed,
+		Revoked:  k.Revoked,
+	}
+}
+
+// NewOrganization return a new Organization entity
+func NewOrganization(o *entity.Organization) *Organization {
+	return &Organization{
+		ID:      strconv.FormatInt(o.ID, 10),
+		Name:    o.Name,
+		Created: o.Created,
+	}
+}
+
+// NewMember return a new Member entity
+func NewMember(m *entity.Member) *Member {
+	return &Member{
+		User:    &User{ID: strconv.FormatInt(m.UserID, 10)},
+		Role:    OrganizationRole(strings.ToUpper(m.Role)),
+		Created: m.Created,
+	}
+}
+
+// NewJob return a new Job entity
+func NewJob(j *entity.Job) *Job {
+	job := &Job{
+		ID:      j.ID,
+		Name:    j.Name,
+		Status:  JobStatus(strings.ToUpper(j.Status)),
+		Created: j.Created,
+		Updated: j.Updated,
+	}
+
+	if len(j.Error) != 0 {
+		job.Error = &j.Error
+	}
+
+	return job
+}
+
+// NewSchedule return a new Schedule entity
+func NewSchedule(sc *entity.Schedule) *Schedule {
+	schedule := &Schedule{
+		Job:     sc.Job,
+		Spec:    sc.Spec,
+		LastRun: sc.LastRun,
+		NextRun: sc.NextRun,
+	}
+
+	if len(sc.LastStatus) != 0 {
+		status := JobStatus(strings.ToUpper(sc.LastStatus))
+		schedule.LastStatus = &status
+	}
+
+	return schedule
+}
+
+// NewPageInfo return a new PageInfo entity
+func NewPageInfo(p *store.PageInfo) *PageInfo {
+	page := &PageInfo{
+		HasNextPage:     p.HasNextPage,
+		HasPreviousPage: p.HasPreviousPage,
+	}
+
+	if len(p.StartCursor) != 0 {
+		page.StartCursor = &p.StartCursor
+	}
+
+	if len(p.EndCursor) != 0 {
+		page.EndCursor = &p.EndCursor
+	}
+
+	return page
+}
+
+// NewRole return the Role of a role name
+func NewRole(name string) Role {
+	return Role(strings.ToUpper(name))
+}
+
+// Name return the name of the role in the service
+func (r Role) Name() string {
+	return strings.ToLower(string(r))
+}
+
+// Name return the name of the organization role in the service
+func (r OrganizationRole) Name() string {
+	return strings.ToLower(string(r))
+}
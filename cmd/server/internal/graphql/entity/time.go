@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalTime write a Time scalar keeping the fraction of seconds, so it can be sent back
+// as the updated value of an optimistic update
+func MarshalTime(t time.Time) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(t.Format(time.RFC3339Nano)))
+	})
+}
+
+// UnmarshalTime read a RFC3339 Time scalar
+func UnmarshalTime(v interface{}) (time.Time, error) {
+	raw, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("time should be a RFC3339 formatted string")
+	}
+
+	return time.Parse(time.RFC3339Nano, raw)
+}
@@ -154,7 +154,8 @@ func ExplorerHandle() http.HandlerFunc {
 func QueryHandler(service service.Interface) http.Handler {
 	hldr := handler.New(
 		NewExecutableSchema(Config{
-			Resolvers: NewResolver(service),
+			Resolvers:  NewResolver(service),
+			Directives: DirectiveRoot{HasRole: HasRole},
 		}),
 	)
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/mail"
 	"strconv"
+	"strings"
 
 	"boiler/cmd/server/internal/graphql/entity"
 	lentity "boiler/pkg/entity"
@@ -26,12 +27,21 @@ type Mutation struct {
 
 // AddUser add a new User to the service
 func (m *Mutation) AddUser(ctx context.Context, input entity.AddUserInput) (*entity.UserResponse, error) {
+	var emails []*lentity.Email
+	if input.Email != nil {
+		address, err := mail.ParseAddress(*input.Email)
+		if err != nil {
+			return nil, errors.ErrInvalidEmailAddress
+		}
+		emails = append(emails, &lentity.Email{Address: address.Address})
+	}
+
 	user := lentity.User{
 		Name:     input.Name,
 		Password: input.Password,
 	}
 
-	err := m.service.AddUser(ctx, &user)
+	err := m.service.AddUser(ctx, &user, emails...)
 	if err != nil {
 		return nil, fmt.Errorf("fail to add user; %w", err)
 	}
@@ -39,6 +49,172 @@ func (m *Mutation) AddUser(ctx context.Context, input entity.AddUserInput) (*ent
 	return &entity.UserResponse{User: &entity.User{ID: strconv.FormatInt(user.ID, 10)}}, nil
 }
 
+// UpdateUser update the profile of an User
+func (m *Mutation) UpdateUser(ctx context.Context, input entity.UpdateUserInput) (*entity.UserResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionUpdateUser, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var user lentity.User
+	err = m.service.GetUserByID(ctx, userID, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		user.Name = strings.TrimSpace(*input.Name)
+		if len(user.Name) == 0 {
+			return nil, errors.ErrInvalidName
+		}
+	}
+
+	user.Updated = input.Updated
+
+	err = m.service.UpdateUser(ctx, &user)
+	if err != nil {
+		return nil, fmt.Errorf("fail to update user; %w", err)
+	}
+
+	return &entity.UserResponse{User: &entity.User{ID: strconv.FormatInt(user.ID, 10)}}, nil
+}
+
+// ChangePassword replace the password of an User
+func (m *Mutation) ChangePassword(ctx context.Context, input entity.ChangePasswordInput) (*entity.UserResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionChangePassword, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.service.ChangePassword(ctx, userID, input.CurrentPassword, input.Password)
+	if err != nil {
+		return nil, fmt.Errorf("fail to change password; %w", err)
+	}
+
+	return &entity.UserResponse{User: &entity.User{ID: input.UserID}}, nil
+}
+
+// SetUserRoles replace the roles of an User
+func (m *Mutation) SetUserRoles(ctx context.Context, input entity.SetUserRolesInput) (*entity.UserResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionSetUserRoles, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(input.Roles))
+	for _, role := range input.Roles {
+		roles = append(roles, role.Name())
+	}
+
+	err = m.service.SetUserRoles(ctx, userID, roles)
+	if err != nil {
+		return nil, fmt.Errorf("fail to set user roles; %w", err)
+	}
+
+	return &entity.UserResponse{User: &entity.User{ID: input.UserID}}, nil
+}
+
+// DeleteUser enqueue the deletion of an User, the Job tells its outcome
+func (m *Mutation) DeleteUser(ctx context.Context, input entity.DeleteUserInput) (*entity.DeleteUserResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionDeleteUser, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var job lentity.Job
+	err = m.service.EnqueueDeleteUser(ctx, userID, &job)
+	if err != nil {
+		return nil, fmt.Errorf("fail to enqueue user deletion; %w", err)
+	}
+
+	return &entity.DeleteUserResponse{Job: entity.NewJob(&job)}, nil
+}
+
+// RestoreUser undelete an User and the Emails deleted with it
+func (m *Mutation) RestoreUser(ctx context.Context, input entity.RestoreUserInput) (*entity.UserResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionRestoreUser, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.service.RestoreUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to restore user; %w", err)
+	}
+
+	return &entity.UserResponse{User: &entity.User{ID: input.UserID}}, nil
+}
+
+// RestoreEmail undelete an Email
+func (m *Mutation) RestoreEmail(ctx context.Context, input entity.RestoreEmailInput) (*entity.EmailResponse, error) {
+	emailID, err := strconv.ParseInt(input.EmailID, 10, 64)
+	if err != nil || emailID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionRestoreEmail, service.Resource{EmailID: emailID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.service.RestoreEmail(ctx, emailID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to restore email; %w", err)
+	}
+
+	return &entity.EmailResponse{Email: &entity.Email{ID: input.EmailID}}, nil
+}
+
+// RequestPasswordReset email a token resetting the password
+func (m *Mutation) RequestPasswordReset(ctx context.Context, input entity.RequestPasswordResetInput) (bool, error) {
+	address, err := mail.ParseAddress(input.Email)
+	if err != nil {
+		return false, errors.ErrInvalidEmailAddress
+	}
+
+	err = m.service.RequestPasswordReset(ctx, address.Address)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ResetPassword replace a password with the token of a password reset request
+func (m *Mutation) ResetPassword(ctx context.Context, input entity.ResetPasswordInput) (bool, error) {
+	err := m.service.ResetPassword(ctx, input.Token, input.Password)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 // AddEmail add a new Email to the service
 func (m *Mutation) AddEmail(ctx context.Context, input entity.AddEmailInput) (*entity.EmailResponse, error) {
 	userID, err := strconv.ParseInt(input.UserID, 10, 64)
@@ -51,6 +227,11 @@ func (m *Mutation) AddEmail(ctx context.Context, input entity.AddEmailInput) (*e
 		return nil, errors.ErrInvalidEmailAddress
 	}
 
+	err = m.service.Authorize(ctx, service.ActionAddEmail, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
 	email := lentity.Email{
 		UserID:  userID,
 		Address: address.Address,
@@ -64,19 +245,250 @@ func (m *Mutation) AddEmail(ctx context.Context, input entity.AddEmailInput) (*e
 	return &entity.EmailResponse{Email: &entity.Email{ID: strconv.FormatInt(email.ID, 10)}}, nil
 }
 
-// AuthUser returns a JWT token
+// VerifyEmail mark an Email as verified by the token sent to it
+func (m *Mutation) VerifyEmail(ctx context.Context, input entity.VerifyEmailInput) (*entity.EmailResponse, error) {
+	var email lentity.Email
+
+	err := m.service.VerifyEmail(ctx, input.Token, &email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.EmailResponse{Email: &entity.Email{ID: strconv.FormatInt(email.ID, 10)}}, nil
+}
+
+// AuthUser returns a JWT token and a refresh token
+// the users with a TOTP first get a challenge, then authenticate with the challenge and a code
 func (m *Mutation) AuthUser(ctx context.Context, input entity.AuthUserInput) (*entity.AuthUserResponse, error) {
 
 	var user lentity.User
-	var token string
+	var tokens lentity.Tokens
 
-	err := m.service.AuthUser(ctx, input.Email, input.Password, &user, &token)
+	var err error
+	if input.Challenge != nil {
+		err = m.service.AuthTOTP(ctx, *input.Challenge, value(input.Code), &user, &tokens)
+	} else {
+		err = m.service.AuthUser(ctx, value(input.Email), value(input.Password), &user, &tokens)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("fail to authenticate user; %w", err)
 	}
 
-	return &entity.AuthUserResponse{
-		Token: token,
-		User:  &entity.User{ID: strconv.FormatInt(user.ID, 10)},
-	}, nil
+	return authResponse(&user, &tokens), nil
+}
+
+// RefreshToken exchange a refresh token for new tokens
+func (m *Mutation) RefreshToken(ctx context.Context, input entity.RefreshTokenInput) (*entity.AuthUserResponse, error) {
+
+	var user lentity.User
+	var tokens lentity.Tokens
+
+	err := m.service.RefreshToken(ctx, input.RefreshToken, &user, &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return authResponse(&user, &tokens), nil
+}
+
+// EnrollTotp generate the TOTP secret of an User
+func (m *Mutation) EnrollTotp(ctx context.Context, input entity.EnrollTOTPInput) (*entity.TOTPEnrollment, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionEnrollTOTP, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var enrollment lentity.TOTPEnrollment
+	err = m.service.EnrollTOTP(ctx, userID, &enrollment)
+	if err != nil {
+		return nil, fmt.Errorf("fail to enroll totp; %w", err)
+	}
+
+	return &entity.TOTPEnrollment{Secret: enrollment.Secret, URI: enrollment.URI}, nil
+}
+
+// ConfirmTotp enable the TOTP of an User and returns its recovery codes
+func (m *Mutation) ConfirmTotp(ctx context.Context, input entity.ConfirmTOTPInput) (*entity.ConfirmTOTPResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionEnrollTOTP, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = m.service.ConfirmTOTP(ctx, userID, input.Code, &codes)
+	if err != nil {
+		return nil, fmt.Errorf("fail to confirm totp; %w", err)
+	}
+
+	return &entity.ConfirmTOTPResponse{RecoveryCodes: codes}, nil
+}
+
+// AddAPIKey create an API key of an User, the key is only returned here
+func (m *Mutation) AddAPIKey(ctx context.Context, input entity.AddAPIKeyInput) (*entity.AddAPIKeyResponse, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionAddAPIKey, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	key := lentity.APIKey{
+		UserID:  userID,
+		Name:    input.Name,
+		Scopes:  input.Scopes,
+		Expires: input.Expires,
+	}
+
+	var raw string
+	err = m.service.AddAPIKey(ctx, &key, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("fail to add api key; %w", err)
+	}
+
+	return &entity.AddAPIKeyResponse{APIKey: entity.NewAPIKey(&key), Key: raw}, nil
+}
+
+// RevokeAPIKey revoke an API key of an User
+func (m *Mutation) RevokeAPIKey(ctx context.Context, input entity.RevokeAPIKeyInput) (bool, error) {
+	userID, err := strconv.ParseInt(input.UserID, 10, 64)
+	if err != nil || userID == 0 {
+		return false, errors.ErrInvalidID
+	}
+
+	keyID, err := strconv.ParseInt(input.APIKeyID, 10, 64)
+	if err != nil || keyID == 0 {
+		return false, errors.ErrInvalidID
+	}
+
+	err = m.service.Authorize(ctx, service.ActionRevokeAPIKey, service.Resource{UserID: userID})
+	if err != nil {
+		return false, err
+	}
+
+	err = m.service.RevokeAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return false, fmt.Errorf("fail to revoke api key; %w", err)
+	}
+
+	return true, nil
+}
+
+// AddOrganization add an Organization owned by the authenticated User
+func (m *Mutation) AddOrganization(ctx context.Context, input entity.AddOrganizationInput) (*entity.OrganizationResponse, error) {
+	viewer, ok := service.Viewer(ctx)
+	if !ok {
+		return nil, errors.ErrUnauthorized
+	}
+
+	err := m.service.Authorize(ctx, service.ActionAddOrganization, service.Resource{UserID: viewer.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	org := lentity.Organization{Name: input.Name}
+	err = m.service.AddOrganization(ctx, &org, viewer.ID)
+	if err != nil {
+		return nil, fmt.Errorf("fail to add organization; %w", err)
+	}
+
+	return &entity.OrganizationResponse{Organization: entity.NewOrganization(&org)}, nil
+}
+
+// InviteMember email an invitation to join an Organization
+func (m *Mutation) InviteMember(ctx context.Context, input entity.InviteMemberInput) (bool, error) {
+	orgID, err := strconv.ParseInt(input.OrganizationID, 10, 64)
+	if err != nil || orgID == 0 {
+		return false, errors.ErrInvalidID
+	}
+
+	address, err := mail.ParseAddress(input.Email)
+	if err != nil {
+		return false, errors.ErrInvalidEmailAddress
+	}
+
+	err = m.service.Authorize(ctx, service.ActionInviteMember, service.Resource{OrganizationID: orgID})
+	if err != nil {
+		return false, err
+	}
+
+	err = m.service.InviteMember(ctx, orgID, address.Address, input.Role.Name())
+	if err != nil {
+		return false, fmt.Errorf("fail to invite member; %w", err)
+	}
+
+	return true, nil
+}
+
+// AcceptInvitation add the authenticated User to the Organization of an invitation
+func (m *Mutation) AcceptInvitation(ctx context.Context, input entity.AcceptInvitationInput) (*entity.MemberResponse, error) {
+	var member lentity.Member
+	err := m.service.AcceptInvitation(ctx, input.Token, &member)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.MemberResponse{Member: entity.NewMember(&member)}, nil
+}
+
+// SwitchOrganization exchange a refresh token for tokens of another Organization
+func (m *Mutation) SwitchOrganization(ctx context.Context, input entity.SwitchOrganizationInput) (*entity.AuthUserResponse, error) {
+	orgID, err := strconv.ParseInt(input.OrganizationID, 10, 64)
+	if err != nil || orgID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	var user lentity.User
+	var tokens lentity.Tokens
+
+	err = m.service.SwitchOrganization(ctx, input.RefreshToken, orgID, &user, &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return authResponse(&user, &tokens), nil
+}
+
+// authResponse return the tokens of user, or its challenge
+func authResponse(user *lentity.User, tokens *lentity.Tokens) *entity.AuthUserResponse {
+	r := &entity.AuthUserResponse{User: &entity.User{ID: strconv.FormatInt(user.ID, 10)}}
+	if len(tokens.Challenge) != 0 {
+		r.Challenge = &tokens.Challenge
+		return r
+	}
+
+	r.Token = &tokens.Access
+	r.RefreshToken = &tokens.Refresh
+	return r
+}
+
+// value return the value of an optional input, empty if it is not set
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+// Logout revoke a refresh token and the access token of the request
+func (m *Mutation) Logout(ctx context.Context, input entity.LogoutInput) (bool, error) {
+	err := m.service.Logout(ctx, input.RefreshToken)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
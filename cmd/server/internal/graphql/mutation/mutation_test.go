@@ -2,13 +2,17 @@ package mutation
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	"boiler/cmd/server/internal/graphql/entity"
 	lentity "boiler/pkg/entity"
 	"boiler/pkg/errors"
+	lservice "boiler/pkg/service"
 	"boiler/pkg/service/mock"
+	"boiler/pkg/store/config"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -62,6 +66,396 @@ func TestAddUser(t *testing.T) {
 	}
 }
 
+func TestUpdateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+	updated := time.Now()
+	name := " Jane "
+
+	// succeed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionUpdateUser, lservice.Resource{UserID: 1}).Return(nil)
+		service.EXPECT().GetUserByID(ctx, int64(1), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, u *lentity.User) error {
+				*u = lentity.User{ID: 1, Name: "John"}
+				return nil
+			})
+		service.EXPECT().UpdateUser(ctx, &lentity.User{ID: 1, Name: "Jane", Updated: updated}).Return(nil)
+
+		r, err := m.UpdateUser(ctx, entity.UpdateUserInput{UserID: "1", Name: &name, Updated: updated})
+		assert.Nil(t, err)
+		assert.Equal(t, "1", r.User.ID)
+	}
+
+	// fails if name is empty
+	{
+		empty := " "
+		service.EXPECT().Authorize(ctx, lservice.ActionUpdateUser, lservice.Resource{UserID: 1}).Return(nil)
+		service.EXPECT().GetUserByID(ctx, int64(1), gomock.Any()).Return(nil)
+
+		r, err := m.UpdateUser(ctx, entity.UpdateUserInput{UserID: "1", Name: &empty, Updated: updated})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidName, err)
+	}
+
+	// fails if modified since it was read
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionUpdateUser, lservice.Resource{UserID: 1}).Return(nil)
+		service.EXPECT().GetUserByID(ctx, int64(1), gomock.Any()).Return(nil)
+		service.EXPECT().UpdateUser(ctx, gomock.Any()).Return(errors.ErrUpdateConflict)
+
+		r, err := m.UpdateUser(ctx, entity.UpdateUserInput{UserID: "1", Updated: updated})
+		assert.Nil(t, r)
+		assert.True(t, errors.Is(err, errors.ErrUpdateConflict))
+	}
+
+	// fails if not allowed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionUpdateUser, lservice.Resource{UserID: 2}).Return(errors.ErrForbidden)
+
+		r, err := m.UpdateUser(ctx, entity.UpdateUserInput{UserID: "2", Updated: updated})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if invalid ID
+	{
+		r, err := m.UpdateUser(ctx, entity.UpdateUserInput{UserID: "a"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionChangePassword, lservice.Resource{UserID: 1}).Return(nil)
+		service.EXPECT().ChangePassword(ctx, int64(1), "old", "new").Return(nil)
+
+		r, err := m.ChangePassword(ctx, entity.ChangePasswordInput{UserID: "1", CurrentPassword: "old", Password: "new"})
+		assert.Nil(t, err)
+		assert.Equal(t, "1", r.User.ID)
+	}
+
+	// fails if service fails
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionChangePassword, lservice.Resource{UserID: 1}).Return(nil)
+		service.EXPECT().ChangePassword(ctx, int64(1), "bad", "new").Return(errors.ErrInvalidPassword)
+
+		r, err := m.ChangePassword(ctx, entity.ChangePasswordInput{UserID: "1", CurrentPassword: "bad", Password: "new"})
+		assert.Nil(t, r)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPassword))
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionSetUserRoles, lservice.Resource{UserID: 1}).Return(nil)
+		service.EXPECT().SetUserRoles(ctx, int64(1), []string{"admin", "member"}).Return(nil)
+
+		r, err := m.SetUserRoles(ctx, entity.SetUserRolesInput{
+			UserID: "1",
+			Roles:  []entity.Role{entity.RoleAdmin, entity.RoleMember},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "1", r.User.ID)
+	}
+
+	// fails if not allowed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionSetUserRoles, lservice.Resource{UserID: 1}).Return(errors.ErrForbidden)
+
+		r, err := m.SetUserRoles(ctx, entity.SetUserRolesInput{UserID: "1"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if invalid ID
+	{
+		r, err := m.SetUserRoles(ctx, entity.SetUserRolesInput{UserID: "a"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed
+	{
+		service.EXPECT().RefreshToken(ctx, "refresh", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, u *lentity.User, t *lentity.Tokens) error {
+				u.ID = 4
+				*t = lentity.Tokens{Access: "access", Refresh: "refresh2"}
+				return nil
+			})
+
+		r, err := m.RefreshToken(ctx, entity.RefreshTokenInput{RefreshToken: "refresh"})
+		assert.Nil(t, err)
+		assert.Equal(t, "4", r.User.ID)
+		assert.Equal(t, "access", *r.Token)
+		assert.Equal(t, "refresh2", *r.RefreshToken)
+		assert.Nil(t, r.Challenge)
+	}
+
+	// fails if service fails
+	{
+		service.EXPECT().RefreshToken(ctx, "refresh", gomock.Any(), gomock.Any()).Return(errors.ErrInvalidRefreshToken)
+
+		r, err := m.RefreshToken(ctx, entity.RefreshTokenInput{RefreshToken: "refresh"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidRefreshToken, err)
+	}
+}
+
+func TestAuthUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+	str := func(s string) *string { return &s }
+
+	// succeed with a challenge if the user has a TOTP
+	{
+		service.EXPECT().AuthUser(ctx, "a@b.c", "pass", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ string, u *lentity.User, t *lentity.Tokens) error {
+				u.ID = 4
+				*t = lentity.Tokens{Challenge: "challenge"}
+				return nil
+			})
+
+		r, err := m.AuthUser(ctx, entity.AuthUserInput{Email: str("a@b.c"), Password: str("pass")})
+		assert.Nil(t, err)
+		assert.Equal(t, "4", r.User.ID)
+		assert.Equal(t, "challenge", *r.Challenge)
+		assert.Nil(t, r.Token)
+		assert.Nil(t, r.RefreshToken)
+	}
+
+	// succeed with the challenge and a code
+	{
+		service.EXPECT().AuthTOTP(ctx, "challenge", "123456", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ string, u *lentity.User, t *lentity.Tokens) error {
+				u.ID = 4
+				*t = lentity.Tokens{Access: "access", Refresh: "refresh"}
+				return nil
+			})
+
+		r, err := m.AuthUser(ctx, entity.AuthUserInput{Challenge: str("challenge"), Code: str("123456")})
+		assert.Nil(t, err)
+		assert.Equal(t, "access", *r.Token)
+		assert.Equal(t, "refresh", *r.RefreshToken)
+		assert.Nil(t, r.Challenge)
+	}
+
+	// fails if service fails
+	{
+		service.EXPECT().AuthTOTP(ctx, "challenge", "", gomock.Any(), gomock.Any()).Return(errors.ErrInvalidTOTPCode)
+
+		r, err := m.AuthUser(ctx, entity.AuthUserInput{Challenge: str("challenge")})
+		assert.Nil(t, r)
+		assert.True(t, errors.Is(err, errors.ErrInvalidTOTPCode))
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed to enroll
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionEnrollTOTP, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().EnrollTOTP(ctx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, e *lentity.TOTPEnrollment) error {
+				*e = lentity.TOTPEnrollment{Secret: "S", URI: "otpauth://totp/boiler:jo?secret=S"}
+				return nil
+			})
+
+		r, err := m.EnrollTotp(ctx, entity.EnrollTOTPInput{UserID: "4"})
+		assert.Nil(t, err)
+		assert.Equal(t, &entity.TOTPEnrollment{Secret: "S", URI: "otpauth://totp/boiler:jo?secret=S"}, r)
+	}
+
+	// succeed to confirm
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionEnrollTOTP, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().ConfirmTOTP(ctx, int64(4), "123456", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, _ string, codes *[]string) error {
+				*codes = []string{"aaaa-bbbb"}
+				return nil
+			})
+
+		r, err := m.ConfirmTotp(ctx, entity.ConfirmTOTPInput{UserID: "4", Code: "123456"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"aaaa-bbbb"}, r.RecoveryCodes)
+	}
+
+	// fails if not authorized
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionEnrollTOTP, lservice.Resource{UserID: 5}).Return(errors.ErrForbidden)
+
+		r, err := m.EnrollTotp(ctx, entity.EnrollTOTPInput{UserID: "5"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails with an invalid ID
+	{
+		r, err := m.ConfirmTotp(ctx, entity.ConfirmTOTPInput{UserID: "x", Code: "123456"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed
+	{
+		service.EXPECT().Logout(ctx, "refresh").Return(nil)
+
+		ok, err := m.Logout(ctx, entity.LogoutInput{RefreshToken: "refresh"})
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	// fails if service fails
+	{
+		service.EXPECT().Logout(ctx, "refresh").Return(errors.ErrForbidden)
+
+		ok, err := m.Logout(ctx, entity.LogoutInput{RefreshToken: "refresh"})
+		assert.False(t, ok)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed to request a reset
+	{
+		service.EXPECT().RequestPasswordReset(ctx, "a@b.c").Return(nil)
+
+		ok, err := m.RequestPasswordReset(ctx, entity.RequestPasswordResetInput{Email: "a@b.c"})
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	// fails to request a reset with an invalid address
+	{
+		ok, err := m.RequestPasswordReset(ctx, entity.RequestPasswordResetInput{Email: "nope"})
+		assert.False(t, ok)
+		assert.Equal(t, errors.ErrInvalidEmailAddress, err)
+	}
+
+	// succeed to reset the password
+	{
+		service.EXPECT().ResetPassword(ctx, "tok", "new").Return(nil)
+
+		ok, err := m.ResetPassword(ctx, entity.ResetPasswordInput{Token: "tok", Password: "new"})
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	// fails if service fails
+	{
+		service.EXPECT().ResetPassword(ctx, "tok", "new").Return(errors.ErrInvalidResetToken)
+
+		ok, err := m.ResetPassword(ctx, entity.ResetPasswordInput{Token: "tok", Password: "new"})
+		assert.False(t, ok)
+		assert.Equal(t, errors.ErrInvalidResetToken, err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed
+	{
+		service.EXPECT().VerifyEmail(ctx, "tok", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, e *lentity.Email) error {
+				e.ID = 3
+				return nil
+			})
+
+		resp, err := m.VerifyEmail(ctx, entity.VerifyEmailInput{Token: "tok"})
+		assert.Nil(t, err)
+		assert.Equal(t, "3", resp.Email.ID)
+	}
+
+	// fails if service fails
+	{
+		service.EXPECT().VerifyEmail(ctx, "tok", gomock.Any()).Return(errors.ErrInvalidVerificationToken)
+
+		resp, err := m.VerifyEmail(ctx, entity.VerifyEmailInput{Token: "tok"})
+		assert.Nil(t, resp)
+		assert.Equal(t, errors.ErrInvalidVerificationToken, err)
+	}
+}
+
 func TestAddEmail(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -77,6 +471,7 @@ func TestAddEmail(t *testing.T) {
 		address := "email@email.com"
 		userID := int64(12)
 
+		service.EXPECT().Authorize(ctx, lservice.ActionAddEmail, lservice.Resource{UserID: userID}).Return(nil)
 		service.EXPECT().AddEmail(ctx, gomock.Any()).
 			DoAndReturn(func(_ context.Context, e *lentity.Email) error {
 				e.ID = 1
@@ -122,6 +517,7 @@ func TestAddEmail(t *testing.T) {
 		address := "email@email.com"
 		userID := int64(12)
 
+		service.EXPECT().Authorize(ctx, lservice.ActionAddEmail, lservice.Resource{UserID: userID}).Return(nil)
 		service.EXPECT().AddEmail(ctx, gomock.Any()).Return(errors.ErrAlreadyExists)
 
 		u, err := m.AddEmail(ctx, entity.AddEmailInput{
@@ -138,6 +534,7 @@ func TestAddEmail(t *testing.T) {
 		userID := int64(12)
 
 		errOpz := errors.New("opz")
+		service.EXPECT().Authorize(ctx, lservice.ActionAddEmail, lservice.Resource{UserID: userID}).Return(nil)
 		service.EXPECT().AddEmail(ctx, gomock.Any()).Return(errOpz)
 
 		u, err := m.AddEmail(ctx, entity.AddEmailInput{
@@ -148,3 +545,282 @@ func TestAddEmail(t *testing.T) {
 		assert.Nil(t, u)
 	}
 }
+
+func TestAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed to add
+	{
+		expires := time.Now().Add(time.Hour)
+		service.EXPECT().Authorize(ctx, lservice.ActionAddAPIKey, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().AddAPIKey(ctx, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, key *lentity.APIKey, raw *string) error {
+				assert.Equal(t, lentity.APIKey{UserID: 4, Name: "ci", Scopes: []string{lentity.ScopeUsersRead},
+					Expires: &expires}, *key)
+				key.ID = 7
+				key.Prefix = "bk_0a1b2c3d"
+				*raw = "bk_0a1b2c3d_secret"
+				return nil
+			})
+
+		r, err := m.AddAPIKey(ctx, entity.AddAPIKeyInput{UserID: "4", Name: "ci",
+			Scopes: []string{lentity.ScopeUsersRead}, Expires: &expires})
+		assert.Nil(t, err)
+		assert.Equal(t, "7", r.APIKey.ID)
+		assert.Equal(t, "bk_0a1b2c3d", r.APIKey.Prefix)
+		assert.Equal(t, "bk_0a1b2c3d_secret", r.Key)
+	}
+
+	// succeed to revoke
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionRevokeAPIKey, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().RevokeAPIKey(ctx, int64(4), int64(7)).Return(nil)
+
+		ok, err := m.RevokeAPIKey(ctx, entity.RevokeAPIKeyInput{UserID: "4", APIKeyID: "7"})
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	// fails if the api key lacks the scope
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionAddAPIKey, lservice.Resource{UserID: 4}).Return(errors.ErrMissingScope)
+
+		r, err := m.AddAPIKey(ctx, entity.AddAPIKeyInput{UserID: "4", Name: "ci", Scopes: []string{lentity.ScopeUsersRead}})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrMissingScope, err)
+	}
+
+	// fails with an invalid ID
+	{
+		ok, err := m.RevokeAPIKey(ctx, entity.RevokeAPIKeyInput{UserID: "4", APIKeyID: "x"})
+		assert.False(t, ok)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionDeleteUser, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().EnqueueDeleteUser(ctx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, job *lentity.Job) error {
+				*job = lentity.Job{ID: "j1", Name: lservice.DeleteUser, Status: lentity.JobQueued}
+				return nil
+			})
+
+		r, err := m.DeleteUser(ctx, entity.DeleteUserInput{UserID: "4"})
+		assert.Nil(t, err)
+		assert.Equal(t, "j1", r.Job.ID)
+		assert.Equal(t, entity.JobStatusQueued, r.Job.Status)
+	}
+
+	// fails if not allowed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionDeleteUser, lservice.Resource{UserID: 4}).Return(errors.ErrForbidden)
+
+		r, err := m.DeleteUser(ctx, entity.DeleteUserInput{UserID: "4"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if the deletion can not be enqueued
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionDeleteUser, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().EnqueueDeleteUser(ctx, int64(4), gomock.Any()).Return(fmt.Errorf("opz"))
+
+		r, err := m.DeleteUser(ctx, entity.DeleteUserInput{UserID: "4"})
+		assert.Nil(t, r)
+		assert.Equal(t, "fail to enqueue user deletion; opz", err.Error())
+	}
+
+	// fails with an invalid ID
+	{
+		r, err := m.DeleteUser(ctx, entity.DeleteUserInput{UserID: "x"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.TODO()
+
+	// succeed to restore an user
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionRestoreUser, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().RestoreUser(ctx, int64(4)).Return(nil)
+
+		r, err := m.RestoreUser(ctx, entity.RestoreUserInput{UserID: "4"})
+		assert.Nil(t, err)
+		assert.Equal(t, "4", r.User.ID)
+	}
+
+	// succeed to restore an email
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionRestoreEmail, lservice.Resource{EmailID: 6}).Return(nil)
+		service.EXPECT().RestoreEmail(ctx, int64(6)).Return(nil)
+
+		r, err := m.RestoreEmail(ctx, entity.RestoreEmailInput{EmailID: "6"})
+		assert.Nil(t, err)
+		assert.Equal(t, "6", r.Email.ID)
+	}
+
+	// fails if not allowed
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionRestoreUser, lservice.Resource{UserID: 4}).Return(errors.ErrForbidden)
+
+		r, err := m.RestoreUser(ctx, entity.RestoreUserInput{UserID: "4"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if the address was added again
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionRestoreEmail, lservice.Resource{EmailID: 6}).Return(nil)
+		service.EXPECT().RestoreEmail(ctx, int64(6)).Return(errors.ErrAlreadyExists)
+
+		r, err := m.RestoreEmail(ctx, entity.RestoreEmailInput{EmailID: "6"})
+		assert.Nil(t, r)
+		assert.True(t, errors.Is(err, errors.ErrAlreadyExists))
+	}
+
+	// fails with an invalid ID
+	{
+		r, err := m.RestoreEmail(ctx, entity.RestoreEmailInput{EmailID: "x"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
+
+func TestOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := mock.NewMockInterface(ctrl)
+
+	m := NewMutation(service)
+
+	ctx := context.WithValue(context.TODO(), config.ContextKeyAuthenticationUser{}, &lentity.JWTUser{ID: 4})
+
+	// succeed to add
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionAddOrganization, lservice.Resource{UserID: 4}).Return(nil)
+		service.EXPECT().AddOrganization(ctx, &lentity.Organization{Name: "acme"}, int64(4)).
+			DoAndReturn(func(_ context.Context, org *lentity.Organization, _ int64) error {
+				org.ID = 3
+				return nil
+			})
+
+		r, err := m.AddOrganization(ctx, entity.AddOrganizationInput{Name: "acme"})
+		assert.Nil(t, err)
+		assert.Equal(t, "3", r.Organization.ID)
+		assert.Equal(t, "acme", r.Organization.Name)
+	}
+
+	// succeed to invite
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionInviteMember, lservice.Resource{OrganizationID: 3}).Return(nil)
+		service.EXPECT().InviteMember(ctx, int64(3), "a@b.c", lentity.OrgRoleAdmin).Return(nil)
+
+		ok, err := m.InviteMember(ctx, entity.InviteMemberInput{OrganizationID: "3", Email: "a@b.c",
+			Role: entity.OrganizationRoleAdmin})
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	// succeed to invite the normalized address
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionInviteMember, lservice.Resource{OrganizationID: 3}).Return(nil)
+		service.EXPECT().InviteMember(ctx, int64(3), "a@b.c", lentity.OrgRoleAdmin).Return(nil)
+
+		ok, err := m.InviteMember(ctx, entity.InviteMemberInput{OrganizationID: "3", Email: "A <a@b.c>",
+			Role: entity.OrganizationRoleAdmin})
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+
+	// succeed to accept
+	{
+		service.EXPECT().AcceptInvitation(ctx, "token", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, member *lentity.Member) error {
+				*member = lentity.Member{OrganizationID: 3, UserID: 4, Role: lentity.OrgRoleAdmin}
+				return nil
+			})
+
+		r, err := m.AcceptInvitation(ctx, entity.AcceptInvitationInput{Token: "token"})
+		assert.Nil(t, err)
+		assert.Equal(t, "4", r.Member.User.ID)
+		assert.Equal(t, entity.OrganizationRoleAdmin, r.Member.Role)
+	}
+
+	// succeed to switch
+	{
+		service.EXPECT().SwitchOrganization(ctx, "raw", int64(3), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _ int64, user *lentity.User, tokens *lentity.Tokens) error {
+				*user = lentity.User{ID: 4}
+				*tokens = lentity.Tokens{Access: "access", Refresh: "refresh"}
+				return nil
+			})
+
+		r, err := m.SwitchOrganization(ctx, entity.SwitchOrganizationInput{RefreshToken: "raw", OrganizationID: "3"})
+		assert.Nil(t, err)
+		assert.Equal(t, "access", *r.Token)
+		assert.Equal(t, "refresh", *r.RefreshToken)
+	}
+
+	// fails to add if anonymous
+	{
+		r, err := m.AddOrganization(context.TODO(), entity.AddOrganizationInput{Name: "acme"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrUnauthorized, err)
+	}
+
+	// fails to invite if not an admin of the organization
+	{
+		service.EXPECT().Authorize(ctx, lservice.ActionInviteMember, lservice.Resource{OrganizationID: 3}).Return(errors.ErrForbidden)
+
+		ok, err := m.InviteMember(ctx, entity.InviteMemberInput{OrganizationID: "3", Email: "a@b.c",
+			Role: entity.OrganizationRoleMember})
+		assert.False(t, ok)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails to invite an invalid address
+	{
+		ok, err := m.InviteMember(ctx, entity.InviteMemberInput{OrganizationID: "3", Email: "a@b.c\r\nBcc: d@e.f",
+			Role: entity.OrganizationRoleMember})
+		assert.False(t, ok)
+		assert.Equal(t, errors.ErrInvalidEmailAddress, err)
+	}
+
+	// fails to switch if not a member
+	{
+		service.EXPECT().SwitchOrganization(ctx, "raw", int64(6), gomock.Any(), gomock.Any()).Return(errors.ErrNotMember)
+
+		r, err := m.SwitchOrganization(ctx, entity.SwitchOrganizationInput{RefreshToken: "raw", OrganizationID: "6"})
+		assert.Nil(t, r)
+		assert.Equal(t, errors.ErrNotMember, err)
+	}
+}
@@ -12,20 +12,23 @@ import (
 )
 
 // NewQuery return a new QueryResolver
-func NewQuery(ru *resolver.User) QueryResolver {
+func NewQuery(ru *resolver.User, rj *resolver.Job) QueryResolver {
 	return &Query{
 		ru: ru,
+		rj: rj,
 	}
 }
 
 // Query is a Query User struct
 type Query struct {
 	ru *resolver.User
+	rj *resolver.Job
 }
 
-// Users return users
-func (r *Query) Users(ctx context.Context, limit *int) ([]*entity.User, error) {
-	return r.ru.Users(ctx, uint(*limit))
+// Users return a page of users
+func (r *Query) Users(ctx context.Context, first *int, after *string, last *int, before *string,
+	sortBy *entity.SortBy, includeDeleted *bool) (*entity.UserConnection, error) {
+	return r.ru.Users(ctx, first, after, last, before, sortBy, includeDeleted)
 }
 
 // User return an user
@@ -33,6 +36,16 @@ func (r *Query) User(ctx context.Context, userID string) (*entity.User, error) {
 	return r.ru.User(ctx, userID)
 }
 
+// Job return a job of the worker
+func (r *Query) Job(ctx context.Context, jobID string) (*entity.Job, error) {
+	return r.rj.Job(ctx, jobID)
+}
+
+// Schedules return the schedules of the worker
+func (r *Query) Schedules(ctx context.Context) ([]*entity.Schedule, error) {
+	return r.rj.Schedules(ctx)
+}
+
 func (r *Query) Viewer(ctx context.Context) (*entity.User, error) {
 
 	raw := ctx.Value(config.ContextKeyAuthenticationUser{})
@@ -19,7 +19,7 @@ type Resolver struct {
 
 // Query return a new QueryResolver
 func (r *Resolver) Query() QueryResolver {
-	return NewQuery(resolver.NewUser(r.service))
+	return NewQuery(resolver.NewUser(r.service), resolver.NewJob(r.service))
 }
 
 // Mutation return a new MutationResolver
@@ -42,6 +42,16 @@ func (r *Resolver) AuthUserResponse() AuthUserResponseResolver {
 	return resolver.NewAuthUserResponse(r.service)
 }
 
+// Organization return a new OrganizationResolver
+func (r *Resolver) Organization() OrganizationResolver {
+	return resolver.NewOrganization(r.service)
+}
+
+// Member return a new MemberResolver
+func (r *Resolver) Member() MemberResolver {
+	return resolver.NewOrganization(r.service)
+}
+
 // Email return a new EmailResolver
 func (r *Resolver) Email() EmailResolver {
 	return resolver.NewEmail(r.service)
@@ -23,7 +23,7 @@ type Email struct {
 	service service.Interface
 }
 
-// User resolve User by Email
+// User resolve User by Email, the email was authorized by the field returning it
 func (r *Email) User(ctx context.Context, e *entity.Email) (*entity.User, error) {
 
 	var u lentity.User
@@ -42,8 +42,18 @@ func (r *Email) Email(ctx context.Context, rawEmailID string) (*entity.Email, er
 		return nil, errors.ErrInvalidID
 	}
 
+	err = r.service.Authorize(ctx, service.ActionReadEmail, service.Resource{EmailID: emailID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	return r.email(ctx, emailID)
+}
+
+// email return an Email by ID without authorization, the caller already authorized it
+func (r *Email) email(ctx context.Context, emailID int64) (*entity.Email, error) {
 	emails := make([]lentity.Email, 0)
-	err = r.service.FilterEmails(ctx, store.FilterEmails{EmailID: emailID}, &emails)
+	err := r.service.FilterEmails(ctx, store.FilterEmails{EmailID: emailID}, &emails, nil)
 	if err != nil {
 		return nil, Wrap(ctx, err, "fail to filter emails")
 	}
@@ -10,6 +10,7 @@ import (
 	"boiler/cmd/server/internal/graphql/resolver"
 	"boiler/pkg/entity"
 	"boiler/pkg/errors"
+	"boiler/pkg/service"
 	"boiler/pkg/service/mock"
 	"boiler/pkg/store"
 
@@ -70,11 +71,12 @@ func TestEmailEmail(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewEmail(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadEmail, service.Resource{EmailID: 5}).Return(nil)
 		m.EXPECT().
 			FilterEmails(gomock.Any(), store.FilterEmails{
 				EmailID: 5,
-			}, gomock.Any()).
-			DoAndReturn(func(ctx context.Context, filter store.FilterEmails, emails *[]entity.Email) error {
+			}, gomock.Any(), nil).
+			DoAndReturn(func(ctx context.Context, filter store.FilterEmails, emails *[]entity.Email, _ *store.PageInfo) error {
 				*emails = append(*emails, entity.Email{ID: 5, Address: "a@b.c"})
 				return nil
 			})
@@ -102,8 +104,9 @@ func TestEmailEmail(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewEmail(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadEmail, service.Resource{EmailID: 500}).Return(nil)
 		m.EXPECT().
-			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 500}, gomock.Any()).
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 500}, gomock.Any(), nil).
 			Return(errors.New("err"))
 
 		email, err := r.Email(ctxDebug, "500")
@@ -117,8 +120,9 @@ func TestEmailEmail(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewEmail(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadEmail, service.Resource{EmailID: 404}).Return(nil)
 		m.EXPECT().
-			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 404}, gomock.Any()).
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 404}, gomock.Any(), nil).
 			Return(nil)
 
 		email, err := r.Email(ctxDebug, "404")
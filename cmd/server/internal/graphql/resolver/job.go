@@ -0,0 +1,75 @@
+package resolver
+
+import (
+	"context"
+
+	"boiler/cmd/server/internal/graphql/entity"
+	lentity "boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+)
+
+// NewJob return a new Job resolver
+func NewJob(service service.Interface) *Job {
+	return &Job{
+		service: service,
+	}
+}
+
+// Job resolver for Job
+type Job struct {
+	service service.Interface
+}
+
+// Job resolve Job by jobID, a job is read by the user who enqueued it
+// it is authorized before the lookup and a job of another user it can not read is not found
+func (r *Job) Job(ctx context.Context, jobID string) (*entity.Job, error) {
+	viewer, ok := service.Viewer(ctx)
+	if !ok {
+		return nil, Wrap(ctx, errors.ErrUnauthorized, "fail to authorize")
+	}
+
+	err := r.service.Authorize(ctx, service.ActionReadJob, service.Resource{UserID: viewer.ID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	var job lentity.Job
+	err = r.service.GetJob(ctx, jobID, &job)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to get job")
+	}
+
+	if job.UserID != viewer.ID {
+		err = r.service.Authorize(ctx, service.ActionReadJob, service.Resource{UserID: job.UserID})
+		if errors.Is(err, errors.ErrForbidden) {
+			err = errors.ErrNotFound
+		}
+		if err != nil {
+			return nil, Wrap(ctx, err, "fail to get job")
+		}
+	}
+
+	return entity.NewJob(&job), nil
+}
+
+// Schedules resolve the schedules of the worker
+func (r *Job) Schedules(ctx context.Context) ([]*entity.Schedule, error) {
+	err := r.service.Authorize(ctx, service.ActionListSchedules, service.Resource{})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	var schedules []lentity.Schedule
+	err = r.service.FilterSchedules(ctx, &schedules)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to filter schedules")
+	}
+
+	resp := make([]*entity.Schedule, 0, len(schedules))
+	for i := range schedules {
+		resp = append(resp, entity.NewSchedule(&schedules[i]))
+	}
+
+	return resp, nil
+}
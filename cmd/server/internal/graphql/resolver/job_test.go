@@ -0,0 +1,135 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gentity "boiler/cmd/server/internal/graphql/entity"
+	"boiler/cmd/server/internal/graphql/resolver"
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	"boiler/pkg/service/mock"
+	"boiler/pkg/store/config"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	as := func(userID int64) context.Context {
+		return context.WithValue(ctxDebug, config.ContextKeyAuthenticationUser{}, &entity.JWTUser{ID: userID})
+	}
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewJob(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, job *entity.Job) error {
+				*job = entity.Job{ID: "j1", Name: "delete_user", UserID: 4, Status: entity.JobFailed, Error: "opz"}
+				return nil
+			})
+
+		job, err := r.Job(as(4), "j1")
+		assert.Nil(t, err)
+		assert.Equal(t, "j1", job.ID)
+		assert.Equal(t, gentity.JobStatusFailed, job.Status)
+		if assert.NotNil(t, job.Error) {
+			assert.Equal(t, "opz", *job.Error)
+		}
+	}
+
+	// fails if anonymous without looking the job up
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewJob(m)
+
+		job, err := r.Job(ctxDebug, "j1")
+		assert.Nil(t, job)
+		assert.Equal(t, errors.ErrUnauthorized, err)
+	}
+
+	// fails if the job of another user is not allowed, like a job not found
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewJob(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 5}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, job *entity.Job) error {
+				*job = entity.Job{ID: "j1", UserID: 4}
+				return nil
+			})
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 4}).
+			Return(errors.ErrForbidden)
+
+		job, err := r.Job(as(5), "j1")
+		assert.Nil(t, job)
+		assert.Equal(t, errors.ErrNotFound, err)
+	}
+
+	// fails if not found
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewJob(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 5}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j2", gomock.Any()).Return(errors.ErrNotFound)
+
+		job, err := r.Job(as(5), "j2")
+		assert.Nil(t, job)
+		assert.Equal(t, errors.ErrNotFound, err)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewJob(m)
+
+		lastRun := time.Now()
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListSchedules, service.Resource{}).Return(nil)
+		m.EXPECT().FilterSchedules(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, schedules *[]entity.Schedule) error {
+				*schedules = []entity.Schedule{
+					{Job: "purge_tokens", Spec: "0 0 * * * *", LastRun: &lastRun, LastStatus: entity.JobSucceeded},
+					{Job: "purge_deleted", Spec: "0 0 3 * * *"},
+				}
+				return nil
+			})
+
+		schedules, err := r.Schedules(ctxDebug)
+		assert.Nil(t, err)
+		if assert.Len(t, schedules, 2) {
+			assert.Equal(t, "purge_tokens", schedules[0].Job)
+			if assert.NotNil(t, schedules[0].LastStatus) {
+				assert.Equal(t, gentity.JobStatusSucceeded, *schedules[0].LastStatus)
+			}
+			assert.Nil(t, schedules[1].LastRun)
+			assert.Nil(t, schedules[1].LastStatus)
+		}
+	}
+
+	// fails if not allowed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewJob(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListSchedules, service.Resource{}).Return(errors.ErrForbidden)
+
+		schedules, err := r.Schedules(ctxDebug)
+		assert.Nil(t, schedules)
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+}
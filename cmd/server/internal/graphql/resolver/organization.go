@@ -0,0 +1,65 @@
+package resolver
+
+import (
+	"context"
+	"strconv"
+
+	"boiler/cmd/server/internal/graphql/entity"
+	lentity "boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+)
+
+// NewOrganization return a new Organization resolver
+func NewOrganization(service service.Interface) *Organization {
+	return &Organization{
+		service: service,
+	}
+}
+
+// Organization resolver for Organization and Member
+type Organization struct {
+	service service.Interface
+}
+
+// Members return the members of the organization
+func (r *Organization) Members(ctx context.Context, o *entity.Organization) ([]*entity.Member, error) {
+	orgID, err := strconv.ParseInt(o.ID, 10, 64)
+	if err != nil || orgID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = r.service.Authorize(ctx, service.ActionListMembers, service.Resource{OrganizationID: orgID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	var ms []lentity.Member
+	err = r.service.FilterMembers(ctx, orgID, &ms)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to list members")
+	}
+
+	members := make([]*entity.Member, 0, len(ms))
+	for i := range ms {
+		members = append(members, entity.NewMember(&ms[i]))
+	}
+
+	return members, nil
+}
+
+// User resolve User by Member, the member was authorized by the field returning it
+func (r *Organization) User(ctx context.Context, m *entity.Member) (*entity.User, error) {
+	userID, err := strconv.ParseInt(m.User.ID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	var u lentity.User
+	err = r.service.GetUserByID(ctx, userID, &u)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to get user")
+	}
+
+	return entity.NewUser(&u), nil
+}
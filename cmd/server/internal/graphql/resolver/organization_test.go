@@ -0,0 +1,83 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	gentity "boiler/cmd/server/internal/graphql/entity"
+	"boiler/cmd/server/internal/graphql/resolver"
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	"boiler/pkg/service/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrganizationMembers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewOrganization(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListMembers, service.Resource{OrganizationID: 3}).Return(nil)
+		m.EXPECT().
+			FilterMembers(gomock.Any(), int64(3), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, members *[]entity.Member) error {
+				*members = []entity.Member{{OrganizationID: 3, UserID: 4, Role: entity.OrgRoleOwner}}
+				return nil
+			})
+
+		members, err := r.Members(ctxDebug, &gentity.Organization{ID: "3"})
+		assert.Nil(t, err)
+		assert.Equal(t, []*gentity.Member{{User: &gentity.User{ID: "4"}, Role: gentity.OrganizationRoleOwner}}, members)
+	}
+
+	// fails if not a member
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewOrganization(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListMembers, service.Resource{OrganizationID: 3}).Return(errors.ErrForbidden)
+
+		members, err := r.Members(ctxDebug, &gentity.Organization{ID: "3"})
+		assert.Nil(t, members)
+		assert.True(t, errors.Is(err, errors.ErrForbidden))
+	}
+}
+
+func TestMemberUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewOrganization(m)
+
+		m.EXPECT().
+			GetUserByID(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, user *entity.User) error {
+				*user = entity.User{ID: 4, Name: "john"}
+				return nil
+			})
+
+		user, err := r.User(ctxDebug, &gentity.Member{User: &gentity.User{ID: "4"}})
+		assert.Nil(t, err)
+		assert.Equal(t, &gentity.User{ID: "4", Name: "john"}, user)
+	}
+
+	// fails with an invalid ID
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewOrganization(m)
+
+		user, err := r.User(ctxDebug, &gentity.Member{User: &gentity.User{ID: "x"}})
+		assert.Nil(t, user)
+		assert.Equal(t, errors.ErrInvalidID, err)
+	}
+}
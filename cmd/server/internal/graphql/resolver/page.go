@@ -0,0 +1,53 @@
+package resolver
+
+import (
+	"boiler/cmd/server/internal/graphql/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// page is the keyset pagination of a connection
+type page struct {
+	after    string
+	before   string
+	backward bool
+	sortBy   store.SortBy
+	limit    uint
+}
+
+// newPage map the Relay connection arguments to a keyset pagination
+// first limits the page after the cursor, last the page before it or the end, they can not be used together
+func newPage(first *int, after *string, last *int, before *string, sortBy *entity.SortBy) (page, error) {
+	var p page
+
+	if first != nil && last != nil {
+		return p, errors.ErrInvalidLimit
+	}
+
+	if after != nil {
+		p.after = *after
+	}
+
+	if before != nil {
+		p.before = *before
+	}
+
+	if sortBy != nil && *sortBy == entity.SortByCreated {
+		p.sortBy = store.SortByCreated
+	}
+
+	limit := first
+	if last != nil {
+		limit = last
+		p.backward = true
+	}
+
+	if limit != nil {
+		if *limit <= 0 {
+			return p, errors.ErrInvalidLimit
+		}
+		p.limit = uint(*limit)
+	}
+
+	return p, nil
+}
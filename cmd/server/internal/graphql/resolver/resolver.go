@@ -12,7 +12,9 @@ import (
 
 // Wrap wrap error
 func Wrap(ctx context.Context, err error, args ...string) error {
-	if errors.Is(err, errors.ErrNotFound) {
+	if errors.Is(err, errors.ErrNotFound) ||
+		errors.Is(err, errors.ErrUnauthorized) || errors.Is(err, errors.ErrForbidden) ||
+		errors.Is(err, errors.ErrTooManyRequests) {
 		return err
 	}
 
@@ -2,8 +2,10 @@ package resolver
 
 import (
 	"context"
+	"strconv"
 
 	"boiler/cmd/server/internal/graphql/entity"
+	"boiler/pkg/errors"
 	"boiler/pkg/service"
 )
 
@@ -19,14 +21,24 @@ type Response struct {
 	service service.Interface
 }
 
-// User return an User
+// User return an User, the mutation returning it already authorized it
 func (r *Response) User(ctx context.Context, ur *entity.UserResponse) (*entity.User, error) {
-	return (&User{r.service}).User(ctx, ur.User.ID)
+	userID, err := strconv.ParseInt(ur.User.ID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	return (&User{r.service}).user(ctx, userID)
 }
 
-// Email return an Email
+// Email return an Email, the mutation returning it already authorized it
 func (r *Response) Email(ctx context.Context, ur *entity.EmailResponse) (*entity.Email, error) {
-	return (&Email{r.service}).Email(ctx, ur.Email.ID)
+	emailID, err := strconv.ParseInt(ur.Email.ID, 10, 64)
+	if err != nil || emailID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	return (&Email{r.service}).email(ctx, emailID)
 }
 
 // NewAuthResponse return a new Response resolver
@@ -41,6 +53,12 @@ type AuthUserResponse struct {
 	service service.Interface
 }
 
+// User return the authenticated User
 func (r *AuthUserResponse) User(ctx context.Context, ur *entity.AuthUserResponse) (*entity.User, error) {
-	return (&User{r.service}).User(ctx, ur.User.ID)
+	userID, err := strconv.ParseInt(ur.User.ID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	return (&User{r.service}).user(ctx, userID)
 }
@@ -30,8 +30,18 @@ func (r *User) User(ctx context.Context, rawUserID string) (*entity.User, error)
 		return nil, errors.ErrInvalidID
 	}
 
+	err = r.service.Authorize(ctx, service.ActionReadUser, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	return r.user(ctx, userID)
+}
+
+// user return an user by ID without authorization, the caller already authorized it
+func (r *User) user(ctx context.Context, userID int64) (*entity.User, error) {
 	var u lentity.User
-	err = r.service.GetUserByID(ctx, userID, &u)
+	err := r.service.GetUserByID(ctx, userID, &u)
 	if err == nil {
 		return entity.NewUser(&u), nil
 	}
@@ -39,39 +49,189 @@ func (r *User) User(ctx context.Context, rawUserID string) (*entity.User, error)
 	return nil, Wrap(ctx, err, "fail to get user")
 }
 
-// Users return a slice of User
-func (r *User) Users(ctx context.Context, limit uint) ([]*entity.User, error) {
+// Users return a page of users, the deleted users are only listed with includeDeleted
+func (r *User) Users(ctx context.Context, first *int, after *string, last *int, before *string,
+	sortBy *entity.SortBy, includeDeleted *bool) (*entity.UserConnection, error) {
+
+	p, err := newPage(first, after, last, before, sortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.service.Authorize(ctx, service.ActionListUsers, service.Resource{})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	deleted, err := r.includeDeleted(ctx, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := store.FilterUsers{
+		After: p.after, Before: p.before, Backward: p.backward, SortBy: p.sortBy, Limit: p.limit,
+		IncludeDeleted: deleted,
+	}
 
 	us := make([]lentity.User, 0)
-	err := r.service.FilterUsers(ctx, store.FilterUsers{Limit: limit}, &us)
+	var info store.PageInfo
+	err = r.service.FilterUsers(ctx, filter, &us, &info)
 	if err == nil {
-		users := make([]*entity.User, 0, len(us))
+		conn := &entity.UserConnection{
+			Edges:    make([]*entity.UserEdge, 0, len(us)),
+			PageInfo: entity.NewPageInfo(&info),
+		}
 		for _, u := range us {
-			users = append(users, entity.NewUser(&u))
+			conn.Edges = append(conn.Edges, &entity.UserEdge{
+				Cursor: store.NewCursor(p.sortBy, u.ID, u.Created).String(),
+				Node:   entity.NewUser(&u),
+			})
 		}
 
-		return users, nil
+		return conn, nil
 	}
 
 	return nil, Wrap(ctx, err, "fail to filter users")
 }
 
-// Emails return a slice of email
-func (r *User) Emails(ctx context.Context, u *entity.User) ([]*entity.Email, error) {
+// includeDeleted authorize listing the deleted rows when they are asked for
+func (r *User) includeDeleted(ctx context.Context, includeDeleted *bool) (bool, error) {
+	if includeDeleted == nil || !*includeDeleted {
+		return false, nil
+	}
+
+	err := r.service.Authorize(ctx, service.ActionIncludeDeleted, service.Resource{})
+	if err != nil {
+		return false, Wrap(ctx, err, "fail to authorize")
+	}
+
+	return true, nil
+}
+
+// Emails return a page of emails of the user, the deleted emails are only listed with includeDeleted
+func (r *User) Emails(ctx context.Context, u *entity.User, first *int, after *string, last *int, before *string,
+	sortBy *entity.SortBy, includeDeleted *bool) (*entity.EmailConnection, error) {
+
 	userID, err := strconv.ParseInt(u.ID, 10, 64)
 	if err != nil || userID == 0 {
 		return nil, errors.ErrInvalidID
 	}
 
+	p, err := newPage(first, after, last, before, sortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.service.Authorize(ctx, service.ActionListEmails, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	deleted, err := r.includeDeleted(ctx, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := store.FilterEmails{
+		UserID: userID, After: p.after, Before: p.before, Backward: p.backward, SortBy: p.sortBy, Limit: p.limit,
+		IncludeDeleted: deleted,
+	}
+
 	es := make([]lentity.Email, 0)
-	err = r.service.FilterEmails(ctx, store.FilterEmails{UserID: userID}, &es)
+	var info store.PageInfo
+	err = r.service.FilterEmails(ctx, filter, &es, &info)
 	if err == nil {
-		emails := make([]*entity.Email, 0, len(es))
+		conn := &entity.EmailConnection{
+			Edges:    make([]*entity.EmailEdge, 0, len(es)),
+			PageInfo: entity.NewPageInfo(&info),
+		}
 		for _, e := range es {
-			emails = append(emails, entity.NewEmail(&e))
+			conn.Edges = append(conn.Edges, &entity.EmailEdge{
+				Cursor: store.NewCursor(p.sortBy, e.ID, e.Created).String(),
+				Node:   entity.NewEmail(&e),
+			})
 		}
-		return emails, nil
+
+		return conn, nil
 	}
 
 	return nil, Wrap(ctx, err, "fail to filter emails")
 }
+
+// Roles return the roles of the user
+func (r *User) Roles(ctx context.Context, u *entity.User) ([]entity.Role, error) {
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = r.service.Authorize(ctx, service.ActionReadUser, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	var names []string
+	err = r.service.GetUserRoles(ctx, userID, &names)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to get user roles")
+	}
+
+	roles := make([]entity.Role, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, entity.NewRole(name))
+	}
+
+	return roles, nil
+}
+
+// APIKeys return the API keys of the user
+func (r *User) APIKeys(ctx context.Context, u *entity.User) ([]*entity.APIKey, error) {
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = r.service.Authorize(ctx, service.ActionListAPIKeys, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	var ks []lentity.APIKey
+	err = r.service.FilterAPIKeys(ctx, userID, &ks)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to list api keys")
+	}
+
+	keys := make([]*entity.APIKey, 0, len(ks))
+	for i := range ks {
+		keys = append(keys, entity.NewAPIKey(&ks[i]))
+	}
+
+	return keys, nil
+}
+
+// Organizations return the organizations of the user
+func (r *User) Organizations(ctx context.Context, u *entity.User) ([]*entity.Organization, error) {
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, errors.ErrInvalidID
+	}
+
+	err = r.service.Authorize(ctx, service.ActionListOrganizations, service.Resource{UserID: userID})
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to authorize")
+	}
+
+	var os []lentity.Organization
+	err = r.service.FilterOrganizations(ctx, userID, &os)
+	if err != nil {
+		return nil, Wrap(ctx, err, "fail to list organizations")
+	}
+
+	orgs := make([]*entity.Organization, 0, len(os))
+	for i := range os {
+		orgs = append(orgs, entity.NewOrganization(&os[i]))
+	}
+
+	return orgs, nil
+}
@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	gentity "boiler/cmd/server/internal/graphql/entity"
 	"boiler/cmd/server/internal/graphql/resolver"
 	"boiler/pkg/entity"
 	"boiler/pkg/errors"
+	"boiler/pkg/service"
 	"boiler/pkg/service/mock"
 	"boiler/pkg/store"
 	"boiler/pkg/store/config"
@@ -33,6 +35,7 @@ func TestUserUser(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
 		m.EXPECT().
 			GetUserByID(gomock.Any(), int64(4), gomock.Any()).
 			DoAndReturn(func(_ context.Context, _ int64, u *entity.User) error {
@@ -63,6 +66,7 @@ func TestUserUser(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
 		m.EXPECT().
 			GetUserByID(gomock.Any(), int64(4), gomock.Any()).
 			Return(fmt.Errorf("opz"))
@@ -72,6 +76,19 @@ func TestUserUser(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "opz")
 	}
+	// fails if not authenticated
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().
+			Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).
+			Return(errors.ErrUnauthorized)
+
+		u, err := r.User(context.Background(), "4")
+		assert.Nil(t, u)
+		assert.Equal(t, errors.ErrUnauthorized, err)
+	}
 }
 
 func TestUserUsers(t *testing.T) {
@@ -83,18 +100,94 @@ func TestUserUsers(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
+		first := 2
+		after := "a1"
+		sortBy := gentity.SortByCreated
+		created := time.Now()
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
 		m.EXPECT().
-			FilterUsers(gomock.Any(), store.FilterUsers{Limit: 2}, gomock.Any()).
-			DoAndReturn(func(_ context.Context, _ store.FilterUsers, users *[]entity.User) error {
-				*users = append(*users, entity.User{ID: 4, Name: "John Doe"})
+			FilterUsers(gomock.Any(), store.FilterUsers{After: after, SortBy: store.SortByCreated, Limit: 2}, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, users *[]entity.User, page *store.PageInfo) error {
+				*users = append(*users, entity.User{ID: 4, Name: "John Doe", Created: created})
+				*page = store.PageInfo{EndCursor: "e", HasNextPage: true}
 				return nil
 			})
 
-		users, err := r.Users(ctxDebug, 2)
+		users, err := r.Users(ctxDebug, &first, &after, nil, nil, &sortBy, nil)
 		assert.Nil(t, err)
 		assert.NotNil(t, users)
-		assert.Equal(t, len(users), 1)
-		assert.Equal(t, "John Doe", users[0].Name)
+		assert.Equal(t, len(users.Edges), 1)
+		assert.Equal(t, "John Doe", users.Edges[0].Node.Name)
+		assert.Equal(t, store.NewCursor(store.SortByCreated, 4, created).String(), users.Edges[0].Cursor)
+		assert.True(t, users.PageInfo.HasNextPage)
+		assert.False(t, users.PageInfo.HasPreviousPage)
+		assert.Nil(t, users.PageInfo.StartCursor)
+		assert.Equal(t, "e", *users.PageInfo.EndCursor)
+	}
+
+	// last with before
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		last := 3
+		before := "b1"
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
+		m.EXPECT().
+			FilterUsers(gomock.Any(), store.FilterUsers{Before: before, Backward: true, Limit: 3}, gomock.Any(), gomock.Any()).
+			Return(nil)
+
+		users, err := r.Users(ctxDebug, nil, nil, &last, &before, nil, nil)
+		assert.Nil(t, err)
+		assert.Len(t, users.Edges, 0)
+	}
+
+	// last without before seeks from the end
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		last := 2
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
+		m.EXPECT().
+			FilterUsers(gomock.Any(), store.FilterUsers{Backward: true, Limit: 2}, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, users *[]entity.User, page *store.PageInfo) error {
+				*users = append(*users, entity.User{ID: 5}, entity.User{ID: 6})
+				*page = store.PageInfo{StartCursor: "s", HasPreviousPage: true}
+				return nil
+			})
+
+		users, err := r.Users(ctxDebug, nil, nil, &last, nil, nil, nil)
+		assert.Nil(t, err)
+		assert.Len(t, users.Edges, 2)
+		assert.Equal(t, "6", users.Edges[1].Node.ID)
+		assert.True(t, users.PageInfo.HasPreviousPage)
+		assert.False(t, users.PageInfo.HasNextPage)
+	}
+
+	// fails if first and last are used together
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		first, last := 2, 2
+		users, err := r.Users(ctxDebug, &first, nil, &last, nil, nil, nil)
+		assert.Nil(t, users)
+		assert.Equal(t, errors.ErrInvalidLimit, err)
+	}
+
+	// fails if limit is invalid
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		first := 0
+		users, err := r.Users(ctxDebug, &first, nil, nil, nil, nil, nil)
+		assert.Nil(t, users)
+		assert.Equal(t, errors.ErrInvalidLimit, err)
 	}
 
 	// fails if service fails
@@ -102,12 +195,14 @@ func TestUserUsers(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
+		first := 4
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
 		m.EXPECT().
-			FilterUsers(gomock.Any(), store.FilterUsers{Limit: 4}, gomock.Any()).
+			FilterUsers(gomock.Any(), store.FilterUsers{Limit: 4}, gomock.Any(), gomock.Any()).
 			Return(fmt.Errorf("opz"))
 
-		users, err := r.Users(ctxDebug, 4)
-		assert.Len(t, users, 0)
+		users, err := r.Users(ctxDebug, &first, nil, nil, nil, nil, nil)
+		assert.Nil(t, users)
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "opz")
 	}
@@ -122,17 +217,84 @@ func TestUserEmails(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: 4}).Return(nil)
 		m.EXPECT().
-			FilterEmails(gomock.Any(), store.FilterEmails{UserID: 4}, gomock.Any()).
-			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+			FilterEmails(gomock.Any(), store.FilterEmails{UserID: 4}, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email, _ *store.PageInfo) error {
 				*emails = append(*emails, entity.Email{ID: 4, Address: "a@b.c"})
 				return nil
 			})
 
-		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "4"})
+		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "4"}, nil, nil, nil, nil, nil, nil)
 		assert.Nil(t, err)
 		assert.NotNil(t, emails)
-		assert.Equal(t, len(emails), 1)
+		assert.Equal(t, len(emails.Edges), 1)
+		assert.Equal(t, "a@b.c", emails.Edges[0].Node.Address)
+		assert.Equal(t, store.NewCursor(store.SortByID, 4, time.Time{}).String(), emails.Edges[0].Cursor)
+	}
+
+	// succeed including the deleted emails
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		deletedAt := time.Now()
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionIncludeDeleted, service.Resource{}).Return(nil)
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{UserID: 4, IncludeDeleted: true}, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email, _ *store.PageInfo) error {
+				*emails = append(*emails, entity.Email{ID: 4, Address: "a@b.c", DeletedAt: &deletedAt})
+				return nil
+			})
+
+		include := true
+		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "4"}, nil, nil, nil, nil, nil, &include)
+		assert.Nil(t, err)
+		if assert.Len(t, emails.Edges, 1) {
+			assert.Equal(t, &deletedAt, emails.Edges[0].Node.DeletedAt)
+		}
+	}
+
+	// fails if not allowed to include the deleted emails
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionIncludeDeleted, service.Resource{}).Return(errors.ErrForbidden)
+
+		include := true
+		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "4"}, nil, nil, nil, nil, nil, &include)
+		assert.Nil(t, emails)
+		assert.NotNil(t, err)
+	}
+
+	// last without before seeks from the end
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		last := 1
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{UserID: 4, Backward: true, Limit: 1}, gomock.Any(), gomock.Any()).
+			Return(nil)
+
+		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "4"}, nil, nil, &last, nil, nil, nil)
+		assert.Nil(t, err)
+		assert.Len(t, emails.Edges, 0)
+	}
+
+	// fails if first and last are used together
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		first, last := 1, 1
+		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "4"}, &first, nil, &last, nil, nil, nil)
+		assert.Nil(t, emails)
+		assert.Equal(t, errors.ErrInvalidLimit, err)
 	}
 
 	// fail if invalid ID
@@ -140,7 +302,7 @@ func TestUserEmails(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
-		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "0"})
+		emails, err := r.Emails(ctxDebug, &gentity.User{ID: "0"}, nil, nil, nil, nil, nil, nil)
 		assert.Nil(t, emails)
 		assert.Equal(t, err, errors.ErrInvalidID)
 	}
@@ -150,13 +312,122 @@ func TestUserEmails(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 		r := resolver.NewUser(m)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: 2}).Return(nil)
 		m.EXPECT().
-			FilterEmails(gomock.Any(), store.FilterEmails{UserID: 2}, gomock.Any()).
+			FilterEmails(gomock.Any(), store.FilterEmails{UserID: 2}, gomock.Any(), gomock.Any()).
 			Return(fmt.Errorf("opz"))
 
-		users, err := r.Emails(ctxDebug, &gentity.User{ID: strconv.FormatInt(2, 10)})
+		users, err := r.Emails(ctxDebug, &gentity.User{ID: strconv.FormatInt(2, 10)}, nil, nil, nil, nil, nil, nil)
 		assert.Nil(t, users)
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "opz")
 	}
 }
+
+func TestUserRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().
+			GetUserRoles(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleAdmin, entity.RoleMember}
+				return nil
+			})
+
+		roles, err := r.Roles(ctxDebug, &gentity.User{ID: "4"})
+		assert.Nil(t, err)
+		assert.Equal(t, []gentity.Role{gentity.RoleAdmin, gentity.RoleMember}, roles)
+	}
+
+	// fails if service fails
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().GetUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(fmt.Errorf("opz"))
+
+		roles, err := r.Roles(ctxDebug, &gentity.User{ID: "4"})
+		assert.Nil(t, roles)
+		assert.Equal(t, "opz", err.Error())
+	}
+}
+
+func TestUserAPIKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListAPIKeys, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().
+			FilterAPIKeys(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, keys *[]entity.APIKey) error {
+				*keys = []entity.APIKey{{ID: 7, UserID: 4, Name: "ci", Prefix: "bk_0a1b2c3d",
+					Scopes: []string{entity.ScopeUsersRead}}}
+				return nil
+			})
+
+		keys, err := r.APIKeys(ctxDebug, &gentity.User{ID: "4"})
+		assert.Nil(t, err)
+		assert.Equal(t, []*gentity.APIKey{{ID: "7", Name: "ci", Prefix: "bk_0a1b2c3d",
+			Scopes: []string{entity.ScopeUsersRead}}}, keys)
+	}
+
+	// fails if the api key lacks the scope
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListAPIKeys, service.Resource{UserID: 4}).Return(errors.ErrMissingScope)
+
+		keys, err := r.APIKeys(ctxDebug, &gentity.User{ID: "4"})
+		assert.Nil(t, keys)
+		assert.True(t, errors.Is(err, errors.ErrMissingScope))
+	}
+}
+
+func TestUserOrganizations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListOrganizations, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().
+			FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, orgs *[]entity.Organization) error {
+				*orgs = []entity.Organization{{ID: 3, Name: "acme"}}
+				return nil
+			})
+
+		orgs, err := r.Organizations(ctxDebug, &gentity.User{ID: "4"})
+		assert.Nil(t, err)
+		assert.Equal(t, []*gentity.Organization{{ID: "3", Name: "acme"}}, orgs)
+	}
+
+	// fails if not the user
+	{
+		m := mock.NewMockInterface(ctrl)
+		r := resolver.NewUser(m)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListOrganizations, service.Resource{UserID: 4}).Return(errors.ErrForbidden)
+
+		orgs, err := r.Organizations(ctxDebug, &gentity.User{ID: "4"})
+		assert.Nil(t, orgs)
+		assert.True(t, errors.Is(err, errors.ErrForbidden))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/mail"
 	"strconv"
 	"strings"
+	"time"
 
 	"boiler/pkg/entity"
 	"boiler/pkg/errors"
@@ -24,11 +25,23 @@ type Handle struct {
 	resp    Resp
 }
 
+// authorize check the request can do action on resource, it writes the failure
+func (h *Handle) authorize(w http.ResponseWriter, r *http.Request, action service.Action, resource service.Resource) bool {
+	err := h.service.Authorize(r.Context(), action, resource)
+	if err != nil {
+		h.resp.Failf(w, r, "could not authorize %s; %w", action, err)
+		return false
+	}
+
+	return true
+}
+
 // AddUser handle an AddUser request
 func (h *Handle) AddUser(w http.ResponseWriter, r *http.Request) {
 	payload := struct {
 		Name     string `json:"name"`
 		Password string `json:"password"`
+		Email    string `json:"email"`
 	}{}
 
 	err := json.NewDecoder(r.Body).Decode(&payload)
@@ -43,12 +56,22 @@ func (h *Handle) AddUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var emails []*entity.Email
+	if len(payload.Email) != 0 {
+		address, err := mail.ParseAddress(payload.Email)
+		if err != nil {
+			h.resp.Fail(w, r, errors.ErrInvalidEmailAddress)
+			return
+		}
+		emails = append(emails, &entity.Email{Address: address.Address})
+	}
+
 	user := entity.User{
 		Name:     payload.Name,
 		Password: payload.Password,
 	}
 
-	err = h.service.AddUser(r.Context(), &user)
+	err = h.service.AddUser(r.Context(), &user, emails...)
 	if err != nil {
 		h.resp.Failf(w, r, "could not add user; %w", err)
 		return
@@ -59,32 +82,101 @@ func (h *Handle) AddUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// pagination is the query of a paginated list
+type pagination struct {
+	After  string
+	Before string
+	SortBy store.SortBy
+	Limit  uint
+	// IncludeDeleted list the deleted rows too, it needs ActionIncludeDeleted
+	IncludeDeleted bool
+}
+
+// parsePagination read the limit, after, before, sort and include_deleted query params
+func parsePagination(r *http.Request, limit uint) (pagination, error) {
+	query := r.URL.Query()
+
+	p := pagination{
+		After:  query.Get("after"),
+		Before: query.Get("before"),
+		SortBy: store.SortBy(query.Get("sort")),
+		Limit:  limit,
+	}
+
+	if rawLimit := query.Get("limit"); len(rawLimit) > 0 {
+		l, err := strconv.Atoi(rawLimit)
+		if err != nil || l <= 0 {
+			return p, errors.ErrInvalidLimit
+		}
+		p.Limit = uint(l)
+	}
+
+	if raw := query.Get("include_deleted"); len(raw) > 0 {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			return p, errors.AddCode(errors.ErrBadRequest, "invalid_include_deleted")
+		}
+		p.IncludeDeleted = include
+	}
+
+	return p, nil
+}
+
+// cursors return the cursors a client use to fetch the pages around page
+func cursors(page store.PageInfo) (next, prev interface{}) {
+	if page.HasNextPage && len(page.EndCursor) != 0 {
+		next = page.EndCursor
+	}
+
+	if page.HasPreviousPage && len(page.StartCursor) != 0 {
+		prev = page.StartCursor
+	}
+
+	return next, prev
+}
+
 // ListUsers handle an ListUsers request
 func (h *Handle) ListUsers(w http.ResponseWriter, r *http.Request) {
-	limit := 100
-	rawLimit := r.URL.Query()["limit"]
-	if len(rawLimit) > 0 {
-		var err error
-		limit, err = strconv.Atoi(rawLimit[0])
-		if err != nil || limit <= 0 {
-			h.resp.Fail(w, r, errors.ErrInvalidLimit)
-			return
-		}
+	p, err := parsePagination(r, 100)
+	if err != nil {
+		h.resp.Fail(w, r, err)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionListUsers, service.Resource{}) {
+		return
+	}
+
+	if p.IncludeDeleted && !h.authorize(w, r, service.ActionIncludeDeleted, service.Resource{}) {
+		return
+	}
+
+	filter := store.FilterUsers{
+		After:          p.After,
+		Before:         p.Before,
+		SortBy:         p.SortBy,
+		Limit:          p.Limit,
+		IncludeDeleted: p.IncludeDeleted,
 	}
 
 	users := make([]entity.User, 0)
-	err := h.service.FilterUsers(r.Context(), store.FilterUsers{Limit: uint(limit)}, &users)
+	var page store.PageInfo
+	err = h.service.FilterUsers(r.Context(), filter, &users, &page)
 	if err != nil {
 		h.resp.Failf(w, r, "could not filter users; %w", err)
 		return
 	}
 
+	next, prev := cursors(page)
 	h.resp.JSON(w, r, map[string]interface{}{
-		"users": users,
+		"users":       users,
+		"next_cursor": next,
+		"prev_cursor": prev,
 	})
 }
 
 // DeleteUser handle an DeleteUser request
+// with ?async=1 the deletion is left to the worker, it responds 202 with the ID of the job
 func (h *Handle) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 	if err != nil || userID == 0 {
@@ -92,6 +184,33 @@ func (h *Handle) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var async bool
+	if raw := r.URL.Query().Get("async"); len(raw) > 0 {
+		async, err = strconv.ParseBool(raw)
+		if err != nil {
+			h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_async"))
+			return
+		}
+	}
+
+	if !h.authorize(w, r, service.ActionDeleteUser, service.Resource{UserID: userID}) {
+		return
+	}
+
+	if async {
+		var job entity.Job
+		err = h.service.EnqueueDeleteUser(r.Context(), userID, &job)
+		if err != nil {
+			h.resp.Failf(w, r, "could not enqueue user deletion; %w", err)
+			return
+		}
+
+		h.resp.JSONStatus(w, r, http.StatusAccepted, map[string]interface{}{
+			"job_id": job.ID,
+		})
+		return
+	}
+
 	err = h.service.DeleteUser(r.Context(), userID)
 	if err != nil {
 		h.resp.Failf(w, r, "could not delete user; %w", err)
@@ -101,133 +220,988 @@ func (h *Handle) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	h.resp.JSON(w, r, nil)
 }
 
-// GetUser handle an GetUser request
-func (h *Handle) GetUser(w http.ResponseWriter, r *http.Request) {
+// RestoreUser handle the restoration of a deleted user and the emails deleted with it
+func (h *Handle) RestoreUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 	if err != nil || userID == 0 {
 		h.resp.Fail(w, r, errors.ErrInvalidUserID)
 		return
 	}
 
-	user := new(entity.User)
-	err = h.service.GetUserByID(r.Context(), userID, user)
+	if !h.authorize(w, r, service.ActionRestoreUser, service.Resource{UserID: userID}) {
+		return
+	}
+
+	err = h.service.RestoreUser(r.Context(), userID)
 	if err != nil {
-		h.resp.Failf(w, r, "could not get user; %w", err)
+		h.resp.Failf(w, r, "could not restore user; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// GetJob handle a GetJob request, a job is read by the user who enqueued it
+// the request is authorized before the lookup and a job of another user it can not read is not found, so the
+// IDs of the jobs can not be probed
+func (h *Handle) GetJob(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := service.Viewer(r.Context())
+	if !ok {
+		h.resp.Fail(w, r, errors.ErrUnauthorized)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionReadJob, service.Resource{UserID: viewer.ID}) {
 		return
 	}
 
+	var job entity.Job
+	err := h.service.GetJob(r.Context(), chi.URLParam(r, "jobID"), &job)
+	if err != nil {
+		h.resp.Failf(w, r, "could not get job; %w", err)
+		return
+	}
+
+	if job.UserID != viewer.ID {
+		err = h.service.Authorize(r.Context(), service.ActionReadJob, service.Resource{UserID: job.UserID})
+		if errors.Is(err, errors.ErrForbidden) {
+			err = errors.ErrNotFound
+		}
+		if err != nil {
+			h.resp.Failf(w, r, "could not get job; %w", err)
+			return
+		}
+	}
+
 	h.resp.JSON(w, r, map[string]interface{}{
-		"user": user,
+		"job": job,
 	})
 }
 
-// AddEmail handle an AddEmail request
-func (h *Handle) AddEmail(w http.ResponseWriter, r *http.Request) {
-	payload := struct {
-		UserID  int64  `json:"user_id"`
-		Address string `json:"address"`
-	}{}
+// ListSchedules handle a ListSchedules request, it lists the schedules of the worker with their runs
+func (h *Handle) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r, service.ActionListSchedules, service.Resource{}) {
+		return
+	}
 
-	err := json.NewDecoder(r.Body).Decode(&payload)
+	var schedules []entity.Schedule
+	err := h.service.FilterSchedules(r.Context(), &schedules)
 	if err != nil {
-		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		h.resp.Failf(w, r, "could not filter schedules; %w", err)
 		return
 	}
 
-	emailAddress, err := mail.ParseAddress(payload.Address)
+	h.resp.JSON(w, r, map[string]interface{}{
+		"schedules": schedules,
+	})
+}
+
+// ListDeadJobs handle a ListDeadJobs request, it lists a page of 20 dead jobs of the worker, page defaults to 1
+func (h *Handle) ListDeadJobs(w http.ResponseWriter, r *http.Request) {
+	page := uint64(1)
+	if raw := r.URL.Query().Get("page"); len(raw) > 0 {
+		var err error
+		page, err = strconv.ParseUint(raw, 10, 32)
+		if err != nil || page == 0 {
+			h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_page"))
+			return
+		}
+	}
+
+	if !h.authorize(w, r, service.ActionManageDeadJobs, service.Resource{}) {
+		return
+	}
+
+	var (
+		jobs  []entity.DeadJob
+		total int64
+	)
+	err := h.service.FilterDeadJobs(r.Context(), uint(page), &jobs, &total)
 	if err != nil {
-		h.resp.Fail(w, r, errors.ErrInvalidEmailAddress)
+		h.resp.Failf(w, r, "could not filter dead jobs; %w", err)
 		return
 	}
 
-	if payload.UserID < 1 {
-		h.resp.Fail(w, r, errors.ErrInvalidID)
+	h.resp.JSON(w, r, map[string]interface{}{
+		"jobs":  jobs,
+		"total": total,
+	})
+}
+
+// RetryDeadJob handle a RetryDeadJob request, the dead job is found by the time it died and its ID
+func (h *Handle) RetryDeadJob(w http.ResponseWriter, r *http.Request) {
+	diedAt, err := strconv.ParseInt(chi.URLParam(r, "diedAt"), 10, 64)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_died_at"))
 		return
 	}
 
-	email := entity.Email{
-		UserID:  payload.UserID,
-		Address: emailAddress.Address,
+	if !h.authorize(w, r, service.ActionManageDeadJobs, service.Resource{}) {
+		return
 	}
 
-	err = h.service.AddEmail(r.Context(), &email)
+	err = h.service.RetryDeadJob(r.Context(), diedAt, chi.URLParam(r, "jobID"))
 	if err != nil {
-		h.resp.Failf(w, r, "could not add email; %w", err)
+		h.resp.Failf(w, r, "could not retry dead job; %w", err)
 		return
 	}
 
-	h.resp.JSON(w, r, struct {
-		EmailID int64 `json:"email_id"`
-	}{email.ID})
+	h.resp.JSON(w, r, nil)
 }
 
-// DeleteEmail handle an DeleteEmail request
-func (h *Handle) DeleteEmail(w http.ResponseWriter, r *http.Request) {
-	emailID, err := strconv.ParseInt(chi.URLParam(r, "emailID"), 10, 64)
-	if err != nil || emailID <= 0 {
-		h.resp.Fail(w, r, errors.ErrInvalidEmailID)
+// RetryDeadJobs handle a RetryDeadJobs request, every dead job is enqueued again
+func (h *Handle) RetryDeadJobs(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r, service.ActionManageDeadJobs, service.Resource{}) {
 		return
 	}
 
-	err = h.service.DeleteEmail(r.Context(), emailID)
+	err := h.service.RetryDeadJobs(r.Context())
 	if err != nil {
-		h.resp.Failf(w, r, "could not delete email; %w", err)
+		h.resp.Failf(w, r, "could not retry dead jobs; %w", err)
 		return
 	}
 
 	h.resp.JSON(w, r, nil)
 }
 
-// ListEmails handle an ListEmails request
-func (h *Handle) ListEmails(w http.ResponseWriter, r *http.Request) {
-	params := r.URL.Query()["user_id"]
-	if len(params) == 0 {
-		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "missing_query_user_id"))
+// DeleteDeadJob handle a DeleteDeadJob request, the dead job is found by the time it died and its ID
+func (h *Handle) DeleteDeadJob(w http.ResponseWriter, r *http.Request) {
+	diedAt, err := strconv.ParseInt(chi.URLParam(r, "diedAt"), 10, 64)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_died_at"))
 		return
 	}
 
-	userID, err := strconv.ParseInt(params[0], 10, 64)
+	if !h.authorize(w, r, service.ActionManageDeadJobs, service.Resource{}) {
+		return
+	}
+
+	err = h.service.DeleteDeadJob(r.Context(), diedAt, chi.URLParam(r, "jobID"))
+	if err != nil {
+		h.resp.Failf(w, r, "could not delete dead job; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// DeleteDeadJobs handle a DeleteDeadJobs request, every dead job is removed
+func (h *Handle) DeleteDeadJobs(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r, service.ActionManageDeadJobs, service.Resource{}) {
+		return
+	}
+
+	err := h.service.DeleteDeadJobs(r.Context())
+	if err != nil {
+		h.resp.Failf(w, r, "could not delete dead jobs; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// UpdateUser handle an UpdateUser request
+// updated is the update time of the user as read by the client, absent fields are kept
+func (h *Handle) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
 	if err != nil || userID == 0 {
 		h.resp.Fail(w, r, errors.ErrInvalidUserID)
 		return
 	}
 
-	emails := make([]entity.Email, 0)
-	err = h.service.FilterEmails(r.Context(), store.FilterEmails{UserID: userID}, &emails)
+	if !h.authorize(w, r, service.ActionUpdateUser, service.Resource{UserID: userID}) {
+		return
+	}
+
+	payload := struct {
+		Name    *string   `json:"name"`
+		Updated time.Time `json:"updated"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		h.resp.Failf(w, r, "could not filter email; %w", err)
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	if payload.Updated.IsZero() {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "missing_updated"))
+		return
+	}
+
+	user := new(entity.User)
+	err = h.service.GetUserByID(r.Context(), userID, user)
+	if err != nil {
+		h.resp.Failf(w, r, "could not get user; %w", err)
+		return
+	}
+
+	if payload.Name != nil {
+		user.Name = strings.TrimSpace(*payload.Name)
+		if len(user.Name) == 0 {
+			h.resp.Fail(w, r, errors.ErrInvalidName)
+			return
+		}
+	}
+
+	user.Updated = payload.Updated
+
+	err = h.service.UpdateUser(r.Context(), user)
+	if err != nil {
+		h.resp.Failf(w, r, "could not update user; %w", err)
 		return
 	}
 
 	h.resp.JSON(w, r, map[string]interface{}{
-		"emails": emails,
+		"user": user,
 	})
 }
 
-// AuthUser handle an authentication request
-func (h *Handle) AuthUser(w http.ResponseWriter, r *http.Request) {
+// ChangePassword handle a ChangePassword request
+func (h *Handle) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionChangePassword, service.Resource{UserID: userID}) {
+		return
+	}
+
 	payload := struct {
-		Email    string `json:"email"`
+		Current  string `json:"current_password"`
 		Password string `json:"password"`
 	}{}
 
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	err = h.service.ChangePassword(r.Context(), userID, payload.Current, payload.Password)
+	if err != nil {
+		h.resp.Failf(w, r, "could not change password; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// RequestPasswordReset handle a password reset request
+func (h *Handle) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		Email string `json:"email"`
+	}{}
+
 	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
 		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
 		return
 	}
 
-	var user *entity.User
-	var token *string
+	emailAddress, err := mail.ParseAddress(payload.Email)
+	if err != nil {
+		h.resp.Fail(w, r, errors.ErrInvalidEmailAddress)
+		return
+	}
 
-	err = h.service.AuthUser(r.Context(), payload.Email, payload.Password, user, token)
+	err = h.service.RequestPasswordReset(r.Context(), emailAddress.Address)
 	if err != nil {
-		h.resp.Failf(w, r, "could not auth user; %w", err)
+		h.resp.Failf(w, r, "could not request password reset; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// ResetPassword handle the reset of a password by the token of a password reset request
+func (h *Handle) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	err = h.service.ResetPassword(r.Context(), payload.Token, payload.Password)
+	if err != nil {
+		h.resp.Failf(w, r, "could not reset password; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// GetUserRoles handle a GetUserRoles request
+func (h *Handle) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionReadUser, service.Resource{UserID: userID}) {
+		return
+	}
+
+	roles := make([]string, 0)
+	err = h.service.GetUserRoles(r.Context(), userID, &roles)
+	if err != nil {
+		h.resp.Failf(w, r, "could not get user roles; %w", err)
 		return
 	}
 
 	h.resp.JSON(w, r, map[string]interface{}{
-		"user":  user,
-		"token": token,
+		"roles": roles,
 	})
 }
+
+// SetUserRoles handle a SetUserRoles request, the roles replace the ones of the user
+func (h *Handle) SetUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	payload := struct {
+		Roles []string `json:"roles"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionSetUserRoles, service.Resource{UserID: userID}) {
+		return
+	}
+
+	err = h.service.SetUserRoles(r.Context(), userID, payload.Roles)
+	if err != nil {
+		h.resp.Failf(w, r, "could not set user roles; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// GetUser handle an GetUser request
+func (h *Handle) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionReadUser, service.Resource{UserID: userID}) {
+		return
+	}
+
+	user := new(entity.User)
+	err = h.service.GetUserByID(r.Context(), userID, user)
+	if err != nil {
+		h.resp.Failf(w, r, "could not get user; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"user": user,
+	})
+}
+
+// AddEmail handle an AddEmail request
+func (h *Handle) AddEmail(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		UserID  int64  `json:"user_id"`
+		Address string `json:"address"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	emailAddress, err := mail.ParseAddress(payload.Address)
+	if err != nil {
+		h.resp.Fail(w, r, errors.ErrInvalidEmailAddress)
+		return
+	}
+
+	if payload.UserID < 1 {
+		h.resp.Fail(w, r, errors.ErrInvalidID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionAddEmail, service.Resource{UserID: payload.UserID}) {
+		return
+	}
+
+	email := entity.Email{
+		UserID:  payload.UserID,
+		Address: emailAddress.Address,
+	}
+
+	err = h.service.AddEmail(r.Context(), &email)
+	if err != nil {
+		h.resp.Failf(w, r, "could not add email; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, struct {
+		EmailID int64 `json:"email_id"`
+	}{email.ID})
+}
+
+// VerifyEmail handle the verification of an email by the token sent to it
+func (h *Handle) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		Token string `json:"token"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	var email entity.Email
+	err = h.service.VerifyEmail(r.Context(), payload.Token, &email)
+	if err != nil {
+		h.resp.Failf(w, r, "could not verify email; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, email)
+}
+
+// DeleteEmail handle an DeleteEmail request
+func (h *Handle) DeleteEmail(w http.ResponseWriter, r *http.Request) {
+	emailID, err := strconv.ParseInt(chi.URLParam(r, "emailID"), 10, 64)
+	if err != nil || emailID <= 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidEmailID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionDeleteEmail, service.Resource{EmailID: emailID}) {
+		return
+	}
+
+	err = h.service.DeleteEmail(r.Context(), emailID)
+	if err != nil {
+		h.resp.Failf(w, r, "could not delete email; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// RestoreEmail handle the restoration of a deleted email
+func (h *Handle) RestoreEmail(w http.ResponseWriter, r *http.Request) {
+	emailID, err := strconv.ParseInt(chi.URLParam(r, "emailID"), 10, 64)
+	if err != nil || emailID <= 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidEmailID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionRestoreEmail, service.Resource{EmailID: emailID}) {
+		return
+	}
+
+	err = h.service.RestoreEmail(r.Context(), emailID)
+	if err != nil {
+		h.resp.Failf(w, r, "could not restore email; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// ListEmails handle an ListEmails request
+func (h *Handle) ListEmails(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()["user_id"]
+	if len(params) == 0 {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "missing_query_user_id"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	p, err := parsePagination(r, 0)
+	if err != nil {
+		h.resp.Fail(w, r, err)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionListEmails, service.Resource{UserID: userID}) {
+		return
+	}
+
+	if p.IncludeDeleted && !h.authorize(w, r, service.ActionIncludeDeleted, service.Resource{}) {
+		return
+	}
+
+	filter := store.FilterEmails{
+		UserID:         userID,
+		After:          p.After,
+		Before:         p.Before,
+		SortBy:         p.SortBy,
+		Limit:          p.Limit,
+		IncludeDeleted: p.IncludeDeleted,
+	}
+
+	emails := make([]entity.Email, 0)
+	var page store.PageInfo
+	err = h.service.FilterEmails(r.Context(), filter, &emails, &page)
+	if err != nil {
+		h.resp.Failf(w, r, "could not filter email; %w", err)
+		return
+	}
+
+	next, prev := cursors(page)
+	h.resp.JSON(w, r, map[string]interface{}{
+		"emails":      emails,
+		"next_cursor": next,
+		"prev_cursor": prev,
+	})
+}
+
+// AuthUser handle an authentication request
+// the users with a TOTP get a challenge, they authenticate again with the challenge and a code
+func (h *Handle) AuthUser(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		Email     string `json:"email"`
+		Password  string `json:"password"`
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	var user entity.User
+	var tokens entity.Tokens
+
+	if len(payload.Challenge) != 0 {
+		err = h.service.AuthTOTP(r.Context(), payload.Challenge, payload.Code, &user, &tokens)
+	} else {
+		err = h.service.AuthUser(r.Context(), payload.Email, payload.Password, &user, &tokens)
+	}
+	if err != nil {
+		h.resp.Failf(w, r, "could not auth user; %w", err)
+		return
+	}
+
+	if len(tokens.Challenge) != 0 {
+		h.resp.JSON(w, r, map[string]interface{}{
+			"user":      user,
+			"challenge": tokens.Challenge,
+		})
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"user":          user,
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+	})
+}
+
+// EnrollTOTP handle the enrollment of a TOTP, it returns the secret to add to the app
+func (h *Handle) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionEnrollTOTP, service.Resource{UserID: userID}) {
+		return
+	}
+
+	var enrollment entity.TOTPEnrollment
+	err = h.service.EnrollTOTP(r.Context(), userID, &enrollment)
+	if err != nil {
+		h.resp.Failf(w, r, "could not enroll totp; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, enrollment)
+}
+
+// ConfirmTOTP handle the confirmation of a TOTP by a code of the app, it returns the recovery codes
+func (h *Handle) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionEnrollTOTP, service.Resource{UserID: userID}) {
+		return
+	}
+
+	payload := struct {
+		Code string `json:"code"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	var codes []string
+	err = h.service.ConfirmTOTP(r.Context(), userID, payload.Code, &codes)
+	if err != nil {
+		h.resp.Failf(w, r, "could not confirm totp; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// ListAPIKeys handle the listing of the API keys of an user
+func (h *Handle) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionListAPIKeys, service.Resource{UserID: userID}) {
+		return
+	}
+
+	keys := make([]entity.APIKey, 0)
+	err = h.service.FilterAPIKeys(r.Context(), userID, &keys)
+	if err != nil {
+		h.resp.Failf(w, r, "could not list api keys; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"api_keys": keys,
+	})
+}
+
+// AddAPIKey handle the creation of an API key, the key is only returned here
+func (h *Handle) AddAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	payload := struct {
+		Name    string     `json:"name"`
+		Scopes  []string   `json:"scopes"`
+		Expires *time.Time `json:"expires"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionAddAPIKey, service.Resource{UserID: userID}) {
+		return
+	}
+
+	key := entity.APIKey{
+		UserID:  userID,
+		Name:    payload.Name,
+		Scopes:  payload.Scopes,
+		Expires: payload.Expires,
+	}
+
+	var raw string
+	err = h.service.AddAPIKey(r.Context(), &key, &raw)
+	if err != nil {
+		h.resp.Failf(w, r, "could not add api key; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"api_key": key,
+		"key":     raw,
+	})
+}
+
+// RevokeAPIKey handle the revocation of an API key
+func (h *Handle) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil || keyID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionRevokeAPIKey, service.Resource{UserID: userID}) {
+		return
+	}
+
+	err = h.service.RevokeAPIKey(r.Context(), userID, keyID)
+	if err != nil {
+		h.resp.Failf(w, r, "could not revoke api key; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// AddOrganization handle the creation of an organization owned by the authenticated user
+func (h *Handle) AddOrganization(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		Name string `json:"name"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	viewer, ok := service.Viewer(r.Context())
+	if !ok {
+		h.resp.Fail(w, r, errors.ErrUnauthorized)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionAddOrganization, service.Resource{UserID: viewer.ID}) {
+		return
+	}
+
+	org := entity.Organization{Name: payload.Name}
+	err = h.service.AddOrganization(r.Context(), &org, viewer.ID)
+	if err != nil {
+		h.resp.Failf(w, r, "could not add organization; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"organization": org,
+	})
+}
+
+// ListOrganizations handle the listing of the organizations of an user
+func (h *Handle) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidUserID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionListOrganizations, service.Resource{UserID: userID}) {
+		return
+	}
+
+	orgs := make([]entity.Organization, 0)
+	err = h.service.FilterOrganizations(r.Context(), userID, &orgs)
+	if err != nil {
+		h.resp.Failf(w, r, "could not list organizations; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"organizations": orgs,
+	})
+}
+
+// ListMembers handle the listing of the members of an organization
+func (h *Handle) ListMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.ParseInt(chi.URLParam(r, "orgID"), 10, 64)
+	if err != nil || orgID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidID)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionListMembers, service.Resource{OrganizationID: orgID}) {
+		return
+	}
+
+	members := make([]entity.Member, 0)
+	err = h.service.FilterMembers(r.Context(), orgID, &members)
+	if err != nil {
+		h.resp.Failf(w, r, "could not list members; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"members": members,
+	})
+}
+
+// InviteMember handle the invitation of an email to join an organization
+func (h *Handle) InviteMember(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.ParseInt(chi.URLParam(r, "orgID"), 10, 64)
+	if err != nil || orgID == 0 {
+		h.resp.Fail(w, r, errors.ErrInvalidID)
+		return
+	}
+
+	payload := struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	emailAddress, err := mail.ParseAddress(payload.Email)
+	if err != nil {
+		h.resp.Fail(w, r, errors.ErrInvalidEmailAddress)
+		return
+	}
+
+	if !h.authorize(w, r, service.ActionInviteMember, service.Resource{OrganizationID: orgID}) {
+		return
+	}
+
+	err = h.service.InviteMember(r.Context(), orgID, emailAddress.Address, payload.Role)
+	if err != nil {
+		h.resp.Failf(w, r, "could not invite member; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// AcceptInvitation handle the acceptance of an invitation by the authenticated user
+func (h *Handle) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		Token string `json:"token"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	var member entity.Member
+	err = h.service.AcceptInvitation(r.Context(), payload.Token, &member)
+	if err != nil {
+		h.resp.Failf(w, r, "could not accept invitation; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"member": member,
+	})
+}
+
+// SwitchOrganization handle the exchange of a refresh token for tokens of another organization
+func (h *Handle) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		RefreshToken   string `json:"refresh_token"`
+		OrganizationID int64  `json:"organization_id"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	var user entity.User
+	var tokens entity.Tokens
+
+	err = h.service.SwitchOrganization(r.Context(), payload.RefreshToken, payload.OrganizationID, &user, &tokens)
+	if err != nil {
+		h.resp.Failf(w, r, "could not switch organization; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"user":          user,
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+	})
+}
+
+// RefreshToken handle the exchange of a refresh token for new tokens
+func (h *Handle) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	var user entity.User
+	var tokens entity.Tokens
+
+	err = h.service.RefreshToken(r.Context(), payload.RefreshToken, &user, &tokens)
+	if err != nil {
+		h.resp.Failf(w, r, "could not refresh token; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, map[string]interface{}{
+		"user":          user,
+		"token":         tokens.Access,
+		"refresh_token": tokens.Refresh,
+	})
+}
+
+// Logout handle the revocation of a refresh token family and of the current access token
+func (h *Handle) Logout(w http.ResponseWriter, r *http.Request) {
+	payload := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		h.resp.Fail(w, r, errors.AddCode(errors.ErrBadRequest, "invalid_payload"))
+		return
+	}
+
+	err = h.service.Logout(r.Context(), payload.RefreshToken)
+	if err != nil {
+		h.resp.Failf(w, r, "could not logout; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, nil)
+}
+
+// JWKS handle the request of the public keys verifying the access tokens
+func (h *Handle) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := h.service.JWKS(r.Context())
+	if err != nil {
+		h.resp.Failf(w, r, "could not get JWKS; %w", err)
+		return
+	}
+
+	h.resp.JSON(w, r, set)
+}
@@ -8,15 +8,20 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"boiler/cmd/server/internal/rest"
 	"boiler/cmd/server/internal/router"
 	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+	"boiler/pkg/service"
 	"boiler/pkg/service/mock"
 	"boiler/pkg/store"
 
 	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,6 +61,29 @@ func TestAddUserHandle(t *testing.T) {
 		assert.Nil(t, err)
 	}
 
+	// succeed with an email
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		user := &entity.User{Name: "John"}
+		m.EXPECT().AddUser(gomock.Any(), user, &entity.Email{Address: "john@example.com"}).Return(nil)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users", h.AddUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"name":"John","email":"John <john@example.com>"}`)
+		res, err := http.Post(fmt.Sprintf("%s/users", ts.URL), "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
 	// fail if payload is invalid
 	{
 		m := mock.NewMockInterface(ctrl)
@@ -160,6 +188,7 @@ func TestDeleteUserHandle(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 
 		user := &entity.User{ID: 4, Name: "John"}
+		m.EXPECT().Authorize(gomock.Any(), service.ActionDeleteUser, service.Resource{UserID: user.ID}).Return(nil)
 		m.EXPECT().DeleteUser(gomock.Any(), user.ID).Return(nil)
 
 		r := chi.NewRouter()
@@ -177,70 +206,1282 @@ func TestDeleteUserHandle(t *testing.T) {
 		res, err := http.DefaultClient.Do(req)
 		res.Body.Close()
 		assert.Nil(t, err)
-		assert.Equal(t, res.StatusCode, http.StatusOK)
-	}
+		assert.Equal(t, res.StatusCode, http.StatusOK)
+	}
+
+	// succeed asynchronously
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionDeleteUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().EnqueueDeleteUser(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, job *entity.Job) error {
+				job.ID = "j1"
+				return nil
+			})
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/4?async=1", ts.URL), nil)
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+		var resp struct {
+			JobID string `json:"job_id"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+		assert.Equal(t, "j1", resp.JobID)
+	}
+
+	// fails if invalid async
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/4?async=maybe", ts.URL), nil)
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+
+	// fails if invalid userID
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/0?debug", ts.URL), nil)
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Len(t, resp.Error.Codes, 3)
+		assert.Equal(t, "invalid_user_id", resp.Error.Codes[0])
+		assert.Equal(t, "invalid_id", resp.Error.Codes[1])
+		assert.Equal(t, "bad_request", resp.Error.Codes[2])
+		assert.Nil(t, err)
+	}
+
+	// fails if service fails
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		user := &entity.User{ID: 4, Name: "John"}
+		m.EXPECT().Authorize(gomock.Any(), service.ActionDeleteUser, service.Resource{UserID: user.ID}).Return(nil)
+		m.EXPECT().DeleteUser(gomock.Any(), user.ID).Return(fmt.Errorf("opz"))
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/%d?debug", ts.URL, user.ID), nil)
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Len(t, resp.Error.Codes, 1)
+		assert.Equal(t, "internal_server_error", resp.Error.Codes[0])
+		assert.Equal(t, "could not delete user; opz", resp.Error.Msg)
+	}
+
+	// fails if not authenticated or not allowed
+	for _, tc := range []struct {
+		err    error
+		status int
+		codes  []string
+	}{
+		{errors.ErrUnauthorized, http.StatusUnauthorized, []string{"unauthorized"}},
+		{errors.ErrForbidden, http.StatusForbidden, []string{"forbidden"}},
+	} {
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionDeleteUser, service.Resource{UserID: 4}).Return(tc.err)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(http.MethodDelete, ts.URL+"/users/4", nil)
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.status, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, tc.codes, resp.Error.Codes)
+	}
+}
+
+func TestGetJobHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	r := chi.NewRouter()
+	router.ApplyMiddlewares(r, nil, m)
+
+	h := rest.New(m, new(rest.DefaultResp))
+	r.Get("/jobs/{jobID}", h.GetJob)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	get := func(url string, authenticated bool) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+url, nil)
+		assert.Nil(t, err)
+		if authenticated {
+			req.Header.Set("Authorization", router.APIKeyAuthorization+"bk_key")
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	as := func(userID int64) {
+		m.EXPECT().AuthAPIKey(gomock.Any(), "bk_key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, viewer *entity.JWTUser) error {
+				*viewer = entity.JWTUser{ID: userID}
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		as(4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, job *entity.Job) error {
+				*job = entity.Job{ID: "j1", UserID: 4, Status: entity.JobFailed, Error: "opz"}
+				return nil
+			})
+
+		res := get("/jobs/j1", true)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			Job entity.Job `json:"job"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+		assert.Equal(t, entity.JobFailed, resp.Job.Status)
+		assert.Equal(t, "opz", resp.Job.Error)
+	}
+
+	// succeed to read the job of another user if allowed
+	{
+		as(1)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 1}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, job *entity.Job) error {
+				*job = entity.Job{ID: "j1", UserID: 4}
+				return nil
+			})
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 4}).Return(nil)
+
+		res := get("/jobs/j1", true)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// fails if anonymous without looking the job up
+	{
+		res := get("/jobs/j1", false)
+		res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	}
+
+	// fails if the job of another user is not allowed, like a job not found
+	{
+		as(5)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 5}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, job *entity.Job) error {
+				*job = entity.Job{ID: "j1", UserID: 4}
+				return nil
+			})
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 4}).
+			Return(errors.ErrForbidden)
+
+		res := get("/jobs/j1", true)
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Contains(t, resp.Error.Codes, "not_found")
+	}
+
+	// fails if not found
+	{
+		as(5)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadJob, service.Resource{UserID: 5}).Return(nil)
+		m.EXPECT().GetJob(gomock.Any(), "j2", gomock.Any()).Return(errors.ErrNotFound)
+
+		res := get("/jobs/j2", true)
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Contains(t, resp.Error.Codes, "not_found")
+	}
+}
+
+func TestListSchedulesHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	r := chi.NewRouter()
+	router.ApplyMiddlewares(r, nil, m)
+
+	h := rest.New(m, new(rest.DefaultResp))
+	r.Get("/schedules", h.ListSchedules)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// succeed
+	{
+		next := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListSchedules, service.Resource{}).Return(nil)
+		m.EXPECT().FilterSchedules(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, schedules *[]entity.Schedule) error {
+				*schedules = []entity.Schedule{{Job: "purge_tokens", Spec: "0 0 * * * *", NextRun: next}}
+				return nil
+			})
+
+		res, err := http.Get(fmt.Sprintf("%s/schedules", ts.URL))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			Schedules []entity.Schedule `json:"schedules"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+		if assert.Len(t, resp.Schedules, 1) {
+			assert.Equal(t, "purge_tokens", resp.Schedules[0].Job)
+			assert.Nil(t, resp.Schedules[0].LastRun)
+			assert.True(t, next.Equal(resp.Schedules[0].NextRun))
+		}
+	}
+
+	// fails if not allowed
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListSchedules, service.Resource{}).Return(errors.ErrForbidden)
+
+		res, err := http.Get(fmt.Sprintf("%s/schedules", ts.URL))
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	}
+}
+
+func TestDeadJobsHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	r := chi.NewRouter()
+	router.ApplyMiddlewares(r, nil, m)
+
+	h := rest.New(m, new(rest.DefaultResp))
+	r.Get("/jobs/dead", h.ListDeadJobs)
+	r.Post("/jobs/dead/retry", h.RetryDeadJobs)
+	r.Delete("/jobs/dead", h.DeleteDeadJobs)
+	r.Post("/jobs/dead/{diedAt}/{jobID}/retry", h.RetryDeadJob)
+	r.Delete("/jobs/dead/{diedAt}/{jobID}", h.DeleteDeadJob)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	do := func(method, path string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		assert.Nil(t, err)
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed to list the dead jobs
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionManageDeadJobs, service.Resource{}).Return(nil)
+		m.EXPECT().FilterDeadJobs(gomock.Any(), uint(2), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ uint, jobs *[]entity.DeadJob, total *int64) error {
+				*jobs = []entity.DeadJob{{ID: "a1", Name: service.DeleteUser, Error: "database is locked"}}
+				*total = 21
+				return nil
+			})
+
+		res := do(http.MethodGet, "/jobs/dead?page=2")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			Jobs  []entity.DeadJob `json:"jobs"`
+			Total int64            `json:"total"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+		assert.Equal(t, int64(21), resp.Total)
+		if assert.Len(t, resp.Jobs, 1) {
+			assert.Equal(t, "database is locked", resp.Jobs[0].Error)
+		}
+	}
+
+	// succeed to retry and delete a dead job
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionManageDeadJobs, service.Resource{}).Return(nil).Times(2)
+		m.EXPECT().RetryDeadJob(gomock.Any(), int64(1600000100), "a1").Return(nil)
+		m.EXPECT().DeleteDeadJob(gomock.Any(), int64(1600000100), "a1").Return(nil)
+
+		res := do(http.MethodPost, "/jobs/dead/1600000100/a1/retry")
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = do(http.MethodDelete, "/jobs/dead/1600000100/a1")
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// succeed to retry and delete every dead job
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionManageDeadJobs, service.Resource{}).Return(nil).Times(2)
+		m.EXPECT().RetryDeadJobs(gomock.Any()).Return(nil)
+		m.EXPECT().DeleteDeadJobs(gomock.Any()).Return(nil)
+
+		res := do(http.MethodPost, "/jobs/dead/retry")
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res = do(http.MethodDelete, "/jobs/dead")
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// fails if the page is invalid
+	{
+		res := do(http.MethodGet, "/jobs/dead?page=0")
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+
+	// fails if the dead job is missing
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionManageDeadJobs, service.Resource{}).Return(nil)
+		m.EXPECT().RetryDeadJob(gomock.Any(), int64(1600000100), "a1").Return(errors.ErrNotFound)
+
+		res := do(http.MethodPost, "/jobs/dead/1600000100/a1/retry")
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+
+	// fails if not allowed
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionManageDeadJobs, service.Resource{}).Return(errors.ErrForbidden)
+
+		res := do(http.MethodDelete, "/jobs/dead")
+		res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	}
+}
+
+func TestRestoreHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	r := chi.NewRouter()
+	router.ApplyMiddlewares(r, nil, m)
+
+	h := rest.New(m, new(rest.DefaultResp))
+	r.Get("/users", h.ListUsers)
+	r.Post("/users/{userID:[0-9]+}/restore", h.RestoreUser)
+	r.Post("/emails/{emailID:[0-9]+}/restore", h.RestoreEmail)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// succeed restoring an user
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionRestoreUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().RestoreUser(gomock.Any(), int64(4)).Return(nil)
+
+		res, err := http.Post(fmt.Sprintf("%s/users/4/restore", ts.URL), "application/json", nil)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// fails if the email is not deleted
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionRestoreEmail, service.Resource{EmailID: 6}).Return(nil)
+		m.EXPECT().RestoreEmail(gomock.Any(), int64(6)).Return(errors.ErrNotFound)
+
+		res, err := http.Post(fmt.Sprintf("%s/emails/6/restore", ts.URL), "application/json", nil)
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+
+	// succeed listing the deleted users
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionIncludeDeleted, service.Resource{}).Return(nil)
+		m.EXPECT().
+			FilterUsers(gomock.Any(), store.FilterUsers{Limit: 100, IncludeDeleted: true}, gomock.Any(), gomock.Any()).
+			Return(nil)
+
+		res, err := http.Get(fmt.Sprintf("%s/users?include_deleted=true", ts.URL))
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// fails if not allowed to list the deleted users
+	{
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionIncludeDeleted, service.Resource{}).Return(errors.ErrForbidden)
+
+		res, err := http.Get(fmt.Sprintf("%s/users?include_deleted=1", ts.URL))
+		assert.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	}
+
+	// fails if include_deleted is invalid
+	{
+		res, err := http.Get(fmt.Sprintf("%s/users?include_deleted=maybe", ts.URL))
+		assert.Nil(t, err)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "invalid_include_deleted", resp.Error.Codes[0])
+	}
+}
+
+func TestUpdateUserHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+	patch := func(url, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBufferString(body))
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionUpdateUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().
+			GetUserByID(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, u *entity.User) error {
+				*u = entity.User{ID: 4, Name: "John", Updated: updated.Add(-time.Hour)}
+				return nil
+			})
+		m.EXPECT().
+			UpdateUser(gomock.Any(), &entity.User{ID: 4, Name: "Jane", Updated: updated}).
+			DoAndReturn(func(_ context.Context, u *entity.User) error {
+				u.Updated = updated.Add(time.Second)
+				return nil
+			})
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Patch("/users/{userID}", h.UpdateUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res := patch(ts.URL+"/users/4", `{"name":" Jane ","updated":"2020-01-02T03:04:05.000006Z"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct{ User entity.User }
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, "Jane", resp.User.Name)
+		assert.True(t, updated.Add(time.Second).Equal(resp.User.Updated))
+	}
+
+	// fails if updated is missing
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionUpdateUser, service.Resource{UserID: 4}).Return(nil)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Patch("/users/{userID}", h.UpdateUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res := patch(ts.URL+"/users/4", `{"name":"Jane"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"missing_updated", "bad_request"}, resp.Error.Codes)
+	}
+
+	// fails if modified since it was read
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		m.EXPECT().Authorize(gomock.Any(), service.ActionUpdateUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().GetUserByID(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(errors.ErrUpdateConflict)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Patch("/users/{userID}", h.UpdateUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res := patch(ts.URL+"/users/4", `{"updated":"2020-01-02T03:04:05Z"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"update_conflict", "conflict"}, resp.Error.Codes)
+	}
+}
+
+func TestChangePasswordHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionChangePassword, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().ChangePassword(gomock.Any(), int64(4), "old", "new").Return(nil)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users/{userID}/password", h.ChangePassword)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"current_password":"old","password":"new"}`)
+		res, err := http.Post(ts.URL+"/users/4/password", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails if current password is wrong
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionChangePassword, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().ChangePassword(gomock.Any(), int64(4), "bad", "new").Return(errors.ErrInvalidPassword)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users/{userID}/password", h.ChangePassword)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"current_password":"bad","password":"new"}`)
+		res, err := http.Post(ts.URL+"/users/4/password", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"invalid_password", "bad_request"}, resp.Error.Codes)
+	}
+}
+
+func TestPasswordResetHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	post := func(m *mock.MockInterface, url, body string) *http.Response {
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users/password-reset", h.RequestPasswordReset)
+		r.Post("/users/password-reset/confirm", h.ResetPassword)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res, err := http.Post(ts.URL+url, "application/json", bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed to request a reset
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().RequestPasswordReset(gomock.Any(), "a@b.c").Return(nil)
+
+		res := post(m, "/users/password-reset", `{"email":"John <a@b.c>"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to request a reset with an invalid address
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		res := post(m, "/users/password-reset", `{"email":"nope"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"invalid_email_address", "bad_request"}, resp.Error.Codes)
+	}
+
+	// succeed to reset the password
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().ResetPassword(gomock.Any(), "tok", "new").Return(nil)
+
+		res := post(m, "/users/password-reset/confirm", `{"token":"tok","password":"new"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to reset the password with an invalid token
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().ResetPassword(gomock.Any(), "tok", "new").Return(errors.ErrInvalidResetToken)
+
+		res := post(m, "/users/password-reset/confirm", `{"token":"tok","password":"new"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"invalid_reset_token", "bad_request"}, resp.Error.Codes)
+	}
+}
+
+func TestTOTPHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	post := func(m *mock.MockInterface, url, body string) *http.Response {
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users/login", h.AuthUser)
+		r.Post("/users/{userID}/totp", h.EnrollTOTP)
+		r.Post("/users/{userID}/totp/confirm", h.ConfirmTOTP)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res, err := http.Post(ts.URL+url, "application/json", bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed to enroll
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionEnrollTOTP, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().EnrollTOTP(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, e *entity.TOTPEnrollment) error {
+				*e = entity.TOTPEnrollment{Secret: "S", URI: "otpauth://totp/boiler:jo?secret=S"}
+				return nil
+			})
+
+		res := post(m, "/users/4/totp", ``)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp entity.TOTPEnrollment
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, "S", resp.Secret)
+		assert.Equal(t, "otpauth://totp/boiler:jo?secret=S", resp.URI)
+	}
+
+	// succeed to confirm
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionEnrollTOTP, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().ConfirmTOTP(gomock.Any(), int64(4), "123456", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, _ string, codes *[]string) error {
+				*codes = []string{"aaaa-bbbb", "cccc-dddd"}
+				return nil
+			})
+
+		res := post(m, "/users/4/totp/confirm", `{"code":"123456"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"aaaa-bbbb", "cccc-dddd"}, resp.RecoveryCodes)
+	}
+
+	// fails to confirm with a wrong code
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionEnrollTOTP, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().ConfirmTOTP(gomock.Any(), int64(4), "000000", gomock.Any()).Return(errors.ErrInvalidTOTPCode)
+
+		res := post(m, "/users/4/totp/confirm", `{"code":"000000"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"invalid_totp_code", "bad_request"}, resp.Error.Codes)
+	}
+
+	// fails to enroll another user
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionEnrollTOTP, service.Resource{UserID: 5}).Return(errors.ErrForbidden)
+
+		res := post(m, "/users/5/totp", ``)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// succeed to get a challenge
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().AuthUser(gomock.Any(), "a@b.c", "pass", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ string, u *entity.User, t *entity.Tokens) error {
+				u.ID = 4
+				*t = entity.Tokens{Challenge: "challenge"}
+				return nil
+			})
+
+		res := post(m, "/users/login", `{"email":"a@b.c","password":"pass"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp map[string]interface{}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, "challenge", resp["challenge"])
+		assert.NotContains(t, resp, "token")
+	}
+
+	// succeed to exchange the challenge and a code for the tokens
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().AuthTOTP(gomock.Any(), "challenge", "123456", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ string, u *entity.User, t *entity.Tokens) error {
+				u.ID = 4
+				*t = entity.Tokens{Access: "access", Refresh: "refresh"}
+				return nil
+			})
+
+		res := post(m, "/users/login", `{"challenge":"challenge","code":"123456"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			Token        string
+			RefreshToken string `json:"refresh_token"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, "access", resp.Token)
+		assert.Equal(t, "refresh", resp.RefreshToken)
+	}
+
+	// fails if the challenge is invalid
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().AuthTOTP(gomock.Any(), "bad", "123456", gomock.Any(), gomock.Any()).Return(errors.ErrInvalidChallenge)
+
+		res := post(m, "/users/login", `{"challenge":"bad","code":"123456"}`)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		res.Body.Close()
+	}
+}
+
+func TestUserRolesHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	put := func(url, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewBufferString(body))
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().
+			GetUserRoles(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleMember}
+				return nil
+			})
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Get("/users/{userID}/roles", h.GetUserRoles)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res, err := http.Get(ts.URL + "/users/4/roles")
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct{ Roles []string }
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{entity.RoleMember}, resp.Roles)
+	}
+
+	// succeed setting the roles
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionSetUserRoles, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), int64(4), []string{entity.RoleSupport}).Return(nil)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Put("/users/{userID}/roles", h.SetUserRoles)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res := put(ts.URL+"/users/4/roles", `{"roles":["support"]}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails if a role is invalid
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionSetUserRoles, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), int64(4), []string{"unknown"}).Return(errors.ErrInvalidRole)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Put("/users/{userID}/roles", h.SetUserRoles)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res := put(ts.URL+"/users/4/roles", `{"roles":["unknown"]}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"invalid_role", "bad_request"}, resp.Error.Codes)
+	}
+
+	// fails if not allowed
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionSetUserRoles, service.Resource{UserID: 4}).Return(errors.ErrForbidden)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Put("/users/{userID}/roles", h.SetUserRoles)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res := put(ts.URL+"/users/4/roles", `{"roles":["admin"]}`)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res.Body.Close()
+	}
+}
+
+func TestAuthHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tokens := entity.Tokens{Access: "access", Refresh: "refresh"}
+
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().AuthUser(gomock.Any(), "a@b.c", "pass", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ string, u *entity.User, t *entity.Tokens) error {
+				u.ID = 4
+				*t = tokens
+				return nil
+			})
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users/login", h.AuthUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"email":"a@b.c","password":"pass"}`)
+		res, err := http.Post(ts.URL+"/users/login", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			User         entity.User
+			Token        string
+			RefreshToken string `json:"refresh_token"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, int64(4), resp.User.ID)
+		assert.Equal(t, "access", resp.Token)
+		assert.Equal(t, "refresh", resp.RefreshToken)
+	}
+
+	// fails with too many requests once throttled
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().AuthUser(gomock.Any(), "a@b.c", "pass", gomock.Any(), gomock.Any()).Return(errors.ErrTooManyAttempts)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/users/login", h.AuthUser)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"email":"a@b.c","password":"pass"}`)
+		res, err := http.Post(ts.URL+"/users/login", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"too_many_attempts", "too_many_requests"}, resp.Error.Codes)
+	}
+
+	// succeed refreshing the tokens
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().RefreshToken(gomock.Any(), "refresh", gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, u *entity.User, t *entity.Tokens) error {
+				u.ID = 4
+				*t = entity.Tokens{Access: "access2", Refresh: "refresh2"}
+				return nil
+			})
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/auth/refresh", h.RefreshToken)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"refresh_token":"refresh"}`)
+		res, err := http.Post(ts.URL+"/auth/refresh", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			Token        string
+			RefreshToken string `json:"refresh_token"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, "access2", resp.Token)
+		assert.Equal(t, "refresh2", resp.RefreshToken)
+	}
+
+	// fails if the refresh token was reused
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().RefreshToken(gomock.Any(), "refresh", gomock.Any(), gomock.Any()).Return(errors.ErrRefreshTokenReused)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/auth/refresh", h.RefreshToken)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"refresh_token":"refresh"}`)
+		res, err := http.Post(ts.URL+"/auth/refresh", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"refresh_token_reused", "invalid_refresh_token", "unauthorized"}, resp.Error.Codes)
+	}
+
+	// succeed to logout
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Logout(gomock.Any(), "refresh").Return(nil)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/auth/logout", h.Logout)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		body := bytes.NewBufferString(`{"refresh_token":"refresh"}`)
+		res, err := http.Post(ts.URL+"/auth/logout", "application/json", body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to logout if payload is invalid
+	{
+		m := mock.NewMockInterface(ctrl)
+
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/auth/logout", h.Logout)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		res, err := http.Post(ts.URL+"/auth/logout", "application/json", bytes.NewBufferString("{"))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		res.Body.Close()
+	}
+}
+
+func TestJWKSHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// succeed
+	{
+		key, err := keyset.Generate(jwa.ES256)
+		assert.Nil(t, err)
+
+		set, err := keyset.New(time.Minute, key).Public()
+		assert.Nil(t, err)
 
-	// fails if invalid userID
-	{
 		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().JWKS(gomock.Any()).Return(set, nil)
 
 		r := chi.NewRouter()
 		router.ApplyMiddlewares(r, nil, m)
 
 		h := rest.New(m, new(rest.DefaultResp))
-		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+		r.Get("/.well-known/jwks.json", h.JWKS)
 
 		ts := httptest.NewServer(r)
 		defer ts.Close()
 
-		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/0?debug", ts.URL), nil)
-		assert.Nil(t, err)
-
-		res, err := http.DefaultClient.Do(req)
+		res, err := http.Get(ts.URL + "/.well-known/jwks.json")
 		assert.Nil(t, err)
-		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
 
-		var resp rest.ErrResponse
+		var resp struct {
+			Keys []struct {
+				Kid string
+				Alg string
+			}
+		}
 		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
 		res.Body.Close()
 
-		assert.Len(t, resp.Error.Codes, 3)
-		assert.Equal(t, "invalid_user_id", resp.Error.Codes[0])
-		assert.Equal(t, "invalid_id", resp.Error.Codes[1])
-		assert.Equal(t, "bad_request", resp.Error.Codes[2])
-		assert.Nil(t, err)
+		assert.Len(t, resp.Keys, 1)
+		assert.Equal(t, key.ID, resp.Keys[0].Kid)
+		assert.Equal(t, "ES256", resp.Keys[0].Alg)
 	}
+}
 
-	// fails if service fails
-	{
-		m := mock.NewMockInterface(ctrl)
-
-		user := &entity.User{ID: 4, Name: "John"}
-		m.EXPECT().DeleteUser(gomock.Any(), user.ID).Return(fmt.Errorf("opz"))
+func TestVerifyEmailHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
 
+	post := func(m *mock.MockInterface) *http.Response {
 		r := chi.NewRouter()
 		router.ApplyMiddlewares(r, nil, m)
 
 		h := rest.New(m, new(rest.DefaultResp))
-		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+		r.Post("/emails/verify", h.VerifyEmail)
 
 		ts := httptest.NewServer(r)
 		defer ts.Close()
 
-		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/%d?debug", ts.URL, user.ID), nil)
+		res, err := http.Post(ts.URL+"/emails/verify", "application/json", bytes.NewBufferString(`{"token":"tok"}`))
 		assert.Nil(t, err)
+		return res
+	}
 
-		res, err := http.DefaultClient.Do(req)
-		assert.Nil(t, err)
-		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	// succeed
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().VerifyEmail(gomock.Any(), "tok", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, e *entity.Email) error {
+				now := time.Now()
+				*e = entity.Email{ID: 3, UserID: 4, Address: "a@b.c", VerifiedAt: &now}
+				return nil
+			})
+
+		res := post(m)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var email entity.Email
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&email))
+		res.Body.Close()
+
+		assert.Equal(t, int64(3), email.ID)
+		assert.NotNil(t, email.VerifiedAt)
+	}
+
+	// fails if the token is invalid
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().VerifyEmail(gomock.Any(), "tok", gomock.Any()).Return(errors.ErrInvalidVerificationToken)
+
+		res := post(m)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
 
 		var resp rest.ErrResponse
 		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
 		res.Body.Close()
 
-		assert.Len(t, resp.Error.Codes, 1)
-		assert.Equal(t, "internal_server_error", resp.Error.Codes[0])
-		assert.Equal(t, "could not delete user; opz", resp.Error.Msg)
+		assert.Contains(t, resp.Error.Codes, "invalid_verification_token")
+	}
+
+	// fails if already verified
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().VerifyEmail(gomock.Any(), "tok", gomock.Any()).Return(errors.ErrEmailAlreadyVerified)
+
+		res := post(m)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+		res.Body.Close()
 	}
 }
 
@@ -254,10 +1495,12 @@ func TestUsersHandle(t *testing.T) {
 
 		user := &entity.User{ID: 4, Name: "John Doe"}
 		users := []entity.User{}
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
 		m.EXPECT().
-			FilterUsers(gomock.Any(), store.FilterUsers{Limit: 3}, &users).
-			DoAndReturn(func(_ context.Context, _ store.FilterUsers, us *[]entity.User) error {
+			FilterUsers(gomock.Any(), store.FilterUsers{After: "a1", SortBy: store.SortByCreated, Limit: 3}, &users, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, us *[]entity.User, page *store.PageInfo) error {
 				*us = append(*us, *user)
+				*page = store.PageInfo{StartCursor: "s", EndCursor: "e", HasNextPage: true}
 				return nil
 			})
 
@@ -270,15 +1513,23 @@ func TestUsersHandle(t *testing.T) {
 		ts := httptest.NewServer(r)
 		defer ts.Close()
 
-		res, err := http.Get(fmt.Sprintf("%s/users?limit=3", ts.URL))
+		res, err := http.Get(fmt.Sprintf("%s/users?limit=3&after=a1&sort=created", ts.URL))
 		assert.Nil(t, err)
 		assert.Equal(t, http.StatusOK, res.StatusCode)
 
-		var resp struct{ Users []*entity.User }
+		var resp struct {
+			Users      []*entity.User
+			NextCursor *string `json:"next_cursor"`
+			PrevCursor *string `json:"prev_cursor"`
+		}
 		err = json.NewDecoder(res.Body).Decode(&resp)
 		assert.Nil(t, err)
 		res.Body.Close()
 
+		if assert.NotNil(t, resp.NextCursor) {
+			assert.Equal(t, "e", *resp.NextCursor)
+		}
+		assert.Nil(t, resp.PrevCursor)
 		assert.Len(t, resp.Users, 1)
 		assert.Equal(t, resp.Users[0].ID, user.ID)
 		assert.Equal(t, resp.Users[0].Name, user.Name)
@@ -316,7 +1567,8 @@ func TestUsersHandle(t *testing.T) {
 		m := mock.NewMockInterface(ctrl)
 
 		users := []entity.User{}
-		m.EXPECT().FilterUsers(gomock.Any(), store.FilterUsers{Limit: 100}, &users).Return(fmt.Errorf("not working"))
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListUsers, service.Resource{}).Return(nil)
+		m.EXPECT().FilterUsers(gomock.Any(), store.FilterUsers{Limit: 100}, &users, gomock.Any()).Return(fmt.Errorf("not working"))
 
 		r := chi.NewRouter()
 		router.ApplyMiddlewares(r, nil, m)
@@ -349,6 +1601,7 @@ func TestUserHandle(t *testing.T) {
 	{
 		m := mock.NewMockInterface(ctrl)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
 		m.EXPECT().
 			GetUserByID(gomock.Any(), int64(4), gomock.Any()).
 			DoAndReturn(func(_ context.Context, id int64, u *entity.User) error {
@@ -403,6 +1656,7 @@ func TestUserHandle(t *testing.T) {
 	{
 		m := mock.NewMockInterface(ctrl)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionReadUser, service.Resource{UserID: 4}).Return(nil)
 		m.EXPECT().
 			GetUserByID(gomock.Any(), int64(4), gomock.Any()).
 			Return(fmt.Errorf("failed"))
@@ -436,6 +1690,7 @@ func TestAddEmailHandle(t *testing.T) {
 			Address: "example@email.com",
 		}
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionAddEmail, service.Resource{UserID: 12}).Return(nil)
 		m.EXPECT().
 			AddEmail(gomock.Any(), &email).
 			DoAndReturn(func(_ context.Context, e *entity.Email) error {
@@ -564,6 +1819,7 @@ func TestAddEmailHandle(t *testing.T) {
 		}
 		myErr := fmt.Errorf("fails")
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionAddEmail, service.Resource{UserID: 12}).Return(nil)
 		m.EXPECT().
 			AddEmail(gomock.Any(), &email).
 			Return(myErr)
@@ -604,6 +1860,7 @@ func TestDeleteEmailHandle(t *testing.T) {
 
 		emailID := int64(12)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionDeleteEmail, service.Resource{EmailID: emailID}).Return(nil)
 		m.EXPECT().
 			DeleteEmail(gomock.Any(), emailID).
 			Return(nil)
@@ -661,6 +1918,7 @@ func TestDeleteEmailHandle(t *testing.T) {
 
 		emailID := int64(1)
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionDeleteEmail, service.Resource{EmailID: emailID}).Return(nil)
 		m.EXPECT().
 			DeleteEmail(gomock.Any(), emailID).
 			Return(fmt.Errorf("opz"))
@@ -706,9 +1964,10 @@ func TestEmailsHandle(t *testing.T) {
 			{ID: 3, Address: "devs@example.com"},
 		}
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: user.ID}).Return(nil)
 		m.EXPECT().
-			FilterEmails(gomock.Any(), store.FilterEmails{UserID: user.ID}, gomock.Any()).
-			DoAndReturn(func(_ context.Context, f store.FilterEmails, es *[]entity.Email) error {
+			FilterEmails(gomock.Any(), store.FilterEmails{UserID: user.ID}, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, f store.FilterEmails, es *[]entity.Email, _ *store.PageInfo) error {
 				*es = emails
 				return nil
 			})
@@ -726,11 +1985,15 @@ func TestEmailsHandle(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, res.StatusCode, http.StatusOK)
 
-		var rEmails struct{ Emails []*entity.Email }
+		var rEmails struct {
+			Emails     []*entity.Email
+			NextCursor *string `json:"next_cursor"`
+		}
 		err = json.NewDecoder(res.Body).Decode(&rEmails)
 		res.Body.Close()
 		assert.Nil(t, err)
 		assert.NotNil(t, rEmails)
+		assert.Nil(t, rEmails.NextCursor)
 
 		assert.Len(t, rEmails.Emails, len(emails))
 		for i, e := range rEmails.Emails {
@@ -745,8 +2008,9 @@ func TestEmailsHandle(t *testing.T) {
 
 		user := entity.User{ID: 4, Name: "John Doe"}
 
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListEmails, service.Resource{UserID: user.ID}).Return(nil)
 		m.EXPECT().
-			FilterEmails(gomock.Any(), store.FilterEmails{UserID: user.ID}, gomock.Any()).
+			FilterEmails(gomock.Any(), store.FilterEmails{UserID: user.ID}, gomock.Any(), gomock.Any()).
 			Return(fmt.Errorf("failed"))
 
 		r := chi.NewRouter()
@@ -801,3 +2065,340 @@ func TestEmailsHandle(t *testing.T) {
 		res.Body.Close()
 	}
 }
+
+func TestAPIKeyHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	do := func(m *mock.MockInterface, method, url, body string) *http.Response {
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Get("/users/{userID}/api-keys", h.ListAPIKeys)
+		r.Post("/users/{userID}/api-keys", h.AddAPIKey)
+		r.Delete("/users/{userID}/api-keys/{keyID}", h.RevokeAPIKey)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(method, ts.URL+url, bytes.NewBufferString(body))
+		assert.Nil(t, err)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed to add
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionAddAPIKey, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().AddAPIKey(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, key *entity.APIKey, raw *string) error {
+				assert.Equal(t, int64(4), key.UserID)
+				assert.Equal(t, "ci", key.Name)
+				assert.Equal(t, []string{entity.ScopeUsersRead}, key.Scopes)
+				assert.Nil(t, key.Expires)
+				key.ID = 7
+				key.Prefix = "bk_0a1b2c3d"
+				*raw = "bk_0a1b2c3d_secret"
+				return nil
+			})
+
+		res := do(m, http.MethodPost, "/users/4/api-keys", `{"name":"ci","scopes":["users:read"]}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			APIKey entity.APIKey `json:"api_key"`
+			Key    string        `json:"key"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, int64(7), resp.APIKey.ID)
+		assert.Equal(t, "bk_0a1b2c3d", resp.APIKey.Prefix)
+		assert.Equal(t, "bk_0a1b2c3d_secret", resp.Key)
+	}
+
+	// fails to add with an unknown scope
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionAddAPIKey, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().AddAPIKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.ErrInvalidScope)
+
+		res := do(m, http.MethodPost, "/users/4/api-keys", `{"name":"ci","scopes":["users:*"]}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"invalid_scope", "bad_request"}, resp.Error.Codes)
+	}
+
+	// succeed to list
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListAPIKeys, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().FilterAPIKeys(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, keys *[]entity.APIKey) error {
+				*keys = append(*keys, entity.APIKey{ID: 7, UserID: 4, Name: "ci", Hash: "hash"})
+				return nil
+			})
+
+		res := do(m, http.MethodGet, "/users/4/api-keys", ``)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp map[string][]map[string]interface{}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Len(t, resp["api_keys"], 1)
+		assert.Equal(t, "ci", resp["api_keys"][0]["name"])
+		assert.NotContains(t, resp["api_keys"][0], "hash")
+	}
+
+	// succeed to revoke
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionRevokeAPIKey, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().RevokeAPIKey(gomock.Any(), int64(4), int64(7)).Return(nil)
+
+		res := do(m, http.MethodDelete, "/users/4/api-keys/7", ``)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to revoke the key of another user
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionRevokeAPIKey, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().RevokeAPIKey(gomock.Any(), int64(4), int64(8)).Return(errors.ErrNotFound)
+
+		res := do(m, http.MethodDelete, "/users/4/api-keys/8", ``)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"not_found", "bad_request"}, resp.Error.Codes)
+	}
+
+	// fails if the api key lacks the scope
+	{
+		m := mock.NewMockInterface(ctrl)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListAPIKeys, service.Resource{UserID: 4}).Return(errors.ErrMissingScope)
+
+		res := do(m, http.MethodGet, "/users/4/api-keys", ``)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"missing_scope", "forbidden"}, resp.Error.Codes)
+	}
+}
+
+func TestOrganizationHandle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	do := func(m *mock.MockInterface, method, url, body string) *http.Response {
+		r := chi.NewRouter()
+		router.ApplyMiddlewares(r, nil, m)
+
+		h := rest.New(m, new(rest.DefaultResp))
+		r.Post("/organizations", h.AddOrganization)
+		r.Get("/users/{userID}/organizations", h.ListOrganizations)
+		r.Get("/organizations/{orgID}/members", h.ListMembers)
+		r.Post("/organizations/{orgID}/invitations", h.InviteMember)
+		r.Post("/organizations/invitations/accept", h.AcceptInvitation)
+		r.Post("/auth/organization", h.SwitchOrganization)
+
+		ts := httptest.NewServer(r)
+		defer ts.Close()
+
+		req, err := http.NewRequest(method, ts.URL+url, bytes.NewBufferString(body))
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", router.APIKeyAuthorization+"bk_key")
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	as := func(m *mock.MockInterface, userID int64) {
+		m.EXPECT().AuthAPIKey(gomock.Any(), "bk_key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, viewer *entity.JWTUser) error {
+				*viewer = entity.JWTUser{ID: userID}
+				return nil
+			})
+	}
+
+	// succeed to add
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionAddOrganization, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().AddOrganization(gomock.Any(), &entity.Organization{Name: "acme"}, int64(4)).
+			DoAndReturn(func(_ context.Context, org *entity.Organization, _ int64) error {
+				org.ID = 3
+				return nil
+			})
+
+		res := do(m, http.MethodPost, "/organizations", `{"name":"acme"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp struct {
+			Organization entity.Organization `json:"organization"`
+		}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, int64(3), resp.Organization.ID)
+	}
+
+	// succeed to list the organizations and the members
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListOrganizations, service.Resource{UserID: 4}).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, orgs *[]entity.Organization) error {
+				*orgs = append(*orgs, entity.Organization{ID: 3, Name: "acme"})
+				return nil
+			})
+
+		res := do(m, http.MethodGet, "/users/4/organizations", ``)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var orgs map[string][]entity.Organization
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&orgs))
+		res.Body.Close()
+		assert.Equal(t, []entity.Organization{{ID: 3, Name: "acme"}}, orgs["organizations"])
+
+		as(m, 4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionListMembers, service.Resource{OrganizationID: 3}).Return(nil)
+		m.EXPECT().FilterMembers(gomock.Any(), int64(3), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, members *[]entity.Member) error {
+				*members = append(*members, entity.Member{OrganizationID: 3, UserID: 4, Role: entity.OrgRoleOwner})
+				return nil
+			})
+
+		res = do(m, http.MethodGet, "/organizations/3/members", ``)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var members map[string][]entity.Member
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&members))
+		res.Body.Close()
+		assert.Equal(t, []entity.Member{{OrganizationID: 3, UserID: 4, Role: entity.OrgRoleOwner}}, members["members"])
+	}
+
+	// succeed to invite and accept
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionInviteMember, service.Resource{OrganizationID: 3}).Return(nil)
+		m.EXPECT().InviteMember(gomock.Any(), int64(3), "a@b.c", entity.OrgRoleMember).Return(nil)
+
+		res := do(m, http.MethodPost, "/organizations/3/invitations", `{"email":"a@b.c","role":"member"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+
+		as(m, 5)
+		m.EXPECT().AcceptInvitation(gomock.Any(), "token", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, member *entity.Member) error {
+				*member = entity.Member{OrganizationID: 3, UserID: 5, Role: entity.OrgRoleMember}
+				return nil
+			})
+
+		res = do(m, http.MethodPost, "/organizations/invitations/accept", `{"token":"token"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to invite if not an admin of the organization
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionInviteMember, service.Resource{OrganizationID: 3}).Return(errors.ErrForbidden)
+
+		res := do(m, http.MethodPost, "/organizations/3/invitations", `{"email":"a@b.c","role":"member"}`)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// succeed to invite the normalized address
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().Authorize(gomock.Any(), service.ActionInviteMember, service.Resource{OrganizationID: 3}).Return(nil)
+		m.EXPECT().InviteMember(gomock.Any(), int64(3), "a@b.c", entity.OrgRoleMember).Return(nil)
+
+		res := do(m, http.MethodPost, "/organizations/3/invitations", `{"email":"A <a@b.c>","role":"member"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to invite an invalid address
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+
+		res := do(m, http.MethodPost, "/organizations/3/invitations", `{"email":"a@b.c\r\nBcc: d@e.f","role":"member"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// fails to accept an invitation twice
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 5)
+		m.EXPECT().AcceptInvitation(gomock.Any(), "token", gomock.Any()).Return(errors.ErrAlreadyMember)
+
+		res := do(m, http.MethodPost, "/organizations/invitations/accept", `{"token":"token"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"already_member", "conflict"}, resp.Error.Codes)
+	}
+
+	// succeed to switch organization
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().SwitchOrganization(gomock.Any(), "raw", int64(3), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, _ int64, user *entity.User, tokens *entity.Tokens) error {
+				*user = entity.User{ID: 4}
+				*tokens = entity.Tokens{Access: "access", Refresh: "refresh"}
+				return nil
+			})
+
+		res := do(m, http.MethodPost, "/auth/organization", `{"refresh_token":"raw","organization_id":3}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var resp map[string]interface{}
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, "access", resp["token"])
+		assert.Equal(t, "refresh", resp["refresh_token"])
+	}
+
+	// fails to switch to an organization of which the user is not a member
+	{
+		m := mock.NewMockInterface(ctrl)
+		as(m, 4)
+		m.EXPECT().SwitchOrganization(gomock.Any(), "raw", int64(6), gomock.Any(), gomock.Any()).Return(errors.ErrNotMember)
+
+		res := do(m, http.MethodPost, "/auth/organization", `{"refresh_token":"raw","organization_id":6}`)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res.Body.Close()
+	}
+}
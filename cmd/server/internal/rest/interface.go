@@ -7,4 +7,5 @@ type Resp interface {
 	Fail(w http.ResponseWriter, r *http.Request, err error)
 	Failf(w http.ResponseWriter, r *http.Request, format string, a ...interface{})
 	JSON(w http.ResponseWriter, r *http.Request, data interface{})
+	JSONStatus(w http.ResponseWriter, r *http.Request, status int, data interface{})
 }
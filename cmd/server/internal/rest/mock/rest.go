@@ -74,3 +74,15 @@ func (mr *MockRespMockRecorder) JSON(w, r, data interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JSON", reflect.TypeOf((*MockResp)(nil).JSON), w, r, data)
 }
+
+// JSONStatus mocks base method.
+func (m *MockResp) JSONStatus(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "JSONStatus", w, r, status, data)
+}
+
+// JSONStatus indicates an expected call of JSONStatus.
+func (mr *MockRespMockRecorder) JSONStatus(w, r, status, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JSONStatus", reflect.TypeOf((*MockResp)(nil).JSONStatus), w, r, status, data)
+}
@@ -2,6 +2,7 @@ package rest
 
 import (
 	"boiler/pkg/errors"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -26,13 +27,22 @@ func (d DefaultResp) Fail(w http.ResponseWriter, r *http.Request, err error) {
 	}
 
 	resp := new(ErrResponse)
+	var status int
 
 	resp.Error.Msg = err.Error()
 	if errors.Is(err, errors.ErrBadRequest) {
-		w.WriteHeader(http.StatusBadRequest)
+		status = http.StatusBadRequest
+	} else if errors.Is(err, errors.ErrUnauthorized) {
+		status = http.StatusUnauthorized
+	} else if errors.Is(err, errors.ErrForbidden) {
+		status = http.StatusForbidden
+	} else if errors.Is(err, errors.ErrConflict) {
+		status = http.StatusConflict
+	} else if errors.Is(err, errors.ErrTooManyRequests) {
+		status = http.StatusTooManyRequests
 	} else {
 		log.Error().Err(err).Str("file", errors.Caller()).Send()
-		w.WriteHeader(http.StatusInternalServerError)
+		status = http.StatusInternalServerError
 		resp.Error.Codes = append(resp.Error.Codes, "internal_server_error")
 
 		if len(r.URL.Query()["debug"]) == 0 {
@@ -52,7 +62,7 @@ func (d DefaultResp) Fail(w http.ResponseWriter, r *http.Request, err error) {
 		break
 	}
 
-	d.JSON(w, r, resp)
+	d.JSONStatus(w, r, status, resp)
 }
 
 // FailF same as Fail, but with error format
@@ -63,12 +73,25 @@ func (d DefaultResp) Failf(w http.ResponseWriter, r *http.Request, format string
 // JSON writes the content of the param data as JSON.
 // if ?pretty is present, it will pretty print the response.
 func (d DefaultResp) JSON(w http.ResponseWriter, r *http.Request, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	e := json.NewEncoder(w)
+	d.JSONStatus(w, r, http.StatusOK, data)
+}
+
+// JSONStatus same as JSON, but with the status code of the response
+func (d DefaultResp) JSONStatus(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	// encoded before the headers are written so an encoding error still fails the response
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
 	if len(r.URL.Query()["pretty"]) != 0 {
 		e.SetIndent(" ", " ")
 	}
 	if err := e.Encode(data); err != nil {
 		d.Fail(w, r, fmt.Errorf("could not write json response; %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Error().Err(err).Msg("could not write json response")
 	}
 }
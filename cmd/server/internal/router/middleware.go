@@ -3,18 +3,21 @@ package router
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
+	"boiler/cmd/server/internal/rest"
 	"boiler/pkg/entity"
 	"boiler/pkg/errors"
+	"boiler/pkg/service"
 	"boiler/pkg/store/config"
 
 	"github.com/go-chi/chi/middleware"
-	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/rs/zerolog/log"
 )
@@ -51,22 +54,76 @@ func Recoverer(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// RequestIDMiddleware inject the ID of middleware.RequestID in the request context as config.ContextKeyRequestID,
+// the service keeps it in the jobs enqueued by the request
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		requestID := middleware.GetReqID(r.Context())
+		if len(requestID) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), config.ContextKeyRequestID{}, requestID)))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// ClientIPMiddleware inject the IP address of the client in the request context, it follows
+// middleware.RealIP which replaces the remote address by the one of the proxy headers
+func ClientIPMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip = host
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), config.ContextKeyClientIP{}, ip)))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// APIKeyAuthorization is the Authorization scheme of the API keys
+const APIKeyAuthorization = "ApiKey "
+
 // AuthUserMiddleware parse JWT Token and inject it back as a *entity.AuthUser from request if available
-func AuthUserMiddleware(cfg *config.Config) func(next http.Handler) http.Handler {
+// the tokens denied by a logout are ignored, the request is then anonymous
+// an `Authorization: ApiKey <key>` authenticates the user of the key, limited to its scopes
+func AuthUserMiddleware(cfg *config.Config, srv service.Interface) func(next http.Handler) http.Handler {
 	prefixLen := len("Bearer ")
 
 	return func(next http.Handler) http.Handler {
 
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if raw := r.Header.Get("Authorization"); len(raw) > prefixLen {
-				token, err := jwt.ParseString(raw[prefixLen:], jwt.WithVerify(jwa.RS256, &cfg.JWT.PrivateKey.PublicKey))
-				if err == nil && jwt.Verify(token) == nil {
+			raw := r.Header.Get("Authorization")
+			if strings.HasPrefix(raw, APIKeyAuthorization) {
+				var viewer entity.JWTUser
+				err := srv.AuthAPIKey(r.Context(), raw[len(APIKeyAuthorization):], &viewer)
+				if err == nil {
+					next.ServeHTTP(w, r.WithContext(
+						context.WithValue(r.Context(), config.ContextKeyAuthenticationUser{}, &viewer),
+					))
+					return
+				}
+
+				if !errors.Is(err, errors.ErrInvalidAPIKey) {
+					log.Error().Err(err).Msg("could not authenticate api key")
+				}
+			} else if len(raw) > prefixLen {
+				token, err := cfg.JWT.Keys.Parse(raw[prefixLen:])
+				if err == nil && service.HasAudience(token, service.AccessAudience) && !denied(r.Context(), srv, token.JwtID()) {
 					id, err := strconv.ParseInt(token.Subject(), 10, 64)
-					if err == nil {
+					if err == nil && !revoked(r.Context(), srv, id, token.IssuedAt()) {
 						next.ServeHTTP(w, r.WithContext(
 							context.WithValue(
 								r.Context(), config.ContextKeyAuthenticationUser{}, &entity.JWTUser{
-									ID: id,
+									ID:             id,
+									Roles:          roles(token),
+									TokenID:        token.JwtID(),
+									Expires:        token.Expiration(),
+									OrganizationID: organization(token),
 								},
 							),
 						))
@@ -80,3 +137,84 @@ func AuthUserMiddleware(cfg *config.Config) func(next http.Handler) http.Handler
 		return http.HandlerFunc(fn)
 	}
 }
+
+// denied tells if the token jti is in the deny list, a failing check denies the token
+func denied(ctx context.Context, srv service.Interface, jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	denied, err := srv.IsTokenDenied(ctx, jti)
+	if err != nil {
+		log.Error().Err(err).Msg("could not check denied token")
+		return true
+	}
+
+	return denied
+}
+
+// revoked tells if the tokens of the user issued before the token were revoked, a failing check revokes the token
+func revoked(ctx context.Context, srv service.Interface, userID int64, issuedAt time.Time) bool {
+	revoked, err := srv.IsTokenRevoked(ctx, userID, issuedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("could not check revoked token")
+		return true
+	}
+
+	return revoked
+}
+
+// organization return the organization claim of a token, zero if it has none
+func organization(token jwt.Token) int64 {
+	raw, ok := token.Get(service.OrganizationClaim)
+	if !ok {
+		return 0
+	}
+
+	// the numbers of the claims are decoded as float64
+	id, _ := raw.(float64)
+	return int64(id)
+}
+
+// roles return the roles claim of a token
+func roles(token jwt.Token) []string {
+	raw, ok := token.Get(service.RolesClaim)
+	if !ok {
+		return nil
+	}
+
+	list, _ := raw.([]interface{})
+	roles := make([]string, 0, len(list))
+	for _, v := range list {
+		if role, ok := v.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// RequireRole reject the requests of users without one of roles
+// anonymous requests fail with ErrUnauthorized and the others with ErrForbidden
+func RequireRole(roles ...string) func(next http.Handler) http.Handler {
+	resp := new(rest.DefaultResp)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			viewer, ok := service.Viewer(r.Context())
+			if !ok {
+				resp.Fail(w, r, errors.ErrUnauthorized)
+				return
+			}
+
+			if !viewer.HasRole(roles...) {
+				resp.Fail(w, r, errors.ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
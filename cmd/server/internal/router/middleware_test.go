@@ -0,0 +1,322 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"boiler/cmd/server/internal/rest"
+	"boiler/cmd/server/internal/router"
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+	"boiler/pkg/service"
+	"boiler/pkg/service/mock"
+	"boiler/pkg/store/config"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthUserMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, key)
+	cfg := &config.Config{JWT: config.JWT{Keys: keys}}
+	m := mock.NewMockInterface(ctrl)
+
+	issued := time.Now().Truncate(time.Second)
+	tok := jwt.New()
+	_ = tok.Set(jwt.SubjectKey, "4")
+	_ = tok.Set(jwt.IssuedAtKey, issued.Unix())
+	_ = tok.Set(jwt.ExpirationKey, time.Now().Add(time.Minute).Unix())
+	_ = tok.Set(jwt.JwtIDKey, "jti")
+	_ = tok.Set(jwt.AudienceKey, service.AccessAudience)
+	raw, err := keys.Sign(tok)
+	assert.Nil(t, err)
+
+	var viewer *entity.JWTUser
+	r := chi.NewRouter()
+	r.Use(router.AuthUserMiddleware(cfg, m))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		viewer, _ = service.Viewer(r.Context())
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	get := func(raw []byte) {
+		viewer = nil
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer "+string(raw))
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+	}
+
+	// succeed
+	{
+		m.EXPECT().IsTokenDenied(gomock.Any(), "jti").Return(false, nil)
+		m.EXPECT().IsTokenRevoked(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, issuedAt time.Time) (bool, error) {
+				assert.True(t, issued.Equal(issuedAt))
+				return false, nil
+			})
+
+		get(raw)
+		assert.NotNil(t, viewer)
+		assert.Equal(t, int64(4), viewer.ID)
+		assert.Equal(t, "jti", viewer.TokenID)
+		assert.Equal(t, int64(0), viewer.OrganizationID)
+	}
+
+	// succeed with the organization of the token
+	{
+		org := jwt.New()
+		_ = org.Set(jwt.SubjectKey, "4")
+		_ = org.Set(jwt.ExpirationKey, time.Now().Add(time.Minute).Unix())
+		_ = org.Set(jwt.JwtIDKey, "jti")
+		_ = org.Set(jwt.AudienceKey, service.AccessAudience)
+		_ = org.Set(service.OrganizationClaim, 3)
+		raw, err := keys.Sign(org)
+		assert.Nil(t, err)
+		m.EXPECT().IsTokenDenied(gomock.Any(), "jti").Return(false, nil)
+		m.EXPECT().IsTokenRevoked(gomock.Any(), int64(4), gomock.Any()).Return(false, nil)
+
+		get(raw)
+		assert.NotNil(t, viewer)
+		assert.Equal(t, int64(3), viewer.OrganizationID)
+	}
+
+	// anonymous if the token is denied
+	{
+		m.EXPECT().IsTokenDenied(gomock.Any(), "jti").Return(true, nil)
+
+		get(raw)
+		assert.Nil(t, viewer)
+	}
+
+	// anonymous if the tokens of the user are revoked
+	{
+		m.EXPECT().IsTokenDenied(gomock.Any(), "jti").Return(false, nil)
+		m.EXPECT().IsTokenRevoked(gomock.Any(), int64(4), gomock.Any()).Return(true, nil)
+
+		get(raw)
+		assert.Nil(t, viewer)
+	}
+
+	// anonymous if the revocation check fails
+	{
+		m.EXPECT().IsTokenDenied(gomock.Any(), "jti").Return(false, nil)
+		m.EXPECT().IsTokenRevoked(gomock.Any(), int64(4), gomock.Any()).Return(false, errors.New("opz"))
+
+		get(raw)
+		assert.Nil(t, viewer)
+	}
+
+	// anonymous if not an access token
+	{
+		other := jwt.New()
+		_ = other.Set(jwt.SubjectKey, "4")
+		_ = other.Set(jwt.ExpirationKey, time.Now().Add(time.Minute).Unix())
+		_ = other.Set(jwt.AudienceKey, service.VerifyEmailAudience)
+		raw, err := keys.Sign(other)
+		assert.Nil(t, err)
+
+		get(raw)
+		assert.Nil(t, viewer)
+	}
+
+	// anonymous if the deny list fails
+	{
+		m.EXPECT().IsTokenDenied(gomock.Any(), "jti").Return(false, errors.New("opz"))
+
+		get(raw)
+		assert.Nil(t, viewer)
+	}
+
+	apiKey := func(key string) {
+		viewer = nil
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		assert.Nil(t, err)
+		req.Header.Set("Authorization", "ApiKey "+key)
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+	}
+
+	// succeed with an api key
+	{
+		m.EXPECT().
+			AuthAPIKey(gomock.Any(), "bk_key", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, v *entity.JWTUser) error {
+				*v = entity.JWTUser{ID: 4, APIKeyID: 7, Scopes: []string{entity.ScopeUsersRead}}
+				return nil
+			})
+
+		apiKey("bk_key")
+		assert.NotNil(t, viewer)
+		assert.Equal(t, int64(4), viewer.ID)
+		assert.Equal(t, int64(7), viewer.APIKeyID)
+		assert.True(t, viewer.HasScope(entity.ScopeUsersRead))
+		assert.False(t, viewer.HasScope(entity.ScopeUsersWrite))
+	}
+
+	// anonymous if the api key is invalid
+	{
+		m.EXPECT().AuthAPIKey(gomock.Any(), "bk_key", gomock.Any()).Return(errors.ErrInvalidAPIKey)
+
+		apiKey("bk_key")
+		assert.Nil(t, viewer)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var requestID, expected interface{}
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(router.RequestIDMiddleware)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Context().Value(config.ContextKeyRequestID{})
+		expected = middleware.GetReqID(r.Context())
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// succeed with the ID of middleware.RequestID
+	res, err := http.Get(ts.URL)
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.NotEmpty(t, requestID)
+	assert.Equal(t, expected, requestID)
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	var ip string
+	r := chi.NewRouter()
+	r.Use(middleware.RealIP)
+	r.Use(router.ClientIPMiddleware)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		ip = service.ClientIP(r.Context())
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	get := func(realIP string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		assert.Nil(t, err)
+		if len(realIP) != 0 {
+			req.Header.Set("X-Real-IP", realIP)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+	}
+
+	// succeed with the remote address
+	{
+		get("")
+		assert.Equal(t, "127.0.0.1", ip)
+	}
+
+	// succeed with the address of the proxy header
+	{
+		get("1.2.3.4")
+		assert.Equal(t, "1.2.3.4", ip)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, key)
+	cfg := &config.Config{JWT: config.JWT{Keys: keys}}
+	m := mock.NewMockInterface(ctrl)
+	m.EXPECT().IsTokenRevoked(gomock.Any(), int64(4), gomock.Any()).Return(false, nil).AnyTimes()
+
+	token := func(roles ...string) string {
+		tok := jwt.New()
+		_ = tok.Set(jwt.SubjectKey, "4")
+		_ = tok.Set(jwt.ExpirationKey, time.Now().Add(time.Minute).Unix())
+		_ = tok.Set(jwt.AudienceKey, service.AccessAudience)
+		_ = tok.Set(service.RolesClaim, roles)
+
+		raw, err := keys.Sign(tok)
+		assert.Nil(t, err)
+		return string(raw)
+	}
+
+	r := chi.NewRouter()
+	r.Use(router.AuthUserMiddleware(cfg, m))
+	r.With(router.RequireRole(entity.RoleAdmin, entity.RoleSupport)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		viewer, _ := service.Viewer(r.Context())
+		_ = json.NewEncoder(w).Encode(viewer)
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	get := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		assert.Nil(t, err)
+		if len(token) != 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	// succeed with one of the roles
+	{
+		res := get(token(entity.RoleMember, entity.RoleSupport))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var viewer entity.JWTUser
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&viewer))
+		res.Body.Close()
+
+		assert.Equal(t, entity.JWTUser{ID: 4, Roles: []string{entity.RoleMember, entity.RoleSupport}}, viewer)
+	}
+
+	// fails without the roles
+	{
+		res := get(token(entity.RoleMember))
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		var resp rest.ErrResponse
+		assert.Nil(t, json.NewDecoder(res.Body).Decode(&resp))
+		res.Body.Close()
+
+		assert.Equal(t, []string{"forbidden"}, resp.Error.Codes)
+	}
+
+	// fails if anonymous
+	{
+		res := get("")
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		res.Body.Close()
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"boiler/cmd/server/internal/graphql"
 	"boiler/cmd/server/internal/rest"
 	"boiler/cmd/server/internal/website"
+	"boiler/pkg/entity"
 	"boiler/pkg/service"
 	"boiler/pkg/store/config"
 
@@ -27,7 +28,9 @@ func ApplyMiddlewares(r chi.Router, cfg *config.Config, service service.Interfac
 	r.Use(middleware.Timeout(5 * time.Second))
 
 	// custom middlewares
-	r.Use(AuthUserMiddleware(cfg))
+	r.Use(RequestIDMiddleware)
+	r.Use(ClientIPMiddleware)
+	r.Use(AuthUserMiddleware(cfg, service))
 
 	// Rest Debug
 	r.Use(func(next http.Handler) http.Handler {
@@ -47,10 +50,13 @@ func ApplyMiddlewares(r chi.Router, cfg *config.Config, service service.Interfac
 
 // ApplyRoute define the routes of the service
 func ApplyRoute(r chi.Router, service service.Interface) {
+	h := rest.New(service, new(rest.DefaultResp))
+
 	// website
 	r.Get("/", website.Handle)
 	r.Get("/favicon.ico", http.NotFound)
 	r.Handle("/static/*", http.FileServer(http.Dir("cmd/server/internal/website")))
+	r.Get("/.well-known/jwks.json", h.JWKS)
 
 	// graphql
 	r.Route("/graphql", func(g chi.Router) {
@@ -61,16 +67,45 @@ func ApplyRoute(r chi.Router, service service.Interface) {
 
 	// rest
 	r.Route("/rest", func(r chi.Router) {
-		h := rest.New(service, new(rest.DefaultResp))
-
 		r.Get("/users", h.ListUsers)
 		r.Post("/users", h.AddUser)
 		r.Get("/users/{userID:[0-9]+}", h.GetUser)
+		r.Patch("/users/{userID:[0-9]+}", h.UpdateUser)
+		r.Post("/users/{userID:[0-9]+}/password", h.ChangePassword)
+		r.Post("/users/{userID:[0-9]+}/totp", h.EnrollTOTP)
+		r.Post("/users/{userID:[0-9]+}/totp/confirm", h.ConfirmTOTP)
+		r.Get("/users/{userID:[0-9]+}/api-keys", h.ListAPIKeys)
+		r.Post("/users/{userID:[0-9]+}/api-keys", h.AddAPIKey)
+		r.Delete("/users/{userID:[0-9]+}/api-keys/{keyID:[0-9]+}", h.RevokeAPIKey)
+		r.Post("/users/password-reset", h.RequestPasswordReset)
+		r.Post("/users/password-reset/confirm", h.ResetPassword)
 		r.Delete("/users/{userID:[0-9]+}", h.DeleteUser)
+		r.Post("/users/{userID:[0-9]+}/restore", h.RestoreUser)
+		r.Get("/users/{userID:[0-9]+}/roles", h.GetUserRoles)
+		r.With(RequireRole(entity.RoleAdmin)).Put("/users/{userID:[0-9]+}/roles", h.SetUserRoles)
 		r.Post("/users/login", h.AuthUser)
+		r.Post("/auth/refresh", h.RefreshToken)
+		r.Post("/auth/logout", h.Logout)
+		r.Post("/auth/organization", h.SwitchOrganization)
+
+		r.Post("/organizations", h.AddOrganization)
+		r.Get("/users/{userID:[0-9]+}/organizations", h.ListOrganizations)
+		r.Get("/organizations/{orgID:[0-9]+}/members", h.ListMembers)
+		r.Post("/organizations/{orgID:[0-9]+}/invitations", h.InviteMember)
+		r.Post("/organizations/invitations/accept", h.AcceptInvitation)
 
 		r.Get("/emails", h.ListEmails)
 		r.Post("/emails", h.AddEmail)
+		r.Post("/emails/verify", h.VerifyEmail)
 		r.Delete("/emails/{emailID:[0-9]+}", h.DeleteEmail)
+		r.Post("/emails/{emailID:[0-9]+}/restore", h.RestoreEmail)
+
+		r.Get("/jobs/dead", h.ListDeadJobs)
+		r.Post("/jobs/dead/retry", h.RetryDeadJobs)
+		r.Delete("/jobs/dead", h.DeleteDeadJobs)
+		r.Post("/jobs/dead/{diedAt:[0-9]+}/{jobID}/retry", h.RetryDeadJob)
+		r.Delete("/jobs/dead/{diedAt:[0-9]+}/{jobID}", h.DeleteDeadJob)
+		r.Get("/jobs/{jobID}", h.GetJob)
+		r.Get("/schedules", h.ListSchedules)
 	})
 }
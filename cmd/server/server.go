@@ -11,6 +11,8 @@ import (
 
 	"boiler/cmd"
 	"boiler/cmd/server/internal/router"
+	workerRouter "boiler/cmd/worker/router"
+	"boiler/pkg/keyset"
 	"boiler/pkg/store/config"
 
 	"github.com/go-chi/chi"
@@ -20,11 +22,30 @@ import (
 
 func main() {
 	var port = flag.Int("port", 2000, "")
+	var worker = flag.Bool("worker", false, "run the worker in the server")
 
 	flag.Parse()
 
 	cfg := config.New()
-	sv, _ := cmd.New(cfg)
+	sv, q := cmd.New(cfg)
+
+	rotateCtx, stopRotate := context.WithCancel(context.Background())
+	defer stopRotate()
+	if cfg.JWT.RotateEvery > 0 {
+		go cfg.JWT.Keys.RotateEvery(rotateCtx, cfg.JWT.RotateEvery, func() (keyset.Key, error) {
+			return keyset.Generate(cfg.JWT.Algorithm)
+		})
+	}
+
+	if *worker {
+		pool := q.NewPool(cfg.Worker.Concurrency)
+		workerRouter.ApplyMiddlewares(pool, cfg, sv)
+		workerRouter.ApplyRoute(pool, cfg, sv)
+
+		log.Info().Msg("[worker] Listening...")
+		pool.Start()
+		defer pool.Stop()
+	}
 
 	r := chi.NewRouter()
 	router.ApplyMiddlewares(r, cfg, sv)
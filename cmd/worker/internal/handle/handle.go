@@ -1,9 +1,11 @@
 package handle
 
 import (
-	"boiler/pkg/service"
 	"context"
 
+	"boiler/pkg/entity"
+	"boiler/pkg/service"
+
 	"github.com/gocraft/work"
 )
 
@@ -15,10 +17,34 @@ type Handle struct {
 	service service.Interface
 }
 
-func (h *Handle) DeleteUser(j *work.Job) error {
-	return h.service.DeleteUser(context.Background(), j.ArgInt64("id"))
+func (h *Handle) DeleteUser(ctx context.Context, p *entity.DeleteUserPayload) error {
+	return h.service.DeleteUser(ctx, p.ID)
+}
+
+func (h *Handle) DeleteEmail(ctx context.Context, p *entity.DeleteEmailPayload) error {
+	return h.service.DeleteEmail(ctx, p.ID)
+}
+
+func (h *Handle) PurgeDeleted(ctx context.Context, j *work.Job) error {
+	return h.service.PurgeDeleted(ctx)
+}
+
+func (h *Handle) PurgeTokens(ctx context.Context, j *work.Job) error {
+	return h.service.PurgeTokens(ctx)
+}
+
+func (h *Handle) PurgeOrphanedEmails(ctx context.Context, j *work.Job) error {
+	return h.service.PurgeOrphanedEmails(ctx)
+}
+
+func (h *Handle) SendVerifyEmail(ctx context.Context, p *entity.SendVerifyEmailPayload) error {
+	return h.service.SendVerifyEmail(ctx, p.ID)
+}
+
+func (h *Handle) SendPasswordReset(ctx context.Context, p *entity.SendPasswordResetPayload) error {
+	return h.service.SendPasswordReset(ctx, p.Email)
 }
 
-func (h *Handle) DeleteEmail(j *work.Job) error {
-	return h.service.DeleteEmail(context.Background(), j.ArgInt64("id"))
+func (h *Handle) SendEmail(ctx context.Context, p *entity.SendEmailPayload) error {
+	return h.service.SendEmail(ctx, p.To, p.Template, p.Data)
 }
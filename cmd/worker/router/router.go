@@ -0,0 +1,139 @@
+// Package router registers the jobs of the worker on a pool, for the worker and the server running it
+package router
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"boiler/cmd/worker/internal/handle"
+	"boiler/pkg/entity"
+	"boiler/pkg/service"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/rs/zerolog/log"
+)
+
+// ApplyMiddlewares restore the request and the user who enqueued the jobs, log the jobs, track the status of
+// the jobs enqueued with an ID and record the runs of the scheduled jobs
+func ApplyMiddlewares(pool queue.Pool, cfg *config.Config, sv service.Interface) {
+	pool.Middleware(func(ctx context.Context, j *work.Job, next queue.Handler) error {
+		ctx = service.JobContext(ctx, j.Args)
+
+		logger := log.With().Str("job", j.Name).Str("id", j.ID)
+		if requestID, ok := ctx.Value(config.ContextKeyRequestID{}).(string); ok {
+			logger = logger.Str("request_id", requestID)
+		}
+		if userID, ok := ctx.Value(config.ContextKeyJobUserID{}).(int64); ok {
+			logger = logger.Int64("user_id", userID)
+		}
+		l := logger.Logger()
+
+		return next(l.WithContext(ctx), j)
+	})
+
+	pool.Middleware(func(ctx context.Context, j *work.Job, next queue.Handler) error {
+		start := time.Now()
+		log.Ctx(ctx).Info().Msg("starting...")
+		defer func() {
+			log.Ctx(ctx).Info().Str("duration", time.Since(start).String()).Msg("finished")
+		}()
+
+		return next(ctx, j)
+	})
+
+	// track the status of the jobs enqueued with an ID, a failed run queues the job again until it is dead, the
+	// status is written even if ctx is done
+	pool.Middleware(func(ctx context.Context, j *work.Job, next queue.Handler) error {
+		jobID, _ := j.Args[service.JobIDArg].(string)
+		if len(jobID) == 0 {
+			return next(ctx, j)
+		}
+
+		if err := sv.SetJobStatus(context.Background(), jobID, entity.JobRunning, nil); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("job_id", jobID).Msg("could not track job")
+		}
+
+		err := next(ctx, j)
+
+		status := entity.JobSucceeded
+		switch {
+		case err != nil && dead(cfg, j, err):
+			status = entity.JobFailed
+		case err != nil:
+			status = entity.JobQueued
+		}
+		if serr := sv.SetJobStatus(context.Background(), jobID, status, err); serr != nil {
+			log.Ctx(ctx).Error().Err(serr).Str("job_id", jobID).Msg("could not track job")
+		}
+
+		return err
+	})
+
+	// record the runs of the scheduled jobs
+	scheduled := make(map[string]bool, len(cfg.Worker.Schedules))
+	for _, schedule := range cfg.Worker.Schedules {
+		scheduled[schedule.Job] = true
+	}
+	pool.Middleware(func(ctx context.Context, j *work.Job, next queue.Handler) error {
+		if !scheduled[j.Name] {
+			return next(ctx, j)
+		}
+
+		start := time.Now()
+		err := next(ctx, j)
+		if rerr := sv.RecordScheduleRun(context.Background(), j.Name, start, err); rerr != nil {
+			log.Ctx(ctx).Error().Err(rerr).Msg("could not record schedule run")
+		}
+
+		return err
+	})
+}
+
+// dead report whether the failed run of j is its last one, j.Fails does not count it yet
+func dead(cfg *config.Config, j *work.Job, err error) bool {
+	maxFails := int64(cfg.Worker.Retries[j.Name].MaxFails)
+	if maxFails == 0 {
+		maxFails = queue.DefaultMaxFails
+	}
+
+	return errors.Is(err, queue.ErrDead) || j.Fails+1 >= maxFails
+}
+
+// ApplyRoute register the handlers of the jobs and enqueue the schedules of the config
+func ApplyRoute(pool queue.Pool, cfg *config.Config, sv service.Interface) {
+	handler := handle.New(sv)
+
+	queue.JobWithPayload(pool, service.DeleteUser, options(cfg, service.DeleteUser, 10), handler.DeleteUser)
+	queue.JobWithPayload(pool, service.DeleteEmail, options(cfg, service.DeleteEmail, 10), handler.DeleteEmail)
+	queue.JobWithPayload(pool, service.SendVerifyEmail, options(cfg, service.SendVerifyEmail, 5),
+		handler.SendVerifyEmail)
+	queue.JobWithPayload(pool, service.SendEmail, options(cfg, service.SendEmail, 5), handler.SendEmail)
+	queue.JobWithPayload(pool, service.SendPasswordReset, options(cfg, service.SendPasswordReset, 5),
+		handler.SendPasswordReset)
+	pool.JobWithOptions(service.PurgeDeleted, options(cfg, service.PurgeDeleted, 1), handler.PurgeDeleted)
+	pool.JobWithOptions(service.PurgeTokens, options(cfg, service.PurgeTokens, 1), handler.PurgeTokens)
+	pool.JobWithOptions(service.PurgeOrphanedEmails, options(cfg, service.PurgeOrphanedEmails, 1),
+		handler.PurgeOrphanedEmails)
+
+	for _, schedule := range cfg.Worker.Schedules {
+		pool.PeriodicallyEnqueue(schedule.Spec, schedule.Job)
+	}
+}
+
+// options return the options of the job with its retry policy and its timeout, the jobs without a policy retry
+// like gocraft/work
+func options(cfg *config.Config, name string, priority uint) queue.JobOptions {
+	retry := cfg.Worker.Retries[name]
+	opts := queue.JobOptions{
+		JobOptions: work.JobOptions{Priority: priority, MaxFails: retry.MaxFails},
+		Timeout:    cfg.Worker.Timeouts[name],
+	}
+	if retry.Backoff > 0 {
+		opts.Backoff = queue.ExponentialBackoff(retry.Backoff)
+	}
+
+	return opts
+}
@@ -0,0 +1,101 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"boiler/cmd/worker/router"
+	"boiler/pkg/entity"
+	"boiler/pkg/service"
+	"boiler/pkg/service/mock"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// pool runs the jobs through its middlewares when asked
+type pool struct {
+	queue.Pool
+	middlewares []queue.Middleware
+}
+
+func (p *pool) Middleware(fn queue.Middleware) {
+	p.middlewares = append(p.middlewares, fn)
+}
+
+func (p *pool) run(j *work.Job, fn queue.Handler) error {
+	handler := fn
+	for i := len(p.middlewares) - 1; i >= 0; i-- {
+		middleware, next := p.middlewares[i], handler
+		handler = func(ctx context.Context, j *work.Job) error {
+			return middleware(ctx, j, next)
+		}
+	}
+
+	return handler(context.Background(), j)
+}
+
+func TestJobStatusMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	p := &pool{}
+	router.ApplyMiddlewares(p, &config.Config{Worker: config.Worker{Retries: map[string]config.Retry{
+		service.DeleteUser: {MaxFails: 3},
+	}}}, m)
+
+	job := func(name string, fails int64) *work.Job {
+		return &work.Job{ID: "a1", Name: name, Fails: fails, Args: map[string]interface{}{service.JobIDArg: "j1"}}
+	}
+	opz := fmt.Errorf("opz")
+
+	// succeed
+	{
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobRunning, nil).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobSucceeded, nil).Return(nil)
+
+		assert.Nil(t, p.run(job(service.DeleteUser, 0), func(context.Context, *work.Job) error { return nil }))
+	}
+
+	// queued again if the job has retries left
+	{
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobRunning, nil).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobQueued, opz).Return(nil)
+
+		assert.Equal(t, opz, p.run(job(service.DeleteUser, 1), func(context.Context, *work.Job) error { return opz }))
+	}
+
+	// failed if the job is out of retries
+	{
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobRunning, nil).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobFailed, opz).Return(nil)
+
+		assert.Equal(t, opz, p.run(job(service.DeleteUser, 2), func(context.Context, *work.Job) error { return opz }))
+	}
+
+	// failed if the job is dead
+	{
+		err := queue.Dead(opz)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobRunning, nil).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobFailed, err).Return(nil)
+
+		assert.Equal(t, err, p.run(job(service.DeleteUser, 0), func(context.Context, *work.Job) error { return err }))
+	}
+
+	// failed after the default max fails of a job without a policy
+	{
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobRunning, nil).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobQueued, opz).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobRunning, nil).Return(nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), "j1", entity.JobFailed, opz).Return(nil)
+
+		fail := func(context.Context, *work.Job) error { return opz }
+		assert.Equal(t, opz, p.run(job(service.SendEmail, queue.DefaultMaxFails-2), fail))
+		assert.Equal(t, opz, p.run(job(service.SendEmail, queue.DefaultMaxFails-1), fail))
+	}
+}
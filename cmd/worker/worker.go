@@ -3,38 +3,22 @@ package main
 import (
 	"os"
 	"os/signal"
-	"time"
 
 	"boiler/cmd"
-	"boiler/cmd/worker/internal/handle"
-	"boiler/pkg/service"
+	"boiler/cmd/worker/router"
 	"boiler/pkg/store/config"
 
-	"github.com/gocraft/work"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 
 	cfg := config.New()
-	sv, redisPool := cmd.New(cfg)
+	sv, q := cmd.New(cfg)
 
-	handler := handle.New(sv)
-
-	pool := work.NewWorkerPool(handler, cfg.Worker.Concurrency, "all", redisPool)
-
-	// middleware
-	pool.Middleware(func(j *work.Job, next work.NextMiddlewareFunc) error {
-		start := time.Now()
-		log.Info().Str("job", j.Name).Msg("starting...")
-		defer log.Info().Str("job", j.Name).Str("duration", time.Since(start).String()).Msg("finished")
-
-		return next()
-	})
-
-	// Route
-	pool.JobWithOptions(service.DeleteUser, work.JobOptions{Priority: 10, MaxFails: 1}, handler.DeleteUser)
-	pool.JobWithOptions(service.DeleteEmail, work.JobOptions{Priority: 10, MaxFails: 1}, handler.DeleteEmail)
+	pool := q.NewPool(cfg.Worker.Concurrency)
+	router.ApplyMiddlewares(pool, cfg, sv)
+	router.ApplyRoute(pool, cfg, sv)
 
 	// Start worker
 	log.Info().Msg("[worker] Listening...")
@@ -12,14 +12,15 @@ require (
 	github.com/golangci/golangci-lint v1.37.1
 	github.com/gomodule/redigo v1.8.2
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
-	github.com/lestrrat-go/jwx v1.0.4
+	github.com/lestrrat-go/jwx v1.1.0
+	github.com/lib/pq v1.9.0
 	github.com/mattn/go-sqlite3 v1.14.1
 	github.com/mitchellh/mapstructure v1.3.3 // indirect
 	github.com/rafaelsq/wtc v1.0.8
-	github.com/robfig/cron v1.2.0 // indirect
+	github.com/robfig/cron v1.2.0
 	github.com/rs/zerolog v1.15.0
 	github.com/stretchr/testify v1.7.0
 	github.com/tinylib/msgp v1.1.5
 	github.com/vektah/gqlparser/v2 v2.1.0
-	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/crypto v0.0.0-20201217014255-9d1352758620
 )
@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// scopes of the API keys, a key only authorizes the actions of its scopes
+const (
+	ScopeUsersRead    = "users:read"
+	ScopeUsersWrite   = "users:write"
+	ScopeEmailsRead   = "emails:read"
+	ScopeEmailsWrite  = "emails:write"
+	ScopeAPIKeysRead  = "api_keys:read"
+	ScopeAPIKeysWrite = "api_keys:write"
+
+	ScopeOrganizationsRead  = "organizations:read"
+	ScopeOrganizationsWrite = "organizations:write"
+)
+
+// Scopes are the scopes an API key can have
+var Scopes = []string{
+	ScopeUsersRead, ScopeUsersWrite, ScopeEmailsRead, ScopeEmailsWrite, ScopeAPIKeysRead, ScopeAPIKeysWrite,
+	ScopeOrganizationsRead, ScopeOrganizationsWrite,
+}
+
+// APIKey is a personal API key of an user, only the hash of the key is kept
+// the prefix starts the key, it identifies the key in the lists without revealing it
+type APIKey struct {
+	ID      int64     `json:"id"`
+	UserID  int64     `json:"user_id"`
+	Name    string    `json:"name"`
+	Prefix  string    `json:"prefix"`
+	Hash    string    `json:"-"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+	// Expires is nil for a key valid until it is revoked
+	Expires  *time.Time `json:"expires"`
+	LastUsed *time.Time `json:"last_used"`
+	Revoked  bool       `json:"revoked"`
+}
+
+// HasScope tells if the key has the scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, have := range k.Scopes {
+		if have == scope {
+			return true
+		}
+	}
+
+	return false
+}
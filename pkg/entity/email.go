@@ -10,4 +10,8 @@ type Email struct {
 	UserID  int64     `json:"user_id"`
 	Address string    `json:"address"`
 	Created time.Time `json:"created"`
+	// VerifiedAt is nil until the user proves owning the address
+	VerifiedAt *time.Time `json:"verified_at"`
+	// DeletedAt is set while the email is deleted and can still be restored
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
@@ -3,6 +3,8 @@ package entity
 // Code generated by github.com/tinylib/msgp DO NOT EDIT.
 
 import (
+	"time"
+
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -48,6 +50,42 @@ func (z *Email) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "Created")
 				return
 			}
+		case "VerifiedAt":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "VerifiedAt")
+					return
+				}
+				z.VerifiedAt = nil
+			} else {
+				if z.VerifiedAt == nil {
+					z.VerifiedAt = new(time.Time)
+				}
+				*z.VerifiedAt, err = dc.ReadTime()
+				if err != nil {
+					err = msgp.WrapError(err, "VerifiedAt")
+					return
+				}
+			}
+		case "DeletedAt":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "DeletedAt")
+					return
+				}
+				z.DeletedAt = nil
+			} else {
+				if z.DeletedAt == nil {
+					z.DeletedAt = new(time.Time)
+				}
+				*z.DeletedAt, err = dc.ReadTime()
+				if err != nil {
+					err = msgp.WrapError(err, "DeletedAt")
+					return
+				}
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -61,9 +99,9 @@ func (z *Email) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Email) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 4
+	// map header, size 6
 	// write "ID"
-	err = en.Append(0x84, 0xa2, 0x49, 0x44)
+	err = en.Append(0x86, 0xa2, 0x49, 0x44)
 	if err != nil {
 		return
 	}
@@ -102,15 +140,49 @@ func (z *Email) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "Created")
 		return
 	}
+	// write "VerifiedAt"
+	err = en.Append(0xaa, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x41, 0x74)
+	if err != nil {
+		return
+	}
+	if z.VerifiedAt == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = en.WriteTime(*z.VerifiedAt)
+		if err != nil {
+			err = msgp.WrapError(err, "VerifiedAt")
+			return
+		}
+	}
+	// write "DeletedAt"
+	err = en.Append(0xa9, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x41, 0x74)
+	if err != nil {
+		return
+	}
+	if z.DeletedAt == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = en.WriteTime(*z.DeletedAt)
+		if err != nil {
+			err = msgp.WrapError(err, "DeletedAt")
+			return
+		}
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Email) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 4
+	// map header, size 6
 	// string "ID"
-	o = append(o, 0x84, 0xa2, 0x49, 0x44)
+	o = append(o, 0x86, 0xa2, 0x49, 0x44)
 	o = msgp.AppendInt64(o, z.ID)
 	// string "UserID"
 	o = append(o, 0xa6, 0x55, 0x73, 0x65, 0x72, 0x49, 0x44)
@@ -121,6 +193,20 @@ func (z *Email) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "Created"
 	o = append(o, 0xa7, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64)
 	o = msgp.AppendTime(o, z.Created)
+	// string "VerifiedAt"
+	o = append(o, 0xaa, 0x56, 0x65, 0x72, 0x69, 0x66, 0x69, 0x65, 0x64, 0x41, 0x74)
+	if z.VerifiedAt == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o = msgp.AppendTime(o, *z.VerifiedAt)
+	}
+	// string "DeletedAt"
+	o = append(o, 0xa9, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x41, 0x74)
+	if z.DeletedAt == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o = msgp.AppendTime(o, *z.DeletedAt)
+	}
 	return
 }
 
@@ -166,6 +252,40 @@ func (z *Email) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "Created")
 				return
 			}
+		case "VerifiedAt":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.VerifiedAt = nil
+			} else {
+				if z.VerifiedAt == nil {
+					z.VerifiedAt = new(time.Time)
+				}
+				*z.VerifiedAt, bts, err = msgp.ReadTimeBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "VerifiedAt")
+					return
+				}
+			}
+		case "DeletedAt":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.DeletedAt = nil
+			} else {
+				if z.DeletedAt == nil {
+					z.DeletedAt = new(time.Time)
+				}
+				*z.DeletedAt, bts, err = msgp.ReadTimeBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "DeletedAt")
+					return
+				}
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -180,6 +300,17 @@ func (z *Email) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Email) Msgsize() (s int) {
-	s = 1 + 3 + msgp.Int64Size + 7 + msgp.Int64Size + 8 + msgp.StringPrefixSize + len(z.Address) + 8 + msgp.TimeSize
+	s = 1 + 3 + msgp.Int64Size + 7 + msgp.Int64Size + 8 + msgp.StringPrefixSize + len(z.Address) + 8 + msgp.TimeSize + 11
+	if z.VerifiedAt == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.TimeSize
+	}
+	s += 10
+	if z.DeletedAt == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.TimeSize
+	}
 	return
 }
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// statuses of a tracked job
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is the status of a job of the worker, UserID is the user who enqueued it
+// Error is the error of the last failed run
+type Job struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	UserID  int64     `json:"user_id"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// DeadJob is a job of the worker which failed its last retry, DiedAt and ID find it
+// Error is the error of its last run, Args only holds the job, request and user IDs
+type DeadJob struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Args       map[string]interface{} `json:"args"`
+	Fails      int64                  `json:"fails"`
+	Error      string                 `json:"error"`
+	EnqueuedAt time.Time              `json:"enqueued_at"`
+	DiedAt     time.Time              `json:"died_at"`
+}
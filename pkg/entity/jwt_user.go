@@ -1,5 +1,44 @@
 package entity
 
+import "time"
+
 type JWTUser struct {
-	ID int64 `json:"id"`
+	ID    int64    `json:"id"`
+	Roles []string `json:"roles"`
+	// TokenID is the jti of the access token, it is denied once the user logs out
+	TokenID string    `json:"-"`
+	Expires time.Time `json:"-"`
+	// APIKeyID is the key authenticating the request, its scopes limit what the roles grant
+	APIKeyID int64    `json:"-"`
+	Scopes   []string `json:"-"`
+	// OrganizationID is the current organization of the user, the lists only show its members
+	OrganizationID int64 `json:"-"`
+}
+
+// HasRole tells if the user has one of roles
+func (u JWTUser) HasRole(roles ...string) bool {
+	for _, have := range u.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasScope tells if the user can act within scope, only the API keys are limited by scopes
+func (u JWTUser) HasScope(scope string) bool {
+	if u.APIKeyID == 0 {
+		return true
+	}
+
+	for _, have := range u.Scopes {
+		if have == scope {
+			return true
+		}
+	}
+
+	return false
 }
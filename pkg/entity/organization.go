@@ -0,0 +1,33 @@
+package entity
+
+import "time"
+
+// roles of the members of an organization, they only apply within the organization
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// OrgRoles are the roles a member can have
+var OrgRoles = []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleMember}
+
+// Organization is a customer, the users are its members and only see the users of their organization
+type Organization struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// Member is the membership of an user in an organization
+type Member struct {
+	OrganizationID int64     `json:"organization_id"`
+	UserID         int64     `json:"user_id"`
+	Role           string    `json:"role"`
+	Created        time.Time `json:"created"`
+}
+
+// CanInvite tells if the member can invite new members
+func (m Member) CanInvite() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
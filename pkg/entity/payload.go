@@ -0,0 +1,170 @@
+//go:generate go run github.com/tinylib/msgp -tests=false
+package entity
+
+import "boiler/pkg/errors"
+
+// versions of the job payloads, a change of a payload bumps its version and upgrades the older ones in Upgrade
+const (
+	DeleteUserPayloadVersion      = 1
+	DeleteEmailPayloadVersion     = 1
+	SendVerifyEmailPayloadVersion = 1
+	SendEmailPayloadVersion       = 1
+
+	SendPasswordResetPayloadVersion = 1
+)
+
+// ErrInvalidPayloadVersion is the error of a payload of an unknown version
+var ErrInvalidPayloadVersion = errors.AddCodeWithMessage(errors.ErrBadRequest, "invalid_payload_version",
+	"invalid payload version")
+
+// DeleteUserPayload is the payload of the job deleting a user
+type DeleteUserPayload struct {
+	Version int   `msg:"version"`
+	ID      int64 `msg:"id"`
+}
+
+// Upgrade read the untyped args of the jobs enqueued before the payloads
+func (p *DeleteUserPayload) Upgrade(args map[string]interface{}) error {
+	if p.Version == 0 {
+		p.Version, p.ID = DeleteUserPayloadVersion, argInt64(args, "id")
+	}
+
+	return nil
+}
+
+// Validate fails if the version or the user ID is invalid
+func (p *DeleteUserPayload) Validate() error {
+	if p.Version != DeleteUserPayloadVersion {
+		return ErrInvalidPayloadVersion
+	}
+	if p.ID <= 0 {
+		return errors.ErrInvalidUserID
+	}
+
+	return nil
+}
+
+// DeleteEmailPayload is the payload of the job deleting an email
+type DeleteEmailPayload struct {
+	Version int   `msg:"version"`
+	ID      int64 `msg:"id"`
+}
+
+// Upgrade read the untyped args of the jobs enqueued before the payloads
+func (p *DeleteEmailPayload) Upgrade(args map[string]interface{}) error {
+	if p.Version == 0 {
+		p.Version, p.ID = DeleteEmailPayloadVersion, argInt64(args, "id")
+	}
+
+	return nil
+}
+
+// Validate fails if the version or the email ID is invalid
+func (p *DeleteEmailPayload) Validate() error {
+	if p.Version != DeleteEmailPayloadVersion {
+		return ErrInvalidPayloadVersion
+	}
+	if p.ID <= 0 {
+		return errors.ErrInvalidEmailID
+	}
+
+	return nil
+}
+
+// SendVerifyEmailPayload is the payload of the job sending the message verifying an email
+type SendVerifyEmailPayload struct {
+	Version int   `msg:"version"`
+	ID      int64 `msg:"id"`
+}
+
+// Upgrade read the untyped args of the jobs enqueued before the payloads
+func (p *SendVerifyEmailPayload) Upgrade(args map[string]interface{}) error {
+	if p.Version == 0 {
+		p.Version, p.ID = SendVerifyEmailPayloadVersion, argInt64(args, "id")
+	}
+
+	return nil
+}
+
+// Validate fails if the version or the email ID is invalid
+func (p *SendVerifyEmailPayload) Validate() error {
+	if p.Version != SendVerifyEmailPayloadVersion {
+		return ErrInvalidPayloadVersion
+	}
+	if p.ID <= 0 {
+		return errors.ErrInvalidEmailID
+	}
+
+	return nil
+}
+
+// SendEmailPayload is the payload of the job sending the message Template to To, Data holds the values of the
+// template
+type SendEmailPayload struct {
+	Version  int                    `msg:"version"`
+	To       string                 `msg:"to"`
+	Template string                 `msg:"template"`
+	Data     map[string]interface{} `msg:"data"`
+}
+
+// Upgrade read the untyped args of the jobs enqueued before the payloads
+func (p *SendEmailPayload) Upgrade(args map[string]interface{}) error {
+	if p.Version == 0 {
+		p.Version = SendEmailPayloadVersion
+		p.To, _ = args["to"].(string)
+		p.Template, _ = args["template"].(string)
+		p.Data, _ = args["data"].(map[string]interface{})
+	}
+
+	return nil
+}
+
+// Validate fails if the version, the address or the template is missing
+func (p *SendEmailPayload) Validate() error {
+	if p.Version != SendEmailPayloadVersion {
+		return ErrInvalidPayloadVersion
+	}
+	if len(p.To) == 0 {
+		return errors.ErrInvalidEmailAddress
+	}
+	if len(p.Template) == 0 {
+		return errors.AddCode(errors.ErrBadRequest, "invalid_template")
+	}
+
+	return nil
+}
+
+// SendPasswordResetPayload is the payload of the job sending a password reset to the user of Email, if any
+type SendPasswordResetPayload struct {
+	Version int    `msg:"version"`
+	Email   string `msg:"email"`
+}
+
+// Upgrade do nothing, the job came with the payloads
+func (p *SendPasswordResetPayload) Upgrade(args map[string]interface{}) error {
+	return nil
+}
+
+// Validate fails if the version or the address is missing
+func (p *SendPasswordResetPayload) Validate() error {
+	if p.Version != SendPasswordResetPayloadVersion {
+		return ErrInvalidPayloadVersion
+	}
+	if len(p.Email) == 0 {
+		return errors.ErrInvalidEmailAddress
+	}
+
+	return nil
+}
+
+// argInt64 return the integer arg of key, the args read from JSON hold numbers as float64
+func argInt64(args map[string]interface{}, key string) int64 {
+	switch v := args[key].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+
+	return 0
+}
@@ -0,0 +1,28 @@
+package entity
+
+// names of the roles created by the migrations
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleMember  = "member"
+)
+
+// PermissionAll is the permission granting every action
+const PermissionAll = "*"
+
+// Role is a named set of permissions, a permission is the name of an action of the service
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// Can tells if the role grants the action
+func (r Role) Can(action string) bool {
+	for _, p := range r.Permissions {
+		if p == PermissionAll || p == action {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// Schedule is a job the worker enqueues at the times of a cron spec with seconds
+// LastRun and LastStatus are the ones of its last run, nil and empty if it never ran
+type Schedule struct {
+	Job        string     `json:"job"`
+	Spec       string     `json:"spec"`
+	LastRun    *time.Time `json:"last_run"`
+	LastStatus string     `json:"last_status,omitempty"`
+	NextRun    time.Time  `json:"next_run"`
+}
@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+// Tokens are returned by an authentication
+// the access token authenticates the requests, the refresh token is exchanged for new tokens
+// when the user has a second factor the authentication only returns a challenge, it is exchanged
+// with a code for the tokens
+type Tokens struct {
+	Access    string `json:"token"`
+	Refresh   string `json:"refresh_token"`
+	Challenge string `json:"challenge,omitempty"`
+}
+
+// RefreshToken is a stored refresh token, only the hash of the token is kept
+// the tokens rotated from the same authentication share a family
+type RefreshToken struct {
+	ID      int64     `json:"id"`
+	UserID  int64     `json:"user_id"`
+	Family  string    `json:"family"`
+	Hash    string    `json:"-"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// Used is set once the token was exchanged, using it again revokes the family
+	Used    bool `json:"used"`
+	Revoked bool `json:"revoked"`
+	// OrganizationID is the organization of the access tokens issued with it
+	OrganizationID int64 `json:"organization_id"`
+}
+
+// PasswordReset is a stored password reset token, only the hash of the token is kept
+type PasswordReset struct {
+	ID      int64     `json:"id"`
+	UserID  int64     `json:"user_id"`
+	Hash    string    `json:"-"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// Used is set once the password was reset, a token resets once
+	Used bool `json:"used"`
+}
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+// TOTP is the second factor of an user, it is required to authenticate once confirmed
+type TOTP struct {
+	UserID int64  `json:"user_id"`
+	Secret string `json:"-"`
+	// Confirmed is set once the user proved the app has the secret
+	Confirmed bool `json:"confirmed"`
+	// Counter is the last time step used, a code authenticates once
+	Counter int64     `json:"-"`
+	Created time.Time `json:"created"`
+}
+
+// TOTPEnrollment is returned by the enrollment, the URI is displayed as a QR code for the apps
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCode is a stored recovery code, only the hash of the code is kept
+// a recovery code replaces a TOTP code once, when the app is lost
+type RecoveryCode struct {
+	ID      int64     `json:"id"`
+	UserID  int64     `json:"user_id"`
+	Hash    string    `json:"-"`
+	Created time.Time `json:"created"`
+	Used    bool      `json:"used"`
+}
@@ -11,4 +11,6 @@ type User struct {
 	Password string    `json:"-"`
 	Created  time.Time `json:"created"`
 	Updated  time.Time `json:"updated"`
+	// DeletedAt is set while the user is deleted and can still be restored
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
@@ -3,6 +3,8 @@ package entity
 // Code generated by github.com/tinylib/msgp DO NOT EDIT.
 
 import (
+	"time"
+
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -54,6 +56,24 @@ func (z *User) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "Updated")
 				return
 			}
+		case "DeletedAt":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "DeletedAt")
+					return
+				}
+				z.DeletedAt = nil
+			} else {
+				if z.DeletedAt == nil {
+					z.DeletedAt = new(time.Time)
+				}
+				*z.DeletedAt, err = dc.ReadTime()
+				if err != nil {
+					err = msgp.WrapError(err, "DeletedAt")
+					return
+				}
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -67,9 +87,9 @@ func (z *User) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *User) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 5
+	// map header, size 6
 	// write "ID"
-	err = en.Append(0x85, 0xa2, 0x49, 0x44)
+	err = en.Append(0x86, 0xa2, 0x49, 0x44)
 	if err != nil {
 		return
 	}
@@ -118,15 +138,32 @@ func (z *User) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err, "Updated")
 		return
 	}
+	// write "DeletedAt"
+	err = en.Append(0xa9, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x41, 0x74)
+	if err != nil {
+		return
+	}
+	if z.DeletedAt == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		err = en.WriteTime(*z.DeletedAt)
+		if err != nil {
+			err = msgp.WrapError(err, "DeletedAt")
+			return
+		}
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *User) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 5
+	// map header, size 6
 	// string "ID"
-	o = append(o, 0x85, 0xa2, 0x49, 0x44)
+	o = append(o, 0x86, 0xa2, 0x49, 0x44)
 	o = msgp.AppendInt64(o, z.ID)
 	// string "Name"
 	o = append(o, 0xa4, 0x4e, 0x61, 0x6d, 0x65)
@@ -140,6 +177,13 @@ func (z *User) MarshalMsg(b []byte) (o []byte, err error) {
 	// string "Updated"
 	o = append(o, 0xa7, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64)
 	o = msgp.AppendTime(o, z.Updated)
+	// string "DeletedAt"
+	o = append(o, 0xa9, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x41, 0x74)
+	if z.DeletedAt == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		o = msgp.AppendTime(o, *z.DeletedAt)
+	}
 	return
 }
 
@@ -191,6 +235,23 @@ func (z *User) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "Updated")
 				return
 			}
+		case "DeletedAt":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.DeletedAt = nil
+			} else {
+				if z.DeletedAt == nil {
+					z.DeletedAt = new(time.Time)
+				}
+				*z.DeletedAt, bts, err = msgp.ReadTimeBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "DeletedAt")
+					return
+				}
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -205,6 +266,11 @@ func (z *User) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *User) Msgsize() (s int) {
-	s = 1 + 3 + msgp.Int64Size + 5 + msgp.StringPrefixSize + len(z.Name) + 9 + msgp.StringPrefixSize + len(z.Password) + 8 + msgp.TimeSize + 8 + msgp.TimeSize
+	s = 1 + 3 + msgp.Int64Size + 5 + msgp.StringPrefixSize + len(z.Name) + 9 + msgp.StringPrefixSize + len(z.Password) + 8 + msgp.TimeSize + 8 + msgp.TimeSize + 10
+	if z.DeletedAt == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.TimeSize
+	}
 	return
 }
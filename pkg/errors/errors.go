@@ -14,8 +14,11 @@ var (
 var (
 	// Base Errors
 
-	ErrBadRequest   = AddCodeWithMessage(nil, "bad_request", "bad request")
-	ErrUnauthorized = AddCodeWithMessage(nil, "unauthorized", "unauthorized")
+	ErrBadRequest      = AddCodeWithMessage(nil, "bad_request", "bad request")
+	ErrUnauthorized    = AddCodeWithMessage(nil, "unauthorized", "unauthorized")
+	ErrForbidden       = AddCodeWithMessage(nil, "forbidden", "forbidden")
+	ErrConflict        = AddCodeWithMessage(nil, "conflict", "conflict")
+	ErrTooManyRequests = AddCodeWithMessage(nil, "too_many_requests", "too many requests")
 
 	// Service
 
@@ -28,4 +31,41 @@ var (
 	ErrInvalidEmailAddress = AddCodeWithMessage(ErrBadRequest, "invalid_email_address", "invalid email address")
 	ErrInvalidName         = AddCodeWithMessage(ErrBadRequest, "invalid_name", "invalid name")
 	ErrInvalidLimit        = AddCodeWithMessage(ErrBadRequest, "invalid_limit", "invalid limit")
+	ErrInvalidCursor       = AddCodeWithMessage(ErrBadRequest, "invalid_cursor", "invalid cursor")
+	ErrInvalidSort         = AddCodeWithMessage(ErrBadRequest, "invalid_sort", "invalid sort")
+	ErrInvalidRole         = AddCodeWithMessage(ErrBadRequest, "invalid_role", "invalid role")
+
+	ErrEmailNotVerified         = AddCodeWithMessage(ErrForbidden, "email_not_verified", "email not verified")
+	ErrEmailAlreadyVerified     = AddCodeWithMessage(ErrConflict, "email_already_verified", "email already verified")
+	ErrInvalidVerificationToken = AddCodeWithMessage(ErrBadRequest, "invalid_verification_token", "invalid verification token")
+
+	ErrInvalidResetToken = AddCodeWithMessage(ErrBadRequest, "invalid_reset_token", "invalid reset token")
+
+	ErrUnknownTemplate = AddCodeWithMessage(ErrBadRequest, "unknown_template", "unknown template")
+
+	ErrInvalidCredentials = AddCodeWithMessage(ErrUnauthorized, "invalid_credentials", "invalid credentials")
+	ErrTooManyAttempts    = AddCodeWithMessage(ErrTooManyRequests, "too_many_attempts", "too many failed attempts, retry later")
+
+	ErrTOTPAlreadyEnabled = AddCodeWithMessage(ErrConflict, "totp_already_enabled", "totp already enabled")
+	ErrTOTPNotEnrolled    = AddCodeWithMessage(ErrBadRequest, "totp_not_enrolled", "totp not enrolled")
+	ErrInvalidTOTPCode    = AddCodeWithMessage(ErrBadRequest, "invalid_totp_code", "invalid totp code")
+	ErrInvalidChallenge   = AddCodeWithMessage(ErrUnauthorized, "invalid_challenge", "invalid challenge")
+
+	ErrInvalidScope      = AddCodeWithMessage(ErrBadRequest, "invalid_scope", "invalid scope")
+	ErrInvalidExpiration = AddCodeWithMessage(ErrBadRequest, "invalid_expiration", "invalid expiration")
+	ErrInvalidAPIKey     = AddCodeWithMessage(ErrUnauthorized, "invalid_api_key", "invalid api key")
+	ErrMissingScope      = AddCodeWithMessage(ErrForbidden, "missing_scope", "the api key lacks the scope")
+
+	ErrNotMember         = AddCodeWithMessage(ErrForbidden, "not_member", "not a member of the organization")
+	ErrAlreadyMember     = AddCodeWithMessage(ErrConflict, "already_member", "already a member of the organization")
+	ErrInvalidInvitation = AddCodeWithMessage(ErrBadRequest, "invalid_invitation", "invalid invitation")
+
+	ErrInvalidToken        = AddCodeWithMessage(ErrUnauthorized, "invalid_token", "invalid token")
+	ErrInvalidRefreshToken = AddCodeWithMessage(ErrUnauthorized, "invalid_refresh_token", "invalid refresh token")
+	ErrRefreshTokenReused  = AddCodeWithMessage(ErrInvalidRefreshToken, "refresh_token_reused", "refresh token reused")
+	ErrUpdateConflict      = AddCodeWithMessage(ErrConflict, "update_conflict", "modified since it was read")
+
+	// Store
+
+	ErrMissingOrganization = New("the filter has no organization")
 )
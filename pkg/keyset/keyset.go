@@ -0,0 +1,307 @@
+// Package keyset holds the keys signing and verifying the JWT tokens
+package keyset
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"boiler/pkg/errors"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog/log"
+)
+
+// Key is a private key of the keyset
+// it signs from NotBefore until NotAfter and verifies until NotAfter plus the overlap
+type Key struct {
+	ID        string
+	Algorithm jwa.SignatureAlgorithm
+	Private   crypto.Signer
+	NotBefore time.Time
+	// NotAfter is zero while the key was not rotated
+	NotAfter time.Time
+}
+
+// NewKey return a key for private, the algorithm depends on its type and its ID is the JWK thumbprint
+func NewKey(private crypto.Signer) (Key, error) {
+	var alg jwa.SignatureAlgorithm
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		alg = jwa.RS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+		alg = jwa.ES256
+	case ed25519.PrivateKey:
+		alg = jwa.EdDSA
+	default:
+		return Key{}, fmt.Errorf("unsupported key %T", private)
+	}
+
+	pub, err := jwk.New(private.Public())
+	if err != nil {
+		return Key{}, fmt.Errorf("could not convert key; %w", err)
+	}
+
+	err = jwk.AssignKeyID(pub)
+	if err != nil {
+		return Key{}, fmt.Errorf("could not assign key ID; %w", err)
+	}
+
+	return Key{ID: pub.KeyID(), Algorithm: alg, Private: private}, nil
+}
+
+// Generate return a new key for alg, one of RS256, ES256 or EdDSA
+func Generate(alg jwa.SignatureAlgorithm) (Key, error) {
+	var private crypto.Signer
+	var err error
+
+	switch alg {
+	case jwa.RS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwa.ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwa.EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return Key{}, fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("could not generate key; %w", err)
+	}
+
+	return NewKey(private)
+}
+
+// ParsePEM read a PKCS1, PKCS8 or EC private key
+func ParsePEM(raw []byte) (Key, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return Key{}, fmt.Errorf("could not decode PEM")
+	}
+
+	var private interface{}
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("could not parse key; %w", err)
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("unsupported key %T", private)
+	}
+
+	return NewKey(signer)
+}
+
+// Keyset sign with its newest active key and verify with every key not expired
+type Keyset struct {
+	mu      sync.RWMutex
+	keys    []Key
+	overlap time.Duration
+
+	// Now is the clock of the keyset
+	Now func() time.Time
+}
+
+// New return a keyset, overlap is how long a rotated key keeps verifying
+// it must be longer than the lifetime of the tokens
+func New(overlap time.Duration, keys ...Key) *Keyset {
+	return &Keyset{keys: keys, overlap: overlap, Now: time.Now}
+}
+
+// Add a key to the keyset
+func (k *Keyset) Add(key Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = append(k.keys, key)
+}
+
+// Rotate schedule next to replace the signing keys after the overlap
+// next is published right away so the verifiers caching the JWKS know it before it signs
+func (k *Keyset) Rotate(next Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.Now()
+	start := now.Add(k.overlap)
+
+	keys := k.keys[:0]
+	for _, key := range k.keys {
+		if !key.NotAfter.IsZero() && now.After(key.NotAfter.Add(k.overlap)) {
+			continue
+		}
+
+		if key.NotAfter.IsZero() || key.NotAfter.After(start) {
+			key.NotAfter = start
+		}
+		keys = append(keys, key)
+	}
+
+	next.NotBefore = start
+	next.NotAfter = time.Time{}
+	k.keys = append(keys, next)
+}
+
+// RotateEvery rotate the keys with a key from generate at each interval until ctx is done
+func (k *Keyset) RotateEvery(ctx context.Context, interval time.Duration, generate func() (Key, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			key, err := generate()
+			if err != nil {
+				log.Error().Err(err).Msg("could not rotate keys")
+				continue
+			}
+
+			k.Rotate(key)
+		}
+	}
+}
+
+// Signer return the key signing now, the newest one if many are active
+func (k *Keyset) Signer() (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.Now()
+
+	var signer Key
+	found := false
+	for _, key := range k.keys {
+		if now.Before(key.NotBefore) || (!key.NotAfter.IsZero() && !now.Before(key.NotAfter)) {
+			continue
+		}
+
+		if !found || key.NotBefore.After(signer.NotBefore) {
+			signer, found = key, true
+		}
+	}
+
+	return signer, found
+}
+
+// Sign serialize and sign t with the signing key, its ID is set as the kid header
+func (k *Keyset) Sign(t jwt.Token) ([]byte, error) {
+	key, ok := k.Signer()
+	if !ok {
+		return nil, fmt.Errorf("could not sign; no active key")
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.KeyIDKey, key.ID)
+
+	raw, err := jwt.Sign(t, key.Algorithm, key.Private, jwt.WithHeaders(headers))
+	if err != nil {
+		return nil, fmt.Errorf("could not sign; %w", err)
+	}
+
+	return raw, nil
+}
+
+// Parse verify the signature of a token with the key of its kid header and validate its claims
+// it fails with ErrInvalidToken if the key is unknown, expired or of another algorithm
+func (k *Keyset) Parse(raw string) (jwt.Token, error) {
+	msg, err := jws.ParseString(raw)
+	if err != nil || len(msg.Signatures()) != 1 {
+		return nil, errors.ErrInvalidToken
+	}
+
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	key, ok := k.verifier(headers.KeyID())
+	if !ok || headers.Algorithm() != key.Algorithm {
+		return nil, errors.ErrInvalidToken
+	}
+
+	token, err := jwt.ParseString(raw,
+		jwt.WithVerify(key.Algorithm, key.Private.Public()),
+		jwt.WithValidate(true),
+		jwt.WithClock(jwt.ClockFunc(k.Now)),
+	)
+	if err != nil {
+		return nil, errors.ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// Public return the JWK set of the keys able to verify, sorted by ID
+func (k *Keyset) Public() (jwk.Set, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.Now()
+	keys := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		if k.verifies(key, now) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	set := jwk.NewSet()
+	for _, key := range keys {
+		pub, err := jwk.New(key.Private.Public())
+		if err != nil {
+			return nil, fmt.Errorf("could not convert key; %w", err)
+		}
+
+		_ = pub.Set(jwk.KeyIDKey, key.ID)
+		_ = pub.Set(jwk.AlgorithmKey, key.Algorithm)
+		_ = pub.Set(jwk.KeyUsageKey, jwk.ForSignature)
+		set.Add(pub)
+	}
+
+	return set, nil
+}
+
+// verifier return the key of ID if it can verify now
+func (k *Keyset) verifier(ID string) (Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.Now()
+	for _, key := range k.keys {
+		if key.ID == ID && k.verifies(key, now) {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// verifies tells if key can verify a token at now
+func (k *Keyset) verifies(key Key, now time.Time) bool {
+	return key.NotAfter.IsZero() || now.Before(key.NotAfter.Add(k.overlap))
+}
@@ -0,0 +1,199 @@
+package keyset_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func token() jwt.Token {
+	t := jwt.New()
+	_ = t.Set(jwt.SubjectKey, "4")
+	_ = t.Set(jwt.ExpirationKey, time.Now().Add(time.Hour).Unix())
+	return t
+}
+
+func TestSignParse(t *testing.T) {
+	for _, alg := range []jwa.SignatureAlgorithm{jwa.RS256, jwa.ES256, jwa.EdDSA} {
+		key, err := keyset.Generate(alg)
+		assert.Nil(t, err)
+		assert.Equal(t, alg, key.Algorithm)
+		assert.NotEmpty(t, key.ID)
+
+		keys := keyset.New(time.Minute, key)
+
+		// succeed
+		raw, err := keys.Sign(token())
+		assert.Nil(t, err)
+
+		msg, err := jws.Parse(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, key.ID, msg.Signatures()[0].ProtectedHeaders().KeyID())
+
+		parsed, err := keys.Parse(string(raw))
+		assert.Nil(t, err, alg)
+		assert.Equal(t, "4", parsed.Subject())
+
+		// fails if the key is unknown
+		other, err := keyset.Generate(alg)
+		assert.Nil(t, err)
+
+		_, err = keyset.New(time.Minute, other).Parse(string(raw))
+		assert.Equal(t, errors.ErrInvalidToken, err)
+	}
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+	keys := keyset.New(time.Minute, key)
+
+	// fails if expired
+	{
+		expired := token()
+		_ = expired.Set(jwt.ExpirationKey, time.Now().Add(-time.Minute).Unix())
+		raw, err := keys.Sign(expired)
+		assert.Nil(t, err)
+
+		_, err = keys.Parse(string(raw))
+		assert.Equal(t, errors.ErrInvalidToken, err)
+	}
+
+	// fails if the algorithm is not the one of the key
+	{
+		headers := jws.NewHeaders()
+		_ = headers.Set(jws.KeyIDKey, key.ID)
+		raw, err := jwt.Sign(token(), jwa.HS256, []byte("secret"), jwt.WithHeaders(headers))
+		assert.Nil(t, err)
+
+		_, err = keys.Parse(string(raw))
+		assert.Equal(t, errors.ErrInvalidToken, err)
+	}
+
+	// fails if not a token
+	{
+		_, err := keys.Parse("invalid")
+		assert.Equal(t, errors.ErrInvalidToken, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Now()
+
+	old, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+	next, err := keyset.Generate(jwa.EdDSA)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, old)
+	keys.Now = func() time.Time { return now }
+
+	before, err := keys.Sign(token())
+	assert.Nil(t, err)
+
+	keys.Rotate(next)
+
+	// the old key signs during the overlap and the next one is published
+	signer, ok := keys.Signer()
+	assert.True(t, ok)
+	assert.Equal(t, old.ID, signer.ID)
+
+	set, err := keys.Public()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, set.Len())
+
+	// the next key signs after the overlap, the old one still verifies
+	now = now.Add(time.Minute)
+
+	signer, ok = keys.Signer()
+	assert.True(t, ok)
+	assert.Equal(t, next.ID, signer.ID)
+
+	_, err = keys.Parse(string(before))
+	assert.Nil(t, err)
+
+	// the old key is dropped after a second overlap
+	now = now.Add(time.Minute)
+
+	_, err = keys.Parse(string(before))
+	assert.Equal(t, errors.ErrInvalidToken, err)
+
+	set, err = keys.Public()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, set.Len())
+}
+
+func TestParsePEM(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	// succeed with PKCS8
+	{
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		assert.Nil(t, err)
+
+		key, err := keyset.ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		assert.Nil(t, err)
+		assert.Equal(t, jwa.ES256, key.Algorithm)
+	}
+
+	// succeed with an EC key
+	{
+		der, err := x509.MarshalECPrivateKey(private)
+		assert.Nil(t, err)
+
+		key, err := keyset.ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+		assert.Nil(t, err)
+		assert.Equal(t, jwa.ES256, key.Algorithm)
+	}
+
+	// fails if the curve is not supported
+	{
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		assert.Nil(t, err)
+		der, err := x509.MarshalECPrivateKey(p384)
+		assert.Nil(t, err)
+
+		_, err = keyset.ParsePEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+		assert.NotNil(t, err)
+	}
+
+	// fails if not PEM
+	{
+		_, err := keyset.ParsePEM([]byte("invalid"))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	key, err := keyset.Generate(jwa.EdDSA)
+	assert.Nil(t, err)
+
+	set, err := keyset.New(time.Minute, key).Public()
+	assert.Nil(t, err)
+
+	raw, err := json.Marshal(set)
+	assert.Nil(t, err)
+
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.Nil(t, json.Unmarshal(raw, &jwks))
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, key.ID, jwks.Keys[0]["kid"])
+	assert.Equal(t, "EdDSA", jwks.Keys[0]["alg"])
+	assert.Equal(t, "OKP", jwks.Keys[0]["kty"])
+	assert.Equal(t, "sig", jwks.Keys[0]["use"])
+	assert.Nil(t, jwks.Keys[0]["d"])
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// APIKeyPrefix starts every API key, it makes the keys easy to recognize
+	APIKeyPrefix = "bk_"
+	// APIKeyTouchEvery is how often the last use of an API key is stored
+	APIKeyTouchEvery = time.Minute
+)
+
+// AddAPIKey create an API key for key.UserID with key.Name, key.Scopes and the optional key.Expires
+// the key is only returned in raw, it is stored hashed
+// a request authenticated by an API key can only create keys within its own scopes
+func (s *Service) AddAPIKey(ctx context.Context, key *entity.APIKey, raw *string) error {
+	key.Name = strings.TrimSpace(key.Name)
+	if len(key.Name) == 0 {
+		return errors.ErrInvalidName
+	}
+
+	scopes, err := validScopes(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	if viewer, ok := Viewer(ctx); ok {
+		for _, scope := range scopes {
+			if !viewer.HasScope(scope) {
+				return errors.ErrMissingScope
+			}
+		}
+	}
+
+	if key.Expires != nil && !key.Expires.After(time.Now()) {
+		return errors.ErrInvalidExpiration
+	}
+
+	id, err := randomString(4)
+	if err != nil {
+		return err
+	}
+
+	secret, err := randomString(24)
+	if err != nil {
+		return err
+	}
+
+	key.Prefix = APIKeyPrefix + id
+	key.Scopes = scopes
+	key.Revoked = false
+	key.LastUsed = nil
+	plain := key.Prefix + "_" + secret
+	key.Hash = hashToken(plain)
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.AddAPIKey(ctx, tx, key)
+	})
+	if err != nil {
+		return fmt.Errorf("could not add api key; %w", err)
+	}
+
+	*raw = plain
+	return nil
+}
+
+// FilterAPIKeys return the API keys of the user, the revoked ones included
+func (s *Service) FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error {
+	err := s.store.FilterAPIKeys(ctx, userID, keys)
+	if err != nil {
+		return fmt.Errorf("could not list api keys; %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revoke an API key of the user, it fails with ErrNotFound if the user has no such key
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.RevokeAPIKey(ctx, tx, userID, keyID)
+	})
+	if err == errors.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not revoke api key; %w", err)
+	}
+
+	return nil
+}
+
+// AuthAPIKey authenticate an API key as its user, limited to the scopes of the key
+// it fails with ErrInvalidAPIKey if the key is unknown, revoked or expired
+func (s *Service) AuthAPIKey(ctx context.Context, raw string, viewer *entity.JWTUser) error {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return errors.ErrInvalidAPIKey
+	}
+
+	var key entity.APIKey
+	err := s.store.FetchAPIKey(ctx, hashToken(raw), &key)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch api key; %w", err)
+	}
+
+	now := time.Now()
+	if key.Revoked || (key.Expires != nil && !key.Expires.After(now)) {
+		return errors.ErrInvalidAPIKey
+	}
+
+	// the keys of a deleted user are purged with it
+	var user entity.User
+	err = s.GetUserByID(ctx, key.UserID, &user)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return err
+	}
+
+	var roles []string
+	err = s.GetUserRoles(ctx, key.UserID, &roles)
+	if err != nil {
+		return err
+	}
+
+	orgID, err := s.organization(ctx, key.UserID, 0)
+	if err != nil {
+		return err
+	}
+
+	// the last use is approximate, it is not worth a write per request
+	if key.LastUsed == nil || now.Sub(*key.LastUsed) >= APIKeyTouchEvery {
+		err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+			return s.store.TouchAPIKey(ctx, tx, key.ID, now)
+		})
+		if err != nil {
+			log.Error().Err(err).Int64("api_key", key.ID).Msg("could not touch api key")
+		}
+	}
+
+	*viewer = entity.JWTUser{
+		ID:             key.UserID,
+		Roles:          roles,
+		APIKeyID:       key.ID,
+		Scopes:         key.Scopes,
+		OrganizationID: orgID,
+	}
+	return nil
+}
+
+// validScopes return the sorted scopes without duplicates
+// it fails with ErrInvalidScope if there is none or one is unknown
+func validScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.ErrInvalidScope
+	}
+
+	known := make(map[string]bool, len(entity.Scopes))
+	for _, scope := range entity.Scopes {
+		known[scope] = true
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	valid := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !known[scope] {
+			return nil, errors.ErrInvalidScope
+		}
+
+		if seen[scope] {
+			continue
+		}
+
+		seen[scope] = true
+		valid = append(valid, scope)
+	}
+
+	sort.Strings(valid)
+	return valid, nil
+}
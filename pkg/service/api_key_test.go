@@ -0,0 +1,244 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		var stored entity.APIKey
+		m.EXPECT().AddAPIKey(gomock.Any(), tx, gomock.Any()).DoAndReturn(func(_ context.Context, _ store.Tx, key *entity.APIKey) error {
+			key.ID = 7
+			stored = *key
+			return nil
+		})
+		tx.EXPECT().Commit().Return(nil)
+
+		key := entity.APIKey{
+			UserID: 4,
+			Name:   " ci ",
+			Scopes: []string{entity.ScopeUsersWrite, entity.ScopeUsersRead, entity.ScopeUsersRead},
+		}
+		var raw string
+		assert.Nil(t, srv.AddAPIKey(ctx, &key, &raw))
+		assert.Equal(t, int64(7), key.ID)
+		assert.Equal(t, "ci", stored.Name)
+		assert.Equal(t, []string{entity.ScopeUsersRead, entity.ScopeUsersWrite}, stored.Scopes)
+		assert.True(t, strings.HasPrefix(raw, stored.Prefix+"_"))
+		assert.True(t, strings.HasPrefix(stored.Prefix, service.APIKeyPrefix))
+		assert.Equal(t, hash(raw), stored.Hash)
+	}
+
+	// fails if name is empty
+	{
+		var raw string
+		err := srv.AddAPIKey(ctx, &entity.APIKey{UserID: 4, Scopes: []string{entity.ScopeUsersRead}}, &raw)
+		assert.Equal(t, errors.ErrInvalidName, err)
+	}
+
+	// fails if a scope is unknown
+	{
+		var raw string
+		err := srv.AddAPIKey(ctx, &entity.APIKey{UserID: 4, Name: "ci", Scopes: []string{"users:*"}}, &raw)
+		assert.Equal(t, errors.ErrInvalidScope, err)
+
+		err = srv.AddAPIKey(ctx, &entity.APIKey{UserID: 4, Name: "ci"}, &raw)
+		assert.Equal(t, errors.ErrInvalidScope, err)
+	}
+
+	// fails if expired
+	{
+		past := time.Now().Add(-time.Minute)
+		var raw string
+		err := srv.AddAPIKey(ctx, &entity.APIKey{UserID: 4, Name: "ci", Scopes: []string{entity.ScopeUsersRead},
+			Expires: &past}, &raw)
+		assert.Equal(t, errors.ErrInvalidExpiration, err)
+	}
+
+	// fails if the api key authenticating lacks a scope
+	{
+		ctx := context.WithValue(ctx, config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: 4, APIKeyID: 7, Scopes: []string{entity.ScopeAPIKeysWrite}})
+		var raw string
+		err := srv.AddAPIKey(ctx, &entity.APIKey{UserID: 4, Name: "ci", Scopes: []string{entity.ScopeUsersRead}}, &raw)
+		assert.Equal(t, errors.ErrMissingScope, err)
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddAPIKey(gomock.Any(), tx, gomock.Any()).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		var raw string
+		err := srv.AddAPIKey(ctx, &entity.APIKey{UserID: 4, Name: "ci", Scopes: []string{entity.ScopeUsersRead}}, &raw)
+		assert.Equal(t, "could not add api key; opz", err.Error())
+		assert.Empty(t, raw)
+	}
+}
+
+func TestAuthAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	raw := service.APIKeyPrefix + "0a1b2c3d_secret"
+	fetch := func(key entity.APIKey) {
+		m.EXPECT().
+			FetchAPIKey(ctx, hash(raw), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, k *entity.APIKey) error {
+				*k = key
+				return nil
+			})
+	}
+
+	user := func(users ...entity.User) {
+		m.EXPECT().
+			FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, us *[]entity.User) error {
+				*us = users
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		fetch(entity.APIKey{ID: 7, UserID: 4, Scopes: []string{entity.ScopeUsersRead}})
+		user(entity.User{ID: 4})
+		m.EXPECT().
+			FetchUserRoles(ctx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleMember}
+				return nil
+			})
+		m.EXPECT().
+			FilterOrganizations(ctx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, orgs *[]entity.Organization) error {
+				*orgs = []entity.Organization{{ID: 3}, {ID: 5}}
+				return nil
+			})
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().TouchAPIKey(gomock.Any(), tx, int64(7), gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var viewer entity.JWTUser
+		assert.Nil(t, srv.AuthAPIKey(ctx, raw, &viewer))
+		assert.Equal(t, entity.JWTUser{
+			ID:             4,
+			Roles:          []string{entity.RoleMember},
+			APIKeyID:       7,
+			Scopes:         []string{entity.ScopeUsersRead},
+			OrganizationID: 3,
+		}, viewer)
+	}
+
+	// succeed without touching a key just used
+	{
+		now := time.Now()
+		fetch(entity.APIKey{ID: 7, UserID: 4, Scopes: []string{entity.ScopeUsersRead}, LastUsed: &now})
+		user(entity.User{ID: 4})
+		m.EXPECT().FetchUserRoles(ctx, int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(ctx, int64(4), gomock.Any()).Return(nil)
+
+		var viewer entity.JWTUser
+		assert.Nil(t, srv.AuthAPIKey(ctx, raw, &viewer))
+		assert.Equal(t, int64(7), viewer.APIKeyID)
+	}
+
+	// fails if the user is deleted
+	{
+		fetch(entity.APIKey{ID: 7, UserID: 4, Scopes: []string{entity.ScopeUsersRead}})
+		user()
+
+		var viewer entity.JWTUser
+		assert.Equal(t, errors.ErrInvalidAPIKey, srv.AuthAPIKey(ctx, raw, &viewer))
+	}
+
+	// fails if the key is unknown
+	{
+		m.EXPECT().FetchAPIKey(ctx, hash(raw), gomock.Any()).Return(errors.ErrNotFound)
+
+		var viewer entity.JWTUser
+		assert.Equal(t, errors.ErrInvalidAPIKey, srv.AuthAPIKey(ctx, raw, &viewer))
+		assert.Equal(t, errors.ErrInvalidAPIKey, srv.AuthAPIKey(ctx, "secret", &viewer))
+	}
+
+	// fails if the key is revoked or expired
+	{
+		fetch(entity.APIKey{ID: 7, UserID: 4, Revoked: true})
+
+		var viewer entity.JWTUser
+		assert.Equal(t, errors.ErrInvalidAPIKey, srv.AuthAPIKey(ctx, raw, &viewer))
+
+		past := time.Now().Add(-time.Second)
+		fetch(entity.APIKey{ID: 7, UserID: 4, Expires: &past})
+		assert.Equal(t, errors.ErrInvalidAPIKey, srv.AuthAPIKey(ctx, raw, &viewer))
+	}
+
+	// fails if store fails
+	{
+		m.EXPECT().FetchAPIKey(ctx, hash(raw), gomock.Any()).Return(fmt.Errorf("opz"))
+
+		var viewer entity.JWTUser
+		err := srv.AuthAPIKey(ctx, raw, &viewer)
+		assert.Equal(t, "could not fetch api key; opz", err.Error())
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RevokeAPIKey(gomock.Any(), tx, int64(4), int64(7)).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.RevokeAPIKey(ctx, 4, 7))
+	}
+
+	// fails if not found
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RevokeAPIKey(gomock.Any(), tx, int64(4), int64(7)).Return(errors.ErrNotFound)
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, errors.ErrNotFound, srv.RevokeAPIKey(ctx, 4, 7))
+	}
+}
@@ -3,64 +3,260 @@ package service
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"boiler/pkg/entity"
+	"boiler/pkg/errors"
 	"boiler/pkg/store"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog/log"
 )
 
-// AddEmail add a new email
+// VerifyEmailAudience is the audience of the tokens verifying the emails
+const VerifyEmailAudience = "verify_email"
+
+// EmailClaim is the claim of the address verified by a token
+const EmailClaim = "email"
+
+// VerifyEmailTemplate is the template of the message verifying an email
+const VerifyEmailTemplate = "verify_email"
+
+// AddEmail add a new email and enqueue the message verifying it
 func (s *Service) AddEmail(ctx context.Context, email *entity.Email) error {
-	tx, err := s.store.Tx()
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.AddEmail(ctx, tx, email)
+	})
+	if err != nil {
+		return fmt.Errorf("could not add email; %w", err)
+	}
+
+	s.enqueueVerifyEmail(ctx, email.ID)
+	return nil
+}
+
+// enqueueVerifyEmail enqueue the message verifying an email
+// the email is added even if it fails, the failure is only logged
+func (s *Service) enqueueVerifyEmail(ctx context.Context, emailID int64) {
+	payload := entity.SendVerifyEmailPayload{Version: entity.SendVerifyEmailPayloadVersion, ID: emailID}
+	args, err := jobArgs(ctx, &payload)
+	if err == nil {
+		_, err = s.enqueuer.Enqueue(SendVerifyEmail, args)
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("email", emailID).Msg("could not enqueue email verification")
+	}
+}
+
+// SendVerifyEmail send a message with a token verifying the email
+func (s *Service) SendVerifyEmail(ctx context.Context, emailID int64) error {
+	var email entity.Email
+	err := s.getEmailByID(ctx, emailID, &email)
+	if err != nil {
+		return err
+	}
+
+	if email.VerifiedAt != nil {
+		return nil
+	}
+
+	jti, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t := jwt.New()
+	_ = t.Set(jwt.SubjectKey, strconv.FormatInt(email.ID, 10))
+	_ = t.Set(jwt.IssuedAtKey, now.Unix())
+	_ = t.Set(jwt.ExpirationKey, now.Add(s.config.Auth.VerifyEmailExpireIn).Unix())
+	_ = t.Set(jwt.AudienceKey, VerifyEmailAudience)
+	_ = t.Set(jwt.IssuerKey, s.config.JWT.Issuer)
+	_ = t.Set(jwt.JwtIDKey, jti)
+	_ = t.Set(EmailClaim, email.Address)
+
+	token, err := s.config.JWT.Keys.Sign(t)
+	if err != nil {
+		return err
+	}
+
+	err = s.SendEmail(ctx, email.Address, VerifyEmailTemplate, map[string]interface{}{
+		"Address":  email.Address,
+		"Token":    string(token),
+		"ExpireIn": s.config.Auth.VerifyEmailExpireIn.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("could not send email verification; %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail mark the email of token as verified, a token verifies once
+func (s *Service) VerifyEmail(ctx context.Context, token string, email *entity.Email) error {
+	t, err := s.config.JWT.Keys.Parse(token)
+	if err != nil || !HasAudience(t, VerifyEmailAudience) || len(t.JwtID()) == 0 {
+		return errors.ErrInvalidVerificationToken
+	}
+
+	emailID, err := strconv.ParseInt(t.Subject(), 10, 64)
 	if err != nil {
-		return fmt.Errorf("could not begin transaction; %w", err)
+		return errors.ErrInvalidVerificationToken
 	}
 
-	err = s.store.AddEmail(ctx, tx, email)
+	denied, err := s.IsTokenDenied(ctx, t.JwtID())
 	if err != nil {
-		if er := tx.Rollback(); er != nil {
-			err = fmt.Errorf("%s; %w", er, err)
+		return err
+	}
+	if denied {
+		return errors.ErrInvalidVerificationToken
+	}
+
+	err = s.getEmailByID(ctx, emailID, email)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	// the address could have been deleted and added again
+	address, _ := t.Get(EmailClaim)
+	if address != email.Address {
+		return errors.ErrInvalidVerificationToken
+	}
+
+	now := store.Now()
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.VerifyEmail(ctx, tx, email.ID, now)
+		if err == errors.ErrUpdateConflict {
+			return errors.ErrEmailAlreadyVerified
+		}
+		if err != nil {
+			return err
 		}
 
-		return fmt.Errorf("could not add email; %w", err)
+		return s.store.DenyToken(ctx, tx, t.JwtID(), t.Expiration())
+	})
+	if err != nil {
+		return fmt.Errorf("could not verify email; %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("could not add email; %w", err)
+	email.VerifiedAt = &now
+	return nil
+}
+
+// getEmailByID get an email by ID
+func (s *Service) getEmailByID(ctx context.Context, emailID int64, email *entity.Email) error {
+	var emails []entity.Email
+	err := s.store.FilterEmails(ctx, store.FilterEmails{EmailID: emailID, AllOrganizations: true}, &emails)
+	if err != nil {
+		return err
+	}
+	if len(emails) != 1 {
+		return errors.ErrNotFound
 	}
 
+	*email = emails[0]
 	return nil
 }
 
 // EnqueueDeleteEmail enqueue email to be deleted
 func (s *Service) EnqueueDeleteEmail(ctx context.Context, emailID int64) error {
-	_, err := s.enqueuer.Enqueue(DeleteEmail, map[string]interface{}{"id": emailID})
+	payload := entity.DeleteEmailPayload{Version: entity.DeleteEmailPayloadVersion, ID: emailID}
+	args, err := jobArgs(ctx, &payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.enqueuer.Enqueue(DeleteEmail, args)
 	return err
 }
 
-// DeleteEmail remove an email
+// DeleteEmail mark an email deleted, it can be restored until it is purged
 func (s *Service) DeleteEmail(ctx context.Context, emailID int64) error {
-	tx, err := s.store.Tx()
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.DeleteEmail(ctx, tx, emailID)
+	})
 	if err != nil {
-		return fmt.Errorf("could not begin delete email transaction; %w", err)
+		return fmt.Errorf("could not delete email; %w", err)
 	}
 
-	err = s.store.DeleteEmail(ctx, tx, emailID)
-	if err != nil {
-		if er := tx.Rollback(); er != nil {
-			err = fmt.Errorf("%s; %w", er, err)
-		}
+	return nil
+}
 
-		return fmt.Errorf("could not delete email; %w", err)
+// PurgeOrphanedEmails remove the emails left by users which do not exist
+func (s *Service) PurgeOrphanedEmails(ctx context.Context) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.PurgeOrphanedEmails(ctx, tx)
+	})
+	if err != nil {
+		return fmt.Errorf("could not purge orphaned emails; %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("could not commit delete email; %w", err)
+	return nil
+}
+
+// RestoreEmail undelete an email, it fails with ErrNotFound if it is not deleted
+// and ErrAlreadyExists if its address was added again
+func (s *Service) RestoreEmail(ctx context.Context, emailID int64) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.RestoreEmail(ctx, tx, emailID)
+	})
+	if err == errors.ErrNotFound || err == errors.ErrAlreadyExists {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not restore email; %w", err)
 	}
 
 	return nil
 }
 
-// FilterEmails retrieve emails
-func (s *Service) FilterEmails(ctx context.Context, filter store.FilterEmails, emails *[]entity.Email) error {
-	return s.store.FilterEmails(ctx, filter, emails)
+// FilterEmails retrieve a page of emails, page can be nil
+func (s *Service) FilterEmails(ctx context.Context, filter store.FilterEmails, emails *[]entity.Email, page *store.PageInfo) error {
+	// the emails of the other organizations are never listed, a viewer without organization lists them all
+	orgID := viewerOrganization(ctx)
+	filter.OrganizationID, filter.AllOrganizations = orgID, orgID == 0
+
+	if filter.Limit == 0 {
+		filter.Limit = FilterEmailsDefaultLimit
+	}
+
+	seek, err := filter.Seek()
+	if err != nil {
+		return err
+	}
+
+	// an extra email tells if there is another page
+	limit := filter.Limit
+	filter.Limit++
+
+	err = s.store.FilterEmails(ctx, filter, emails)
+	if err != nil {
+		return err
+	}
+
+	more := uint(len(*emails)) > limit
+	if more {
+		if seek.Backward {
+			*emails = (*emails)[1:]
+		} else {
+			*emails = (*emails)[:limit]
+		}
+	}
+
+	if page != nil {
+		var first, last *store.Cursor
+		if n := len(*emails); n != 0 {
+			f := store.NewCursor(seek.SortBy, (*emails)[0].ID, (*emails)[0].Created)
+			l := store.NewCursor(seek.SortBy, (*emails)[n-1].ID, (*emails)[n-1].Created)
+			first, last = &f, &l
+		}
+
+		*page = store.NewPageInfo(seek, more, first, last)
+	}
+
+	return nil
 }
@@ -2,18 +2,23 @@ package service_test
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
 	"boiler/pkg/service"
+	smock "boiler/pkg/service/mock"
 	"boiler/pkg/store"
 	"boiler/pkg/store/config"
+	"boiler/pkg/store/mailer"
 	"boiler/pkg/store/mock"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,8 +27,9 @@ func TestAddEmail(t *testing.T) {
 	defer ctrl.Finish()
 
 	m := mock.NewMockInterface(ctrl)
+	enq := smock.NewMockEnqueuer(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{Enqueuer: enq})
 
 	var ID int64 = 13
 	var userID int64 = 99
@@ -33,38 +39,54 @@ func TestAddEmail(t *testing.T) {
 
 	// succeed
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
 		email := entity.Email{
 			UserID:  userID,
 			Address: address,
 		}
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddEmail(ctx, tx, &email).
-			DoAndReturn(func(_ context.Context, _ *sql.Tx, e *entity.Email) error {
+			AddEmail(gomock.Any(), tx, &email).
+			DoAndReturn(func(_ context.Context, _ store.Tx, e *entity.Email) error {
 				e.ID = ID
 				return nil
 			})
 
-		mdb.ExpectCommit()
+		tx.EXPECT().Commit().Return(nil)
+		payload := entity.SendVerifyEmailPayload{Version: entity.SendVerifyEmailPayloadVersion, ID: ID}
+		enq.EXPECT().Enqueue(service.SendVerifyEmail, payloadArgs(t, &payload, nil)).Return(nil, nil)
 
-		err = srv.AddEmail(ctx, &email)
+		err := srv.AddEmail(ctx, &email)
 		assert.Nil(t, err)
 		assert.Equal(t, ID, email.ID)
 	}
 
+	// succeed if the verification can not be enqueued
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		email := entity.Email{
+			UserID:  userID,
+			Address: address,
+		}
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddEmail(gomock.Any(), tx, &email).
+			DoAndReturn(func(_ context.Context, _ store.Tx, e *entity.Email) error {
+				e.ID = ID
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+		enq.EXPECT().Enqueue(service.SendVerifyEmail, gomock.Any()).Return(nil, fmt.Errorf("opz"))
+
+		err := srv.AddEmail(ctx, &email)
+		assert.Nil(t, err)
+	}
+
 	// fails if Tx fails
 	{
-		m.EXPECT().Tx().Return(nil, fmt.Errorf("opz"))
+		m.EXPECT().Tx(ctx).Return(nil, fmt.Errorf("opz"))
 
 		email := entity.Email{
 			UserID:  userID,
@@ -73,98 +95,74 @@ func TestAddEmail(t *testing.T) {
 
 		err := srv.AddEmail(ctx, &email)
 		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not begin transaction; opz")
+		assert.Equal(t, err.Error(), "could not add email; could not begin transaction; opz")
 	}
 
 	// fails if service fails
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		email := entity.Email{
 			UserID:  userID,
 			Address: address,
 		}
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddEmail(ctx, tx, &email).
+			AddEmail(gomock.Any(), tx, &email).
 			Return(fmt.Errorf("rollback"))
-		mdb.ExpectRollback()
+		tx.EXPECT().Rollback().Return(nil)
 
-		err = srv.AddEmail(ctx, &email)
+		err := srv.AddEmail(ctx, &email)
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "could not add email; rollback")
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// fails if service fails and rollback fails
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		email := entity.Email{
 			UserID:  userID,
 			Address: address,
 		}
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddEmail(ctx, tx, &email).
+			AddEmail(gomock.Any(), tx, &email).
 			Return(fmt.Errorf("rollback"))
 
-		mdb.ExpectRollback().WillReturnError(fmt.Errorf("rollbackerr"))
+		tx.EXPECT().Rollback().Return(fmt.Errorf("rollbackerr"))
 
-		err = srv.AddEmail(ctx, &email)
+		err := srv.AddEmail(ctx, &email)
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "could not add email; rollbackerr; rollback")
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// fails if commit fails
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		email := entity.Email{
 			UserID:  userID,
 			Address: address,
 		}
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddEmail(ctx, tx, &email).
-			DoAndReturn(func(_ context.Context, _ *sql.Tx, e *entity.Email) error {
+			AddEmail(gomock.Any(), tx, &email).
+			DoAndReturn(func(_ context.Context, _ store.Tx, e *entity.Email) error {
 				e.ID = ID
 				return nil
 			})
 
-		mdb.ExpectCommit().WillReturnError(fmt.Errorf("commit failed"))
+		tx.EXPECT().Commit().Return(fmt.Errorf("commit failed"))
 
-		err = srv.AddEmail(ctx, &email)
+		err := srv.AddEmail(ctx, &email)
 		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not add email; commit failed")
-		assert.Nil(t, mdb.ExpectationsWereMet())
+		assert.Equal(t, err.Error(), "could not add email; could not commit transaction; commit failed")
 	}
 }
 
@@ -174,7 +172,7 @@ func TestDeleteEmail(t *testing.T) {
 
 	m := mock.NewMockInterface(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{})
 
 	var ID int64 = 13
 
@@ -182,107 +180,146 @@ func TestDeleteEmail(t *testing.T) {
 
 	// succeed
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteEmail(ctx, tx, ID).
+			DeleteEmail(gomock.Any(), tx, ID).
 			Return(nil)
 
-		mdb.ExpectCommit()
+		tx.EXPECT().Commit().Return(nil)
 
-		err = srv.DeleteEmail(ctx, ID)
+		err := srv.DeleteEmail(ctx, ID)
 		assert.Nil(t, err)
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// tx
 	{
-		m.EXPECT().Tx().Return(nil, fmt.Errorf("tx fail"))
+		m.EXPECT().Tx(ctx).Return(nil, fmt.Errorf("tx fail"))
 
 		err := srv.DeleteEmail(ctx, ID)
 		assert.NotNil(t, err)
-		assert.Equal(t, "could not begin delete email transaction; tx fail", err.Error())
+		assert.Equal(t, "could not delete email; could not begin transaction; tx fail", err.Error())
 	}
 
 	// store fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteEmail(ctx, tx, ID).
+			DeleteEmail(gomock.Any(), tx, ID).
 			Return(fmt.Errorf("opz"))
-		mdb.ExpectRollback()
+		tx.EXPECT().Rollback().Return(nil)
 
-		err = srv.DeleteEmail(ctx, ID)
+		err := srv.DeleteEmail(ctx, ID)
 		assert.NotNil(t, err)
 		assert.Equal(t, "could not delete email; opz", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// commit fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteEmail(ctx, tx, ID).
+			DeleteEmail(gomock.Any(), tx, ID).
 			Return(nil)
 
-		mdb.ExpectCommit().WillReturnError(fmt.Errorf("commit fail"))
+		tx.EXPECT().Commit().Return(fmt.Errorf("commit fail"))
 
-		err = srv.DeleteEmail(ctx, ID)
+		err := srv.DeleteEmail(ctx, ID)
 		assert.NotNil(t, err)
-		assert.Equal(t, "could not commit delete email; commit fail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
+		assert.Equal(t, "could not delete email; could not commit transaction; commit fail", err.Error())
 	}
 
 	// rollback fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer func() { _ = db.Close() }()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteEmail(ctx, tx, ID).
+			DeleteEmail(gomock.Any(), tx, ID).
 			Return(fmt.Errorf("database fail"))
 
-		mdb.ExpectRollback().WillReturnError(fmt.Errorf("rollbackfail"))
+		tx.EXPECT().Rollback().Return(fmt.Errorf("rollbackfail"))
 
-		err = srv.DeleteEmail(ctx, ID)
+		err := srv.DeleteEmail(ctx, ID)
 		assert.NotNil(t, err)
 		assert.Equal(t, "could not delete email; rollbackfail; database fail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
+	}
+}
+
+func TestEnqueueDeleteEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enq := smock.NewMockEnqueuer(ctrl)
+
+	srv := service.New(&config.Config{}, mock.NewMockInterface(ctrl), service.Options{Enqueuer: enq})
+
+	ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{}, &entity.JWTUser{ID: 4})
+	ctx = context.WithValue(ctx, config.ContextKeyRequestID{}, "host/abc-000001")
+
+	// succeed with the request and the user who enqueued it
+	{
+		payload := entity.DeleteEmailPayload{Version: entity.DeleteEmailPayloadVersion, ID: 5}
+		enq.EXPECT().Enqueue(service.DeleteEmail, payloadArgs(t, &payload, map[string]interface{}{
+			service.JobRequestIDArg: "host/abc-000001",
+			service.JobUserIDArg:    int64(4),
+		})).Return(nil, nil)
+
+		assert.Nil(t, srv.EnqueueDeleteEmail(ctx, 5))
+	}
+
+	// fails if the enqueuer fails
+	{
+		enq.EXPECT().Enqueue(service.DeleteEmail, gomock.Any()).Return(nil, fmt.Errorf("opz"))
+
+		assert.Equal(t, "opz", srv.EnqueueDeleteEmail(ctx, 5).Error())
+	}
+}
+
+func TestRestoreEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RestoreEmail(gomock.Any(), tx, int64(13)).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.RestoreEmail(ctx, 13))
+	}
+
+	// fails if the address was added again
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RestoreEmail(gomock.Any(), tx, int64(13)).Return(errors.ErrAlreadyExists)
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, errors.ErrAlreadyExists, srv.RestoreEmail(ctx, 13))
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RestoreEmail(gomock.Any(), tx, int64(13)).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, "could not restore email; opz", srv.RestoreEmail(ctx, 13).Error())
 	}
 }
 
@@ -292,30 +329,269 @@ func TestFilterEmails(t *testing.T) {
 
 	m := mock.NewMockInterface(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{})
 
-	var ID int64 = 13
 	var userID int64 = 99
 	address := "contact@example.com"
-	filter := store.FilterEmails{UserID: userID}
 	ctx := context.Background()
 
-	var emails []entity.Email
+	// default limit
+	{
+		filter := store.FilterEmails{UserID: userID, AllOrganizations: true}
+		m.
+			EXPECT().
+			FilterEmails(ctx, store.FilterEmails{
+				UserID: userID, Limit: service.FilterEmailsDefaultLimit + 1, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, es *[]entity.Email) error {
+				*es = append(*es, entity.Email{ID: 13, UserID: userID, Address: address})
+				return nil
+			})
+
+		var emails []entity.Email
+		var page store.PageInfo
+		err := srv.FilterEmails(ctx, filter, &emails, &page)
+		assert.Nil(t, err)
+		assert.Len(t, emails, 1)
+		assert.Equal(t, int64(13), emails[0].ID)
+		assert.False(t, page.HasNextPage)
+		assert.False(t, page.HasPreviousPage)
+		assert.Equal(t, store.NewCursor(store.SortByID, 13, time.Time{}).String(), page.EndCursor)
+	}
+
+	// has next page
+	{
+		after := store.NewCursor(store.SortByID, 10, time.Time{}).String()
+		filter := store.FilterEmails{UserID: userID, After: after, Limit: 2, AllOrganizations: true}
+		m.
+			EXPECT().
+			FilterEmails(ctx, store.FilterEmails{UserID: userID, After: after, Limit: 3, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, es *[]entity.Email) error {
+				*es = append(*es, entity.Email{ID: 11}, entity.Email{ID: 12}, entity.Email{ID: 13})
+				return nil
+			})
+
+		var emails []entity.Email
+		var page store.PageInfo
+		err := srv.FilterEmails(ctx, filter, &emails, &page)
+		assert.Nil(t, err)
+		assert.Len(t, emails, 2)
+		assert.Equal(t, int64(12), emails[1].ID)
+		assert.True(t, page.HasNextPage)
+		assert.True(t, page.HasPreviousPage)
+		assert.Equal(t, store.NewCursor(store.SortByID, 11, time.Time{}).String(), page.StartCursor)
+		assert.Equal(t, store.NewCursor(store.SortByID, 12, time.Time{}).String(), page.EndCursor)
+	}
 
-	m.
-		EXPECT().
-		FilterEmails(ctx, filter, &emails).
-		DoAndReturn(func(_ context.Context, _ store.FilterEmails, es *[]entity.Email) error {
-			*es = append(*es, entity.Email{
-				ID:      ID,
-				UserID:  userID,
-				Address: address,
+	// has previous page
+	{
+		before := store.NewCursor(store.SortByID, 14, time.Time{}).String()
+		filter := store.FilterEmails{UserID: userID, Before: before, Limit: 2, AllOrganizations: true}
+		m.
+			EXPECT().
+			FilterEmails(ctx, store.FilterEmails{
+				UserID: userID, Before: before, Limit: 3, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, es *[]entity.Email) error {
+				*es = append(*es, entity.Email{ID: 11}, entity.Email{ID: 12}, entity.Email{ID: 13})
+				return nil
 			})
-			return nil
-		})
 
-	err := srv.FilterEmails(ctx, filter, &emails)
+		var emails []entity.Email
+		var page store.PageInfo
+		err := srv.FilterEmails(ctx, filter, &emails, &page)
+		assert.Nil(t, err)
+		assert.Len(t, emails, 2)
+		assert.Equal(t, int64(12), emails[0].ID)
+		assert.True(t, page.HasNextPage)
+		assert.True(t, page.HasPreviousPage)
+	}
+
+	// fails if cursor is invalid
+	{
+		var emails []entity.Email
+		err := srv.FilterEmails(ctx, store.FilterEmails{UserID: userID, After: "x", AllOrganizations: true}, &emails, nil)
+		assert.Equal(t, errors.ErrInvalidCursor, err)
+	}
+}
+
+// sent is a mailer.Mailer recording the messages
+type sent []mailer.Message
+
+func (s *sent) Send(_ context.Context, msg mailer.Message) error {
+	*s = append(*s, msg)
+	return nil
+}
+
+func TestSendVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, key)
+	m := mock.NewMockInterface(ctrl)
+	var msgs sent
+	srv := service.New(&config.Config{
+		JWT:  config.JWT{Keys: keys},
+		Auth: config.Auth{VerifyEmailExpireIn: time.Hour},
+	}, m, service.Options{Mailer: &msgs})
+	ctx := context.Background()
+
+	filter := func(emails ...entity.Email) {
+		m.EXPECT().FilterEmails(ctx, store.FilterEmails{EmailID: 3, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, e *[]entity.Email) error {
+				*e = emails
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		filter(entity.Email{ID: 3, Address: "a@b.c"})
+
+		assert.Nil(t, srv.SendVerifyEmail(ctx, 3))
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, "a@b.c", msgs[0].To)
+	}
+
+	// skip a verified email
+	{
+		msgs = nil
+		now := time.Now()
+		filter(entity.Email{ID: 3, Address: "a@b.c", VerifiedAt: &now})
+
+		assert.Nil(t, srv.SendVerifyEmail(ctx, 3))
+		assert.Len(t, msgs, 0)
+	}
+
+	// fails if the email does not exist
+	{
+		filter()
+
+		assert.Equal(t, errors.ErrNotFound, srv.SendVerifyEmail(ctx, 3))
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
 	assert.Nil(t, err)
-	assert.Len(t, emails, 1)
-	assert.Equal(t, ID, emails[0].ID)
+
+	keys := keyset.New(time.Minute, key)
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{JWT: config.JWT{Keys: keys}}, m, service.Options{})
+	ctx := context.Background()
+
+	token := func(audience, address string) string {
+		tok := jwt.New()
+		_ = tok.Set(jwt.SubjectKey, "3")
+		_ = tok.Set(jwt.ExpirationKey, time.Now().Add(time.Minute).Unix())
+		_ = tok.Set(jwt.AudienceKey, audience)
+		_ = tok.Set(jwt.JwtIDKey, "jti")
+		_ = tok.Set(service.EmailClaim, address)
+
+		raw, err := keys.Sign(tok)
+		assert.Nil(t, err)
+		return string(raw)
+	}
+	filter := func() {
+		m.EXPECT().FilterEmails(ctx, store.FilterEmails{EmailID: 3, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, e *[]entity.Email) error {
+				*e = []entity.Email{{ID: 3, Address: "a@b.c"}}
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		m.EXPECT().IsTokenDenied(ctx, "jti").Return(false, nil)
+		filter()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().VerifyEmail(gomock.Any(), tx, int64(3), gomock.Any()).Return(nil)
+		m.EXPECT().DenyToken(gomock.Any(), tx, "jti", gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var email entity.Email
+		assert.Nil(t, srv.VerifyEmail(ctx, token(service.VerifyEmailAudience, "a@b.c"), &email))
+		assert.Equal(t, int64(3), email.ID)
+		assert.NotNil(t, email.VerifiedAt)
+	}
+
+	// fails if the token is not a verification token
+	{
+		var email entity.Email
+		err := srv.VerifyEmail(ctx, token(service.AccessAudience, "a@b.c"), &email)
+		assert.Equal(t, errors.ErrInvalidVerificationToken, err)
+	}
+
+	// fails if the token was used
+	{
+		m.EXPECT().IsTokenDenied(ctx, "jti").Return(true, nil)
+
+		var email entity.Email
+		err := srv.VerifyEmail(ctx, token(service.VerifyEmailAudience, "a@b.c"), &email)
+		assert.Equal(t, errors.ErrInvalidVerificationToken, err)
+	}
+
+	// fails if the address changed
+	{
+		m.EXPECT().IsTokenDenied(ctx, "jti").Return(false, nil)
+		filter()
+
+		var email entity.Email
+		err := srv.VerifyEmail(ctx, token(service.VerifyEmailAudience, "x@b.c"), &email)
+		assert.Equal(t, errors.ErrInvalidVerificationToken, err)
+	}
+
+	// fails if already verified
+	{
+		m.EXPECT().IsTokenDenied(ctx, "jti").Return(false, nil)
+		filter()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().VerifyEmail(gomock.Any(), tx, int64(3), gomock.Any()).Return(errors.ErrUpdateConflict)
+		tx.EXPECT().Rollback().Return(nil)
+
+		var email entity.Email
+		err := srv.VerifyEmail(ctx, token(service.VerifyEmailAudience, "a@b.c"), &email)
+		assert.True(t, errors.Is(err, errors.ErrEmailAlreadyVerified))
+	}
+}
+
+func TestPurgeOrphanedEmails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().PurgeOrphanedEmails(gomock.Any(), tx).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.PurgeOrphanedEmails(ctx))
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().PurgeOrphanedEmails(gomock.Any(), tx).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, "could not purge orphaned emails; opz", srv.PurgeOrphanedEmails(ctx).Error())
+	}
 }
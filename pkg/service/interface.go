@@ -3,32 +3,110 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"boiler/pkg/entity"
 	"boiler/pkg/store"
+
+	"github.com/gocraft/work"
+	"github.com/lestrrat-go/jwx/jwk"
 )
 
 const (
-	DeleteUser  string = "delete_user"
-	DeleteEmail string = "delete_email"
+	DeleteUser      string = "delete_user"
+	DeleteEmail     string = "delete_email"
+	SendVerifyEmail string = "send_verify_email"
+	SendEmail       string = "send_email"
+	PurgeDeleted    string = "purge_deleted"
+	PurgeTokens     string = "purge_tokens"
+
+	PurgeOrphanedEmails string = "purge_orphaned_emails"
+	SendPasswordReset   string = "send_password_reset"
 )
 const (
 	// FilterUsersDefaultLimit is the default limit for user filtering
 	FilterUsersDefaultLimit uint = 50
 	// FilterEmailsDefaultLimit is the default limit for email filtering
 	FilterEmailsDefaultLimit uint = 50
+	// PurgeBatchSize is how many deleted users are purged per query
+	PurgeBatchSize uint = 100
 )
 
+// Enqueuer enqueue the jobs of the worker, the queues of pkg/store/queue implement it
+type Enqueuer interface {
+	Enqueue(jobName string, args map[string]interface{}) (*work.Job, error)
+}
+
+// DeadJobs manage the jobs of the worker which failed their last retry, the queue clients of
+// pkg/store/queue implement it
+type DeadJobs interface {
+	DeadJobs(page uint) ([]*work.DeadJob, int64, error)
+	RetryDeadJob(diedAt int64, jobID string) error
+	RetryAllDeadJobs() error
+	DeleteDeadJob(diedAt int64, jobID string) error
+	DeleteAllDeadJobs() error
+}
+
 type Interface interface {
-	AddUser(context.Context, *entity.User) error
+	Authorize(ctx context.Context, action Action, resource Resource) error
+
+	AddUser(ctx context.Context, user *entity.User, emails ...*entity.Email) error
+	UpdateUser(context.Context, *entity.User) error
+	ChangePassword(ctx context.Context, userID int64, current, password string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	SendPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
 	DeleteUser(context.Context, int64) error
-	FilterUsers(context.Context, store.FilterUsers, *[]entity.User) error
+	EnqueueDeleteUser(ctx context.Context, userID int64, job *entity.Job) error
+	RestoreUser(ctx context.Context, userID int64) error
+	PurgeDeleted(ctx context.Context) error
+	FilterUsers(context.Context, store.FilterUsers, *[]entity.User, *store.PageInfo) error
 	GetUserByID(context.Context, int64, *entity.User) error
 	GetUserByEmail(context.Context, string, *entity.User) error
-	AuthUser(context.Context, string, string, *entity.User, *string) error
+	AuthUser(context.Context, string, string, *entity.User, *entity.Tokens) error
+	AuthTOTP(ctx context.Context, challenge, code string, user *entity.User, tokens *entity.Tokens) error
+	EnrollTOTP(ctx context.Context, userID int64, enrollment *entity.TOTPEnrollment) error
+	ConfirmTOTP(ctx context.Context, userID int64, code string, recoveryCodes *[]string) error
+	RefreshToken(ctx context.Context, refresh string, user *entity.User, tokens *entity.Tokens) error
+	Logout(ctx context.Context, refresh string) error
+	IsTokenDenied(ctx context.Context, jti string) (bool, error)
+	IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error)
+	PurgeTokens(ctx context.Context) error
+	JWKS(ctx context.Context) (jwk.Set, error)
+	SetUserRoles(ctx context.Context, userID int64, roles []string) error
+	GetUserRoles(ctx context.Context, userID int64, roles *[]string) error
+	AddAPIKey(ctx context.Context, key *entity.APIKey, raw *string) error
+	FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error
+	RevokeAPIKey(ctx context.Context, userID, keyID int64) error
+	AuthAPIKey(ctx context.Context, raw string, viewer *entity.JWTUser) error
 
-	FilterEmails(context.Context, store.FilterEmails, *[]entity.Email) error
+	FilterEmails(context.Context, store.FilterEmails, *[]entity.Email, *store.PageInfo) error
 	AddEmail(context.Context, *entity.Email) error
+	SendVerifyEmail(ctx context.Context, emailID int64) error
+	VerifyEmail(ctx context.Context, token string, email *entity.Email) error
 	DeleteEmail(context.Context, int64) error
+	RestoreEmail(ctx context.Context, emailID int64) error
 	EnqueueDeleteEmail(context.Context, int64) error
+	PurgeOrphanedEmails(ctx context.Context) error
+
+	SendEmail(ctx context.Context, to, template string, data map[string]interface{}) error
+	EnqueueSendEmail(ctx context.Context, to, template string, data map[string]interface{}) error
+
+	AddOrganization(ctx context.Context, org *entity.Organization, ownerID int64) error
+	GetOrganizationByID(ctx context.Context, orgID int64, org *entity.Organization) error
+	FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error
+	FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error
+	InviteMember(ctx context.Context, orgID int64, address, role string) error
+	AcceptInvitation(ctx context.Context, token string, member *entity.Member) error
+	SwitchOrganization(ctx context.Context, refresh string, orgID int64, user *entity.User, tokens *entity.Tokens) error
+
+	GetJob(ctx context.Context, jobID string, job *entity.Job) error
+	SetJobStatus(ctx context.Context, jobID, status string, jobErr error) error
+	FilterSchedules(ctx context.Context, schedules *[]entity.Schedule) error
+	RecordScheduleRun(ctx context.Context, job string, at time.Time, jobErr error) error
+	FilterDeadJobs(ctx context.Context, page uint, jobs *[]entity.DeadJob, total *int64) error
+	RetryDeadJob(ctx context.Context, diedAt int64, jobID string) error
+	RetryDeadJobs(ctx context.Context) error
+	DeleteDeadJob(ctx context.Context, diedAt int64, jobID string) error
+	DeleteDeadJobs(ctx context.Context) error
 }
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/rs/zerolog/log"
+)
+
+// JobIDArg is the argument of the tracked jobs holding the ID of their status
+const JobIDArg = "job_id"
+
+// JobRequestIDArg and JobUserIDArg are the args of a job holding the request and the user who enqueued it
+const (
+	JobRequestIDArg = "request_id"
+	JobUserIDArg    = "user_id"
+)
+
+// jobArgs return the args of a job holding its payload with the request and the user of ctx, JobContext
+// restores them
+func jobArgs(ctx context.Context, payload queue.Payload) (map[string]interface{}, error) {
+	args, err := queue.EncodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if requestID, ok := ctx.Value(config.ContextKeyRequestID{}).(string); ok && len(requestID) != 0 {
+		args[JobRequestIDArg] = requestID
+	}
+	if viewer, ok := Viewer(ctx); ok {
+		args[JobUserIDArg] = viewer.ID
+	}
+
+	return args, nil
+}
+
+// JobContext return ctx with the request and the user who enqueued the job of args
+// as config.ContextKeyRequestID and config.ContextKeyJobUserID, the user is not authenticated by it
+func JobContext(ctx context.Context, args map[string]interface{}) context.Context {
+	if requestID, ok := args[JobRequestIDArg].(string); ok && len(requestID) != 0 {
+		ctx = context.WithValue(ctx, config.ContextKeyRequestID{}, requestID)
+	}
+
+	// the args read from JSON hold numbers as float64
+	switch userID := args[JobUserIDArg].(type) {
+	case int64:
+		ctx = context.WithValue(ctx, config.ContextKeyJobUserID{}, userID)
+	case float64:
+		ctx = context.WithValue(ctx, config.ContextKeyJobUserID{}, int64(userID))
+	}
+
+	return ctx
+}
+
+// enqueueJob record a queued job of the viewer then enqueue it, the worker tracks its status by JobIDArg
+// the job is marked failed if it could not be enqueued
+func (s *Service) enqueueJob(ctx context.Context, name string, payload queue.Payload, job *entity.Job) error {
+	args, err := jobArgs(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	id, err := randomString(12)
+	if err != nil {
+		return err
+	}
+
+	*job = entity.Job{ID: id, Name: name, Status: entity.JobQueued}
+	if viewer, ok := Viewer(ctx); ok {
+		job.UserID = viewer.ID
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.AddJob(ctx, tx, job)
+	})
+	if err != nil {
+		return fmt.Errorf("could not add job; %w", err)
+	}
+
+	args[JobIDArg] = job.ID
+	_, err = s.enqueuer.Enqueue(name, args)
+	if err != nil {
+		if serr := s.SetJobStatus(ctx, job.ID, entity.JobFailed, err); serr != nil {
+			log.Error().Err(serr).Str("job", job.ID).Msg("could not mark job failed")
+		}
+		return fmt.Errorf("could not enqueue job; %w", err)
+	}
+
+	return nil
+}
+
+// GetJob return the status of a job, it fails with ErrNotFound
+func (s *Service) GetJob(ctx context.Context, jobID string, job *entity.Job) error {
+	err := s.store.FetchJob(ctx, jobID, job)
+	if err == errors.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch job; %w", err)
+	}
+
+	return nil
+}
+
+// SetJobStatus record the status of a tracked job, the text of jobErr is kept as its error
+func (s *Service) SetJobStatus(ctx context.Context, jobID, status string, jobErr error) error {
+	var errText string
+	if jobErr != nil {
+		errText = jobErr.Error()
+	}
+
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.SetJobStatus(ctx, tx, jobID, status, errText)
+	})
+	if err != nil {
+		return fmt.Errorf("could not set job status; %w", err)
+	}
+
+	return nil
+}
+
+// deadJobArgs are the args listed with the dead jobs, the payloads hold tokens and addresses so they are left out
+var deadJobArgs = []string{JobIDArg, JobRequestIDArg, JobUserIDArg}
+
+// FilterDeadJobs return a page of the dead jobs of the worker, the first page is 1, and the number of dead jobs
+func (s *Service) FilterDeadJobs(ctx context.Context, page uint, jobs *[]entity.DeadJob, total *int64) error {
+	dead, count, err := s.deadJobs.DeadJobs(page)
+	if err != nil {
+		return fmt.Errorf("could not list dead jobs; %w", err)
+	}
+
+	*jobs = make([]entity.DeadJob, 0, len(dead))
+	for _, j := range dead {
+		args := map[string]interface{}{}
+		for _, k := range deadJobArgs {
+			if v, ok := j.Args[k]; ok {
+				args[k] = v
+			}
+		}
+
+		*jobs = append(*jobs, entity.DeadJob{
+			ID:         j.ID,
+			Name:       j.Name,
+			Args:       args,
+			Fails:      j.Fails,
+			Error:      j.LastErr,
+			EnqueuedAt: time.Unix(j.EnqueuedAt, 0).UTC(),
+			DiedAt:     time.Unix(j.DiedAt, 0).UTC(),
+		})
+	}
+	*total = count
+
+	return nil
+}
+
+// RetryDeadJob enqueue a dead job again, it fails with ErrNotFound if there is no such dead job
+func (s *Service) RetryDeadJob(ctx context.Context, diedAt int64, jobID string) error {
+	err := s.deadJobs.RetryDeadJob(diedAt, jobID)
+	if err == work.ErrNotRetried {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not retry dead job; %w", err)
+	}
+
+	return nil
+}
+
+// RetryDeadJobs enqueue every dead job again
+func (s *Service) RetryDeadJobs(ctx context.Context) error {
+	if err := s.deadJobs.RetryAllDeadJobs(); err != nil {
+		return fmt.Errorf("could not retry dead jobs; %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDeadJob remove a dead job, it fails with ErrNotFound if there is no such dead job
+func (s *Service) DeleteDeadJob(ctx context.Context, diedAt int64, jobID string) error {
+	err := s.deadJobs.DeleteDeadJob(diedAt, jobID)
+	if err == work.ErrNotDeleted {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete dead job; %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDeadJobs remove every dead job
+func (s *Service) DeleteDeadJobs(ctx context.Context) error {
+	if err := s.deadJobs.DeleteAllDeadJobs(); err != nil {
+		return fmt.Errorf("could not delete dead jobs; %w", err)
+	}
+
+	return nil
+}
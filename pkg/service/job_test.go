@@ -0,0 +1,292 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	smock "boiler/pkg/service/mock"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// payloadArgs return the args of a job holding p with extra
+func payloadArgs(t *testing.T, p queue.Payload, extra map[string]interface{}) map[string]interface{} {
+	args, err := queue.EncodePayload(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range extra {
+		args[k] = v
+	}
+
+	return args
+}
+
+func TestEnqueueDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	enq := smock.NewMockEnqueuer(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{Enqueuer: enq})
+
+	ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{}, &entity.JWTUser{ID: 4})
+	ctx = context.WithValue(ctx, config.ContextKeyRequestID{}, "host/abc-000001")
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		var jobID string
+		m.EXPECT().Tx(gomock.Any()).Return(tx, nil)
+		m.EXPECT().AddJob(gomock.Any(), tx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, job *entity.Job) error {
+				assert.Equal(t, service.DeleteUser, job.Name)
+				assert.Equal(t, int64(4), job.UserID)
+				assert.Equal(t, entity.JobQueued, job.Status)
+				jobID = job.ID
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+		enq.EXPECT().Enqueue(service.DeleteUser, gomock.Any()).
+			DoAndReturn(func(_ string, args map[string]interface{}) (*work.Job, error) {
+				payload := entity.DeleteUserPayload{Version: entity.DeleteUserPayloadVersion, ID: 6}
+				assert.Equal(t, payloadArgs(t, &payload, map[string]interface{}{
+					service.JobIDArg:        jobID,
+					service.JobRequestIDArg: "host/abc-000001",
+					service.JobUserIDArg:    int64(4),
+				}), args)
+				return nil, nil
+			})
+
+		var job entity.Job
+		assert.Nil(t, srv.EnqueueDeleteUser(ctx, 6, &job))
+		assert.NotEmpty(t, job.ID)
+		assert.Equal(t, jobID, job.ID)
+	}
+
+	// fails and mark the job failed if it can not be enqueued
+	{
+		tx := mock.NewMockTx(ctrl)
+		tx2 := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(gomock.Any()).Return(tx, nil)
+		m.EXPECT().AddJob(gomock.Any(), tx, gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+		enq.EXPECT().Enqueue(service.DeleteUser, gomock.Any()).Return(nil, fmt.Errorf("opz"))
+		m.EXPECT().Tx(gomock.Any()).Return(tx2, nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), tx2, gomock.Any(), entity.JobFailed, "opz").Return(nil)
+		tx2.EXPECT().Commit().Return(nil)
+
+		var job entity.Job
+		err := srv.EnqueueDeleteUser(ctx, 6, &job)
+		assert.Equal(t, "could not enqueue job; opz", err.Error())
+	}
+
+	// fails if the job can not be added
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(gomock.Any()).Return(tx, nil)
+		m.EXPECT().AddJob(gomock.Any(), tx, gomock.Any()).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		var job entity.Job
+		err := srv.EnqueueDeleteUser(ctx, 6, &job)
+		assert.Equal(t, "could not add job; opz", err.Error())
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	ctx := context.Background()
+
+	// succeed
+	{
+		m.EXPECT().FetchJob(ctx, "j1", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, job *entity.Job) error {
+				*job = entity.Job{ID: "j1", Status: entity.JobSucceeded}
+				return nil
+			})
+
+		var job entity.Job
+		assert.Nil(t, srv.GetJob(ctx, "j1", &job))
+		assert.Equal(t, entity.JobSucceeded, job.Status)
+	}
+
+	// fails if not found
+	{
+		m.EXPECT().FetchJob(ctx, "j2", gomock.Any()).Return(errors.ErrNotFound)
+
+		var job entity.Job
+		assert.Equal(t, errors.ErrNotFound, srv.GetJob(ctx, "j2", &job))
+	}
+
+	// fails if store fails
+	{
+		m.EXPECT().FetchJob(ctx, "j3", gomock.Any()).Return(fmt.Errorf("opz"))
+
+		var job entity.Job
+		assert.Equal(t, "could not fetch job; opz", srv.GetJob(ctx, "j3", &job).Error())
+	}
+}
+
+func TestSetJobStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), tx, "j1", entity.JobFailed, "opz").Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.SetJobStatus(ctx, "j1", entity.JobFailed, fmt.Errorf("opz")))
+	}
+
+	// fails if not found
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetJobStatus(gomock.Any(), tx, "j2", entity.JobRunning, "").Return(errors.ErrNotFound)
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.SetJobStatus(ctx, "j2", entity.JobRunning, nil)
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+	}
+}
+
+func TestDeadJobs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dead := smock.NewMockDeadJobs(ctrl)
+
+	srv := service.New(&config.Config{}, mock.NewMockInterface(ctrl), service.Options{DeadJobs: dead})
+
+	ctx := context.Background()
+
+	// succeed to list the dead jobs
+	{
+		dead.EXPECT().DeadJobs(uint(2)).Return([]*work.DeadJob{{
+			DiedAt: 1600000100,
+			Job: &work.Job{ID: "a1", Name: service.DeleteUser, Args: map[string]interface{}{
+				queue.PayloadArg: "c2VjcmV0", service.JobIDArg: "j1", service.JobUserIDArg: float64(4)},
+				Fails: 5, LastErr: "database is locked", EnqueuedAt: 1600000000},
+		}}, int64(21), nil)
+
+		var (
+			jobs  []entity.DeadJob
+			total int64
+		)
+		assert.Nil(t, srv.FilterDeadJobs(ctx, 2, &jobs, &total))
+		assert.Equal(t, int64(21), total)
+		assert.Equal(t, []entity.DeadJob{{
+			ID:         "a1",
+			Name:       service.DeleteUser,
+			Args:       map[string]interface{}{service.JobIDArg: "j1", service.JobUserIDArg: float64(4)},
+			Fails:      5,
+			Error:      "database is locked",
+			EnqueuedAt: time.Unix(1600000000, 0).UTC(),
+			DiedAt:     time.Unix(1600000100, 0).UTC(),
+		}}, jobs)
+	}
+
+	// fails to list if the queue fails
+	{
+		dead.EXPECT().DeadJobs(uint(1)).Return(nil, int64(0), fmt.Errorf("opz"))
+
+		var (
+			jobs  []entity.DeadJob
+			total int64
+		)
+		err := srv.FilterDeadJobs(ctx, 1, &jobs, &total)
+		assert.Equal(t, "could not list dead jobs; opz", err.Error())
+	}
+
+	// succeed to retry and delete a dead job
+	{
+		dead.EXPECT().RetryDeadJob(int64(1600000100), "a1").Return(nil)
+		assert.Nil(t, srv.RetryDeadJob(ctx, 1600000100, "a1"))
+
+		dead.EXPECT().DeleteDeadJob(int64(1600000100), "a1").Return(nil)
+		assert.Nil(t, srv.DeleteDeadJob(ctx, 1600000100, "a1"))
+	}
+
+	// fails if the dead job is missing
+	{
+		dead.EXPECT().RetryDeadJob(int64(1600000100), "a1").Return(work.ErrNotRetried)
+		assert.Equal(t, errors.ErrNotFound, srv.RetryDeadJob(ctx, 1600000100, "a1"))
+
+		dead.EXPECT().DeleteDeadJob(int64(1600000100), "a1").Return(work.ErrNotDeleted)
+		assert.Equal(t, errors.ErrNotFound, srv.DeleteDeadJob(ctx, 1600000100, "a1"))
+	}
+
+	// succeed to retry and delete every dead job
+	{
+		dead.EXPECT().RetryAllDeadJobs().Return(nil)
+		assert.Nil(t, srv.RetryDeadJobs(ctx))
+
+		dead.EXPECT().DeleteAllDeadJobs().Return(nil)
+		assert.Nil(t, srv.DeleteDeadJobs(ctx))
+	}
+
+	// fails if the queue fails
+	{
+		dead.EXPECT().RetryAllDeadJobs().Return(fmt.Errorf("opz"))
+		assert.Equal(t, "could not retry dead jobs; opz", srv.RetryDeadJobs(ctx).Error())
+
+		dead.EXPECT().DeleteAllDeadJobs().Return(fmt.Errorf("opz"))
+		assert.Equal(t, "could not delete dead jobs; opz", srv.DeleteDeadJobs(ctx).Error())
+	}
+}
+
+func TestJobContext(t *testing.T) {
+	// succeed to restore the request and the user of the args, as read from JSON
+	{
+		ctx := service.JobContext(context.Background(), map[string]interface{}{
+			service.JobRequestIDArg: "host/abc-000001",
+			service.JobUserIDArg:    float64(4),
+		})
+		assert.Equal(t, "host/abc-000001", ctx.Value(config.ContextKeyRequestID{}))
+		assert.Equal(t, int64(4), ctx.Value(config.ContextKeyJobUserID{}))
+
+		_, ok := service.Viewer(ctx)
+		assert.False(t, ok)
+	}
+
+	// succeed to leave the context of a job without origin
+	{
+		ctx := service.JobContext(context.Background(), map[string]interface{}{"id": int64(6)})
+		assert.Nil(t, ctx.Value(config.ContextKeyRequestID{}))
+		assert.Nil(t, ctx.Value(config.ContextKeyJobUserID{}))
+	}
+}
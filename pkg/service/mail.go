@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store/mailer"
+)
+
+// EnqueueSendEmail enqueue the message template to to, data must be encodable by msgp
+func (s *Service) EnqueueSendEmail(ctx context.Context, to, template string, data map[string]interface{}) error {
+	payload := entity.SendEmailPayload{Version: entity.SendEmailPayloadVersion, To: to, Template: template, Data: data}
+	args, err := jobArgs(ctx, &payload)
+	if err != nil {
+		return fmt.Errorf("could not enqueue email; %w", err)
+	}
+
+	_, err = s.enqueuer.Enqueue(SendEmail, args)
+	if err != nil {
+		return fmt.Errorf("could not enqueue email; %w", err)
+	}
+
+	return nil
+}
+
+// SendEmail render the message template with data and send it to to
+func (s *Service) SendEmail(ctx context.Context, to, template string, data map[string]interface{}) error {
+	msg, err := mailer.Default.Render(template, to, data)
+	if err != nil {
+		return fmt.Errorf("could not render email; %w", err)
+	}
+
+	err = s.mailer.Send(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("could not send email; %w", err)
+	}
+
+	return nil
+}
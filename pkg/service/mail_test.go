@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	smock "boiler/pkg/service/mock"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// sendEmailPayload return the payload of the send_email job of args
+func sendEmailPayload(t *testing.T, args map[string]interface{}) entity.SendEmailPayload {
+	var payload entity.SendEmailPayload
+	if err := queue.DecodePayload(&work.Job{Args: args}, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+func TestSendEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var msgs sent
+	srv := service.New(&config.Config{}, mock.NewMockInterface(ctrl), service.Options{Mailer: &msgs})
+	ctx := context.Background()
+
+	// succeed
+	{
+		err := srv.SendEmail(ctx, "a@b.c", service.VerifyEmailTemplate, map[string]interface{}{
+			"Address": "a@b.c", "Token": "tok", "ExpireIn": "1h0m0s",
+		})
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, "a@b.c", msgs[0].To)
+		assert.Equal(t, "Verify your email", msgs[0].Subject)
+		assert.Contains(t, msgs[0].Text, "tok")
+		assert.Contains(t, msgs[0].HTML, "tok")
+	}
+
+	// fails if the template does not exist
+	{
+		err := srv.SendEmail(ctx, "a@b.c", "nope", nil)
+		assert.True(t, errors.Is(err, errors.ErrUnknownTemplate))
+	}
+}
+
+func TestEnqueueSendEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enq := smock.NewMockEnqueuer(ctrl)
+	srv := service.New(&config.Config{}, mock.NewMockInterface(ctrl), service.Options{Enqueuer: enq})
+	ctx := context.Background()
+
+	data := map[string]interface{}{"Token": "tok"}
+
+	// succeed
+	{
+		payload := entity.SendEmailPayload{Version: entity.SendEmailPayloadVersion, To: "a@b.c", Template: "t", Data: data}
+		enq.EXPECT().Enqueue(service.SendEmail, payloadArgs(t, &payload, nil)).Return(nil, nil)
+
+		assert.Nil(t, srv.EnqueueSendEmail(ctx, "a@b.c", "t", data))
+	}
+
+	// fails if the payload is invalid
+	{
+		err := srv.EnqueueSendEmail(ctx, "", "t", data)
+		assert.True(t, errors.Is(err, errors.ErrInvalidEmailAddress))
+	}
+
+	// fails if the enqueue fails
+	{
+		enq.EXPECT().Enqueue(service.SendEmail, gomock.Any()).Return(nil, fmt.Errorf("opz"))
+
+		err := srv.EnqueueSendEmail(ctx, "a@b.c", "t", data)
+		assert.Equal(t, "could not enqueue email; opz", err.Error())
+	}
+}
@@ -6,13 +6,150 @@ package mock
 
 import (
 	entity "boiler/pkg/entity"
+	service "boiler/pkg/service"
 	store "boiler/pkg/store"
 	context "context"
 	reflect "reflect"
+	time "time"
 
+	work "github.com/gocraft/work"
 	gomock "github.com/golang/mock/gomock"
+	jwk "github.com/lestrrat-go/jwx/jwk"
 )
 
+// MockEnqueuer is a mock of Enqueuer interface.
+type MockEnqueuer struct {
+	ctrl     *gomock.Controller
+	recorder *MockEnqueuerMockRecorder
+}
+
+// MockEnqueuerMockRecorder is the mock recorder for MockEnqueuer.
+type MockEnqueuerMockRecorder struct {
+	mock *MockEnqueuer
+}
+
+// NewMockEnqueuer creates a new mock instance.
+func NewMockEnqueuer(ctrl *gomock.Controller) *MockEnqueuer {
+	mock := &MockEnqueuer{ctrl: ctrl}
+	mock.recorder = &MockEnqueuerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEnqueuer) EXPECT() *MockEnqueuerMockRecorder {
+	return m.recorder
+}
+
+// Enqueue mocks base method.
+func (m *MockEnqueuer) Enqueue(jobName string, args map[string]interface{}) (*work.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", jobName, args)
+	ret0, _ := ret[0].(*work.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockEnqueuerMockRecorder) Enqueue(jobName, args interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockEnqueuer)(nil).Enqueue), jobName, args)
+}
+
+// MockDeadJobs is a mock of DeadJobs interface.
+type MockDeadJobs struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadJobsMockRecorder
+}
+
+// MockDeadJobsMockRecorder is the mock recorder for MockDeadJobs.
+type MockDeadJobsMockRecorder struct {
+	mock *MockDeadJobs
+}
+
+// NewMockDeadJobs creates a new mock instance.
+func NewMockDeadJobs(ctrl *gomock.Controller) *MockDeadJobs {
+	mock := &MockDeadJobs{ctrl: ctrl}
+	mock.recorder = &MockDeadJobsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadJobs) EXPECT() *MockDeadJobsMockRecorder {
+	return m.recorder
+}
+
+// DeadJobs mocks base method.
+func (m *MockDeadJobs) DeadJobs(page uint) ([]*work.DeadJob, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeadJobs", page)
+	ret0, _ := ret[0].([]*work.DeadJob)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// DeadJobs indicates an expected call of DeadJobs.
+func (mr *MockDeadJobsMockRecorder) DeadJobs(page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeadJobs", reflect.TypeOf((*MockDeadJobs)(nil).DeadJobs), page)
+}
+
+// DeleteAllDeadJobs mocks base method.
+func (m *MockDeadJobs) DeleteAllDeadJobs() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAllDeadJobs")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAllDeadJobs indicates an expected call of DeleteAllDeadJobs.
+func (mr *MockDeadJobsMockRecorder) DeleteAllDeadJobs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllDeadJobs", reflect.TypeOf((*MockDeadJobs)(nil).DeleteAllDeadJobs))
+}
+
+// DeleteDeadJob mocks base method.
+func (m *MockDeadJobs) DeleteDeadJob(diedAt int64, jobID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeadJob", diedAt, jobID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeadJob indicates an expected call of DeleteDeadJob.
+func (mr *MockDeadJobsMockRecorder) DeleteDeadJob(diedAt, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeadJob", reflect.TypeOf((*MockDeadJobs)(nil).DeleteDeadJob), diedAt, jobID)
+}
+
+// RetryAllDeadJobs mocks base method.
+func (m *MockDeadJobs) RetryAllDeadJobs() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryAllDeadJobs")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryAllDeadJobs indicates an expected call of RetryAllDeadJobs.
+func (mr *MockDeadJobsMockRecorder) RetryAllDeadJobs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryAllDeadJobs", reflect.TypeOf((*MockDeadJobs)(nil).RetryAllDeadJobs))
+}
+
+// RetryDeadJob mocks base method.
+func (m *MockDeadJobs) RetryDeadJob(diedAt int64, jobID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryDeadJob", diedAt, jobID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryDeadJob indicates an expected call of RetryDeadJob.
+func (mr *MockDeadJobsMockRecorder) RetryDeadJob(diedAt, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryDeadJob", reflect.TypeOf((*MockDeadJobs)(nil).RetryDeadJob), diedAt, jobID)
+}
+
 // MockInterface is a mock of Interface interface.
 type MockInterface struct {
 	ctrl     *gomock.Controller
@@ -36,6 +173,34 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// AcceptInvitation mocks base method.
+func (m *MockInterface) AcceptInvitation(ctx context.Context, token string, member *entity.Member) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptInvitation", ctx, token, member)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AcceptInvitation indicates an expected call of AcceptInvitation.
+func (mr *MockInterfaceMockRecorder) AcceptInvitation(ctx, token, member interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptInvitation", reflect.TypeOf((*MockInterface)(nil).AcceptInvitation), ctx, token, member)
+}
+
+// AddAPIKey mocks base method.
+func (m *MockInterface) AddAPIKey(ctx context.Context, key *entity.APIKey, raw *string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAPIKey", ctx, key, raw)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAPIKey indicates an expected call of AddAPIKey.
+func (mr *MockInterfaceMockRecorder) AddAPIKey(ctx, key, raw interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAPIKey", reflect.TypeOf((*MockInterface)(nil).AddAPIKey), ctx, key, raw)
+}
+
 // AddEmail mocks base method.
 func (m *MockInterface) AddEmail(arg0 context.Context, arg1 *entity.Email) error {
 	m.ctrl.T.Helper()
@@ -50,22 +215,69 @@ func (mr *MockInterfaceMockRecorder) AddEmail(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEmail", reflect.TypeOf((*MockInterface)(nil).AddEmail), arg0, arg1)
 }
 
+// AddOrganization mocks base method.
+func (m *MockInterface) AddOrganization(ctx context.Context, org *entity.Organization, ownerID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrganization", ctx, org, ownerID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOrganization indicates an expected call of AddOrganization.
+func (mr *MockInterfaceMockRecorder) AddOrganization(ctx, org, ownerID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrganization", reflect.TypeOf((*MockInterface)(nil).AddOrganization), ctx, org, ownerID)
+}
+
 // AddUser mocks base method.
-func (m *MockInterface) AddUser(arg0 context.Context, arg1 *entity.User) error {
+func (m *MockInterface) AddUser(ctx context.Context, user *entity.User, emails ...*entity.Email) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", arg0, arg1)
+	varargs := []interface{}{ctx, user}
+	for _, a := range emails {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddUser", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUser indicates an expected call of AddUser.
-func (mr *MockInterfaceMockRecorder) AddUser(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) AddUser(ctx, user interface{}, emails ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, user}, emails...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockInterface)(nil).AddUser), varargs...)
+}
+
+// AuthAPIKey mocks base method.
+func (m *MockInterface) AuthAPIKey(ctx context.Context, raw string, viewer *entity.JWTUser) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthAPIKey", ctx, raw, viewer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AuthAPIKey indicates an expected call of AuthAPIKey.
+func (mr *MockInterfaceMockRecorder) AuthAPIKey(ctx, raw, viewer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthAPIKey", reflect.TypeOf((*MockInterface)(nil).AuthAPIKey), ctx, raw, viewer)
+}
+
+// AuthTOTP mocks base method.
+func (m *MockInterface) AuthTOTP(ctx context.Context, challenge, code string, user *entity.User, tokens *entity.Tokens) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthTOTP", ctx, challenge, code, user, tokens)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AuthTOTP indicates an expected call of AuthTOTP.
+func (mr *MockInterfaceMockRecorder) AuthTOTP(ctx, challenge, code, user, tokens interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockInterface)(nil).AddUser), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthTOTP", reflect.TypeOf((*MockInterface)(nil).AuthTOTP), ctx, challenge, code, user, tokens)
 }
 
 // AuthUser mocks base method.
-func (m *MockInterface) AuthUser(arg0 context.Context, arg1, arg2 string, arg3 *entity.User, arg4 *string) error {
+func (m *MockInterface) AuthUser(arg0 context.Context, arg1, arg2 string, arg3 *entity.User, arg4 *entity.Tokens) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AuthUser", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(error)
@@ -78,6 +290,76 @@ func (mr *MockInterfaceMockRecorder) AuthUser(arg0, arg1, arg2, arg3, arg4 inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthUser", reflect.TypeOf((*MockInterface)(nil).AuthUser), arg0, arg1, arg2, arg3, arg4)
 }
 
+// Authorize mocks base method.
+func (m *MockInterface) Authorize(ctx context.Context, action service.Action, resource service.Resource) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", ctx, action, resource)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockInterfaceMockRecorder) Authorize(ctx, action, resource interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockInterface)(nil).Authorize), ctx, action, resource)
+}
+
+// ChangePassword mocks base method.
+func (m *MockInterface) ChangePassword(ctx context.Context, userID int64, current, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, userID, current, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockInterfaceMockRecorder) ChangePassword(ctx, userID, current, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockInterface)(nil).ChangePassword), ctx, userID, current, password)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockInterface) ConfirmTOTP(ctx context.Context, userID int64, code string, recoveryCodes *[]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userID, code, recoveryCodes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockInterfaceMockRecorder) ConfirmTOTP(ctx, userID, code, recoveryCodes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockInterface)(nil).ConfirmTOTP), ctx, userID, code, recoveryCodes)
+}
+
+// DeleteDeadJob mocks base method.
+func (m *MockInterface) DeleteDeadJob(ctx context.Context, diedAt int64, jobID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeadJob", ctx, diedAt, jobID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeadJob indicates an expected call of DeleteDeadJob.
+func (mr *MockInterfaceMockRecorder) DeleteDeadJob(ctx, diedAt, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeadJob", reflect.TypeOf((*MockInterface)(nil).DeleteDeadJob), ctx, diedAt, jobID)
+}
+
+// DeleteDeadJobs mocks base method.
+func (m *MockInterface) DeleteDeadJobs(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeadJobs", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeadJobs indicates an expected call of DeleteDeadJobs.
+func (mr *MockInterfaceMockRecorder) DeleteDeadJobs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeadJobs", reflect.TypeOf((*MockInterface)(nil).DeleteDeadJobs), ctx)
+}
+
 // DeleteEmail mocks base method.
 func (m *MockInterface) DeleteEmail(arg0 context.Context, arg1 int64) error {
 	m.ctrl.T.Helper()
@@ -120,32 +402,172 @@ func (mr *MockInterfaceMockRecorder) EnqueueDeleteEmail(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDeleteEmail", reflect.TypeOf((*MockInterface)(nil).EnqueueDeleteEmail), arg0, arg1)
 }
 
+// EnqueueDeleteUser mocks base method.
+func (m *MockInterface) EnqueueDeleteUser(ctx context.Context, userID int64, job *entity.Job) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueDeleteUser", ctx, userID, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueDeleteUser indicates an expected call of EnqueueDeleteUser.
+func (mr *MockInterfaceMockRecorder) EnqueueDeleteUser(ctx, userID, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDeleteUser", reflect.TypeOf((*MockInterface)(nil).EnqueueDeleteUser), ctx, userID, job)
+}
+
+// EnqueueSendEmail mocks base method.
+func (m *MockInterface) EnqueueSendEmail(ctx context.Context, to, template string, data map[string]interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueSendEmail", ctx, to, template, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueSendEmail indicates an expected call of EnqueueSendEmail.
+func (mr *MockInterfaceMockRecorder) EnqueueSendEmail(ctx, to, template, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueSendEmail", reflect.TypeOf((*MockInterface)(nil).EnqueueSendEmail), ctx, to, template, data)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockInterface) EnrollTOTP(ctx context.Context, userID int64, enrollment *entity.TOTPEnrollment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, userID, enrollment)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockInterfaceMockRecorder) EnrollTOTP(ctx, userID, enrollment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockInterface)(nil).EnrollTOTP), ctx, userID, enrollment)
+}
+
+// FilterAPIKeys mocks base method.
+func (m *MockInterface) FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterAPIKeys", ctx, userID, keys)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterAPIKeys indicates an expected call of FilterAPIKeys.
+func (mr *MockInterfaceMockRecorder) FilterAPIKeys(ctx, userID, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterAPIKeys", reflect.TypeOf((*MockInterface)(nil).FilterAPIKeys), ctx, userID, keys)
+}
+
+// FilterDeadJobs mocks base method.
+func (m *MockInterface) FilterDeadJobs(ctx context.Context, page uint, jobs *[]entity.DeadJob, total *int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterDeadJobs", ctx, page, jobs, total)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterDeadJobs indicates an expected call of FilterDeadJobs.
+func (mr *MockInterfaceMockRecorder) FilterDeadJobs(ctx, page, jobs, total interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterDeadJobs", reflect.TypeOf((*MockInterface)(nil).FilterDeadJobs), ctx, page, jobs, total)
+}
+
 // FilterEmails mocks base method.
-func (m *MockInterface) FilterEmails(arg0 context.Context, arg1 store.FilterEmails, arg2 *[]entity.Email) error {
+func (m *MockInterface) FilterEmails(arg0 context.Context, arg1 store.FilterEmails, arg2 *[]entity.Email, arg3 *store.PageInfo) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FilterEmails", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "FilterEmails", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // FilterEmails indicates an expected call of FilterEmails.
-func (mr *MockInterfaceMockRecorder) FilterEmails(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) FilterEmails(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterEmails", reflect.TypeOf((*MockInterface)(nil).FilterEmails), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterEmails", reflect.TypeOf((*MockInterface)(nil).FilterEmails), arg0, arg1, arg2, arg3)
+}
+
+// FilterMembers mocks base method.
+func (m *MockInterface) FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterMembers", ctx, orgID, members)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterMembers indicates an expected call of FilterMembers.
+func (mr *MockInterfaceMockRecorder) FilterMembers(ctx, orgID, members interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterMembers", reflect.TypeOf((*MockInterface)(nil).FilterMembers), ctx, orgID, members)
+}
+
+// FilterOrganizations mocks base method.
+func (m *MockInterface) FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterOrganizations", ctx, userID, orgs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterOrganizations indicates an expected call of FilterOrganizations.
+func (mr *MockInterfaceMockRecorder) FilterOrganizations(ctx, userID, orgs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterOrganizations", reflect.TypeOf((*MockInterface)(nil).FilterOrganizations), ctx, userID, orgs)
+}
+
+// FilterSchedules mocks base method.
+func (m *MockInterface) FilterSchedules(ctx context.Context, schedules *[]entity.Schedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterSchedules", ctx, schedules)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterSchedules indicates an expected call of FilterSchedules.
+func (mr *MockInterfaceMockRecorder) FilterSchedules(ctx, schedules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterSchedules", reflect.TypeOf((*MockInterface)(nil).FilterSchedules), ctx, schedules)
 }
 
 // FilterUsers mocks base method.
-func (m *MockInterface) FilterUsers(arg0 context.Context, arg1 store.FilterUsers, arg2 *[]entity.User) error {
+func (m *MockInterface) FilterUsers(arg0 context.Context, arg1 store.FilterUsers, arg2 *[]entity.User, arg3 *store.PageInfo) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FilterUsers", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "FilterUsers", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // FilterUsers indicates an expected call of FilterUsers.
-func (mr *MockInterfaceMockRecorder) FilterUsers(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) FilterUsers(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterUsers", reflect.TypeOf((*MockInterface)(nil).FilterUsers), arg0, arg1, arg2, arg3)
+}
+
+// GetJob mocks base method.
+func (m *MockInterface) GetJob(ctx context.Context, jobID string, job *entity.Job) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJob", ctx, jobID, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetJob indicates an expected call of GetJob.
+func (mr *MockInterfaceMockRecorder) GetJob(ctx, jobID, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJob", reflect.TypeOf((*MockInterface)(nil).GetJob), ctx, jobID, job)
+}
+
+// GetOrganizationByID mocks base method.
+func (m *MockInterface) GetOrganizationByID(ctx context.Context, orgID int64, org *entity.Organization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrganizationByID", ctx, orgID, org)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetOrganizationByID indicates an expected call of GetOrganizationByID.
+func (mr *MockInterfaceMockRecorder) GetOrganizationByID(ctx, orgID, org interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterUsers", reflect.TypeOf((*MockInterface)(nil).FilterUsers), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrganizationByID", reflect.TypeOf((*MockInterface)(nil).GetOrganizationByID), ctx, orgID, org)
 }
 
 // GetUserByEmail mocks base method.
@@ -175,3 +597,370 @@ func (mr *MockInterfaceMockRecorder) GetUserByID(arg0, arg1, arg2 interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockInterface)(nil).GetUserByID), arg0, arg1, arg2)
 }
+
+// GetUserRoles mocks base method.
+func (m *MockInterface) GetUserRoles(ctx context.Context, userID int64, roles *[]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRoles", ctx, userID, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetUserRoles indicates an expected call of GetUserRoles.
+func (mr *MockInterfaceMockRecorder) GetUserRoles(ctx, userID, roles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRoles", reflect.TypeOf((*MockInterface)(nil).GetUserRoles), ctx, userID, roles)
+}
+
+// InviteMember mocks base method.
+func (m *MockInterface) InviteMember(ctx context.Context, orgID int64, address, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InviteMember", ctx, orgID, address, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InviteMember indicates an expected call of InviteMember.
+func (mr *MockInterfaceMockRecorder) InviteMember(ctx, orgID, address, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InviteMember", reflect.TypeOf((*MockInterface)(nil).InviteMember), ctx, orgID, address, role)
+}
+
+// IsTokenDenied mocks base method.
+func (m *MockInterface) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenDenied", ctx, jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenDenied indicates an expected call of IsTokenDenied.
+func (mr *MockInterfaceMockRecorder) IsTokenDenied(ctx, jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenDenied", reflect.TypeOf((*MockInterface)(nil).IsTokenDenied), ctx, jti)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockInterface) IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", ctx, userID, issuedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockInterfaceMockRecorder) IsTokenRevoked(ctx, userID, issuedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockInterface)(nil).IsTokenRevoked), ctx, userID, issuedAt)
+}
+
+// JWKS mocks base method.
+func (m *MockInterface) JWKS(ctx context.Context) (jwk.Set, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS", ctx)
+	ret0, _ := ret[0].(jwk.Set)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockInterfaceMockRecorder) JWKS(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockInterface)(nil).JWKS), ctx)
+}
+
+// Logout mocks base method.
+func (m *MockInterface) Logout(ctx context.Context, refresh string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, refresh)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockInterfaceMockRecorder) Logout(ctx, refresh interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockInterface)(nil).Logout), ctx, refresh)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockInterface) PurgeDeleted(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockInterfaceMockRecorder) PurgeDeleted(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockInterface)(nil).PurgeDeleted), ctx)
+}
+
+// PurgeOrphanedEmails mocks base method.
+func (m *MockInterface) PurgeOrphanedEmails(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeOrphanedEmails", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeOrphanedEmails indicates an expected call of PurgeOrphanedEmails.
+func (mr *MockInterfaceMockRecorder) PurgeOrphanedEmails(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeOrphanedEmails", reflect.TypeOf((*MockInterface)(nil).PurgeOrphanedEmails), ctx)
+}
+
+// PurgeTokens mocks base method.
+func (m *MockInterface) PurgeTokens(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTokens", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeTokens indicates an expected call of PurgeTokens.
+func (mr *MockInterfaceMockRecorder) PurgeTokens(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTokens", reflect.TypeOf((*MockInterface)(nil).PurgeTokens), ctx)
+}
+
+// RecordScheduleRun mocks base method.
+func (m *MockInterface) RecordScheduleRun(ctx context.Context, job string, at time.Time, jobErr error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordScheduleRun", ctx, job, at, jobErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordScheduleRun indicates an expected call of RecordScheduleRun.
+func (mr *MockInterfaceMockRecorder) RecordScheduleRun(ctx, job, at, jobErr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordScheduleRun", reflect.TypeOf((*MockInterface)(nil).RecordScheduleRun), ctx, job, at, jobErr)
+}
+
+// RefreshToken mocks base method.
+func (m *MockInterface) RefreshToken(ctx context.Context, refresh string, user *entity.User, tokens *entity.Tokens) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshToken", ctx, refresh, user, tokens)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RefreshToken indicates an expected call of RefreshToken.
+func (mr *MockInterfaceMockRecorder) RefreshToken(ctx, refresh, user, tokens interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshToken", reflect.TypeOf((*MockInterface)(nil).RefreshToken), ctx, refresh, user, tokens)
+}
+
+// RequestPasswordReset mocks base method.
+func (m *MockInterface) RequestPasswordReset(ctx context.Context, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPasswordReset", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPasswordReset indicates an expected call of RequestPasswordReset.
+func (mr *MockInterfaceMockRecorder) RequestPasswordReset(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPasswordReset", reflect.TypeOf((*MockInterface)(nil).RequestPasswordReset), ctx, email)
+}
+
+// ResetPassword mocks base method.
+func (m *MockInterface) ResetPassword(ctx context.Context, token, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, token, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockInterfaceMockRecorder) ResetPassword(ctx, token, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockInterface)(nil).ResetPassword), ctx, token, password)
+}
+
+// RestoreEmail mocks base method.
+func (m *MockInterface) RestoreEmail(ctx context.Context, emailID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreEmail", ctx, emailID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreEmail indicates an expected call of RestoreEmail.
+func (mr *MockInterfaceMockRecorder) RestoreEmail(ctx, emailID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreEmail", reflect.TypeOf((*MockInterface)(nil).RestoreEmail), ctx, emailID)
+}
+
+// RestoreUser mocks base method.
+func (m *MockInterface) RestoreUser(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockInterfaceMockRecorder) RestoreUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockInterface)(nil).RestoreUser), ctx, userID)
+}
+
+// RetryDeadJob mocks base method.
+func (m *MockInterface) RetryDeadJob(ctx context.Context, diedAt int64, jobID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryDeadJob", ctx, diedAt, jobID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryDeadJob indicates an expected call of RetryDeadJob.
+func (mr *MockInterfaceMockRecorder) RetryDeadJob(ctx, diedAt, jobID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryDeadJob", reflect.TypeOf((*MockInterface)(nil).RetryDeadJob), ctx, diedAt, jobID)
+}
+
+// RetryDeadJobs mocks base method.
+func (m *MockInterface) RetryDeadJobs(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryDeadJobs", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryDeadJobs indicates an expected call of RetryDeadJobs.
+func (mr *MockInterfaceMockRecorder) RetryDeadJobs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryDeadJobs", reflect.TypeOf((*MockInterface)(nil).RetryDeadJobs), ctx)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockInterface) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, userID, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockInterfaceMockRecorder) RevokeAPIKey(ctx, userID, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockInterface)(nil).RevokeAPIKey), ctx, userID, keyID)
+}
+
+// SendEmail mocks base method.
+func (m *MockInterface) SendEmail(ctx context.Context, to, template string, data map[string]interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendEmail", ctx, to, template, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendEmail indicates an expected call of SendEmail.
+func (mr *MockInterfaceMockRecorder) SendEmail(ctx, to, template, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmail", reflect.TypeOf((*MockInterface)(nil).SendEmail), ctx, to, template, data)
+}
+
+// SendPasswordReset mocks base method.
+func (m *MockInterface) SendPasswordReset(ctx context.Context, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendPasswordReset", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendPasswordReset indicates an expected call of SendPasswordReset.
+func (mr *MockInterfaceMockRecorder) SendPasswordReset(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendPasswordReset", reflect.TypeOf((*MockInterface)(nil).SendPasswordReset), ctx, email)
+}
+
+// SendVerifyEmail mocks base method.
+func (m *MockInterface) SendVerifyEmail(ctx context.Context, emailID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendVerifyEmail", ctx, emailID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendVerifyEmail indicates an expected call of SendVerifyEmail.
+func (mr *MockInterfaceMockRecorder) SendVerifyEmail(ctx, emailID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendVerifyEmail", reflect.TypeOf((*MockInterface)(nil).SendVerifyEmail), ctx, emailID)
+}
+
+// SetJobStatus mocks base method.
+func (m *MockInterface) SetJobStatus(ctx context.Context, jobID, status string, jobErr error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetJobStatus", ctx, jobID, status, jobErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetJobStatus indicates an expected call of SetJobStatus.
+func (mr *MockInterfaceMockRecorder) SetJobStatus(ctx, jobID, status, jobErr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetJobStatus", reflect.TypeOf((*MockInterface)(nil).SetJobStatus), ctx, jobID, status, jobErr)
+}
+
+// SetUserRoles mocks base method.
+func (m *MockInterface) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRoles", ctx, userID, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserRoles indicates an expected call of SetUserRoles.
+func (mr *MockInterfaceMockRecorder) SetUserRoles(ctx, userID, roles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRoles", reflect.TypeOf((*MockInterface)(nil).SetUserRoles), ctx, userID, roles)
+}
+
+// SwitchOrganization mocks base method.
+func (m *MockInterface) SwitchOrganization(ctx context.Context, refresh string, orgID int64, user *entity.User, tokens *entity.Tokens) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SwitchOrganization", ctx, refresh, orgID, user, tokens)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SwitchOrganization indicates an expected call of SwitchOrganization.
+func (mr *MockInterfaceMockRecorder) SwitchOrganization(ctx, refresh, orgID, user, tokens interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SwitchOrganization", reflect.TypeOf((*MockInterface)(nil).SwitchOrganization), ctx, refresh, orgID, user, tokens)
+}
+
+// UpdateUser mocks base method.
+func (m *MockInterface) UpdateUser(arg0 context.Context, arg1 *entity.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockInterfaceMockRecorder) UpdateUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockInterface)(nil).UpdateUser), arg0, arg1)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockInterface) VerifyEmail(ctx context.Context, token string, email *entity.Email) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, token, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockInterfaceMockRecorder) VerifyEmail(ctx, token, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockInterface)(nil).VerifyEmail), ctx, token, email)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+const (
+	// OrganizationClaim is the claim of the current organization of the access tokens
+	OrganizationClaim = "org"
+	// InvitationAudience is the audience of the tokens inviting to join an organization
+	InvitationAudience = "org_invitation"
+	// OrgRoleClaim is the claim of the role given by an invitation
+	OrgRoleClaim = "org_role"
+	// InviteMemberTemplate is the template of the message inviting to join an organization
+	InviteMemberTemplate = "invite_member"
+)
+
+// AddOrganization create an organization owned by the user
+func (s *Service) AddOrganization(ctx context.Context, org *entity.Organization, ownerID int64) error {
+	org.Name = strings.TrimSpace(org.Name)
+	if len(org.Name) == 0 {
+		return errors.ErrInvalidName
+	}
+
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.AddOrganization(ctx, tx, org)
+		if err != nil {
+			return err
+		}
+
+		return s.store.AddMember(ctx, tx, &entity.Member{
+			OrganizationID: org.ID,
+			UserID:         ownerID,
+			Role:           entity.OrgRoleOwner,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("could not add organization; %w", err)
+	}
+
+	return nil
+}
+
+// GetOrganizationByID return an organization, it fails with ErrNotFound
+func (s *Service) GetOrganizationByID(ctx context.Context, orgID int64, org *entity.Organization) error {
+	err := s.store.FetchOrganization(ctx, orgID, org)
+	if err == errors.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch organization; %w", err)
+	}
+
+	return nil
+}
+
+// FilterOrganizations return the organizations of the user
+func (s *Service) FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error {
+	err := s.store.FilterOrganizations(ctx, userID, orgs)
+	if err != nil {
+		return fmt.Errorf("could not list organizations; %w", err)
+	}
+
+	return nil
+}
+
+// FilterMembers return the members of an organization
+func (s *Service) FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error {
+	err := s.store.FilterMembers(ctx, orgID, members)
+	if err != nil {
+		return fmt.Errorf("could not list members; %w", err)
+	}
+
+	return nil
+}
+
+// InviteMember enqueue a message inviting the address to join the organization with role
+// the invitation is a signed token, it is accepted once by the user having the address
+func (s *Service) InviteMember(ctx context.Context, orgID int64, address, role string) error {
+	if role != entity.OrgRoleAdmin && role != entity.OrgRoleMember {
+		return errors.ErrInvalidRole
+	}
+
+	var org entity.Organization
+	err := s.GetOrganizationByID(ctx, orgID, &org)
+	if err != nil {
+		return err
+	}
+
+	jti, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t := jwt.New()
+	_ = t.Set(jwt.SubjectKey, strconv.FormatInt(org.ID, 10))
+	_ = t.Set(jwt.IssuedAtKey, now.Unix())
+	_ = t.Set(jwt.ExpirationKey, now.Add(s.config.Auth.InvitationExpireIn).Unix())
+	_ = t.Set(jwt.AudienceKey, InvitationAudience)
+	_ = t.Set(jwt.IssuerKey, s.config.JWT.Issuer)
+	_ = t.Set(jwt.JwtIDKey, jti)
+	_ = t.Set(EmailClaim, address)
+	_ = t.Set(OrgRoleClaim, role)
+
+	token, err := s.config.JWT.Keys.Sign(t)
+	if err != nil {
+		return err
+	}
+
+	return s.EnqueueSendEmail(ctx, address, InviteMemberTemplate, map[string]interface{}{
+		"Organization": org.Name,
+		"Role":         role,
+		"Token":        string(token),
+		"ExpireIn":     s.config.Auth.InvitationExpireIn.String(),
+	})
+}
+
+// AcceptInvitation add the authenticated user to the organization of the invitation
+// the user must have the invited address, an invitation is accepted once
+func (s *Service) AcceptInvitation(ctx context.Context, token string, member *entity.Member) error {
+	viewer, ok := Viewer(ctx)
+	if !ok {
+		return errors.ErrUnauthorized
+	}
+
+	t, err := s.config.JWT.Keys.Parse(token)
+	if err != nil || !HasAudience(t, InvitationAudience) || len(t.JwtID()) == 0 {
+		return errors.ErrInvalidInvitation
+	}
+
+	orgID, err := strconv.ParseInt(t.Subject(), 10, 64)
+	if err != nil {
+		return errors.ErrInvalidInvitation
+	}
+
+	address, _ := t.Get(EmailClaim)
+	role, _ := t.Get(OrgRoleClaim)
+	if _, ok := role.(string); !ok {
+		return errors.ErrInvalidInvitation
+	}
+
+	denied, err := s.IsTokenDenied(ctx, t.JwtID())
+	if err != nil {
+		return err
+	}
+	if denied {
+		return errors.ErrInvalidInvitation
+	}
+
+	var emails []entity.Email
+	err = s.store.FilterEmails(ctx, store.FilterEmails{Address: fmt.Sprint(address), AllOrganizations: true}, &emails)
+	if err != nil {
+		return fmt.Errorf("could not filter emails; %w", err)
+	}
+	if len(emails) != 1 || emails[0].UserID != viewer.ID {
+		return errors.ErrForbidden
+	}
+
+	*member = entity.Member{OrganizationID: orgID, UserID: viewer.ID, Role: role.(string)}
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.AddMember(ctx, tx, member)
+		if err == errors.ErrAlreadyExists {
+			return errors.ErrAlreadyMember
+		}
+		if err != nil {
+			return err
+		}
+
+		return s.store.DenyToken(ctx, tx, t.JwtID(), t.Expiration())
+	})
+	if err == errors.ErrAlreadyMember {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not accept invitation; %w", err)
+	}
+
+	return nil
+}
+
+// SwitchOrganization exchange a refresh token for new tokens of another organization of the user
+// it fails with ErrNotMember if the user is not a member of the organization
+func (s *Service) SwitchOrganization(ctx context.Context, refresh string, orgID int64, user *entity.User, tokens *entity.Tokens) error {
+	var token entity.RefreshToken
+	err := s.fetchRefreshToken(ctx, refresh, &token)
+	if err != nil {
+		return err
+	}
+
+	var member entity.Member
+	err = s.store.FetchMember(ctx, orgID, token.UserID, &member)
+	if err == errors.ErrNotFound {
+		return errors.ErrNotMember
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch member; %w", err)
+	}
+
+	return s.rotate(ctx, &token, orgID, user, tokens)
+}
+
+// organization return the organization of the tokens of the user, orgID if the user is a member
+// otherwise its first organization, zero if it has none
+func (s *Service) organization(ctx context.Context, userID, orgID int64) (int64, error) {
+	var orgs []entity.Organization
+	err := s.store.FilterOrganizations(ctx, userID, &orgs)
+	if err != nil {
+		return 0, fmt.Errorf("could not list organizations; %w", err)
+	}
+
+	if len(orgs) == 0 {
+		return 0, nil
+	}
+
+	for _, org := range orgs {
+		if org.ID == orgID {
+			return orgID, nil
+		}
+	}
+
+	return orgs[0].ID, nil
+}
+
+// viewerOrganization return the current organization of the authenticated user, zero if it has none
+func viewerOrganization(ctx context.Context) int64 {
+	viewer, ok := Viewer(ctx)
+	if !ok {
+		return 0
+	}
+
+	return viewer.OrganizationID
+}
@@ -0,0 +1,280 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+	"boiler/pkg/service"
+	smock "boiler/pkg/service/mock"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddOrganization(gomock.Any(), tx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, org *entity.Organization) error {
+				assert.Equal(t, "acme", org.Name)
+				org.ID = 3
+				return nil
+			})
+		m.EXPECT().AddMember(gomock.Any(), tx, &entity.Member{OrganizationID: 3, UserID: 4, Role: entity.OrgRoleOwner}).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		org := entity.Organization{Name: " acme "}
+		assert.Nil(t, srv.AddOrganization(ctx, &org, 4))
+		assert.Equal(t, int64(3), org.ID)
+	}
+
+	// fails if name is empty
+	{
+		assert.Equal(t, errors.ErrInvalidName, srv.AddOrganization(ctx, &entity.Organization{Name: " "}, 4))
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddOrganization(gomock.Any(), tx, gomock.Any()).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.AddOrganization(ctx, &entity.Organization{Name: "acme"}, 4)
+		assert.Equal(t, "could not add organization; opz", err.Error())
+	}
+}
+
+func TestInvitation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	m := mock.NewMockInterface(ctrl)
+	enq := smock.NewMockEnqueuer(ctrl)
+	srv := service.New(&config.Config{
+		JWT:  config.JWT{Keys: keyset.New(time.Minute, key)},
+		Auth: config.Auth{InvitationExpireIn: time.Hour},
+	}, m, service.Options{Enqueuer: enq})
+	ctx := context.Background()
+	viewerCtx := context.WithValue(ctx, config.ContextKeyAuthenticationUser{}, &entity.JWTUser{ID: 4})
+
+	invite := func() string {
+		m.EXPECT().FetchOrganization(ctx, int64(3), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, org *entity.Organization) error {
+				*org = entity.Organization{ID: 3, Name: "acme"}
+				return nil
+			})
+
+		var token string
+		enq.EXPECT().Enqueue(service.SendEmail, gomock.Any()).
+			DoAndReturn(func(_ string, args map[string]interface{}) (interface{}, error) {
+				payload := sendEmailPayload(t, args)
+				assert.Equal(t, "a@b.c", payload.To)
+				assert.Equal(t, service.InviteMemberTemplate, payload.Template)
+				assert.Equal(t, "acme", payload.Data["Organization"])
+				token = payload.Data["Token"].(string)
+				return nil, nil
+			})
+
+		assert.Nil(t, srv.InviteMember(ctx, 3, "a@b.c", entity.OrgRoleAdmin))
+		return token
+	}
+
+	address := func(userID int64) {
+		m.EXPECT().FilterEmails(viewerCtx, store.FilterEmails{Address: "a@b.c", AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = []entity.Email{{ID: 9, UserID: userID, Address: "a@b.c"}}
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		token := invite()
+
+		m.EXPECT().IsTokenDenied(viewerCtx, gomock.Any()).Return(false, nil)
+		address(4)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(viewerCtx).Return(tx, nil)
+		m.EXPECT().AddMember(gomock.Any(), tx, &entity.Member{OrganizationID: 3, UserID: 4, Role: entity.OrgRoleAdmin}).Return(nil)
+		m.EXPECT().DenyToken(gomock.Any(), tx, gomock.Any(), gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var member entity.Member
+		assert.Nil(t, srv.AcceptInvitation(viewerCtx, token, &member))
+		assert.Equal(t, entity.Member{OrganizationID: 3, UserID: 4, Role: entity.OrgRoleAdmin}, member)
+	}
+
+	// fails if the role can not be given
+	{
+		assert.Equal(t, errors.ErrInvalidRole, srv.InviteMember(ctx, 3, "a@b.c", entity.OrgRoleOwner))
+	}
+
+	// fails if the organization does not exist
+	{
+		m.EXPECT().FetchOrganization(ctx, int64(3), gomock.Any()).Return(errors.ErrNotFound)
+
+		assert.Equal(t, errors.ErrNotFound, srv.InviteMember(ctx, 3, "a@b.c", entity.OrgRoleMember))
+	}
+
+	// fails if the invitation was accepted or is not one
+	{
+		token := invite()
+		m.EXPECT().IsTokenDenied(viewerCtx, gomock.Any()).Return(true, nil)
+
+		var member entity.Member
+		assert.Equal(t, errors.ErrInvalidInvitation, srv.AcceptInvitation(viewerCtx, token, &member))
+		assert.Equal(t, errors.ErrInvalidInvitation, srv.AcceptInvitation(viewerCtx, "token", &member))
+	}
+
+	// fails if the address is not of the user
+	{
+		token := invite()
+		m.EXPECT().IsTokenDenied(viewerCtx, gomock.Any()).Return(false, nil)
+		address(5)
+
+		var member entity.Member
+		assert.Equal(t, errors.ErrForbidden, srv.AcceptInvitation(viewerCtx, token, &member))
+	}
+
+	// fails if the user is already a member
+	{
+		token := invite()
+		m.EXPECT().IsTokenDenied(viewerCtx, gomock.Any()).Return(false, nil)
+		address(4)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(viewerCtx).Return(tx, nil)
+		m.EXPECT().AddMember(gomock.Any(), tx, gomock.Any()).Return(errors.ErrAlreadyExists)
+		tx.EXPECT().Rollback().Return(nil)
+
+		var member entity.Member
+		assert.Equal(t, errors.ErrAlreadyMember, srv.AcceptInvitation(viewerCtx, token, &member))
+	}
+
+	// fails if not authenticated
+	{
+		var member entity.Member
+		assert.Equal(t, errors.ErrUnauthorized, srv.AcceptInvitation(ctx, "token", &member))
+	}
+}
+
+func TestSwitchOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, key)
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{
+		JWT: config.JWT{Keys: keys, ExpireIn: time.Minute, RefreshExpireIn: time.Hour},
+	}, m, service.Options{})
+	ctx := context.Background()
+
+	fetch := func() {
+		m.EXPECT().FetchRefreshToken(ctx, hash("raw"), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, t *entity.RefreshToken) error {
+				*t = entity.RefreshToken{ID: 2, UserID: 4, Family: "f", Expires: time.Now().Add(time.Hour), OrganizationID: 3}
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		fetch()
+		m.EXPECT().FetchMember(ctx, int64(5), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4}}
+				return nil
+			})
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseRefreshToken(gomock.Any(), tx, int64(2)).Return(nil)
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, orgs *[]entity.Organization) error {
+				*orgs = []entity.Organization{{ID: 3}, {ID: 5}}
+				return nil
+			})
+		m.EXPECT().AddRefreshToken(gomock.Any(), tx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, token *entity.RefreshToken) error {
+				assert.Equal(t, int64(5), token.OrganizationID)
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.SwitchOrganization(ctx, "raw", 5, &user, &tokens))
+
+		parsed, err := keys.Parse(tokens.Access)
+		assert.Nil(t, err)
+		org, ok := parsed.Get(service.OrganizationClaim)
+		assert.True(t, ok)
+		assert.Equal(t, float64(5), org)
+	}
+
+	// fails if the user is not a member
+	{
+		fetch()
+		m.EXPECT().FetchMember(ctx, int64(6), int64(4), gomock.Any()).Return(errors.ErrNotFound)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrNotMember, srv.SwitchOrganization(ctx, "raw", 6, &user, &tokens))
+	}
+}
+
+func TestFilterByOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+		&entity.JWTUser{ID: 4, OrganizationID: 3})
+
+	// succeed only listing the users of the organization
+	{
+		m.EXPECT().
+			FilterUsersID(ctx, store.FilterUsers{OrganizationID: 3, Limit: service.FilterUsersDefaultLimit + 1}, gomock.Any()).
+			Return(nil)
+		m.EXPECT().FetchUsers(ctx, nil, false, gomock.Any()).Return(nil)
+
+		var users []entity.User
+		assert.Nil(t, srv.FilterUsers(ctx, store.FilterUsers{OrganizationID: 5}, &users, nil))
+	}
+
+	// succeed only listing the emails of the organization
+	{
+		m.EXPECT().
+			FilterEmails(ctx, store.FilterEmails{OrganizationID: 3, Limit: service.FilterEmailsDefaultLimit + 1}, gomock.Any()).
+			Return(nil)
+
+		var emails []entity.Email
+		assert.Nil(t, srv.FilterEmails(ctx, store.FilterEmails{AllOrganizations: true}, &emails, nil))
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ResetPasswordTemplate is the template of the message resetting a password
+const ResetPasswordTemplate = "reset_password"
+
+// RequestPasswordReset enqueue the job emailing a token resetting the password of the user of email
+// the job looks the user up, so a request does the same work and takes as long whether a user has the email or
+// not and the response does not reveal the registered emails
+// the requests are throttled per email and per address like the authentications, each one counting as a failure
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	keys := newResetKeys(ctx, email)
+	err := s.checkThrottle(ctx, keys)
+	if err != nil {
+		return err
+	}
+	s.failThrottle(ctx, keys)
+
+	args, err := jobArgs(ctx, &entity.SendPasswordResetPayload{
+		Version: entity.SendPasswordResetPayloadVersion,
+		Email:   email,
+	})
+	if err != nil {
+		return fmt.Errorf("could not request password reset; %w", err)
+	}
+
+	_, err = s.enqueuer.Enqueue(SendPasswordReset, args)
+	if err != nil {
+		return fmt.Errorf("could not request password reset; %w", err)
+	}
+
+	return nil
+}
+
+// SendPasswordReset email a token resetting the password of the user of email, the job of RequestPasswordReset
+// it does nothing if no user has the email
+func (s *Service) SendPasswordReset(ctx context.Context, email string) error {
+	var IDs []int64
+	filter := store.FilterUsers{Email: email, Limit: FilterUsersDefaultLimit, AllOrganizations: true}
+	err := s.store.FilterUsersID(ctx, filter, &IDs)
+	if err != nil {
+		return fmt.Errorf("could not send password reset; %w", err)
+	}
+	if len(IDs) != 1 {
+		return nil
+	}
+
+	err = s.requestPasswordReset(ctx, IDs[0], email)
+	if err != nil {
+		return fmt.Errorf("could not send password reset; %w", err)
+	}
+
+	return nil
+}
+
+// requestPasswordReset store the hash of a new token and enqueue the message sending it
+func (s *Service) requestPasswordReset(ctx context.Context, userID int64, email string) error {
+	token, err := randomString(32)
+	if err != nil {
+		return err
+	}
+
+	reset := entity.PasswordReset{
+		UserID:  userID,
+		Hash:    hashToken(token),
+		Expires: time.Now().Add(s.config.Auth.PasswordResetExpireIn),
+	}
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.AddPasswordReset(ctx, tx, &reset)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.EnqueueSendEmail(ctx, email, ResetPasswordTemplate, map[string]interface{}{
+		"Token":    token,
+		"ExpireIn": s.config.Auth.PasswordResetExpireIn.String(),
+	})
+}
+
+// ResetPassword replace the password of the user of token and revoke its refresh and access tokens
+// a token resets once, before it expires
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	if len(password) == 0 {
+		return errors.ErrInvalidPassword
+	}
+
+	var reset entity.PasswordReset
+	err := s.store.FetchPasswordReset(ctx, hashToken(token), &reset)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("could not reset password; %w", err)
+	}
+
+	if reset.Used || time.Now().After(reset.Expires) {
+		return errors.ErrInvalidResetToken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("could not generate password; %w", err)
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		// a concurrent reset with the same token loses here
+		err := s.store.UsePasswordReset(ctx, tx, reset.ID)
+		if err == errors.ErrUpdateConflict {
+			return errors.ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.store.UpdateUserPassword(ctx, tx, reset.UserID, string(hash))
+		if err != nil {
+			return err
+		}
+
+		err = s.store.RevokeUserRefreshTokens(ctx, tx, reset.UserID)
+		if err != nil {
+			return err
+		}
+
+		// the access tokens issued before the second of the reset are denied until the last of them expires, iat
+		// is in seconds so a login right after the reset keeps its token
+		now := time.Now()
+		before, expires := now.Truncate(time.Second), now.Add(s.config.JWT.ExpireIn)
+		return s.store.RevokeAccessTokens(ctx, tx, reset.UserID, before, expires)
+	})
+	if err != nil {
+		return fmt.Errorf("could not reset password; %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+	"boiler/pkg/service"
+	smock "boiler/pkg/service/mock"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/memory"
+	"boiler/pkg/store/mock"
+	"boiler/pkg/store/throttle"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enq := smock.NewMockEnqueuer(ctrl)
+	lock := throttle.Policy{Free: 1, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	conf := &config.Config{Throttle: config.Throttle{Account: lock, IP: lock}}
+	srv := service.New(conf, nil, service.Options{Enqueuer: enq, Throttle: throttle.NewMemory()})
+	ctx := context.WithValue(context.Background(), config.ContextKeyClientIP{}, "1.2.3.4")
+
+	// succeed to enqueue the job looking the user up, registered or not
+	{
+		payload := entity.SendPasswordResetPayload{Version: entity.SendPasswordResetPayloadVersion, Email: "a@b.c"}
+		enq.EXPECT().Enqueue(service.SendPasswordReset, payloadArgs(t, &payload, nil)).Return(nil, nil)
+
+		assert.Nil(t, srv.RequestPasswordReset(ctx, "a@b.c"))
+	}
+
+	// fails if the job can not be enqueued
+	{
+		enq.EXPECT().Enqueue(service.SendPasswordReset, gomock.Any()).Return(nil, fmt.Errorf("opz"))
+
+		assert.Equal(t, "could not request password reset; opz", srv.RequestPasswordReset(ctx, "a@b.c").Error())
+	}
+
+	// fails if the email is throttled
+	{
+		assert.Equal(t, errors.ErrTooManyAttempts, srv.RequestPasswordReset(ctx, "a@b.c"))
+	}
+
+	// fails if the address is throttled
+	{
+		assert.Equal(t, errors.ErrTooManyAttempts, srv.RequestPasswordReset(ctx, "d@e.f"))
+
+		other := context.WithValue(context.Background(), config.ContextKeyClientIP{}, "5.6.7.8")
+		enq.EXPECT().Enqueue(service.SendPasswordReset, gomock.Any()).Return(nil, nil)
+		assert.Nil(t, srv.RequestPasswordReset(other, "d@e.f"))
+	}
+}
+
+func TestSendPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	enq := smock.NewMockEnqueuer(ctrl)
+	conf := &config.Config{Auth: config.Auth{PasswordResetExpireIn: time.Hour}}
+	srv := service.New(conf, m, service.Options{Enqueuer: enq})
+	ctx := context.Background()
+
+	filter := func(IDs ...int64) {
+		m.EXPECT().
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: "a@b.c", Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, ids *[]int64) error {
+				*ids = IDs
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		filter(4)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+
+		var stored string
+		m.EXPECT().AddPasswordReset(gomock.Any(), tx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, reset *entity.PasswordReset) error {
+				assert.Equal(t, int64(4), reset.UserID)
+				assert.WithinDuration(t, time.Now().Add(time.Hour), reset.Expires, time.Minute)
+				stored = reset.Hash
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+		enq.EXPECT().Enqueue(service.SendEmail, gomock.Any()).
+			DoAndReturn(func(_ string, args map[string]interface{}) (interface{}, error) {
+				payload := sendEmailPayload(t, args)
+				assert.Equal(t, "a@b.c", payload.To)
+				assert.Equal(t, service.ResetPasswordTemplate, payload.Template)
+
+				// only the hash of the emailed token is stored
+				token := payload.Data["Token"].(string)
+				assert.NotEqual(t, token, stored)
+				assert.Equal(t, stored, hash(token))
+				return nil, nil
+			})
+
+		assert.Nil(t, srv.SendPasswordReset(ctx, "a@b.c"))
+	}
+
+	// succeed without doing anything if the email does not exist
+	{
+		filter()
+
+		assert.Nil(t, srv.SendPasswordReset(ctx, "a@b.c"))
+	}
+
+	// fails if the message can not be enqueued
+	{
+		filter(4)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddPasswordReset(gomock.Any(), tx, gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+		enq.EXPECT().Enqueue(service.SendEmail, gomock.Any()).Return(nil, fmt.Errorf("opz"))
+
+		assert.Equal(t, "could not send password reset; could not enqueue email; opz",
+			srv.SendPasswordReset(ctx, "a@b.c").Error())
+	}
+
+	// fails if the lookup fails
+	{
+		m.EXPECT().FilterUsersID(ctx, gomock.Any(), gomock.Any()).Return(fmt.Errorf("opz"))
+
+		assert.Equal(t, "could not send password reset; opz", srv.SendPasswordReset(ctx, "a@b.c").Error())
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{JWT: config.JWT{ExpireIn: time.Minute}}, m, service.Options{})
+	ctx := context.Background()
+
+	fetch := func(reset entity.PasswordReset) {
+		m.EXPECT().FetchPasswordReset(ctx, hash("tok"), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, r *entity.PasswordReset) error {
+				*r = reset
+				return nil
+			})
+	}
+	valid := entity.PasswordReset{ID: 2, UserID: 4, Expires: time.Now().Add(time.Hour)}
+
+	// succeed
+	{
+		fetch(valid)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UsePasswordReset(gomock.Any(), tx, int64(2)).Return(nil)
+		m.EXPECT().UpdateUserPassword(gomock.Any(), tx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, _ int64, hash string) error {
+				assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("new")))
+				return nil
+			})
+		m.EXPECT().RevokeUserRefreshTokens(gomock.Any(), tx, int64(4)).Return(nil)
+		m.EXPECT().RevokeAccessTokens(gomock.Any(), tx, int64(4), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, _ int64, before, expires time.Time) error {
+				assert.WithinDuration(t, time.Now(), before, time.Second)
+				assert.Equal(t, before.Truncate(time.Second), before)
+				assert.WithinDuration(t, before.Add(time.Minute), expires, time.Second)
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.ResetPassword(ctx, "tok", "new"))
+	}
+
+	// fails if the password is empty
+	{
+		assert.Equal(t, errors.ErrInvalidPassword, srv.ResetPassword(ctx, "tok", ""))
+	}
+
+	// fails if the token does not exist
+	{
+		m.EXPECT().FetchPasswordReset(ctx, hash("tok"), gomock.Any()).Return(errors.ErrNotFound)
+
+		assert.Equal(t, errors.ErrInvalidResetToken, srv.ResetPassword(ctx, "tok", "new"))
+	}
+
+	// fails if the token was used
+	{
+		used := valid
+		used.Used = true
+		fetch(used)
+
+		assert.Equal(t, errors.ErrInvalidResetToken, srv.ResetPassword(ctx, "tok", "new"))
+	}
+
+	// fails if the token expired
+	{
+		expired := valid
+		expired.Expires = time.Now().Add(-time.Second)
+		fetch(expired)
+
+		assert.Equal(t, errors.ErrInvalidResetToken, srv.ResetPassword(ctx, "tok", "new"))
+	}
+
+	// fails if the token is used concurrently
+	{
+		fetch(valid)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UsePasswordReset(gomock.Any(), tx, int64(2)).Return(errors.ErrUpdateConflict)
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.True(t, errors.Is(srv.ResetPassword(ctx, "tok", "new"), errors.ErrInvalidResetToken))
+	}
+}
+
+func TestResetPasswordThenLogin(t *testing.T) {
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	st := memory.New()
+	keys := keyset.New(time.Minute, key)
+	conf := &config.Config{JWT: config.JWT{Keys: keys, ExpireIn: time.Minute}}
+	srv := service.New(conf, st, service.Options{Throttle: throttle.NewMemory()})
+	ctx := context.Background()
+
+	user := entity.User{Name: "a", Password: "old"}
+	err = store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+		if err := st.AddUser(ctx, tx, &user); err != nil {
+			return err
+		}
+		if err := st.AddEmail(ctx, tx, &entity.Email{UserID: user.ID, Address: "a@b.c"}); err != nil {
+			return err
+		}
+
+		reset := entity.PasswordReset{UserID: user.ID, Hash: hash("tok"), Expires: time.Now().Add(time.Hour)}
+		return st.AddPasswordReset(ctx, tx, &reset)
+	})
+	assert.Nil(t, err)
+
+	// succeed to keep the token of a login within the second of the reset
+	{
+		reset := time.Now().Truncate(time.Second)
+		assert.Nil(t, srv.ResetPassword(ctx, "tok", "new"))
+
+		var logged entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.AuthUser(ctx, "a@b.c", "new", &logged, &tokens))
+
+		parsed, err := keys.Parse(tokens.Access)
+		assert.Nil(t, err)
+
+		revoked, err := srv.IsTokenRevoked(ctx, user.ID, parsed.IssuedAt())
+		assert.Nil(t, err)
+		assert.False(t, revoked)
+
+		// the tokens of the seconds before the reset are revoked
+		revoked, err = srv.IsTokenRevoked(ctx, user.ID, reset.Add(-time.Second))
+		assert.Nil(t, err)
+		assert.True(t, revoked)
+	}
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+)
+
+// Action is an operation guarded by Authorize
+type Action string
+
+const (
+	ActionListUsers      Action = "list_users"
+	ActionReadUser       Action = "read_user"
+	ActionUpdateUser     Action = "update_user"
+	ActionChangePassword Action = "change_password"
+	ActionDeleteUser     Action = "delete_user"
+	ActionAddEmail       Action = "add_email"
+	ActionReadEmail      Action = "read_email"
+	ActionListEmails     Action = "list_emails"
+	ActionDeleteEmail    Action = "delete_email"
+	ActionSetUserRoles   Action = "set_user_roles"
+	ActionEnrollTOTP     Action = "enroll_totp"
+	ActionListAPIKeys    Action = "list_api_keys"
+	ActionAddAPIKey      Action = "add_api_key"
+	ActionRevokeAPIKey   Action = "revoke_api_key"
+
+	ActionRestoreUser    Action = "restore_user"
+	ActionRestoreEmail   Action = "restore_email"
+	ActionIncludeDeleted Action = "include_deleted"
+
+	ActionAddOrganization   Action = "add_organization"
+	ActionListOrganizations Action = "list_organizations"
+	ActionListMembers       Action = "list_members"
+	ActionInviteMember      Action = "invite_member"
+
+	ActionReadJob Action = "read_job"
+	// ActionListSchedules has no scope, the API keys can not list the schedules
+	ActionListSchedules Action = "list_schedules"
+	// ActionManageDeadJobs lists, retries and deletes the dead jobs, the API keys can not
+	ActionManageDeadJobs Action = "manage_dead_jobs"
+)
+
+// scopes is the scope an API key needs for each action
+var scopes = map[Action]string{
+	ActionListUsers:      entity.ScopeUsersRead,
+	ActionReadUser:       entity.ScopeUsersRead,
+	ActionUpdateUser:     entity.ScopeUsersWrite,
+	ActionChangePassword: entity.ScopeUsersWrite,
+	ActionDeleteUser:     entity.ScopeUsersWrite,
+	ActionSetUserRoles:   entity.ScopeUsersWrite,
+	ActionEnrollTOTP:     entity.ScopeUsersWrite,
+	ActionAddEmail:       entity.ScopeEmailsWrite,
+	ActionReadEmail:      entity.ScopeEmailsRead,
+	ActionListEmails:     entity.ScopeEmailsRead,
+	ActionDeleteEmail:    entity.ScopeEmailsWrite,
+	ActionListAPIKeys:    entity.ScopeAPIKeysRead,
+	ActionAddAPIKey:      entity.ScopeAPIKeysWrite,
+	ActionRevokeAPIKey:   entity.ScopeAPIKeysWrite,
+
+	ActionRestoreUser:    entity.ScopeUsersWrite,
+	ActionRestoreEmail:   entity.ScopeEmailsWrite,
+	ActionIncludeDeleted: entity.ScopeUsersRead,
+
+	ActionAddOrganization:   entity.ScopeOrganizationsWrite,
+	ActionListOrganizations: entity.ScopeOrganizationsRead,
+	ActionListMembers:       entity.ScopeOrganizationsRead,
+	ActionInviteMember:      entity.ScopeOrganizationsWrite,
+
+	// the jobs are the deletions of users
+	ActionReadJob: entity.ScopeUsersRead,
+}
+
+// Scope return the scope an API key needs to do action
+func Scope(action Action) string {
+	return scopes[action]
+}
+
+// Resource is the target of an action
+// UserID is the user owning the resource, an EmailID is resolved to the user owning the email
+// OrganizationID is the organization acted on, the zero Resource is the whole collection
+type Resource struct {
+	UserID         int64
+	EmailID        int64
+	OrganizationID int64
+}
+
+// Viewer return the authenticated user of the request
+func Viewer(ctx context.Context) (*entity.JWTUser, bool) {
+	user, ok := ctx.Value(config.ContextKeyAuthenticationUser{}).(*entity.JWTUser)
+	return user, ok && user != nil
+}
+
+// Authorize check if the authenticated user can do action on resource
+// users can act on what they own, the permissions of their roles grant the other actions
+// within their current organization, members can list its members and its owners and admins invite
+// only the roles granting every permission act on the users holding the admin role or on their emails
+// it fails with ErrUnauthorized if the request is anonymous and ErrForbidden if the user is not allowed,
+// the requests authenticated by an API key fail with ErrMissingScope without the scope of the action
+func (s *Service) Authorize(ctx context.Context, action Action, resource Resource) error {
+	viewer, ok := Viewer(ctx)
+	if !ok {
+		return errors.ErrUnauthorized
+	}
+
+	if !viewer.HasScope(Scope(action)) {
+		return errors.ErrMissingScope
+	}
+
+	owner, err := s.owns(ctx, viewer.ID, action, resource)
+	if err != nil || owner {
+		return err
+	}
+
+	if viewer.OrganizationID != 0 {
+		err = s.inOrganization(ctx, viewer.OrganizationID, resource)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(viewer.Roles) != 0 {
+		var roles []entity.Role
+		err = s.store.FetchRoles(ctx, viewer.Roles, &roles)
+		if err != nil {
+			return fmt.Errorf("could not fetch roles; %w", err)
+		}
+
+		granted, all := false, false
+		for _, role := range roles {
+			if role.Can(string(action)) {
+				granted, all = true, all || role.Can(entity.PermissionAll)
+			}
+		}
+
+		if granted && !all {
+			return s.notAdmin(ctx, resource)
+		}
+		if granted {
+			return nil
+		}
+	}
+
+	return errors.ErrForbidden
+}
+
+// notAdmin fails with ErrForbidden if the user targeted by the resource, or owning its email, holds the admin role
+func (s *Service) notAdmin(ctx context.Context, resource Resource) error {
+	userID := resource.UserID
+	if userID == 0 && resource.EmailID != 0 {
+		filter := store.FilterEmails{EmailID: resource.EmailID, AllOrganizations: true, IncludeDeleted: true}
+
+		var emails []entity.Email
+		err := s.store.FilterEmails(ctx, filter, &emails)
+		if err != nil {
+			return fmt.Errorf("could not filter emails; %w", err)
+		}
+		if len(emails) == 0 {
+			return errors.ErrNotFound
+		}
+		userID = emails[0].UserID
+	}
+	if userID == 0 {
+		return nil
+	}
+
+	var roles []string
+	err := s.GetUserRoles(ctx, userID, &roles)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		if role == entity.RoleAdmin {
+			return errors.ErrForbidden
+		}
+	}
+
+	return nil
+}
+
+// owns tells if userID owns the resource of an action its owner can do
+func (s *Service) owns(ctx context.Context, userID int64, action Action, resource Resource) (bool, error) {
+	switch action {
+	case ActionReadUser, ActionUpdateUser, ActionChangePassword, ActionDeleteUser,
+		ActionAddEmail, ActionListEmails, ActionEnrollTOTP, ActionListAPIKeys, ActionAddAPIKey, ActionRevokeAPIKey,
+		ActionAddOrganization, ActionListOrganizations, ActionReadJob:
+		return resource.UserID != 0 && resource.UserID == userID, nil
+
+	case ActionListMembers, ActionInviteMember:
+		var member entity.Member
+		err := s.store.FetchMember(ctx, resource.OrganizationID, userID, &member)
+		if err == errors.ErrNotFound {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("could not fetch member; %w", err)
+		}
+		return action == ActionListMembers || member.CanInvite(), nil
+
+	case ActionReadEmail, ActionDeleteEmail:
+		var emails []entity.Email
+		err := s.store.FilterEmails(ctx, store.FilterEmails{EmailID: resource.EmailID, AllOrganizations: true}, &emails)
+		if err != nil {
+			return false, fmt.Errorf("could not filter emails; %w", err)
+		}
+		if len(emails) == 0 {
+			return false, errors.ErrNotFound
+		}
+		return emails[0].UserID == userID, nil
+	}
+
+	return false, nil
+}
+
+// inOrganization check that the resource belongs to the organization, it fails with ErrForbidden
+// if it is another organization or a resource of an user who is not a member
+func (s *Service) inOrganization(ctx context.Context, orgID int64, resource Resource) error {
+	if resource.OrganizationID != 0 && resource.OrganizationID != orgID {
+		return errors.ErrForbidden
+	}
+
+	if resource.UserID != 0 {
+		var member entity.Member
+		err := s.store.FetchMember(ctx, orgID, resource.UserID, &member)
+		if err == errors.ErrNotFound {
+			return errors.ErrForbidden
+		}
+		if err != nil {
+			return fmt.Errorf("could not fetch member; %w", err)
+		}
+	}
+
+	if resource.EmailID != 0 {
+		// a deleted email still belongs to the organization until it is purged
+		filter := store.FilterEmails{EmailID: resource.EmailID, OrganizationID: orgID, IncludeDeleted: true}
+
+		var emails []entity.Email
+		err := s.store.FilterEmails(ctx, filter, &emails)
+		if err != nil {
+			return fmt.Errorf("could not filter emails; %w", err)
+		}
+		if len(emails) == 0 {
+			return errors.ErrForbidden
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,367 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	as := func(userID int64, roles ...string) context.Context {
+		return context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: userID, Roles: roles})
+	}
+
+	fetchRoles := func(names ...string) {
+		m.EXPECT().
+			FetchRoles(gomock.Any(), names, gomock.Any()).
+			DoAndReturn(func(_ context.Context, names []string, roles *[]entity.Role) error {
+				for _, role := range store.DefaultRoles {
+					for _, name := range names {
+						if role.Name == name {
+							*roles = append(*roles, role)
+						}
+					}
+				}
+				return nil
+			})
+	}
+
+	// succeed if owner
+	{
+		err := srv.Authorize(as(4, entity.RoleMember), service.ActionDeleteUser, service.Resource{UserID: 4})
+		assert.Nil(t, err)
+	}
+
+	// succeed if admin
+	{
+		fetchRoles(entity.RoleAdmin)
+		err := srv.Authorize(as(1, entity.RoleAdmin), service.ActionSetUserRoles, service.Resource{UserID: 4})
+		assert.Nil(t, err)
+	}
+
+	// succeed if the role has the permission
+	{
+		fetchRoles(entity.RoleMember, entity.RoleSupport)
+		err := srv.Authorize(as(2, entity.RoleMember, entity.RoleSupport), service.ActionListUsers, service.Resource{})
+		assert.Nil(t, err)
+	}
+
+	// fails if restoring itself without being admin
+	{
+		fetchRoles(entity.RoleSupport)
+		err := srv.Authorize(as(4, entity.RoleSupport), service.ActionRestoreUser, service.Resource{UserID: 4})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// succeed if admin listing the deleted users
+	{
+		fetchRoles(entity.RoleAdmin)
+		err := srv.Authorize(as(1, entity.RoleAdmin), service.ActionIncludeDeleted, service.Resource{})
+		assert.Nil(t, err)
+	}
+
+	// succeed if reading a job it enqueued
+	{
+		err := srv.Authorize(as(4, entity.RoleMember), service.ActionReadJob, service.Resource{UserID: 4})
+		assert.Nil(t, err)
+	}
+
+	// fails if reading the job of another user
+	{
+		fetchRoles(entity.RoleMember)
+		err := srv.Authorize(as(4, entity.RoleMember), service.ActionReadJob, service.Resource{UserID: 5})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// succeed if admin listing the schedules
+	{
+		fetchRoles(entity.RoleAdmin)
+		err := srv.Authorize(as(1, entity.RoleAdmin), service.ActionListSchedules, service.Resource{})
+		assert.Nil(t, err)
+	}
+
+	// fails if an api key lists the schedules
+	{
+		ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: 1, APIKeyID: 7, Roles: []string{entity.RoleAdmin}, Scopes: entity.Scopes})
+		err := srv.Authorize(ctx, service.ActionListSchedules, service.Resource{})
+		assert.Equal(t, errors.ErrMissingScope, err)
+	}
+
+	// succeed if admin managing the dead jobs
+	{
+		fetchRoles(entity.RoleAdmin)
+		err := srv.Authorize(as(1, entity.RoleAdmin), service.ActionManageDeadJobs, service.Resource{})
+		assert.Nil(t, err)
+	}
+
+	// fails if support manages the dead jobs
+	{
+		fetchRoles(entity.RoleSupport)
+		err := srv.Authorize(as(2, entity.RoleSupport), service.ActionManageDeadJobs, service.Resource{})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// succeed if owner of the email
+	{
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 5, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = append(*emails, entity.Email{ID: 5, UserID: 4})
+				return nil
+			})
+
+		err := srv.Authorize(as(4), service.ActionDeleteEmail, service.Resource{EmailID: 5})
+		assert.Nil(t, err)
+	}
+
+	// succeed if the api key has the scope
+	{
+		ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: 4, APIKeyID: 7, Scopes: []string{entity.ScopeUsersRead}})
+		err := srv.Authorize(ctx, service.ActionReadUser, service.Resource{UserID: 4})
+		assert.Nil(t, err)
+	}
+
+	// fails if the api key lacks the scope
+	{
+		ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: 4, APIKeyID: 7, Scopes: []string{entity.ScopeUsersRead}})
+		err := srv.Authorize(ctx, service.ActionDeleteUser, service.Resource{UserID: 4})
+		assert.Equal(t, errors.ErrMissingScope, err)
+	}
+
+	// fails if anonymous
+	{
+		err := srv.Authorize(context.Background(), service.ActionReadUser, service.Resource{UserID: 4})
+		assert.Equal(t, errors.ErrUnauthorized, err)
+	}
+
+	// fails if not owner
+	{
+		err := srv.Authorize(as(2), service.ActionUpdateUser, service.Resource{UserID: 4})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if no role has the permission
+	{
+		fetchRoles(entity.RoleMember)
+		err := srv.Authorize(as(4, entity.RoleMember), service.ActionListUsers, service.Resource{})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if support acts as the user
+	{
+		fetchRoles(entity.RoleSupport)
+		err := srv.Authorize(as(2, entity.RoleSupport), service.ActionChangePassword, service.Resource{UserID: 4})
+		assert.Equal(t, errors.ErrForbidden, err)
+
+		fetchRoles(entity.RoleSupport)
+		err = srv.Authorize(as(2, entity.RoleSupport), service.ActionSetUserRoles, service.Resource{UserID: 2})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// succeed if support updates a user who is not admin
+	{
+		fetchRoles(entity.RoleSupport)
+		m.EXPECT().
+			FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleMember, entity.RoleSupport}
+				return nil
+			})
+
+		err := srv.Authorize(as(2, entity.RoleSupport), service.ActionUpdateUser, service.Resource{UserID: 4})
+		assert.Nil(t, err)
+	}
+
+	// fails if support updates or deletes an admin
+	{
+		fetchRoles(entity.RoleSupport)
+		m.EXPECT().
+			FetchUserRoles(gomock.Any(), int64(1), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleAdmin}
+				return nil
+			})
+
+		err := srv.Authorize(as(2, entity.RoleSupport), service.ActionUpdateUser, service.Resource{UserID: 1})
+		assert.Equal(t, errors.ErrForbidden, err)
+
+		fetchRoles(entity.RoleSupport)
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+
+		admins := service.New(&config.Config{Auth: config.Auth{Admins: []int64{1}}}, m, service.Options{})
+		err = admins.Authorize(as(2, entity.RoleSupport), service.ActionDeleteUser, service.Resource{UserID: 1})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if support deletes the email of an admin
+	{
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 5, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = append(*emails, entity.Email{ID: 5, UserID: 1})
+				return nil
+			})
+		fetchRoles(entity.RoleSupport)
+		filter := store.FilterEmails{EmailID: 5, AllOrganizations: true, IncludeDeleted: true}
+		m.EXPECT().
+			FilterEmails(gomock.Any(), filter, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = append(*emails, entity.Email{ID: 5, UserID: 1})
+				return nil
+			})
+		m.EXPECT().
+			FetchUserRoles(gomock.Any(), int64(1), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleAdmin}
+				return nil
+			})
+
+		err := srv.Authorize(as(2, entity.RoleSupport), service.ActionDeleteEmail, service.Resource{EmailID: 5})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if roles can not be fetched
+	{
+		m.EXPECT().FetchRoles(gomock.Any(), []string{entity.RoleAdmin}, gomock.Any()).Return(fmt.Errorf("opz"))
+
+		err := srv.Authorize(as(1, entity.RoleAdmin), service.ActionListUsers, service.Resource{})
+		assert.Equal(t, "could not fetch roles; opz", err.Error())
+	}
+
+	// fails if not owner of the email
+	{
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 5, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = append(*emails, entity.Email{ID: 5, UserID: 4})
+				return nil
+			})
+
+		err := srv.Authorize(as(2), service.ActionDeleteEmail, service.Resource{EmailID: 5})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if email is not found
+	{
+		m.EXPECT().FilterEmails(gomock.Any(), store.FilterEmails{
+			EmailID: 5, AllOrganizations: true,
+		}, gomock.Any()).Return(nil)
+
+		err := srv.Authorize(as(2), service.ActionReadEmail, service.Resource{EmailID: 5})
+		assert.Equal(t, errors.ErrNotFound, err)
+	}
+
+	// fails if store fails
+	{
+		m.EXPECT().FilterEmails(gomock.Any(), store.FilterEmails{
+			EmailID: 5, AllOrganizations: true,
+		}, gomock.Any()).Return(fmt.Errorf("opz"))
+
+		err := srv.Authorize(as(2), service.ActionReadEmail, service.Resource{EmailID: 5})
+		assert.Equal(t, "could not filter emails; opz", err.Error())
+	}
+}
+
+func TestAuthorizeOrganization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	as := func(userID, orgID int64, roles ...string) context.Context {
+		return context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+			&entity.JWTUser{ID: userID, Roles: roles, OrganizationID: orgID})
+	}
+
+	member := func(orgID, userID int64, role string) {
+		m.EXPECT().
+			FetchMember(gomock.Any(), orgID, userID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, _ int64, member *entity.Member) error {
+				*member = entity.Member{OrganizationID: orgID, UserID: userID, Role: role}
+				return nil
+			})
+	}
+
+	// succeed if admin of the organization
+	{
+		member(3, 4, entity.OrgRoleAdmin)
+		err := srv.Authorize(as(4, 3), service.ActionInviteMember, service.Resource{OrganizationID: 3})
+		assert.Nil(t, err)
+	}
+
+	// succeed if support reads a member of its organization
+	{
+		member(3, 5, entity.OrgRoleMember)
+		m.EXPECT().FetchRoles(gomock.Any(), []string{entity.RoleSupport}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []string, roles *[]entity.Role) error {
+				*roles = []entity.Role{{Name: entity.RoleSupport, Permissions: []string{string(service.ActionReadUser)}}}
+				return nil
+			})
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(5), gomock.Any()).Return(nil)
+
+		err := srv.Authorize(as(4, 3, entity.RoleSupport), service.ActionReadUser, service.Resource{UserID: 5})
+		assert.Nil(t, err)
+	}
+
+	// fails if a member invites
+	{
+		member(3, 4, entity.OrgRoleMember)
+		err := srv.Authorize(as(4, 3), service.ActionInviteMember, service.Resource{OrganizationID: 3})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if the user is of another organization
+	{
+		m.EXPECT().FetchMember(gomock.Any(), int64(3), int64(5), gomock.Any()).Return(errors.ErrNotFound)
+
+		err := srv.Authorize(as(4, 3, entity.RoleAdmin), service.ActionReadUser, service.Resource{UserID: 5})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if the email is of another organization
+	{
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 6, AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = append(*emails, entity.Email{ID: 6, UserID: 5})
+				return nil
+			})
+		m.EXPECT().
+			FilterEmails(gomock.Any(), store.FilterEmails{EmailID: 6, OrganizationID: 3, IncludeDeleted: true}, gomock.Any()).
+			Return(nil)
+
+		err := srv.Authorize(as(4, 3, entity.RoleAdmin), service.ActionReadEmail, service.Resource{EmailID: 6})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+
+	// fails if the resource is another organization
+	{
+		m.EXPECT().FetchMember(gomock.Any(), int64(5), int64(4), gomock.Any()).Return(errors.ErrNotFound)
+
+		err := srv.Authorize(as(4, 3, entity.RoleAdmin), service.ActionListMembers, service.Resource{OrganizationID: 5})
+		assert.Equal(t, errors.ErrForbidden, err)
+	}
+}
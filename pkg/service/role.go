@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store"
+)
+
+// RolesClaim is the JWT claim listing the roles of the user
+const RolesClaim = "roles"
+
+// SetUserRoles replace the roles of an user
+func (s *Service) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.SetUserRoles(ctx, tx, userID, roles)
+	})
+	if err != nil {
+		return fmt.Errorf("could not set user roles; %w", err)
+	}
+
+	return nil
+}
+
+// GetUserRoles return the roles of an user
+// the users of config.Auth.Admins are admins even without the stored role
+func (s *Service) GetUserRoles(ctx context.Context, userID int64, roles *[]string) error {
+	err := s.store.FetchUserRoles(ctx, userID, roles)
+	if err != nil {
+		return fmt.Errorf("could not fetch user roles; %w", err)
+	}
+
+	for _, ID := range s.config.Auth.Admins {
+		if ID != userID {
+			continue
+		}
+
+		for _, role := range *roles {
+			if role == entity.RoleAdmin {
+				return nil
+			}
+		}
+
+		*roles = append(*roles, entity.RoleAdmin)
+		sort.Strings(*roles)
+		return nil
+	}
+
+	return nil
+}
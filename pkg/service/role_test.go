@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/service"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetUserRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, int64(4), []string{entity.RoleSupport}).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.SetUserRoles(ctx, 4, []string{entity.RoleSupport}))
+	}
+
+	// fails if a role does not exist
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, int64(4), []string{"unknown"}).Return(errors.ErrInvalidRole)
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.SetUserRoles(ctx, 4, []string{"unknown"})
+		assert.True(t, errors.Is(err, errors.ErrInvalidRole))
+	}
+}
+
+func TestGetUserRoles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{Auth: config.Auth{Admins: []int64{1}}}, m, service.Options{})
+	ctx := context.Background()
+
+	stored := func(userID int64, names ...string) {
+		m.EXPECT().
+			FetchUserRoles(ctx, userID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = append([]string{}, names...)
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		stored(4, entity.RoleMember, entity.RoleSupport)
+
+		var roles []string
+		assert.Nil(t, srv.GetUserRoles(ctx, 4, &roles))
+		assert.Equal(t, []string{entity.RoleMember, entity.RoleSupport}, roles)
+	}
+
+	// succeed with the admin role of the config
+	{
+		stored(1, entity.RoleMember)
+
+		var roles []string
+		assert.Nil(t, srv.GetUserRoles(ctx, 1, &roles))
+		assert.Equal(t, []string{entity.RoleAdmin, entity.RoleMember}, roles)
+
+		stored(1, entity.RoleAdmin)
+		assert.Nil(t, srv.GetUserRoles(ctx, 1, &roles))
+		assert.Equal(t, []string{entity.RoleAdmin}, roles)
+	}
+
+	// fails if store fails
+	{
+		m.EXPECT().FetchUserRoles(ctx, int64(4), gomock.Any()).Return(fmt.Errorf("opz"))
+
+		var roles []string
+		assert.Equal(t, "could not fetch user roles; opz", srv.GetUserRoles(ctx, 4, &roles).Error())
+	}
+}
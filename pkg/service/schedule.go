@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store"
+
+	"github.com/robfig/cron"
+)
+
+// FilterSchedules return the schedules of the worker with their last run and their next one
+func (s *Service) FilterSchedules(ctx context.Context, schedules *[]entity.Schedule) error {
+	var runs []entity.Schedule
+	err := s.store.FetchScheduleRuns(ctx, &runs)
+	if err != nil {
+		return fmt.Errorf("could not fetch schedule runs; %w", err)
+	}
+
+	last := make(map[string]entity.Schedule, len(runs))
+	for _, run := range runs {
+		last[run.Job] = run
+	}
+
+	now := time.Now()
+	*schedules = make([]entity.Schedule, 0, len(s.config.Worker.Schedules))
+	for _, sc := range s.config.Worker.Schedules {
+		spec, err := cron.Parse(sc.Spec)
+		if err != nil {
+			return fmt.Errorf("could not parse schedule of %s; %w", sc.Job, err)
+		}
+
+		schedule := entity.Schedule{Job: sc.Job, Spec: sc.Spec, NextRun: spec.Next(now).UTC()}
+		if run, ok := last[sc.Job]; ok {
+			schedule.LastRun = run.LastRun
+			schedule.LastStatus = run.LastStatus
+		}
+		*schedules = append(*schedules, schedule)
+	}
+
+	return nil
+}
+
+// RecordScheduleRun record the run of a scheduled job started at a time, it failed if jobErr is not nil
+func (s *Service) RecordScheduleRun(ctx context.Context, job string, at time.Time, jobErr error) error {
+	status := entity.JobSucceeded
+	if jobErr != nil {
+		status = entity.JobFailed
+	}
+
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.SetScheduleRun(ctx, tx, job, at, status)
+	})
+	if err != nil {
+		return fmt.Errorf("could not set schedule run; %w", err)
+	}
+
+	return nil
+}
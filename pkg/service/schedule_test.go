@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/service"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSchedules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{Worker: config.Worker{Schedules: []config.Schedule{
+		{Job: service.PurgeDeleted, Spec: "0 0 3 * * *"},
+		{Job: service.PurgeTokens, Spec: "0 0 * * * *"},
+	}}}, m, service.Options{})
+
+	ctx := context.Background()
+
+	// succeed
+	{
+		lastRun := time.Now().Add(-time.Hour)
+		m.EXPECT().FetchScheduleRuns(ctx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, schedules *[]entity.Schedule) error {
+				*schedules = []entity.Schedule{{Job: service.PurgeTokens, LastRun: &lastRun, LastStatus: entity.JobFailed}}
+				return nil
+			})
+
+		var schedules []entity.Schedule
+		assert.Nil(t, srv.FilterSchedules(ctx, &schedules))
+		if assert.Len(t, schedules, 2) {
+			assert.Equal(t, service.PurgeDeleted, schedules[0].Job)
+			assert.Nil(t, schedules[0].LastRun)
+			assert.Equal(t, 3, schedules[0].NextRun.Local().Hour())
+			assert.True(t, schedules[0].NextRun.After(time.Now()))
+
+			assert.Equal(t, service.PurgeTokens, schedules[1].Job)
+			assert.Equal(t, "0 0 * * * *", schedules[1].Spec)
+			assert.Equal(t, &lastRun, schedules[1].LastRun)
+			assert.Equal(t, entity.JobFailed, schedules[1].LastStatus)
+			assert.WithinDuration(t, time.Now(), schedules[1].NextRun, time.Hour)
+		}
+	}
+
+	// fails if store fails
+	{
+		m.EXPECT().FetchScheduleRuns(ctx, gomock.Any()).Return(fmt.Errorf("opz"))
+
+		var schedules []entity.Schedule
+		err := srv.FilterSchedules(ctx, &schedules)
+		assert.Equal(t, "could not fetch schedule runs; opz", err.Error())
+	}
+}
+
+func TestRecordScheduleRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	ctx := context.Background()
+	at := time.Now()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetScheduleRun(gomock.Any(), tx, service.PurgeTokens, at, entity.JobSucceeded).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.RecordScheduleRun(ctx, service.PurgeTokens, at, nil))
+	}
+
+	// succeed to record a failed run
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetScheduleRun(gomock.Any(), tx, service.PurgeTokens, at, entity.JobFailed).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.RecordScheduleRun(ctx, service.PurgeTokens, at, fmt.Errorf("opz")))
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetScheduleRun(gomock.Any(), tx, service.PurgeTokens, at, entity.JobSucceeded).
+			Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.RecordScheduleRun(ctx, service.PurgeTokens, at, nil)
+		assert.Equal(t, "could not set schedule run; opz", err.Error())
+	}
+}
@@ -3,22 +3,36 @@ package service
 import (
 	"boiler/pkg/store"
 	"boiler/pkg/store/config"
-
-	"github.com/gocraft/work"
+	"boiler/pkg/store/mailer"
+	"boiler/pkg/store/throttle"
 )
 
+// Options are the dependencies of the service besides its config and store, the unset ones are not used
+type Options struct {
+	Enqueuer Enqueuer
+	Mailer   mailer.Mailer
+	Throttle throttle.Throttle
+	DeadJobs DeadJobs
+}
+
 // New return a new service
-func New(conf *config.Config, str store.Interface, enqueuer *work.Enqueuer) Interface {
+func New(conf *config.Config, str store.Interface, opts Options) Interface {
 	return &Service{
-		enqueuer,
+		opts.Enqueuer,
 		conf,
 		str,
+		opts.Mailer,
+		opts.Throttle,
+		opts.DeadJobs,
 	}
 }
 
 // Service is the main service
 type Service struct {
-	enqueuer *work.Enqueuer
+	enqueuer Enqueuer
 	config   *config.Config
 	store    store.Interface
+	mailer   mailer.Mailer
+	throttle throttle.Throttle
+	deadJobs DeadJobs
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"boiler/pkg/errors"
+	"boiler/pkg/store/config"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ClientIP return the IP address of the client of the request, empty if unknown
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(config.ContextKeyClientIP{}).(string)
+	return ip
+}
+
+// authKeys are the throttle keys of an authentication
+type authKeys struct {
+	account string
+	ip      string
+}
+
+// newAuthKeys return the keys throttling the authentications of email from the client of ctx
+func newAuthKeys(ctx context.Context, email string) authKeys {
+	return newThrottleKeys(ctx, "auth", email)
+}
+
+// newResetKeys return the keys throttling the password resets requested for email from the client of ctx
+func newResetKeys(ctx context.Context, email string) authKeys {
+	return newThrottleKeys(ctx, "reset", email)
+}
+
+// newThrottleKeys return the keys of prefix throttling email and the client of ctx
+func newThrottleKeys(ctx context.Context, prefix, email string) authKeys {
+	keys := authKeys{account: prefix + ":account:" + strings.ToLower(email)}
+	if ip := ClientIP(ctx); len(ip) != 0 {
+		keys.ip = prefix + ":ip:" + ip
+	}
+
+	return keys
+}
+
+// checkThrottle fail with ErrTooManyAttempts if the account or the address is locked
+func (s *Service) checkThrottle(ctx context.Context, keys authKeys) error {
+	for _, key := range []string{keys.account, keys.ip} {
+		if len(key) == 0 {
+			continue
+		}
+
+		wait, err := s.throttle.Wait(ctx, key)
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return errors.ErrTooManyAttempts
+		}
+	}
+
+	return nil
+}
+
+// failThrottle count a failed authentication of the account and of the address
+func (s *Service) failThrottle(ctx context.Context, keys authKeys) {
+	if lock, err := s.throttle.Fail(ctx, keys.account, s.config.Throttle.Account); err != nil {
+		log.Error().Err(err).Msg("could not throttle account")
+	} else if lock > 0 {
+		log.Warn().Str("key", keys.account).Dur("lock", lock).Msg("authentication locked")
+	}
+
+	if len(keys.ip) == 0 {
+		return
+	}
+
+	if lock, err := s.throttle.Fail(ctx, keys.ip, s.config.Throttle.IP); err != nil {
+		log.Error().Err(err).Msg("could not throttle address")
+	} else if lock > 0 {
+		log.Warn().Str("key", keys.ip).Dur("lock", lock).Msg("authentication locked")
+	}
+}
+
+var dummy struct {
+	once sync.Once
+	hash []byte
+}
+
+// dummyHash return a password hash of the cost of the stored ones, comparing a password to it
+// takes as long as authenticating an existing user
+func dummyHash() []byte {
+	dummy.once.Do(func() {
+		dummy.hash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+
+	return dummy.hash
+}
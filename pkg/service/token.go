@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// AccessAudience is the audience of the access tokens, the other tokens do not authenticate
+const AccessAudience = "auth"
+
+// RefreshToken exchange a refresh token for new tokens of the same family
+// a refresh token is used once, using it again revokes the whole family and fails with ErrRefreshTokenReused
+func (s *Service) RefreshToken(ctx context.Context, refresh string, user *entity.User, tokens *entity.Tokens) error {
+	var token entity.RefreshToken
+	err := s.fetchRefreshToken(ctx, refresh, &token)
+	if err != nil {
+		return err
+	}
+
+	return s.rotate(ctx, &token, token.OrganizationID, user, tokens)
+}
+
+// rotate use a refresh token to issue new tokens of its family for the organization orgID
+func (s *Service) rotate(ctx context.Context, token *entity.RefreshToken, orgID int64, user *entity.User, tokens *entity.Tokens) error {
+	if token.Revoked || !token.Expires.After(time.Now()) {
+		return errors.ErrInvalidRefreshToken
+	}
+
+	if token.Used {
+		return s.revokeReused(ctx, token.Family)
+	}
+
+	err := s.GetUserByID(ctx, token.UserID, user)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	var claims tokenClaims
+	err = s.readClaims(ctx, user.ID, orgID, &claims)
+	if err != nil {
+		return err
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.UseRefreshToken(ctx, tx, token.ID)
+		if err != nil {
+			return err
+		}
+
+		return s.issueTokens(ctx, tx, user, claims, token.Family, tokens)
+	})
+	if errors.Is(err, errors.ErrUpdateConflict) {
+		// a concurrent refresh used the token first
+		return s.revokeReused(ctx, token.Family)
+	}
+	if err != nil {
+		return fmt.Errorf("could not refresh token; %w", err)
+	}
+
+	return nil
+}
+
+// Logout revoke the family of the refresh token and deny the access token of the request
+func (s *Service) Logout(ctx context.Context, refresh string) error {
+	var token entity.RefreshToken
+	err := s.fetchRefreshToken(ctx, refresh, &token)
+	if err != nil {
+		return err
+	}
+
+	viewer, ok := Viewer(ctx)
+	if ok && viewer.ID != token.UserID {
+		return errors.ErrForbidden
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.RevokeRefreshFamily(ctx, tx, token.Family)
+		if err != nil {
+			return err
+		}
+
+		if !ok || viewer.TokenID == "" {
+			return nil
+		}
+
+		return s.store.DenyToken(ctx, tx, viewer.TokenID, viewer.Expires)
+	})
+	if err != nil {
+		return fmt.Errorf("could not logout; %w", err)
+	}
+
+	return nil
+}
+
+// IsTokenDenied tells if the access token jti was revoked by a logout
+func (s *Service) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	denied, err := s.store.IsTokenDenied(ctx, jti)
+	if err != nil {
+		return false, fmt.Errorf("could not check denied token; %w", err)
+	}
+
+	return denied, nil
+}
+
+// IsTokenRevoked tells if the access token of the user issued at a time was revoked by a password reset
+func (s *Service) IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	revoked, err := s.store.IsTokenRevoked(ctx, userID, issuedAt)
+	if err != nil {
+		return false, fmt.Errorf("could not check revoked token; %w", err)
+	}
+
+	return revoked, nil
+}
+
+// JWKS return the public keys verifying the access tokens
+func (s *Service) JWKS(ctx context.Context) (jwk.Set, error) {
+	set, err := s.config.JWT.Keys.Public()
+	if err != nil {
+		return nil, fmt.Errorf("could not get public keys; %w", err)
+	}
+
+	return set, nil
+}
+
+// tokenClaims are the roles and the organization of the access token of a user
+type tokenClaims struct {
+	roles []string
+	orgID int64
+}
+
+// readClaims read the claims of the tokens of user for the organization orgID, or the first one of the user if
+// it is not a member, it must run before the tx issuing the tokens which holds a connection of the pool
+func (s *Service) readClaims(ctx context.Context, userID, orgID int64, claims *tokenClaims) error {
+	err := s.GetUserRoles(ctx, userID, &claims.roles)
+	if err != nil {
+		return err
+	}
+
+	claims.orgID, err = s.organization(ctx, userID, orgID)
+	return err
+}
+
+// issueTokens sign an access token with claims for user and store a new refresh token in family
+func (s *Service) issueTokens(ctx context.Context, tx store.Tx, user *entity.User, claims tokenClaims, family string, tokens *entity.Tokens) error {
+	jti, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t := jwt.New()
+
+	// https://tools.ietf.org/html/rfc7519#page-9
+	_ = t.Set(jwt.SubjectKey, strconv.FormatInt(user.ID, 10))
+	_ = t.Set(jwt.IssuedAtKey, now.Unix())
+	_ = t.Set(jwt.ExpirationKey, now.Add(s.config.JWT.ExpireIn).Unix())
+	_ = t.Set(jwt.AudienceKey, AccessAudience)
+	_ = t.Set(jwt.IssuerKey, s.config.JWT.Issuer)
+	_ = t.Set(jwt.JwtIDKey, jti)
+	_ = t.Set(RolesClaim, claims.roles)
+	if claims.orgID != 0 {
+		_ = t.Set(OrganizationClaim, claims.orgID)
+	}
+
+	access, err := s.config.JWT.Keys.Sign(t)
+	if err != nil {
+		return err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("could not generate refresh token; %w", err)
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+
+	err = s.store.AddRefreshToken(ctx, tx, &entity.RefreshToken{
+		UserID:         user.ID,
+		Family:         family,
+		Hash:           hashToken(refresh),
+		Expires:        now.Add(s.config.JWT.RefreshExpireIn),
+		OrganizationID: claims.orgID,
+	})
+	if err != nil {
+		return fmt.Errorf("could not add refresh token; %w", err)
+	}
+
+	*tokens = entity.Tokens{Access: string(access), Refresh: refresh}
+	return nil
+}
+
+// fetchRefreshToken find a refresh token from its raw value
+func (s *Service) fetchRefreshToken(ctx context.Context, refresh string, token *entity.RefreshToken) error {
+	if refresh == "" {
+		return errors.ErrInvalidRefreshToken
+	}
+
+	err := s.store.FetchRefreshToken(ctx, hashToken(refresh), token)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch refresh token; %w", err)
+	}
+
+	return nil
+}
+
+// revokeReused revoke a family after one of its tokens was used twice
+func (s *Service) revokeReused(ctx context.Context, family string) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.RevokeRefreshFamily(ctx, tx, family)
+	})
+	if err != nil {
+		return fmt.Errorf("could not revoke refresh family; %w", err)
+	}
+
+	return errors.ErrRefreshTokenReused
+}
+
+// HasAudience tells if audience is one of the audiences of t
+func HasAudience(t jwt.Token, audience string) bool {
+	for _, aud := range t.Audience() {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PurgeTokens remove the refresh tokens, the denied tokens and the password resets which expired
+func (s *Service) PurgeTokens(ctx context.Context) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.PurgeExpiredTokens(ctx, tx, store.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("could not purge expired tokens; %w", err)
+	}
+
+	return nil
+}
+
+// hashToken is the stored form of a refresh token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString return n random bytes hex encoded
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate random; %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
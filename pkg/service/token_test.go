@@ -0,0 +1,224 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+	"boiler/pkg/service"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+)
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{
+		JWT: config.JWT{Keys: keyset.New(time.Minute, key), ExpireIn: time.Minute, RefreshExpireIn: time.Hour},
+	}, m, service.Options{})
+	ctx := context.Background()
+
+	fetch := func(token entity.RefreshToken) {
+		m.EXPECT().FetchRefreshToken(ctx, hash("raw"), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, t *entity.RefreshToken) error {
+				*t = token
+				return nil
+			})
+	}
+	valid := entity.RefreshToken{ID: 2, UserID: 4, Family: "f", Expires: time.Now().Add(time.Hour), OrganizationID: 5}
+
+	// succeed
+	{
+		fetch(valid)
+		m.EXPECT().FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4}}
+				return nil
+			})
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseRefreshToken(gomock.Any(), tx, int64(2)).Return(nil)
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, orgs *[]entity.Organization) error {
+				*orgs = []entity.Organization{{ID: 3}, {ID: 5}}
+				return nil
+			})
+		m.EXPECT().AddRefreshToken(gomock.Any(), tx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ interface{}, token *entity.RefreshToken) error {
+				assert.Equal(t, int64(4), token.UserID)
+				assert.Equal(t, "f", token.Family)
+				assert.Equal(t, int64(5), token.OrganizationID)
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.RefreshToken(ctx, "raw", &user, &tokens))
+		assert.Equal(t, int64(4), user.ID)
+		assert.NotEmpty(t, tokens.Access)
+		assert.NotEqual(t, "raw", tokens.Refresh)
+	}
+
+	// fails if the token does not exist
+	{
+		m.EXPECT().FetchRefreshToken(ctx, hash("raw"), gomock.Any()).Return(errors.ErrNotFound)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidRefreshToken, srv.RefreshToken(ctx, "raw", &user, &tokens))
+	}
+
+	// fails if the token expired
+	{
+		expired := valid
+		expired.Expires = time.Now().Add(-time.Second)
+		fetch(expired)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidRefreshToken, srv.RefreshToken(ctx, "raw", &user, &tokens))
+	}
+
+	// fails and revokes the family if the token was already used
+	{
+		used := valid
+		used.Used = true
+		fetch(used)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RevokeRefreshFamily(gomock.Any(), tx, "f").Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrRefreshTokenReused, srv.RefreshToken(ctx, "raw", &user, &tokens))
+	}
+
+	// fails and revokes the family if the token is used concurrently
+	{
+		fetch(valid)
+		m.EXPECT().FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4}}
+				return nil
+			})
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseRefreshToken(gomock.Any(), tx, int64(2)).Return(errors.ErrUpdateConflict)
+		tx.EXPECT().Rollback().Return(nil)
+		revoke := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(revoke, nil)
+		m.EXPECT().RevokeRefreshFamily(gomock.Any(), revoke, "f").Return(nil)
+		revoke.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrRefreshTokenReused, srv.RefreshToken(ctx, "raw", &user, &tokens))
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	expires := time.Now().Add(time.Minute)
+	ctx := context.WithValue(context.Background(), config.ContextKeyAuthenticationUser{},
+		&entity.JWTUser{ID: 4, TokenID: "jti", Expires: expires})
+
+	fetch := func(userID int64) {
+		m.EXPECT().FetchRefreshToken(ctx, hash("raw"), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, t *entity.RefreshToken) error {
+				*t = entity.RefreshToken{ID: 2, UserID: userID, Family: "f"}
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		fetch(4)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RevokeRefreshFamily(gomock.Any(), tx, "f").Return(nil)
+		m.EXPECT().DenyToken(gomock.Any(), tx, "jti", expires).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.Logout(ctx, "raw"))
+	}
+
+	// fails if the token belongs to another user
+	{
+		fetch(5)
+
+		assert.Equal(t, errors.ErrForbidden, srv.Logout(ctx, "raw"))
+	}
+
+	// fails if the token is empty
+	{
+		assert.Equal(t, errors.ErrInvalidRefreshToken, srv.Logout(ctx, ""))
+	}
+}
+
+func TestPurgeTokens(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().PurgeExpiredTokens(gomock.Any(), tx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, before time.Time) error {
+				assert.WithinDuration(t, time.Now(), before, time.Second)
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.PurgeTokens(ctx))
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().PurgeExpiredTokens(gomock.Any(), tx, gomock.Any()).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, "could not purge expired tokens; opz", srv.PurgeTokens(ctx).Error())
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+	"boiler/pkg/totp"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ChallengeAudience is the audience of the challenges returned by AuthUser to the users with a
+	// second factor
+	ChallengeAudience = "totp_challenge"
+	// RecoveryCodes is the number of recovery codes given by the confirmation of a TOTP
+	RecoveryCodes = 10
+)
+
+// EnrollTOTP generate a new TOTP secret for the user, it is required to authenticate once confirmed
+// enrolling again replaces the unconfirmed secret, it fails with ErrTOTPAlreadyEnabled once confirmed
+func (s *Service) EnrollTOTP(ctx context.Context, userID int64, enrollment *entity.TOTPEnrollment) error {
+	var user entity.User
+	err := s.GetUserByID(ctx, userID, &user)
+	if err != nil {
+		return err
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return err
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.SetTOTP(ctx, tx, &entity.TOTP{UserID: userID, Secret: secret})
+	})
+	if err == errors.ErrUpdateConflict {
+		return errors.ErrTOTPAlreadyEnabled
+	}
+	if err != nil {
+		return fmt.Errorf("could not enroll totp; %w", err)
+	}
+
+	*enrollment = entity.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.config.JWT.Issuer, user.Name, secret),
+	}
+	return nil
+}
+
+// ConfirmTOTP enable the TOTP of the user with a code of its app and return its recovery codes
+// the recovery codes are only stored hashed, they can not be read again
+func (s *Service) ConfirmTOTP(ctx context.Context, userID int64, code string, recoveryCodes *[]string) error {
+	var otp entity.TOTP
+	err := s.store.FetchTOTP(ctx, userID, &otp)
+	if err == errors.ErrNotFound {
+		return errors.ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch totp; %w", err)
+	}
+
+	if otp.Confirmed {
+		return errors.ErrTOTPAlreadyEnabled
+	}
+
+	counter, ok := totp.Validate(otp.Secret, code, time.Now())
+	if !ok {
+		return errors.ErrInvalidTOTPCode
+	}
+
+	codes := make([]string, 0, RecoveryCodes)
+	hashes := make([]string, 0, RecoveryCodes)
+	for i := 0; i < RecoveryCodes; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return err
+		}
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.ConfirmTOTP(ctx, tx, userID, counter)
+		if err == errors.ErrUpdateConflict {
+			return errors.ErrTOTPAlreadyEnabled
+		}
+		if err != nil {
+			return err
+		}
+
+		return s.store.SetRecoveryCodes(ctx, tx, userID, hashes)
+	})
+	if err != nil {
+		return fmt.Errorf("could not confirm totp; %w", err)
+	}
+
+	*recoveryCodes = codes
+	return nil
+}
+
+// AuthTOTP exchange the challenge returned by AuthUser and a code for the tokens of the user
+// the code is a code of the TOTP app or a recovery code, each of them authenticates once
+// the failures are throttled per user and per client address
+func (s *Service) AuthTOTP(ctx context.Context, challenge, code string, user *entity.User, tokens *entity.Tokens) error {
+	t, err := s.config.JWT.Keys.Parse(challenge)
+	if err != nil || !HasAudience(t, ChallengeAudience) || len(t.JwtID()) == 0 {
+		return errors.ErrInvalidChallenge
+	}
+
+	userID, err := strconv.ParseInt(t.Subject(), 10, 64)
+	if err != nil {
+		return errors.ErrInvalidChallenge
+	}
+
+	denied, err := s.IsTokenDenied(ctx, t.JwtID())
+	if err != nil {
+		return err
+	}
+	if denied {
+		return errors.ErrInvalidChallenge
+	}
+
+	keys := newTOTPKeys(ctx, userID)
+	err = s.checkThrottle(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	var otp entity.TOTP
+	err = s.store.FetchTOTP(ctx, userID, &otp)
+	if err == errors.ErrNotFound || (err == nil && !otp.Confirmed) {
+		// the TOTP was removed since the challenge
+		return errors.ErrInvalidChallenge
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch totp; %w", err)
+	}
+
+	err = s.GetUserByID(ctx, userID, user)
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidChallenge
+	}
+	if err != nil {
+		return err
+	}
+
+	var claims tokenClaims
+	err = s.readClaims(ctx, user.ID, 0, &claims)
+	if err != nil {
+		return err
+	}
+
+	family, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.useCode(ctx, tx, &otp, code)
+		if err != nil {
+			return err
+		}
+
+		// a challenge authenticates once
+		err = s.store.DenyToken(ctx, tx, t.JwtID(), t.Expiration())
+		if err != nil {
+			return err
+		}
+
+		return s.issueTokens(ctx, tx, user, claims, family, tokens)
+	})
+	if err == errors.ErrInvalidTOTPCode {
+		s.failThrottle(ctx, keys)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not issue tokens; %w", err)
+	}
+
+	err = s.throttle.Reset(ctx, keys.account)
+	if err != nil {
+		log.Error().Err(err).Msg("could not reset throttle")
+	}
+
+	return nil
+}
+
+// challenge sign the challenge of an user authenticated by its password, it waits for the second factor
+func (s *Service) challenge(user *entity.User, tokens *entity.Tokens) error {
+	jti, err := randomString(16)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t := jwt.New()
+	_ = t.Set(jwt.SubjectKey, strconv.FormatInt(user.ID, 10))
+	_ = t.Set(jwt.IssuedAtKey, now.Unix())
+	_ = t.Set(jwt.ExpirationKey, now.Add(s.config.Auth.ChallengeExpireIn).Unix())
+	_ = t.Set(jwt.AudienceKey, ChallengeAudience)
+	_ = t.Set(jwt.IssuerKey, s.config.JWT.Issuer)
+	_ = t.Set(jwt.JwtIDKey, jti)
+
+	challenge, err := s.config.JWT.Keys.Sign(t)
+	if err != nil {
+		return fmt.Errorf("could not sign challenge; %w", err)
+	}
+
+	*tokens = entity.Tokens{Challenge: string(challenge)}
+	return nil
+}
+
+// hasTOTP tells if the user has a confirmed TOTP
+func (s *Service) hasTOTP(ctx context.Context, userID int64) (bool, error) {
+	var otp entity.TOTP
+	err := s.store.FetchTOTP(ctx, userID, &otp)
+	if err == errors.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not fetch totp; %w", err)
+	}
+
+	return otp.Confirmed, nil
+}
+
+// useCode use code as a code of the TOTP app, or else as a recovery code of the user
+// it fails with ErrInvalidTOTPCode if the code is wrong or was already used
+func (s *Service) useCode(ctx context.Context, tx store.Tx, otp *entity.TOTP, code string) error {
+	if counter, ok := totp.Validate(otp.Secret, code, time.Now()); ok {
+		err := s.store.UseTOTP(ctx, tx, otp.UserID, counter)
+		if err == errors.ErrUpdateConflict {
+			return errors.ErrInvalidTOTPCode
+		}
+
+		return err
+	}
+
+	err := s.store.UseRecoveryCode(ctx, tx, otp.UserID, hashToken(normalizeRecoveryCode(code)))
+	if err == errors.ErrNotFound {
+		return errors.ErrInvalidTOTPCode
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info().Int64("user", otp.UserID).Msg("recovery code used")
+	return nil
+}
+
+// newTOTPKeys return the keys throttling the codes of the user from the client of ctx
+func newTOTPKeys(ctx context.Context, userID int64) authKeys {
+	keys := authKeys{account: "auth:totp:" + strconv.FormatInt(userID, 10)}
+	if ip := ClientIP(ctx); len(ip) != 0 {
+		keys.ip = "auth:ip:" + ip
+	}
+
+	return keys
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCode return a random code of 80 bits written as xxxx-xxxx-xxxx-xxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate recovery code; %w", err)
+	}
+
+	code := strings.ToLower(recoveryEncoding.EncodeToString(b))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// normalizeRecoveryCode return the hashed form of a recovery code, ignoring the case and the separators
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,324 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
+	"boiler/pkg/service"
+	"boiler/pkg/store"
+	"boiler/pkg/store/config"
+	"boiler/pkg/store/mock"
+	"boiler/pkg/store/throttle"
+	"boiler/pkg/totp"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestEnrollTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{JWT: config.JWT{Issuer: "boiler"}}, m, service.Options{})
+	ctx := context.Background()
+
+	fetch := func() {
+		m.EXPECT().
+			FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4, Name: "jo"}}
+				return nil
+			})
+	}
+
+	// succeed
+	{
+		fetch()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		var stored entity.TOTP
+		m.EXPECT().SetTOTP(gomock.Any(), tx, gomock.Any()).DoAndReturn(func(_ context.Context, _ store.Tx, otp *entity.TOTP) error {
+			stored = *otp
+			return nil
+		})
+		tx.EXPECT().Commit().Return(nil)
+
+		var enrollment entity.TOTPEnrollment
+		assert.Nil(t, srv.EnrollTOTP(ctx, 4, &enrollment))
+		assert.Equal(t, int64(4), stored.UserID)
+		assert.Equal(t, enrollment.Secret, stored.Secret)
+
+		u, err := url.Parse(enrollment.URI)
+		assert.Nil(t, err)
+		assert.Equal(t, "/boiler:jo", u.Path)
+		assert.Equal(t, enrollment.Secret, u.Query().Get("secret"))
+	}
+
+	// fails if the TOTP is already confirmed
+	{
+		fetch()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().SetTOTP(gomock.Any(), tx, gomock.Any()).Return(errors.ErrUpdateConflict)
+		tx.EXPECT().Rollback().Return(nil)
+
+		var enrollment entity.TOTPEnrollment
+		assert.Equal(t, errors.ErrTOTPAlreadyEnabled, srv.EnrollTOTP(ctx, 4, &enrollment))
+	}
+
+	// fails if the user does not exist
+	{
+		m.EXPECT().FetchUsers(ctx, []int64{5}, false, gomock.Any()).Return(nil)
+
+		var enrollment entity.TOTPEnrollment
+		assert.Equal(t, errors.ErrNotFound, srv.EnrollTOTP(ctx, 5, &enrollment))
+	}
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	fetchTOTP := func(confirmed bool) {
+		m.EXPECT().
+			FetchTOTP(ctx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, otp *entity.TOTP) error {
+				*otp = entity.TOTP{UserID: 4, Secret: secret, Confirmed: confirmed}
+				return nil
+			})
+	}
+
+	counter := totp.Counter(time.Now())
+	code, err := totp.Code(secret, counter)
+	assert.Nil(t, err)
+
+	// succeed
+	{
+		fetchTOTP(false)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().ConfirmTOTP(gomock.Any(), tx, int64(4), gomock.Any()).Return(nil)
+		var hashes []string
+		m.EXPECT().SetRecoveryCodes(gomock.Any(), tx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, _ int64, h []string) error {
+				hashes = h
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+
+		var codes []string
+		assert.Nil(t, srv.ConfirmTOTP(ctx, 4, code, &codes))
+		assert.Len(t, codes, service.RecoveryCodes)
+		assert.Len(t, hashes, service.RecoveryCodes)
+		assert.Len(t, codes[0], 19)
+		assert.Equal(t, hash(strings.ReplaceAll(codes[0], "-", "")), hashes[0])
+	}
+
+	// fails with a wrong code
+	{
+		fetchTOTP(false)
+
+		var codes []string
+		assert.Equal(t, errors.ErrInvalidTOTPCode, srv.ConfirmTOTP(ctx, 4, "000000", &codes))
+	}
+
+	// fails if already confirmed
+	{
+		fetchTOTP(true)
+
+		var codes []string
+		assert.Equal(t, errors.ErrTOTPAlreadyEnabled, srv.ConfirmTOTP(ctx, 4, code, &codes))
+	}
+
+	// fails if not enrolled
+	{
+		m.EXPECT().FetchTOTP(ctx, int64(4), gomock.Any()).Return(errors.ErrNotFound)
+
+		var codes []string
+		assert.Equal(t, errors.ErrTOTPNotEnrolled, srv.ConfirmTOTP(ctx, 4, code, &codes))
+	}
+
+	// fails if store fails
+	{
+		m.EXPECT().FetchTOTP(ctx, int64(4), gomock.Any()).Return(fmt.Errorf("opz"))
+
+		var codes []string
+		assert.Equal(t, "could not fetch totp; opz", srv.ConfirmTOTP(ctx, 4, code, &codes).Error())
+	}
+}
+
+func TestAuthTOTP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, key)
+
+	m := mock.NewMockInterface(ctrl)
+	lock := throttle.Policy{Free: 1, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	conf := &config.Config{
+		JWT:      config.JWT{Keys: keys, ExpireIn: time.Minute},
+		Auth:     config.Auth{ChallengeExpireIn: time.Minute},
+		Throttle: config.Throttle{Account: lock, IP: lock},
+	}
+	srv := service.New(conf, m, service.Options{Throttle: throttle.NewMemory()})
+	ctx := context.Background()
+
+	password, err := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
+	assert.Nil(t, err)
+
+	fetchUser := func() {
+		m.EXPECT().
+			FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4, Password: string(password)}}
+				return nil
+			})
+	}
+	fetchTOTP := func() {
+		m.EXPECT().
+			FetchTOTP(ctx, int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, otp *entity.TOTP) error {
+				*otp = entity.TOTP{UserID: 4, Secret: secret, Confirmed: true}
+				return nil
+			})
+	}
+	readClaims := func() {
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+	}
+	challenge := func() string {
+		m.EXPECT().
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: "a@b.c", Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, IDs *[]int64) error {
+				*IDs = []int64{4}
+				return nil
+			})
+		fetchUser()
+		fetchTOTP()
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.AuthUser(ctx, "a@b.c", "pass", &user, &tokens))
+		assert.Empty(t, tokens.Access)
+		assert.Empty(t, tokens.Refresh)
+		assert.NotEmpty(t, tokens.Challenge)
+
+		return tokens.Challenge
+	}
+
+	code, err := totp.Code(secret, totp.Counter(time.Now()))
+	assert.Nil(t, err)
+
+	// succeed with a code of the app
+	{
+		c := challenge()
+		m.EXPECT().IsTokenDenied(ctx, gomock.Any()).Return(false, nil)
+		fetchTOTP()
+		fetchUser()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseTOTP(gomock.Any(), tx, int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().DenyToken(gomock.Any(), tx, gomock.Any(), gomock.Any()).Return(nil)
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().AddRefreshToken(gomock.Any(), tx, gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.AuthTOTP(ctx, c, code, &user, &tokens))
+		assert.Equal(t, int64(4), user.ID)
+		assert.NotEmpty(t, tokens.Refresh)
+
+		parsed, err := keys.Parse(tokens.Access)
+		assert.Nil(t, err)
+		assert.True(t, service.HasAudience(parsed, service.AccessAudience))
+	}
+
+	// succeed with a recovery code
+	{
+		c := challenge()
+		m.EXPECT().IsTokenDenied(ctx, gomock.Any()).Return(false, nil)
+		fetchTOTP()
+		fetchUser()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseRecoveryCode(gomock.Any(), tx, int64(4), hash("abcdefgh")).Return(nil)
+		m.EXPECT().DenyToken(gomock.Any(), tx, gomock.Any(), gomock.Any()).Return(nil)
+		m.EXPECT().FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().AddRefreshToken(gomock.Any(), tx, gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.AuthTOTP(ctx, c, "ABCD-efgh", &user, &tokens))
+		assert.NotEmpty(t, tokens.Access)
+	}
+
+	// fails if the challenge is not one
+	{
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidChallenge, srv.AuthTOTP(ctx, "nope", code, &user, &tokens))
+	}
+
+	// fails if the challenge was used
+	{
+		c := challenge()
+		m.EXPECT().IsTokenDenied(ctx, gomock.Any()).Return(true, nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidChallenge, srv.AuthTOTP(ctx, c, code, &user, &tokens))
+	}
+
+	// fails with a used or wrong code, then the user is locked
+	{
+		c := challenge()
+		m.EXPECT().IsTokenDenied(ctx, gomock.Any()).Return(false, nil).Times(3)
+		fetchTOTP()
+		fetchUser()
+		readClaims()
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseTOTP(gomock.Any(), tx, int64(4), gomock.Any()).Return(errors.ErrUpdateConflict)
+		tx.EXPECT().Rollback().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidTOTPCode, srv.AuthTOTP(ctx, c, code, &user, &tokens))
+
+		fetchTOTP()
+		fetchUser()
+		readClaims()
+		tx = mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UseRecoveryCode(gomock.Any(), tx, int64(4), gomock.Any()).Return(errors.ErrNotFound)
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, errors.ErrInvalidTOTPCode, srv.AuthTOTP(ctx, c, "000000", &user, &tokens))
+		assert.Equal(t, errors.ErrTooManyAttempts, srv.AuthTOTP(ctx, c, code, &user, &tokens))
+	}
+}
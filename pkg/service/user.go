@@ -3,146 +3,377 @@ package service
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"time"
 
 	"boiler/pkg/entity"
 	"boiler/pkg/errors"
 	"boiler/pkg/store"
 
-	"github.com/lestrrat-go/jwx/jwa"
-	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// AddUser add a new user
-func (s *Service) AddUser(ctx context.Context, user *entity.User) error {
+// AddUser add a new user with its emails
+// the emails let the user authenticate, nobody else can add them once the user exists
+func (s *Service) AddUser(ctx context.Context, user *entity.User, emails ...*entity.Email) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("could not generate password; %w", err)
 	}
 
-	tx, err := s.store.Tx()
+	user.Password = string(hash)
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.AddUser(ctx, tx, user)
+		if err != nil {
+			return err
+		}
+
+		err = s.store.SetUserRoles(ctx, tx, user.ID, []string{entity.RoleMember})
+		if err != nil {
+			return err
+		}
+
+		for _, email := range emails {
+			email.UserID = user.ID
+			err = s.store.AddEmail(ctx, tx, email)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("could not begin transaction; %w", err)
+		return fmt.Errorf("could not add user; %w", err)
 	}
 
-	user.Password = string(hash)
+	for _, email := range emails {
+		s.enqueueVerifyEmail(ctx, email.ID)
+	}
+
+	return nil
+}
 
-	err = s.store.AddUser(ctx, tx, user)
+// UpdateUser update the profile of an user
+// user.Updated must be the value read before the change, it fails with ErrUpdateConflict if the user
+// was modified since then
+func (s *Service) UpdateUser(ctx context.Context, user *entity.User) error {
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.UpdateUser(ctx, tx, user)
+	})
 	if err != nil {
-		if er := tx.Rollback(); er != nil {
-			err = fmt.Errorf("%s; %w", er, err)
-		}
+		return fmt.Errorf("could not update user; %w", err)
+	}
 
-		return fmt.Errorf("could not add user; %w", err)
+	return nil
+}
+
+// ChangePassword replace the password of an user if current is its password
+func (s *Service) ChangePassword(ctx context.Context, userID int64, current, password string) error {
+	if len(password) == 0 {
+		return errors.ErrInvalidPassword
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("could not add user; %w", err)
+	var user entity.User
+	err := s.GetUserByID(ctx, userID, &user)
+	if err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)) != nil {
+		return errors.ErrInvalidPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("could not generate password; %w", err)
+	}
+
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.UpdateUserPassword(ctx, tx, userID, string(hash))
+	})
+	if err != nil {
+		return fmt.Errorf("could not change password; %w", err)
 	}
 
 	return nil
 }
 
-// AuthUser returns a JWT token from users credentials
-func (s *Service) AuthUser(ctx context.Context, email, password string, user *entity.User, token *string) error {
+// AuthUser authenticate an user from its credentials and returns its tokens
+// each authentication starts a new family of refresh tokens
+// the failures are throttled per account and per client address, they all fail with ErrInvalidCredentials
+// the users with a TOTP only get a challenge, AuthTOTP exchanges it with a code for the tokens
+func (s *Service) AuthUser(ctx context.Context, email, password string, user *entity.User, tokens *entity.Tokens) error {
+	keys := newAuthKeys(ctx, email)
+	err := s.checkThrottle(ctx, keys)
+	if err != nil {
+		return err
+	}
 
-	var IDs []int64
-	err := s.store.FilterUsersID(ctx, store.FilterUsers{Email: email, Limit: FilterUsersDefaultLimit}, &IDs)
+	err = s.checkCredentials(ctx, email, password, user)
+	if err == errors.ErrInvalidCredentials {
+		s.failThrottle(ctx, keys)
+		return err
+	}
 	if err != nil {
 		return err
 	}
-	if len(IDs) != 1 {
-		return errors.ErrNotFound
+
+	// the address is not reset, a valid account must not hide the guesses on the others
+	err = s.throttle.Reset(ctx, keys.account)
+	if err != nil {
+		log.Error().Err(err).Msg("could not reset throttle")
 	}
 
-	err = s.GetUserByID(ctx, IDs[0], user)
+	if s.config.Auth.RequireVerifiedEmail {
+		var emails []entity.Email
+		err = s.store.FilterEmails(ctx, store.FilterEmails{Address: email, AllOrganizations: true}, &emails)
+		if err != nil {
+			return err
+		}
+		if len(emails) != 1 || emails[0].VerifiedAt == nil {
+			return errors.ErrEmailNotVerified
+		}
+	}
+
+	enabled, err := s.hasTOTP(ctx, user.ID)
 	if err != nil {
 		return err
 	}
+	if enabled {
+		return s.challenge(user, tokens)
+	}
 
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
-		return errors.ErrInvalidPassword
+	var claims tokenClaims
+	err = s.readClaims(ctx, user.ID, 0, &claims)
+	if err != nil {
+		return err
 	}
 
-	t := jwt.New()
+	family, err := randomString(16)
+	if err != nil {
+		return err
+	}
 
-	// https://tools.ietf.org/html/rfc7519#page-9
-	_ = t.Set(jwt.SubjectKey, strconv.FormatInt(user.ID, 10))
-	_ = t.Set(jwt.IssuedAtKey, time.Now().Unix())
-	_ = t.Set(jwt.ExpirationKey, time.Now().Add(s.config.JWT.ExpireIn).Unix())
-	_ = t.Set(jwt.AudienceKey, "auth")
-	_ = t.Set(jwt.IssuerKey, s.config.JWT.Issuer)
+	err = store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.issueTokens(ctx, tx, user, claims, family, tokens)
+	})
+	if err != nil {
+		return fmt.Errorf("could not issue tokens; %w", err)
+	}
 
-	raw, err := jwt.Sign(t, jwa.RS256, s.config.JWT.PrivateKey)
+	return nil
+}
+
+// checkCredentials read the user of email if password is its password
+// an unknown email fails like a wrong password and takes as long, so the response does not reveal the
+// registered emails
+func (s *Service) checkCredentials(ctx context.Context, email, password string, user *entity.User) error {
+	var IDs []int64
+	filter := store.FilterUsers{Email: email, Limit: FilterUsersDefaultLimit, AllOrganizations: true}
+	err := s.store.FilterUsersID(ctx, filter, &IDs)
+	if err != nil {
+		return err
+	}
+	if len(IDs) != 1 {
+		// compare anyway to take as long as a wrong password
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return errors.ErrInvalidCredentials
+	}
+
+	err = s.GetUserByID(ctx, IDs[0], user)
 	if err != nil {
 		return err
 	}
 
-	*token = string(raw)
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return errors.ErrInvalidCredentials
+	}
 
 	return nil
 }
 
-// EnqueueDeleteUser enqueue user to be deleted
-func (s *Service) EnqueueDeleteUser(ctx context.Context, userID int64) error {
-	_, err := s.enqueuer.Enqueue(DeleteUser, map[string]interface{}{"id": userID})
-	return err
+// EnqueueDeleteUser enqueue user to be deleted, job is the status of the deletion
+func (s *Service) EnqueueDeleteUser(ctx context.Context, userID int64, job *entity.Job) error {
+	payload := entity.DeleteUserPayload{Version: entity.DeleteUserPayloadVersion, ID: userID}
+	return s.enqueueJob(ctx, DeleteUser, &payload, job)
 }
 
-// DeleteUser remove user by ID
+// DeleteUser mark an user and its emails deleted and end its sessions,
+// they can be restored until they are purged
 func (s *Service) DeleteUser(ctx context.Context, userID int64) error {
-	tx, err := s.store.Tx()
-	if err != nil {
-		return fmt.Errorf("could not begin delete user transaction; %w", err)
-	}
+	return store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.DeleteUser(ctx, tx, userID)
+		if err != nil && err != errors.ErrNotFound {
+			return fmt.Errorf("could not delete user; %w", err)
+		}
 
-	err = s.store.DeleteUser(ctx, tx, userID)
-	if err != nil && err != errors.ErrNotFound {
-		if er := tx.Rollback(); er != nil {
-			err = fmt.Errorf("%s; %w", er, err)
+		err = s.store.DeleteEmailsByUserID(ctx, tx, userID)
+		if err != nil && err != errors.ErrNotFound {
+			return fmt.Errorf("could not delete user emails; %w", err)
 		}
 
-		return fmt.Errorf("could not delete user; %w", err)
-	}
+		err = s.store.RevokeUserRefreshTokens(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("could not revoke user refresh tokens; %w", err)
+		}
 
-	err = s.store.DeleteEmailsByUserID(ctx, tx, userID)
-	if err != nil && err != errors.ErrNotFound {
-		if er := tx.Rollback(); er != nil {
-			err = fmt.Errorf("%s; %w", er, err)
+		return nil
+	})
+}
+
+// RestoreUser undelete an user and the emails deleted with it, it fails with ErrNotFound if it is not deleted
+func (s *Service) RestoreUser(ctx context.Context, userID int64) error {
+	return store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.RestoreUser(ctx, tx, userID)
+		if err == errors.ErrNotFound {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("could not restore user; %w", err)
+		}
+
+		return nil
+	})
+}
+
+// PurgeDeleted remove the users and the emails deleted for longer than the retention
+func (s *Service) PurgeDeleted(ctx context.Context) error {
+	before := time.Now().Add(-s.config.Worker.DeletedRetention)
+
+	for {
+		var IDs []int64
+		err := s.store.FilterDeletedUsersID(ctx, before, PurgeBatchSize, &IDs)
+		if err != nil {
+			return fmt.Errorf("could not filter deleted users; %w", err)
 		}
 
-		return fmt.Errorf("could not delete user emails; %w", err)
+		for _, ID := range IDs {
+			err = s.purgeUser(ctx, ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if uint(len(IDs)) < PurgeBatchSize {
+			break
+		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("could not commit delete user; %w", err)
+	err := store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		return s.store.PurgeEmails(ctx, tx, before)
+	})
+	if err != nil {
+		return fmt.Errorf("could not purge emails; %w", err)
 	}
 
 	return nil
 }
 
-// FilterUsers retrieve users
-func (s *Service) FilterUsers(ctx context.Context, filter store.FilterUsers, users *[]entity.User) error {
+// purgeUser remove a deleted user with everything it has
+func (s *Service) purgeUser(ctx context.Context, userID int64) error {
+	return store.RunInTx(ctx, s.store, func(ctx context.Context, tx store.Tx) error {
+		err := s.store.PurgeUser(ctx, tx, userID)
+		if err != nil && err != errors.ErrNotFound {
+			return fmt.Errorf("could not purge user; %w", err)
+		}
+
+		err = s.store.SetUserRoles(ctx, tx, userID, nil)
+		if err != nil {
+			return fmt.Errorf("could not delete user roles; %w", err)
+		}
+
+		err = s.store.DeleteTOTP(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("could not delete user totp; %w", err)
+		}
+
+		err = s.store.DeleteAPIKeysByUserID(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("could not delete user api keys; %w", err)
+		}
+
+		err = s.store.DeleteMembersByUserID(ctx, tx, userID)
+		if err != nil {
+			return fmt.Errorf("could not delete user memberships; %w", err)
+		}
+
+		return nil
+	})
+}
+
+// FilterUsers retrieve a page of users, page can be nil
+func (s *Service) FilterUsers(ctx context.Context, filter store.FilterUsers, users *[]entity.User, page *store.PageInfo) error {
+	// the users of the other organizations are never listed, a viewer without organization lists them all
+	orgID := viewerOrganization(ctx)
+	filter.OrganizationID, filter.AllOrganizations = orgID, orgID == 0
 
 	if filter.Limit == 0 {
 		filter.Limit = FilterUsersDefaultLimit
 	}
 
+	seek, err := filter.Seek()
+	if err != nil {
+		return err
+	}
+
+	// an extra user tells if there is another page
+	limit := filter.Limit
+	filter.Limit++
+
 	var IDs []int64
-	err := s.store.FilterUsersID(ctx, filter, &IDs)
+	err = s.store.FilterUsersID(ctx, filter, &IDs)
 	if err != nil {
 		return err
 	}
 
-	return s.store.FetchUsers(ctx, IDs, users)
+	more := uint(len(IDs)) > limit
+	if more {
+		if seek.Backward {
+			IDs = IDs[1:]
+		} else {
+			IDs = IDs[:limit]
+		}
+	}
+
+	var us []entity.User
+	err = s.store.FetchUsers(ctx, IDs, filter.IncludeDeleted, &us)
+	if err != nil {
+		return err
+	}
+
+	// keep the order of the filter
+	byID := make(map[int64]entity.User, len(us))
+	for _, u := range us {
+		byID[u.ID] = u
+	}
+
+	*users = make([]entity.User, 0, len(IDs))
+	for _, ID := range IDs {
+		if u, ok := byID[ID]; ok {
+			*users = append(*users, u)
+		}
+	}
+
+	if page != nil {
+		var first, last *store.Cursor
+		if n := len(*users); n != 0 {
+			f := store.NewCursor(seek.SortBy, (*users)[0].ID, (*users)[0].Created)
+			l := store.NewCursor(seek.SortBy, (*users)[n-1].ID, (*users)[n-1].Created)
+			first, last = &f, &l
+		}
+
+		*page = store.NewPageInfo(seek, more, first, last)
+	}
+
+	return nil
 }
 
 // GetUserByID get user by ID
 func (s *Service) GetUserByID(ctx context.Context, userID int64, user *entity.User) error {
 	var users []entity.User
-	err := s.store.FetchUsers(ctx, []int64{userID}, &users)
+	err := s.store.FetchUsers(ctx, []int64{userID}, false, &users)
 	if err != nil {
 		return err
 	}
@@ -156,7 +387,7 @@ func (s *Service) GetUserByID(ctx context.Context, userID int64, user *entity.Us
 // GetUserByEmail get user by Email
 func (s *Service) GetUserByEmail(ctx context.Context, email string, user *entity.User) error {
 
-	filter := store.FilterUsers{Email: email, Limit: FilterUsersDefaultLimit}
+	filter := store.FilterUsers{Email: email, Limit: FilterUsersDefaultLimit, AllOrganizations: true}
 
 	var IDs []int64
 	err := s.store.FilterUsersID(ctx, filter, &IDs)
@@ -4,18 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"boiler/pkg/entity"
 	"boiler/pkg/errors"
+	"boiler/pkg/keyset"
 	"boiler/pkg/service"
+	smock "boiler/pkg/service/mock"
 	"boiler/pkg/store"
 	"boiler/pkg/store/config"
+	"boiler/pkg/store/database"
+	"boiler/pkg/store/migration"
 	"boiler/pkg/store/mock"
+	"boiler/pkg/store/throttle"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/golang/mock/gomock"
+	"github.com/lestrrat-go/jwx/jwa"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestAddUser(t *testing.T) {
@@ -23,8 +33,9 @@ func TestAddUser(t *testing.T) {
 	defer ctrl.Finish()
 
 	m := mock.NewMockInterface(ctrl)
+	enq := smock.NewMockEnqueuer(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{Enqueuer: enq})
 
 	var userID int64 = 99
 	name := "name"
@@ -34,40 +45,77 @@ func TestAddUser(t *testing.T) {
 
 	// succeed
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		user := entity.User{
 			Name:     name,
 			Password: password,
 		}
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddUser(ctx, tx, &user).
-			DoAndReturn(func(_ context.Context, _ *sql.Tx, u *entity.User) error {
+			AddUser(gomock.Any(), tx, &user).
+			DoAndReturn(func(_ context.Context, _ store.Tx, u *entity.User) error {
 				u.ID = userID
 				return nil
 			})
-		mdb.ExpectCommit()
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, userID, []string{entity.RoleMember}).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
 
-		err = srv.AddUser(ctx, &user)
+		err := srv.AddUser(ctx, &user)
 		assert.Nil(t, err)
 		assert.Equal(t, userID, user.ID)
-		assert.Nil(t, mdb.ExpectationsWereMet())
+	}
+
+	// succeed with emails
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		user := entity.User{Name: name, Password: password}
+		email := entity.Email{Address: "a@b.c"}
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddUser(gomock.Any(), tx, &user).
+			DoAndReturn(func(_ context.Context, _ store.Tx, u *entity.User) error {
+				u.ID = userID
+				return nil
+			})
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, userID, []string{entity.RoleMember}).Return(nil)
+		m.EXPECT().AddEmail(gomock.Any(), tx, &entity.Email{UserID: userID, Address: "a@b.c"}).
+			DoAndReturn(func(_ context.Context, _ store.Tx, e *entity.Email) error {
+				e.ID = 7
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+		payload := entity.SendVerifyEmailPayload{Version: entity.SendVerifyEmailPayloadVersion, ID: 7}
+		enq.EXPECT().Enqueue(service.SendVerifyEmail, payloadArgs(t, &payload, nil)).Return(nil, nil)
+
+		err := srv.AddUser(ctx, &user, &email)
+		assert.Nil(t, err)
+		assert.Equal(t, userID, email.UserID)
+	}
+
+	// fails if an email can not be added
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		user := entity.User{Name: name, Password: password}
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().AddUser(gomock.Any(), tx, &user).Return(nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, int64(0), []string{entity.RoleMember}).Return(nil)
+		m.EXPECT().AddEmail(gomock.Any(), tx, gomock.Any()).Return(errors.ErrAlreadyExists)
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.AddUser(ctx, &user, &entity.Email{Address: "a@b.c"})
+		assert.True(t, errors.Is(err, errors.ErrAlreadyExists))
 	}
 
 	// fails if Tx fails
 	{
 		opz := fmt.Errorf("opz")
-		m.EXPECT().Tx().Return(nil, opz)
+		m.EXPECT().Tx(ctx).Return(nil, opz)
 
 		user := entity.User{
 			Name:     name,
@@ -77,282 +125,435 @@ func TestAddUser(t *testing.T) {
 		err := srv.AddUser(ctx, &user)
 		assert.NotNil(t, err)
 		assert.True(t, errors.Is(err, opz))
-		assert.Equal(t, err.Error(), "could not begin transaction; opz")
+		assert.Equal(t, err.Error(), "could not add user; could not begin transaction; opz")
 	}
 
 	// fails if service fails
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		user := entity.User{
 			Name:     name,
 			Password: password,
 		}
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddUser(ctx, tx, &user).
+			AddUser(gomock.Any(), tx, &user).
 			Return(fmt.Errorf("rollback"))
-		mdb.ExpectRollback()
+		tx.EXPECT().Rollback().Return(nil)
 
-		err = srv.AddUser(ctx, &user)
+		err := srv.AddUser(ctx, &user)
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "could not add user; rollback")
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// fails if service fails and rollback fails
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		user := entity.User{
 			Name:     name,
 			Password: password,
 		}
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddUser(ctx, tx, &user).
+			AddUser(gomock.Any(), tx, &user).
 			Return(fmt.Errorf("rollback"))
 
-		mdb.ExpectRollback().WillReturnError(fmt.Errorf("rollbackerr"))
+		tx.EXPECT().Rollback().Return(fmt.Errorf("rollbackerr"))
 
-		err = srv.AddUser(ctx, &user)
+		err := srv.AddUser(ctx, &user)
 		assert.NotNil(t, err)
 		assert.Equal(t, err.Error(), "could not add user; rollbackerr; rollback")
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// fails if commit fails
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
-
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
+		tx := mock.NewMockTx(ctrl)
 
 		user := entity.User{
 			Name:     name,
 			Password: password,
 		}
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			AddUser(ctx, tx, &user).
+			AddUser(gomock.Any(), tx, &user).
 			Return(nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, int64(0), []string{entity.RoleMember}).Return(nil)
 
-		mdb.ExpectCommit().WillReturnError(fmt.Errorf("commit failed"))
+		tx.EXPECT().Commit().Return(fmt.Errorf("commit failed"))
 
-		err = srv.AddUser(ctx, &user)
+		err := srv.AddUser(ctx, &user)
 		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not add user; commit failed")
-		assert.Nil(t, mdb.ExpectationsWereMet())
+		assert.Equal(t, err.Error(), "could not add user; could not commit transaction; commit failed")
 	}
 }
 
-func TestDeleteUser(t *testing.T) {
+func TestUpdateUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	m := mock.NewMockInterface(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
-
-	var userID int64 = 99
+	srv := service.New(&config.Config{}, m, service.Options{})
 
 	ctx := context.Background()
+	updated := time.Now()
 
 	// succeed
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
+		tx := mock.NewMockTx(ctrl)
+		user := entity.User{ID: 3, Name: "name", Updated: updated}
 
-		mdb.ExpectBegin()
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.
+			EXPECT().
+			UpdateUser(gomock.Any(), tx, &user).
+			DoAndReturn(func(_ context.Context, _ store.Tx, u *entity.User) error {
+				u.Updated = updated.Add(time.Second)
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
 
-		tx, err := db.Begin()
+		err := srv.UpdateUser(ctx, &user)
 		assert.Nil(t, err)
+		assert.Equal(t, updated.Add(time.Second), user.Updated)
+	}
+
+	// fails if modified
+	{
+		tx := mock.NewMockTx(ctrl)
+		user := entity.User{ID: 3, Name: "name", Updated: updated}
+
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UpdateUser(gomock.Any(), tx, &user).Return(errors.ErrUpdateConflict)
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.UpdateUser(ctx, &user)
+		assert.True(t, errors.Is(err, errors.ErrUpdateConflict))
+		assert.True(t, errors.Is(err, errors.ErrConflict))
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	ctx := context.Background()
+	var userID int64 = 3
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("current"), bcrypt.MinCost)
+	assert.Nil(t, err)
+
+	fetch := func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+		*users = append(*users, entity.User{ID: userID, Password: string(hash)})
+		return nil
+	}
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().FetchUsers(ctx, []int64{userID}, false, gomock.Any()).DoAndReturn(fetch)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.
+			EXPECT().
+			UpdateUserPassword(gomock.Any(), tx, userID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, _ int64, password string) error {
+				assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(password), []byte("new")))
+				return nil
+			})
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.ChangePassword(ctx, userID, "current", "new"))
+	}
+
+	// fails if current password is wrong
+	{
+		m.EXPECT().FetchUsers(ctx, []int64{userID}, false, gomock.Any()).DoAndReturn(fetch)
+
+		err := srv.ChangePassword(ctx, userID, "wrong", "new")
+		assert.Equal(t, errors.ErrInvalidPassword, err)
+	}
+
+	// fails if new password is empty
+	{
+		err := srv.ChangePassword(ctx, userID, "current", "")
+		assert.Equal(t, errors.ErrInvalidPassword, err)
+	}
+
+	// fails if user is not found
+	{
+		m.EXPECT().FetchUsers(ctx, []int64{userID}, false, gomock.Any()).Return(nil)
+
+		err := srv.ChangePassword(ctx, userID, "current", "new")
+		assert.Equal(t, errors.ErrNotFound, err)
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+
+		m.EXPECT().FetchUsers(ctx, []int64{userID}, false, gomock.Any()).DoAndReturn(fetch)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().UpdateUserPassword(gomock.Any(), tx, userID, gomock.Any()).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := srv.ChangePassword(ctx, userID, "current", "new")
+		assert.Equal(t, "could not change password; opz", err.Error())
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+
+	srv := service.New(&config.Config{}, m, service.Options{})
+
+	var userID int64 = 99
+
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
 
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteUser(ctx, tx, userID).
+			DeleteUser(gomock.Any(), tx, userID).
 			Return(nil)
 		m.
 			EXPECT().
-			DeleteEmailsByUserID(ctx, tx, userID).
+			DeleteEmailsByUserID(gomock.Any(), tx, userID).
 			Return(nil)
-		mdb.ExpectCommit()
+		m.EXPECT().RevokeUserRefreshTokens(gomock.Any(), tx, userID).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
 
-		err = srv.DeleteUser(ctx, userID)
+		err := srv.DeleteUser(ctx, userID)
 		assert.Nil(t, err)
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// fails if Tx fails
 	{
-		m.EXPECT().Tx().Return(nil, fmt.Errorf("tx fails"))
+		m.EXPECT().Tx(ctx).Return(nil, fmt.Errorf("tx fails"))
 
 		err := srv.DeleteUser(ctx, userID)
 		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not begin delete user transaction; tx fails")
+		assert.Equal(t, err.Error(), "could not begin transaction; tx fails")
 	}
 
 	// DeleteUser fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
+		tx := mock.NewMockTx(ctrl)
 
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteUser(ctx, tx, userID).
+			DeleteUser(gomock.Any(), tx, userID).
 			Return(fmt.Errorf("deletefail"))
-		mdb.ExpectRollback()
+		tx.EXPECT().Rollback().Return(nil)
 
-		err = srv.DeleteUser(ctx, userID)
+		err := srv.DeleteUser(ctx, userID)
 		assert.NotNil(t, err)
 		assert.Equal(t, "could not delete user; deletefail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// DeleteUser rollback fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteUser(ctx, tx, userID).
+			DeleteUser(gomock.Any(), tx, userID).
 			Return(fmt.Errorf("deletefail"))
 
-		mdb.ExpectRollback().WillReturnError(fmt.Errorf("rollbackfail"))
+		tx.EXPECT().Rollback().Return(fmt.Errorf("rollbackfail"))
 
-		err = srv.DeleteUser(ctx, userID)
+		err := srv.DeleteUser(ctx, userID)
 		assert.NotNil(t, err)
-		assert.Equal(t, "could not delete user; rollbackfail; deletefail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
+		assert.Equal(t, "rollbackfail; could not delete user; deletefail", err.Error())
 	}
 
 	// DeleteEmail fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
-
-		mdb.ExpectBegin()
+		tx := mock.NewMockTx(ctrl)
 
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteUser(ctx, tx, userID).
+			DeleteUser(gomock.Any(), tx, userID).
 			Return(nil)
 		m.
 			EXPECT().
-			DeleteEmailsByUserID(ctx, tx, userID).
+			DeleteEmailsByUserID(gomock.Any(), tx, userID).
 			Return(fmt.Errorf("deletefail"))
-		mdb.ExpectRollback()
+		tx.EXPECT().Rollback().Return(nil)
 
-		err = srv.DeleteUser(ctx, userID)
+		err := srv.DeleteUser(ctx, userID)
 		assert.NotNil(t, err)
 		assert.Equal(t, "could not delete user emails; deletefail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
 	}
 
 	// DeleteEmail rollback fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
+		tx := mock.NewMockTx(ctrl)
 
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteUser(ctx, tx, userID).
+			DeleteUser(gomock.Any(), tx, userID).
 			Return(nil)
 
 		errDeleteFail := errors.New("deletefail")
 		m.
 			EXPECT().
-			DeleteEmailsByUserID(ctx, tx, userID).
+			DeleteEmailsByUserID(gomock.Any(), tx, userID).
 			Return(errDeleteFail)
 
-		mdb.ExpectRollback().WillReturnError(errors.New("rollbackfail"))
+		tx.EXPECT().Rollback().Return(errors.New("rollbackfail"))
 
-		err = srv.DeleteUser(ctx, userID)
+		err := srv.DeleteUser(ctx, userID)
 		assert.NotNil(t, err)
 		assert.True(t, errors.Is(err, errDeleteFail))
-		assert.Equal(t, "could not delete user emails; rollbackfail; deletefail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
+		assert.Equal(t, "rollbackfail; could not delete user emails; deletefail", err.Error())
 	}
 
 	// commit fail
 	{
-		db, mdb, err := sqlmock.New()
-		assert.Nil(t, err)
-		defer db.Close()
+		tx := mock.NewMockTx(ctrl)
 
-		mdb.ExpectBegin()
-
-		tx, err := db.Begin()
-		assert.Nil(t, err)
-
-		m.EXPECT().Tx().Return(tx, nil)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
 		m.
 			EXPECT().
-			DeleteUser(ctx, tx, userID).
+			DeleteUser(gomock.Any(), tx, userID).
 			Return(nil)
 		m.
 			EXPECT().
-			DeleteEmailsByUserID(ctx, tx, userID).
+			DeleteEmailsByUserID(gomock.Any(), tx, userID).
 			Return(nil)
-		mdb.ExpectCommit().WillReturnError(fmt.Errorf("commitfail"))
+		m.EXPECT().RevokeUserRefreshTokens(gomock.Any(), tx, userID).Return(nil)
+		tx.EXPECT().Commit().Return(fmt.Errorf("commitfail"))
 
-		err = srv.DeleteUser(ctx, userID)
+		err := srv.DeleteUser(ctx, userID)
 		assert.NotNil(t, err)
-		assert.Equal(t, "could not commit delete user; commitfail", err.Error())
-		assert.Nil(t, mdb.ExpectationsWereMet())
+		assert.Equal(t, "could not commit transaction; commitfail", err.Error())
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RestoreUser(gomock.Any(), tx, int64(4)).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.RestoreUser(ctx, 4))
+	}
+
+	// fails if the user is not deleted
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RestoreUser(gomock.Any(), tx, int64(4)).Return(errors.ErrNotFound)
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, errors.ErrNotFound, srv.RestoreUser(ctx, 4))
+	}
+
+	// fails if store fails
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().RestoreUser(gomock.Any(), tx, int64(4)).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, "could not restore user; opz", srv.RestoreUser(ctx, 4).Error())
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	srv := service.New(&config.Config{Worker: config.Worker{DeletedRetention: time.Hour}}, m, service.Options{})
+	ctx := context.Background()
+
+	// succeed
+	{
+		var cutoff time.Time
+		m.EXPECT().FilterDeletedUsersID(ctx, gomock.Any(), service.PurgeBatchSize, gomock.Any()).
+			DoAndReturn(func(_ context.Context, before time.Time, _ uint, IDs *[]int64) error {
+				assert.WithinDuration(t, time.Now().Add(-time.Hour), before, time.Second)
+				cutoff = before
+				*IDs = []int64{4}
+				return nil
+			})
+
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().PurgeUser(gomock.Any(), tx, int64(4)).Return(nil)
+		m.EXPECT().SetUserRoles(gomock.Any(), tx, int64(4), nil).Return(nil)
+		m.EXPECT().DeleteTOTP(gomock.Any(), tx, int64(4)).Return(nil)
+		m.EXPECT().DeleteAPIKeysByUserID(gomock.Any(), tx, int64(4)).Return(nil)
+		m.EXPECT().DeleteMembersByUserID(gomock.Any(), tx, int64(4)).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		etx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(etx, nil)
+		m.EXPECT().PurgeEmails(gomock.Any(), etx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.Tx, before time.Time) error {
+				assert.Equal(t, cutoff, before)
+				return nil
+			})
+		etx.EXPECT().Commit().Return(nil)
+
+		assert.Nil(t, srv.PurgeDeleted(ctx))
+	}
+
+	// fails if a purge fails
+	{
+		m.EXPECT().FilterDeletedUsersID(ctx, gomock.Any(), service.PurgeBatchSize, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ time.Time, _ uint, IDs *[]int64) error {
+				*IDs = []int64{4}
+				return nil
+			})
+
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().PurgeUser(gomock.Any(), tx, int64(4)).Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.Equal(t, "could not purge user; opz", srv.PurgeDeleted(ctx).Error())
+	}
+
+	// fails if filter fails
+	{
+		m.EXPECT().FilterDeletedUsersID(ctx, gomock.Any(), service.PurgeBatchSize, gomock.Any()).Return(fmt.Errorf("opz"))
+
+		assert.Equal(t, "could not filter deleted users; opz", srv.PurgeDeleted(ctx).Error())
 	}
 }
 
@@ -362,11 +563,11 @@ func TestFilterUsersID(t *testing.T) {
 
 	m := mock.NewMockInterface(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{})
 
 	var userID int64 = 99
 	name := "name"
-	filter := store.FilterUsers{Limit: 10}
+	filter := store.FilterUsers{Limit: 10, AllOrganizations: true}
 	ctx := context.Background()
 
 	// success
@@ -374,15 +575,15 @@ func TestFilterUsersID(t *testing.T) {
 		var IDs []int64
 		m.
 			EXPECT().
-			FilterUsersID(ctx, filter, &IDs).
+			FilterUsersID(ctx, store.FilterUsers{Limit: 11, AllOrganizations: true}, &IDs).
 			DoAndReturn(func(_ context.Context, _ store.FilterUsers, ids *[]int64) error {
 				*ids = append(*ids, userID)
 				return nil
 			})
 		m.
 			EXPECT().
-			FetchUsers(ctx, []int64{userID}, gomock.Any()).
-			DoAndReturn(func(_ context.Context, _ []int64, users *[]entity.User) error {
+			FetchUsers(ctx, []int64{userID}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
 				*users = append(*users, entity.User{
 					ID:   userID,
 					Name: name,
@@ -391,11 +592,55 @@ func TestFilterUsersID(t *testing.T) {
 			})
 
 		var users []entity.User
-		err := srv.FilterUsers(ctx, filter, &users)
+		var page store.PageInfo
+		err := srv.FilterUsers(ctx, filter, &users, &page)
 		assert.Nil(t, err)
 		assert.Len(t, users, 1)
 		assert.Equal(t, users[0].ID, userID)
 		assert.Equal(t, users[0].Name, name)
+		assert.False(t, page.HasNextPage)
+		assert.Equal(t, store.NewCursor(store.SortByID, userID, time.Time{}).String(), page.EndCursor)
+	}
+
+	// keep the filter order and trim the extra user
+	{
+		created := time.Now()
+		filter := store.FilterUsers{SortBy: store.SortByCreated, Limit: 2, AllOrganizations: true}
+
+		var IDs []int64
+		m.
+			EXPECT().
+			FilterUsersID(ctx, store.FilterUsers{SortBy: store.SortByCreated, Limit: 3, AllOrganizations: true}, &IDs).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, ids *[]int64) error {
+				*ids = append(*ids, 3, 1, 2)
+				return nil
+			})
+		m.
+			EXPECT().
+			FetchUsers(ctx, []int64{3, 1}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = append(*users, entity.User{ID: 1, Created: created}, entity.User{ID: 3, Created: created})
+				return nil
+			})
+
+		var users []entity.User
+		var page store.PageInfo
+		err := srv.FilterUsers(ctx, filter, &users, &page)
+		assert.Nil(t, err)
+		if assert.Len(t, users, 2) {
+			assert.Equal(t, int64(3), users[0].ID)
+			assert.Equal(t, int64(1), users[1].ID)
+		}
+		assert.True(t, page.HasNextPage)
+		assert.False(t, page.HasPreviousPage)
+		assert.Equal(t, store.NewCursor(store.SortByCreated, 1, created).String(), page.EndCursor)
+	}
+
+	// fails if sort is invalid
+	{
+		var users []entity.User
+		err := srv.FilterUsers(ctx, store.FilterUsers{SortBy: "name", AllOrganizations: true}, &users, nil)
+		assert.Equal(t, errors.ErrInvalidSort, err)
 	}
 
 	// fail
@@ -403,11 +648,11 @@ func TestFilterUsersID(t *testing.T) {
 		var IDs []int64
 		m.
 			EXPECT().
-			FilterUsersID(ctx, filter, &IDs).
+			FilterUsersID(ctx, store.FilterUsers{Limit: 11, AllOrganizations: true}, &IDs).
 			Return(fmt.Errorf("opz"))
 
 		var users []entity.User
-		err := srv.FilterUsers(ctx, filter, &users)
+		err := srv.FilterUsers(ctx, filter, &users, nil)
 		assert.Len(t, users, 0)
 		assert.Equal(t, err.Error(), "opz")
 	}
@@ -419,7 +664,7 @@ func TestGetUserByID(t *testing.T) {
 
 	m := mock.NewMockInterface(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{})
 
 	var userID int64 = 99
 	name := "userName"
@@ -430,8 +675,8 @@ func TestGetUserByID(t *testing.T) {
 	{
 		m.
 			EXPECT().
-			FetchUsers(ctx, []int64{userID}, gomock.Any()).
-			DoAndReturn(func(_ context.Context, ids []int64, users *[]entity.User) error {
+			FetchUsers(ctx, []int64{userID}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, ids []int64, _ bool, users *[]entity.User) error {
 				*users = []entity.User{
 					{
 						ID:   userID,
@@ -452,7 +697,7 @@ func TestGetUserByID(t *testing.T) {
 	{
 		m.
 			EXPECT().
-			FetchUsers(ctx, []int64{userID}, gomock.Any()).
+			FetchUsers(ctx, []int64{userID}, false, gomock.Any()).
 			Return(fmt.Errorf("opz"))
 
 		var user entity.User
@@ -465,7 +710,7 @@ func TestGetUserByID(t *testing.T) {
 	{
 		m.
 			EXPECT().
-			FetchUsers(ctx, []int64{userID}, gomock.Any()).
+			FetchUsers(ctx, []int64{userID}, false, gomock.Any()).
 			Return(nil)
 
 		var user entity.User
@@ -481,7 +726,7 @@ func TestGetUserByEmail(t *testing.T) {
 
 	m := mock.NewMockInterface(ctrl)
 
-	srv := service.New(&config.Config{}, m, nil)
+	srv := service.New(&config.Config{}, m, service.Options{})
 
 	var userID int64 = 99
 	name := "userName"
@@ -493,15 +738,17 @@ func TestGetUserByEmail(t *testing.T) {
 	{
 		m.
 			EXPECT().
-			FilterUsersID(ctx, store.FilterUsers{Email: email, Limit: service.FilterUsersDefaultLimit}, gomock.Any()).
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: email, Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
 			DoAndReturn(func(_ context.Context, _ store.FilterUsers, ids *[]int64) error {
 				*ids = append(*ids, userID)
 				return nil
 			})
 		m.
 			EXPECT().
-			FetchUsers(ctx, []int64{userID}, gomock.Any()).
-			DoAndReturn(func(_ context.Context, ids []int64, users *[]entity.User) error {
+			FetchUsers(ctx, []int64{userID}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, ids []int64, _ bool, users *[]entity.User) error {
 				*users = append(*users, entity.User{
 					ID:   userID,
 					Name: name,
@@ -521,7 +768,9 @@ func TestGetUserByEmail(t *testing.T) {
 	{
 		m.
 			EXPECT().
-			FilterUsersID(ctx, store.FilterUsers{Email: email, Limit: service.FilterUsersDefaultLimit}, gomock.Any()).
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: email, Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
 			Return(fmt.Errorf("opz"))
 
 		var user entity.User
@@ -534,7 +783,9 @@ func TestGetUserByEmail(t *testing.T) {
 	{
 		m.
 			EXPECT().
-			FilterUsersID(ctx, store.FilterUsers{Email: email, Limit: service.FilterUsersDefaultLimit}, gomock.Any()).
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: email, Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
 			Return(nil)
 
 		var user entity.User
@@ -542,3 +793,220 @@ func TestGetUserByEmail(t *testing.T) {
 		assert.Equal(t, errors.ErrNotFound, err)
 	}
 }
+
+func TestAuthUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	keys := keyset.New(time.Minute, key)
+
+	m := mock.NewMockInterface(ctrl)
+	lock := throttle.Policy{Free: 2, Base: time.Minute, Max: time.Hour, Window: time.Hour}
+	conf := &config.Config{
+		JWT:      config.JWT{Keys: keys, ExpireIn: time.Minute},
+		Throttle: config.Throttle{Account: lock, IP: lock},
+	}
+	srv := service.New(conf, m, service.Options{Throttle: throttle.NewMemory()})
+	ctx := context.Background()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
+	assert.Nil(t, err)
+
+	// succeed with the roles in the token
+	{
+		m.EXPECT().
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: "a@b.c", Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, IDs *[]int64) error {
+				*IDs = []int64{4}
+				return nil
+			})
+		m.EXPECT().
+			FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4, Password: string(hash)}}
+				return nil
+			})
+		m.EXPECT().
+			FetchUserRoles(gomock.Any(), int64(4), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, roles *[]string) error {
+				*roles = []string{entity.RoleMember, entity.RoleSupport}
+				return nil
+			})
+		m.EXPECT().FetchTOTP(ctx, int64(4), gomock.Any()).Return(errors.ErrNotFound)
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		m.EXPECT().FilterOrganizations(gomock.Any(), int64(4), gomock.Any()).Return(nil)
+		m.EXPECT().AddRefreshToken(gomock.Any(), tx, gomock.Any()).Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Nil(t, srv.AuthUser(ctx, "a@b.c", "pass", &user, &tokens))
+		assert.NotEmpty(t, tokens.Refresh)
+
+		parsed, err := keys.Parse(tokens.Access)
+		assert.Nil(t, err)
+		assert.Equal(t, "4", parsed.Subject())
+		assert.NotEmpty(t, parsed.JwtID())
+
+		roles, ok := parsed.Get(service.RolesClaim)
+		assert.True(t, ok)
+		assert.Equal(t, []interface{}{entity.RoleMember, entity.RoleSupport}, roles)
+	}
+
+	filter := func(email string, IDs ...int64) {
+		m.EXPECT().
+			FilterUsersID(gomock.Any(), store.FilterUsers{
+				Email: email, Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, ids *[]int64) error {
+				*ids = IDs
+				return nil
+			})
+	}
+	fetch := func() {
+		m.EXPECT().
+			FetchUsers(gomock.Any(), []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4, Password: string(hash)}}
+				return nil
+			})
+	}
+
+	// fails with the same error if the email does not exist or the password is wrong
+	{
+		filter("a@b.c")
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidCredentials, srv.AuthUser(ctx, "a@b.c", "pass", &user, &tokens))
+
+		filter("a@b.c", 4)
+		fetch()
+
+		assert.Equal(t, errors.ErrInvalidCredentials, srv.AuthUser(ctx, "a@b.c", "bad", &user, &tokens))
+	}
+
+	// fails once the account is locked, even with the password
+	{
+		filter("A@b.c", 4)
+		fetch()
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrInvalidCredentials, srv.AuthUser(ctx, "A@b.c", "bad", &user, &tokens))
+		assert.Equal(t, errors.ErrTooManyAttempts, srv.AuthUser(ctx, "a@b.c", "pass", &user, &tokens))
+	}
+
+	// fails once the address is locked, for every account
+	{
+		srv := service.New(conf, m, service.Options{Throttle: throttle.NewMemory()})
+		ip := context.WithValue(ctx, config.ContextKeyClientIP{}, "1.2.3.4")
+
+		var user entity.User
+		var tokens entity.Tokens
+		for _, email := range []string{"x@b.c", "y@b.c", "z@b.c"} {
+			filter(email)
+			assert.Equal(t, errors.ErrInvalidCredentials, srv.AuthUser(ip, email, "bad", &user, &tokens))
+		}
+
+		assert.Equal(t, errors.ErrTooManyAttempts, srv.AuthUser(ip, "a@b.c", "pass", &user, &tokens))
+
+		// another address is not locked
+		filter("a@b.c")
+		other := context.WithValue(ctx, config.ContextKeyClientIP{}, "5.6.7.8")
+		assert.Equal(t, errors.ErrInvalidCredentials, srv.AuthUser(other, "a@b.c", "pass", &user, &tokens))
+	}
+
+	// fails if the email is not verified
+	{
+		conf := &config.Config{JWT: config.JWT{Keys: keys}, Auth: config.Auth{RequireVerifiedEmail: true}}
+		srv := service.New(conf, m, service.Options{Throttle: throttle.NewMemory()})
+
+		m.EXPECT().
+			FilterUsersID(ctx, store.FilterUsers{
+				Email: "a@b.c", Limit: service.FilterUsersDefaultLimit, AllOrganizations: true,
+			}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterUsers, IDs *[]int64) error {
+				*IDs = []int64{4}
+				return nil
+			})
+		m.EXPECT().
+			FetchUsers(ctx, []int64{4}, false, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ []int64, _ bool, users *[]entity.User) error {
+				*users = []entity.User{{ID: 4, Password: string(hash)}}
+				return nil
+			})
+		m.EXPECT().
+			FilterEmails(ctx, store.FilterEmails{Address: "a@b.c", AllOrganizations: true}, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ store.FilterEmails, emails *[]entity.Email) error {
+				*emails = []entity.Email{{ID: 2, UserID: 4, Address: "a@b.c"}}
+				return nil
+			})
+
+		var user entity.User
+		var tokens entity.Tokens
+		assert.Equal(t, errors.ErrEmailNotVerified, srv.AuthUser(ctx, "a@b.c", "pass", &user, &tokens))
+	}
+}
+
+func TestAuthUserConcurrent(t *testing.T) {
+	// the pool of the database is limited like the one of cmd.NewDB
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite3")+"?_busy_timeout=5000")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	m, err := migration.New(db, database.Sqlite3.Migrations())
+	assert.Nil(t, err)
+	assert.Nil(t, m.Up(context.Background()))
+
+	key, err := keyset.Generate(jwa.ES256)
+	assert.Nil(t, err)
+
+	st := database.New(db)
+	conf := &config.Config{JWT: config.JWT{Keys: keyset.New(time.Minute, key), ExpireIn: time.Minute}}
+	srv := service.New(conf, st, service.Options{Throttle: throttle.NewMemory()})
+	ctx := context.Background()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.MinCost)
+	assert.Nil(t, err)
+
+	user := entity.User{Name: "a", Password: string(hash)}
+	err = store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+		if err := st.AddUser(ctx, tx, &user); err != nil {
+			return err
+		}
+
+		return st.AddEmail(ctx, tx, &entity.Email{UserID: user.ID, Address: "a@b.c"})
+	})
+	assert.Nil(t, err)
+
+	// succeed to login concurrently without exhausting the pool
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var user entity.User
+			var tokens entity.Tokens
+			errs <- srv.AuthUser(ctx, "a@b.c", "pass", &user, &tokens)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+}
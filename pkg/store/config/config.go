@@ -1,27 +1,125 @@
 package config
 
 import (
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"io/ioutil"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"boiler/pkg/keyset"
+	"boiler/pkg/store/throttle"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/robfig/cron"
 )
 
 type ContextKeyDebug struct{}
 
 type ContextKeyAuthenticationUser struct{}
 
+// ContextKeyClientIP is the key of the IP address of the client in the request context
+type ContextKeyClientIP struct{}
+
+// ContextKeyRequestID is the key of the ID of the request in its context and in the context of the jobs it enqueued
+type ContextKeyRequestID struct{}
+
+// ContextKeyJobUserID is the key of the ID of the user who enqueued the job in the job context
+type ContextKeyJobUserID struct{}
+
 type Config struct {
-	JWT     JWT
-	Worker  Worker
-	Sqlite3 string
+	JWT      JWT
+	Worker   Worker
+	Database Database
+	Auth     Auth
+	Mailer   Mailer
+	Throttle Throttle
+}
+
+// Throttle limit the failed authentications, per account and per IP address
+type Throttle struct {
+	// Driver is redis, falling back to memory when redis fails, or memory
+	Driver  string
+	Account throttle.Policy
+	IP      throttle.Policy
+}
+
+// Mailer select how the emails are sent
+type Mailer struct {
+	// Driver is one of log, smtp or maildir
+	Driver string
+	From   string
+	SMTP   SMTP
+	// Maildir is the folder the maildir driver drops the messages in
+	Maildir string
+}
+
+// SMTP is the server of the smtp driver, it authenticates if Username is set
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+// Auth configure the authorization policy
+type Auth struct {
+	// Admins are the IDs of the users having the admin role without it being stored
+	// it bootstraps the first admin who can then grant roles
+	Admins []int64
+	// RequireVerifiedEmail reject the authentication by an email not verified
+	RequireVerifiedEmail bool
+	// VerifyEmailExpireIn is the lifetime of the tokens verifying the emails
+	VerifyEmailExpireIn time.Duration
+	// PasswordResetExpireIn is the lifetime of the tokens resetting the passwords
+	PasswordResetExpireIn time.Duration
+	// ChallengeExpireIn is the lifetime of the challenges waiting for the second factor
+	ChallengeExpireIn time.Duration
+	// InvitationExpireIn is the lifetime of the invitations to join an organization
+	InvitationExpireIn time.Duration
+}
+
+// Database select the store backend
+type Database struct {
+	// Driver is one of sqlite3, postgres or memory
+	Driver string
+	DSN    string
 }
 
 type Worker struct {
 	Concurrency uint
 	Redis       Redis
+	Queue       Queue
+	// Schedules are the jobs the worker enqueues periodically, the server lists them with their runs
+	Schedules []Schedule
+	// Retries are the retry policies of the jobs by name
+	Retries map[string]Retry
+	// Timeouts cancel the context of the runs of the jobs by name, the jobs without one never time out
+	Timeouts map[string]time.Duration
+	// DeletedRetention is how long the deleted users and emails can be restored before being purged
+	DeletedRetention time.Duration
+}
+
+// Schedule enqueue Job at the times of Spec, a cron spec with seconds
+type Schedule struct {
+	Job  string
+	Spec string
+}
+
+// Queue select where the jobs wait for the worker
+type Queue struct {
+	// Driver is redis or database, database keeps them in the sqlite3 or postgres database of the store
+	Driver string
+	// Visibility is how long a job run by a worker of the database queue is hidden from the others
+	Visibility time.Duration
+}
+
+// Retry tells how a failed job runs again before it is dead
+type Retry struct {
+	// MaxFails is the number of failed runs making the job dead, 1 never retries it
+	MaxFails uint
+	// Backoff is the wait before the first retry, doubling at each retry; zero waits like gocraft/work
+	Backoff time.Duration
 }
 
 type Redis struct {
@@ -32,17 +130,28 @@ type Redis struct {
 }
 
 type JWT struct {
-	PrivateKey *rsa.PrivateKey
-	ExpireIn   time.Duration
-	Issuer     string
+	// Keys sign the access tokens, the first key of JWT_KEYS signs and the others only verify
+	// for the overlap, it lets a deploy replace the key without rejecting the tokens already issued
+	Keys *keyset.Keyset
+	// RotateEvery generate a new key of Algorithm at each interval, zero disables the rotation
+	// the generated keys are not shared, rotate only with a single server
+	RotateEvery time.Duration
+	Algorithm   jwa.SignatureAlgorithm
+	ExpireIn    time.Duration
+	// RefreshExpireIn is the lifetime of a refresh token, each refresh issues a new one
+	RefreshExpireIn time.Duration
+	Issuer          string
 }
 
 func New() *Config {
 	return &Config{
 		JWT: JWT{
-			PrivateKey: readJWTPrivateKey(),
-			ExpireIn:   time.Second * 30,
-			Issuer:     "boiler",
+			Keys:            readJWTKeys(env("JWT_KEYS", "jwt.pem"), envDuration("JWT_KEY_OVERLAP", time.Minute*5)),
+			RotateEvery:     envDuration("JWT_ROTATE_EVERY", 0),
+			Algorithm:       jwa.SignatureAlgorithm(env("JWT_ALGORITHM", jwa.RS256.String())),
+			ExpireIn:        time.Second * 30,
+			RefreshExpireIn: time.Hour * 24 * 30,
+			Issuer:          "boiler",
 		},
 		Worker: Worker{
 			Concurrency: 10,
@@ -52,22 +161,272 @@ func New() *Config {
 				Wait:      true,
 				Address:   ":6379",
 			},
+			Queue: Queue{
+				Driver:     env("QUEUE_DRIVER", "redis"),
+				Visibility: envMinDuration("QUEUE_VISIBILITY", time.Minute*5, time.Second),
+			},
+			Schedules: envSchedules("SCHEDULES", []Schedule{
+				{Job: "purge_deleted", Spec: "0 0 3 * * *"},
+				{Job: "purge_tokens", Spec: "0 0 * * * *"},
+				{Job: "purge_orphaned_emails", Spec: "0 30 3 * * *"},
+			}),
+			Retries: envRetries("RETRIES", map[string]Retry{
+				"delete_user":           {MaxFails: 5, Backoff: time.Second * 10},
+				"delete_email":          {MaxFails: 5, Backoff: time.Second * 10},
+				"send_verify_email":     {MaxFails: 3},
+				"send_email":            {MaxFails: 5},
+				"send_password_reset":   {MaxFails: 3},
+				"purge_deleted":         {MaxFails: 1},
+				"purge_tokens":          {MaxFails: 1},
+				"purge_orphaned_emails": {MaxFails: 1},
+			}),
+			Timeouts: envTimeouts("TIMEOUTS", map[string]time.Duration{
+				"delete_user":           time.Minute,
+				"delete_email":          time.Minute,
+				"send_verify_email":     time.Second * 30,
+				"send_email":            time.Second * 30,
+				"send_password_reset":   time.Second * 30,
+				"purge_deleted":         time.Minute * 10,
+				"purge_tokens":          time.Minute * 10,
+				"purge_orphaned_emails": time.Minute * 10,
+			}),
+			DeletedRetention: envDuration("DELETED_RETENTION", time.Hour*24*30),
+		},
+		Database: Database{
+			Driver: env("DATABASE_DRIVER", "sqlite3"),
+			DSN:    env("DATABASE_DSN", "./db.sqlite3"),
+		},
+		Auth: Auth{
+			Admins:                envInt64s("ADMIN_USER_IDS"),
+			RequireVerifiedEmail:  envBool("REQUIRE_VERIFIED_EMAIL"),
+			VerifyEmailExpireIn:   time.Hour * 24,
+			PasswordResetExpireIn: time.Hour,
+			ChallengeExpireIn:     5 * time.Minute,
+			InvitationExpireIn:    time.Hour * 24 * 7,
+		},
+		Mailer: Mailer{
+			Driver: env("MAILER", "log"),
+			From:   env("MAIL_FROM", "boiler <noreply@localhost>"),
+			SMTP: SMTP{
+				Addr:     env("SMTP_ADDR", "localhost:25"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+			},
+			Maildir: env("MAILDIR", "./maildir"),
 		},
-		Sqlite3: "./db.sqlite3",
+		Throttle: Throttle{
+			Driver: env("THROTTLE_DRIVER", "redis"),
+			Account: throttle.Policy{
+				Free:   5,
+				Base:   time.Second,
+				Max:    time.Minute * 15,
+				Window: time.Hour,
+			},
+			// an address can be shared by many users
+			IP: throttle.Policy{
+				Free:   20,
+				Base:   time.Second,
+				Max:    time.Minute * 15,
+				Window: time.Hour,
+			},
+		},
+	}
+}
+
+// envInt64s read a comma separated list of integers
+func envInt64s(key string) []int64 {
+	var list []int64
+	for _, raw := range strings.Split(os.Getenv(key), ",") {
+		raw = strings.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid %s; %s", key, err)
+		}
+		list = append(list, v)
+	}
+
+	return list
+}
+
+// envBool read a boolean like true or 1, false if missing
+func envBool(key string) bool {
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		return false
+	}
+
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Fatalf("invalid %s; %s", key, err)
+	}
+
+	return v
+}
+
+// envDuration read a duration like 1h30m
+func envDuration(key string, fallback time.Duration) time.Duration {
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Fatalf("invalid %s; %s", key, err)
+	}
+
+	return v
+}
+
+// envMinDuration read a duration like envDuration, it fails if shorter than min
+func envMinDuration(key string, fallback, min time.Duration) time.Duration {
+	v := envDuration(key, fallback)
+	if v < min {
+		log.Fatalf("invalid %s; must be at least %s", key, min)
+	}
+
+	return v
+}
+
+// envSchedules read a semicolon separated list of job=spec replacing the schedule of the job in defaults,
+// an empty spec removes it
+func envSchedules(key string, defaults []Schedule) []Schedule {
+	schedules := append([]Schedule(nil), defaults...)
+	for _, raw := range strings.Split(os.Getenv(key), ";") {
+		if len(strings.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			log.Fatalf("invalid %s; %q is not job=spec", key, raw)
+		}
+		schedule := Schedule{Job: strings.TrimSpace(parts[0]), Spec: strings.TrimSpace(parts[1])}
+
+		if len(schedule.Spec) != 0 {
+			if _, err := cron.Parse(schedule.Spec); err != nil {
+				log.Fatalf("invalid %s of %s; %s", key, schedule.Job, err)
+			}
+		}
+
+		kept := schedules[:0]
+		for _, s := range schedules {
+			if s.Job != schedule.Job {
+				kept = append(kept, s)
+			}
+		}
+		schedules = kept
+
+		if len(schedule.Spec) != 0 {
+			schedules = append(schedules, schedule)
+		}
 	}
+
+	return schedules
 }
 
-func readJWTPrivateKey() *rsa.PrivateKey {
-	var privateKey *rsa.PrivateKey
-	if rawPrivateKey, err := ioutil.ReadFile("jwt.pem"); err != nil {
-		log.Panic(err)
-	} else {
-		block, _ := pem.Decode(rawPrivateKey)
-		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+// envRetries read a semicolon separated list of job=max_fails or job=max_fails:backoff replacing the retry
+// policy of the job in defaults
+func envRetries(key string, defaults map[string]Retry) map[string]Retry {
+	retries := make(map[string]Retry, len(defaults))
+	for job, retry := range defaults {
+		retries[job] = retry
+	}
+
+	for _, raw := range strings.Split(os.Getenv(key), ";") {
+		if len(strings.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			log.Fatalf("invalid %s; %q is not job=max_fails:backoff", key, raw)
+		}
+		job := strings.TrimSpace(parts[0])
+
+		policy := strings.SplitN(strings.TrimSpace(parts[1]), ":", 2)
+		maxFails, err := strconv.ParseUint(policy[0], 10, 32)
+		if err != nil || maxFails == 0 {
+			log.Fatalf("invalid %s of %s; max_fails must be a positive number", key, job)
+		}
+
+		retry := Retry{MaxFails: uint(maxFails)}
+		if len(policy) == 2 {
+			if retry.Backoff, err = time.ParseDuration(policy[1]); err != nil {
+				log.Fatalf("invalid %s of %s; %s", key, job, err)
+			}
+		}
+		retries[job] = retry
+	}
+
+	return retries
+}
+
+// envTimeouts read a semicolon separated list of job=duration replacing the timeout of the job in defaults,
+// a zero duration removes it
+func envTimeouts(key string, defaults map[string]time.Duration) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(defaults))
+	for job, timeout := range defaults {
+		timeouts[job] = timeout
+	}
+
+	for _, raw := range strings.Split(os.Getenv(key), ";") {
+		if len(strings.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			log.Fatalf("invalid %s; %q is not job=duration", key, raw)
+		}
+		job := strings.TrimSpace(parts[0])
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("invalid %s of %s; %s", key, job, err)
+		}
+
+		delete(timeouts, job)
+		if timeout > 0 {
+			timeouts[job] = timeout
+		}
+	}
+
+	return timeouts
+}
+
+func env(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// readJWTKeys load the comma separated PEM files of keys, the first one signs
+func readJWTKeys(files string, overlap time.Duration) *keyset.Keyset {
+	keys := keyset.New(overlap)
+	now := keys.Now()
+
+	for i, file := range strings.Split(files, ",") {
+		raw, err := ioutil.ReadFile(strings.TrimSpace(file))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		key, err := keyset.ParsePEM(raw)
+		if err != nil {
+			log.Fatalf("invalid JWT key %s; %s", file, err)
+		}
+
+		if i != 0 {
+			key.NotAfter = now
 		}
+		keys.Add(key)
 	}
 
-	return privateKey
+	return keys
 }
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddAPIKey insert a new API key in the database, the scopes are stored space separated
+func (s *Database) AddAPIKey(ctx context.Context, tx store.Tx, key *entity.APIKey) error {
+	var expires interface{}
+	if key.Expires != nil {
+		utc := key.Expires.UTC()
+		key.Expires = &utc
+		expires = utc
+	}
+
+	now := store.Now()
+	id, err := s.insert(ctx, tx,
+		"INSERT INTO api_keys (user_id, name, prefix, hash, scopes, created, expires) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "), now, expires,
+	)
+	if err != nil {
+		return err
+	}
+
+	key.ID = id
+	key.Created = now
+	return nil
+}
+
+// FetchAPIKey find an API key by hash
+func (s *Database) FetchAPIKey(ctx context.Context, hash string, key *entity.APIKey) error {
+	rows, err := s.fetch(ctx, scanAPIKey,
+		"SELECT id, user_id, name, prefix, hash, scopes, created, expires, last_used, revoked FROM api_keys WHERE hash = ?",
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*key = *rows[0].(*entity.APIKey)
+	return nil
+}
+
+// FilterAPIKeys find the API keys of the user
+func (s *Database) FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error {
+	rows, err := s.fetch(ctx, scanAPIKey,
+		"SELECT id, user_id, name, prefix, hash, scopes, created, expires, last_used, revoked FROM api_keys WHERE user_id = ? ORDER BY id",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	*keys = make([]entity.APIKey, 0, len(rows))
+	for _, row := range rows {
+		*keys = append(*keys, *row.(*entity.APIKey))
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revoke an API key of the user, revoking it twice is not an error
+func (s *Database) RevokeAPIKey(ctx context.Context, tx store.Tx, userID, keyID int64) error {
+	return s.update(ctx, tx, "UPDATE api_keys SET revoked = ? WHERE id = ? AND user_id = ?", true, keyID, userID)
+}
+
+// TouchAPIKey set the last use of an API key
+func (s *Database) TouchAPIKey(ctx context.Context, tx store.Tx, keyID int64, at time.Time) error {
+	return s.exec(ctx, tx, "UPDATE api_keys SET last_used = ? WHERE id = ?", at.UTC(), keyID)
+}
+
+// DeleteAPIKeysByUserID remove the API keys of the user
+func (s *Database) DeleteAPIKeysByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	return s.exec(ctx, tx, "DELETE FROM api_keys WHERE user_id = ?", userID)
+}
+
+func scanAPIKey(sc func(dest ...interface{}) error) (interface{}, error) {
+	var key entity.APIKey
+	var scopes string
+	var expires, lastUsed sql.NullTime
+
+	err := sc(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.Created, &expires,
+		&lastUsed, &key.Revoked)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan api key; %w", err)
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	if expires.Valid {
+		key.Expires = &expires.Time
+	}
+	if lastUsed.Valid {
+		key.LastUsed = &lastUsed.Time
+	}
+
+	return &key, nil
+}
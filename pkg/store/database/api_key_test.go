@@ -0,0 +1,96 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAPIKey(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		insertQuery := "INSERT INTO api_keys (user_id, name, prefix, hash, scopes, created, expires) VALUES (?, ?, ?, ?, ?, ?, ?)"
+
+		// succeed
+		{
+			expires := time.Now()
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, []driver.Value{
+				4, "ci", "p", "h", "users:read emails:write", sqlmock.AnyArg(), expires.UTC(),
+			}, 7, nil)
+			mock.ExpectCommit()
+
+			key := entity.APIKey{
+				UserID: 4, Name: "ci", Prefix: "p", Hash: "h",
+				Scopes: []string{entity.ScopeUsersRead, entity.ScopeEmailsWrite}, Expires: &expires,
+			}
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.AddAPIKey(ctx, tx, &key))
+			assert.Nil(t, tx.Commit())
+			assert.Equal(t, int64(7), key.ID)
+			assert.False(t, key.Created.IsZero())
+		}
+
+		// succeed without expiration
+		{
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, []driver.Value{4, "ci", "p", "h", "users:read", sqlmock.AnyArg(), nil}, 8, nil)
+			mock.ExpectCommit()
+
+			key := entity.APIKey{UserID: 4, Name: "ci", Prefix: "p", Hash: "h", Scopes: []string{entity.ScopeUsersRead}}
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.AddAPIKey(ctx, tx, &key))
+			assert.Nil(t, tx.Commit())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		updateQuery := query(d, "UPDATE api_keys SET revoked = ? WHERE id = ? AND user_id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.RevokeAPIKey(ctx, tx, 4, 3))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if the user has no such key
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrNotFound, r.RevokeAPIKey(ctx, tx, 5, 3))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
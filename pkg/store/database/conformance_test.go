@@ -0,0 +1,43 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"boiler/pkg/store"
+	"boiler/pkg/store/database"
+	"boiler/pkg/store/migration"
+	"boiler/pkg/store/storetest"
+)
+
+var memoryDBs int64
+
+// newSqlite3 return a new and migrated sqlite3 in memory database
+func newSqlite3(t *testing.T) *sql.DB {
+	dsn := fmt.Sprintf("file:conformance%d?mode=memory&cache=shared", atomic.AddInt64(&memoryDBs, 1))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migration.New(db, database.Sqlite3.Migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func() store.Interface {
+		return database.New(newSqlite3(t))
+	})
+}
@@ -6,44 +6,166 @@ import (
 	"fmt"
 
 	"boiler/pkg/errors"
-
-	"github.com/mattn/go-sqlite3"
+	"boiler/pkg/store"
 )
 
 // Database map a database access
 type Database struct {
-	sql *sql.DB
+	sql     *sql.DB
+	dialect Dialect
+}
+
+// Tx start a new transaction, it is rolled back if ctx is done before the commit
+func (s *Database) Tx(ctx context.Context) (store.Tx, error) {
+	t, err := s.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx{t}, nil
+}
+
+// tx is a sql transaction supporting savepoints
+type tx struct {
+	*sql.Tx
+}
+
+// Savepoint create a savepoint
+func (t *tx) Savepoint(ctx context.Context, name string) error {
+	_, err := t.ExecContext(ctx, "SAVEPOINT "+name)
+	return err
+}
+
+// RollbackTo rollback to a savepoint
+func (t *tx) RollbackTo(ctx context.Context, name string) error {
+	_, err := t.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+	return err
 }
 
-// Tx start a new transaction
-func (s *Database) Tx() (*sql.Tx, error) {
-	return s.sql.Begin()
+// Release release a savepoint
+func (t *tx) Release(ctx context.Context, name string) error {
+	_, err := t.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
 }
 
-// New create a new database
+// sqlTx unwrap the *sql.Tx of a store transaction
+func sqlTx(t store.Tx) (*sql.Tx, error) {
+	if t, ok := t.(*tx); ok {
+		return t.Tx, nil
+	}
+
+	return nil, fmt.Errorf("unsupported transaction %T", t)
+}
+
+// New create a new sqlite3 database
 func New(sql *sql.DB) *Database {
+	return NewWithDialect(sql, Sqlite3)
+}
+
+// NewWithDialect create a new database speaking the given dialect
+func NewWithDialect(sql *sql.DB, dialect Dialect) *Database {
 	return &Database{
-		sql: sql,
+		sql:     sql,
+		dialect: dialect,
 	}
 }
 
-// Insert execute an insert sql statement
-func Insert(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
-	result, err := tx.ExecContext(ctx, query, args...)
+// DB return the sql database, to share it with the database queue
+func (s *Database) DB() *sql.DB {
+	return s.sql
+}
+
+// Dialect return the dialect of the database
+func (s *Database) Dialect() Dialect {
+	return s.dialect
+}
+
+// insert execute an insert sql statement written with ? placeholders
+func (s *Database) insert(ctx context.Context, tx store.Tx, query string, args ...interface{}) (int64, error) {
+	t, err := sqlTx(tx)
 	if err != nil {
-		if e, is := err.(sqlite3.Error); is && e.ExtendedCode == sqlite3.ErrConstraintUnique {
-			return 0, errors.ErrAlreadyExists
-		}
+		return 0, err
+	}
+
+	return s.dialect.Insert(ctx, t, s.dialect.Rebind(query), args...)
+}
 
-		return 0, fmt.Errorf("could not insert; %w", err)
+// delete execute a delete sql statement written with ? placeholders
+func (s *Database) delete(ctx context.Context, tx store.Tx, query string, args ...interface{}) error {
+	t, err := sqlTx(tx)
+	if err != nil {
+		return err
 	}
 
-	id, err := result.LastInsertId()
+	return Delete(ctx, t, s.dialect.Rebind(query), args...)
+}
+
+// exec execute a sql statement written with ? placeholders
+func (s *Database) exec(ctx context.Context, tx store.Tx, query string, args ...interface{}) error {
+	t, err := sqlTx(tx)
 	if err != nil {
-		return 0, fmt.Errorf("fail to retrieve last inserted ID; %w", err)
+		return err
 	}
 
-	return id, nil
+	_, err = t.ExecContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("could not execute; %w", err)
+	}
+
+	return nil
+}
+
+// update execute an update sql statement written with ? placeholders
+// it fails with ErrNotFound if no row was changed
+func (s *Database) update(ctx context.Context, tx store.Tx, query string, args ...interface{}) error {
+	t, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	result, err := t.ExecContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("could not update; %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not fetch rows affected; %w", err)
+	}
+
+	if n == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// conflict tell why a conditional update changed nothing, the count query written with ? placeholders
+// returns ErrNotFound if the row is missing and ErrUpdateConflict if it was modified
+func (s *Database) conflict(ctx context.Context, tx store.Tx, query string, args ...interface{}) error {
+	t, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	err = t.QueryRowContext(ctx, s.dialect.Rebind(query), args...).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("could not count rows; %w", err)
+	}
+
+	if n == 0 {
+		return errors.ErrNotFound
+	}
+
+	return errors.ErrUpdateConflict
+}
+
+// fetch execute a select sql statement written with ? placeholders
+func (s *Database) fetch(ctx context.Context, scan func(func(...interface{}) error) (interface{}, error),
+	query string, args ...interface{}) ([]interface{}, error) {
+
+	return Select(ctx, s.sql, scan, s.dialect.Rebind(query), args...)
 }
 
 // Delete execute a delete sql statement
@@ -0,0 +1,103 @@
+package database_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io/fs"
+	"regexp"
+	"testing"
+
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachDialect run the same expectations against every dialect
+func forEachDialect(t *testing.T, fn func(*testing.T, database.Dialect, *sql.DB, sqlmock.Sqlmock)) {
+	for _, d := range []database.Dialect{database.Sqlite3, database.Postgres} {
+		t.Run(d.Driver(), func(t *testing.T) {
+			mdb, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mdb.Close()
+
+			fn(t, d, mdb, mock)
+		})
+	}
+}
+
+// query return the query with the placeholders of the dialect, ready to be matched by sqlmock
+func query(d database.Dialect, q string) string {
+	return regexp.QuoteMeta(d.Rebind(q))
+}
+
+// expectInsert expect an insert statement as it is executed by the dialect
+func expectInsert(mock sqlmock.Sqlmock, d database.Dialect, q string, args []driver.Value, id int64, err error) {
+	if d == database.Postgres {
+		e := mock.ExpectQuery(query(d, q) + regexp.QuoteMeta(" RETURNING id")).WithArgs(args...)
+		if err != nil {
+			e.WillReturnError(err)
+			return
+		}
+
+		e.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		return
+	}
+
+	e := mock.ExpectExec(query(d, q)).WithArgs(args...)
+	if err != nil {
+		e.WillReturnError(err)
+		return
+	}
+
+	e.WillReturnResult(sqlmock.NewResult(id, 1))
+}
+
+// errUnique return the unique constraint violation error of the dialect driver
+func errUnique(d database.Dialect) error {
+	if d == database.Postgres {
+		return &pq.Error{Code: "23505"}
+	}
+
+	return sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique}
+}
+
+func TestRebind(t *testing.T) {
+	q := "SELECT id FROM users WHERE id IN (?,?) LIMIT ?"
+	assert.Equal(t, q, database.Sqlite3.Rebind(q))
+	assert.Equal(t, "SELECT id FROM users WHERE id IN ($1,$2) LIMIT $3", database.Postgres.Rebind(q))
+}
+
+func TestDialectByDriver(t *testing.T) {
+	d, err := database.DialectByDriver("postgres")
+	assert.Nil(t, err)
+	assert.Equal(t, database.Postgres, d)
+
+	d, err = database.DialectByDriver("sqlite3")
+	assert.Nil(t, err)
+	assert.Equal(t, database.Sqlite3, d)
+
+	_, err = database.DialectByDriver("mysql")
+	assert.Equal(t, `unsupported database driver "mysql"`, err.Error())
+}
+
+func TestMigrations(t *testing.T) {
+	for _, d := range []database.Dialect{database.Sqlite3, database.Postgres} {
+		files, err := sqlFiles(d)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, files)
+	}
+
+	// every dialect has the same migrations
+	sqlite3Files, _ := sqlFiles(database.Sqlite3)
+	postgresFiles, _ := sqlFiles(database.Postgres)
+	assert.Equal(t, sqlite3Files, postgresFiles)
+}
+
+func sqlFiles(d database.Dialect) ([]string, error) {
+	return fs.Glob(d.Migrations(), "*.sql")
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"boiler/pkg/errors"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+// Dialect is the SQL flavor spoken by a database driver
+type Dialect interface {
+	// Driver is the database/sql driver name
+	Driver() string
+	// Rebind replace the ? placeholders of the query by the ones of the dialect
+	Rebind(query string) string
+	// Insert execute an insert sql statement and return the inserted ID
+	Insert(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error)
+	// Migrations return the schema migrations of the dialect
+	Migrations() fs.FS
+}
+
+var (
+	// Sqlite3 is the dialect of github.com/mattn/go-sqlite3
+	Sqlite3 Dialect = sqlite3Dialect{}
+	// Postgres is the dialect of github.com/lib/pq
+	Postgres Dialect = postgresDialect{}
+)
+
+// DialectByDriver return the dialect of a database/sql driver name
+func DialectByDriver(driver string) (Dialect, error) {
+	for _, d := range []Dialect{Sqlite3, Postgres} {
+		if d.Driver() == driver {
+			return d, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported database driver %q", driver)
+}
+
+func subMigrations(dir string) fs.FS {
+	sub, err := fs.Sub(migrations, "migrations/"+dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+type sqlite3Dialect struct{}
+
+func (sqlite3Dialect) Driver() string { return "sqlite3" }
+
+func (sqlite3Dialect) Rebind(query string) string { return query }
+
+func (sqlite3Dialect) Migrations() fs.FS { return subMigrations("sqlite3") }
+
+func (sqlite3Dialect) Insert(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if e, is := err.(sqlite3.Error); is && e.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, errors.ErrAlreadyExists
+		}
+
+		return 0, fmt.Errorf("could not insert; %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("fail to retrieve last inserted ID; %w", err)
+	}
+
+	return id, nil
+}
+
+// uniqueViolation is the SQLSTATE of unique_violation
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const uniqueViolation = "23505"
+
+type postgresDialect struct{}
+
+func (postgresDialect) Driver() string { return "postgres" }
+
+func (postgresDialect) Migrations() fs.FS { return subMigrations("postgres") }
+
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func (postgresDialect) Insert(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+	if err != nil {
+		if e, is := err.(*pq.Error); is && e.Code == uniqueViolation {
+			return 0, errors.ErrAlreadyExists
+		}
+
+		return 0, fmt.Errorf("could not insert; %w", err)
+	}
+
+	return id, nil
+}
@@ -7,46 +7,126 @@ import (
 	"time"
 
 	"boiler/pkg/entity"
+	"boiler/pkg/errors"
 	"boiler/pkg/store"
 )
 
 // AddEmail insert a new emails in the database
-func (s *Database) AddEmail(ctx context.Context, tx *sql.Tx, email *entity.Email) error {
-	id, err := Insert(ctx, tx,
+func (s *Database) AddEmail(ctx context.Context, tx store.Tx, email *entity.Email) error {
+	id, err := s.insert(ctx, tx,
 		"INSERT INTO emails (user_id, address, created) VALUES (?, ?, ?)",
-		email.UserID, email.Address, time.Now(),
+		email.UserID, email.Address, store.Now(),
 	)
 	email.ID = id
 	return err
 }
 
-// DeleteEmail remove an email from the database
-func (s *Database) DeleteEmail(ctx context.Context, tx *sql.Tx, emailID int64) error {
-	return Delete(ctx, tx, "DELETE FROM emails WHERE id = ?", emailID)
+// VerifyEmail set when an email was verified, it fails with ErrUpdateConflict if it is already verified
+func (s *Database) VerifyEmail(ctx context.Context, tx store.Tx, emailID int64, at time.Time) error {
+	err := s.update(ctx, tx, "UPDATE emails SET verified_at = ? WHERE id = ? AND verified_at IS NULL", at.UTC(), emailID)
+	if err == errors.ErrNotFound {
+		return s.conflict(ctx, tx, "SELECT COUNT(*) FROM emails WHERE id = ?", emailID)
+	}
+
+	return err
 }
 
-// DeleteEmailsByUserID remove email from the database
-func (s *Database) DeleteEmailsByUserID(ctx context.Context, tx *sql.Tx, userID int64) error {
-	return Delete(ctx, tx, "DELETE FROM emails WHERE user_id = ?", userID)
+// DeleteEmail mark an email deleted in the database
+func (s *Database) DeleteEmail(ctx context.Context, tx store.Tx, emailID int64) error {
+	return s.update(ctx, tx, "UPDATE emails SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", store.Now(), emailID)
+}
+
+// DeleteEmailsByUserID mark the emails of an user deleted in the database
+func (s *Database) DeleteEmailsByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	return s.update(ctx, tx,
+		"UPDATE emails SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL", store.Now(), userID)
+}
+
+// RestoreEmail undelete an email in the database
+func (s *Database) RestoreEmail(ctx context.Context, tx store.Tx, emailID int64) error {
+	t, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	err = t.QueryRowContext(ctx, s.dialect.Rebind(
+		"SELECT COUNT(*) FROM emails WHERE deleted_at IS NULL AND id <> ? "+
+			"AND address = (SELECT address FROM emails WHERE id = ?)",
+	), emailID, emailID).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("could not count emails; %w", err)
+	}
+
+	if n != 0 {
+		return errors.ErrAlreadyExists
+	}
+
+	return s.update(ctx, tx, "UPDATE emails SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", emailID)
+}
+
+// PurgeEmails remove from the database the emails deleted before a time
+func (s *Database) PurgeEmails(ctx context.Context, tx store.Tx, before time.Time) error {
+	return s.exec(ctx, tx, "DELETE FROM emails WHERE deleted_at IS NOT NULL AND deleted_at < ?", before.UTC())
+}
+
+// PurgeOrphanedEmails remove from the database the emails of the users which do not exist
+func (s *Database) PurgeOrphanedEmails(ctx context.Context, tx store.Tx) error {
+	return s.exec(ctx, tx, "DELETE FROM emails WHERE user_id NOT IN (SELECT id FROM users)")
 }
 
 // FilterEmails find for emails
 func (s *Database) FilterEmails(ctx context.Context, filter store.FilterEmails, emails *[]entity.Email) error {
-	args := []interface{}{filter.UserID}
-	where := "user_id = ?"
+	if err := filter.CheckOrganization(); err != nil {
+		return err
+	}
+
+	seek, err := filter.Seek()
+	if err != nil {
+		return err
+	}
+
+	query := "SELECT id, user_id, address, created, verified_at, deleted_at FROM emails WHERE "
+	var args []interface{}
+
+	if !filter.IncludeDeleted {
+		query += "deleted_at IS NULL AND "
+	}
+
+	if filter.OrganizationID != 0 {
+		query += memberOf("user_id") + " AND "
+		args = []interface{}{filter.OrganizationID}
+	}
+
 	if filter.EmailID > 0 {
-		where = "id = ?"
-		args = []interface{}{filter.EmailID}
+		query += "id = ?"
+		args = append(args, filter.EmailID)
+	} else if len(filter.Address) != 0 {
+		query += "address = ?"
+		args = append(args, filter.Address)
+	} else {
+		cond, order, kargs := keyset(seek)
+		query += "user_id = ?"
+		args = append(append(args, filter.UserID), kargs...)
+		if len(cond) != 0 {
+			query += " AND " + cond
+		}
+		query += " ORDER BY " + order
+		if filter.Limit > 0 {
+			query += " LIMIT ?"
+			args = append(args, filter.Limit)
+		}
 	}
 
-	rows, err := Select(ctx, s.sql, scanEmail,
-		"SELECT id, user_id, address, created FROM emails WHERE "+where,
-		args...,
-	)
+	rows, err := s.fetch(ctx, scanEmail, query, args...)
 	if err != nil {
 		return err
 	}
 
+	if seek.Backward {
+		reverse(rows)
+	}
+
 	*emails = make([]entity.Email, 0, len(rows))
 	for _, row := range rows {
 		*emails = append(*emails, *row.(*entity.Email))
@@ -61,16 +141,28 @@ func scanEmail(sc func(dest ...interface{}) error) (interface{}, error) {
 	var userID int64
 	var address string
 	var created time.Time
+	var verifiedAt sql.NullTime
+	var deletedAt sql.NullTime
 
-	err := sc(&id, &userID, &address, &created)
+	err := sc(&id, &userID, &address, &created, &verifiedAt, &deletedAt)
 	if err != nil {
 		return nil, fmt.Errorf("could not scan email; %w", err)
 	}
 
-	return &entity.Email{
+	email := &entity.Email{
 		ID:      id,
 		UserID:  userID,
 		Address: address,
 		Created: created,
-	}, nil
+	}
+
+	if verifiedAt.Valid {
+		email.VerifiedAt = &verifiedAt.Time
+	}
+
+	if deletedAt.Valid {
+		email.DeletedAt = &deletedAt.Time
+	}
+
+	return email, nil
 }
@@ -2,8 +2,9 @@ package database_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"regexp"
 	"testing"
 	"time"
 
@@ -13,341 +14,402 @@ import (
 	"boiler/pkg/store/database"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAddEmail(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	const address = "user@example.com"
-
-	// succeed
-	{
-		userID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO emails (user_id, address, created) VALUES (?, ?, ?)"),
-		).WithArgs(userID, address, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(3, 1))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		email := entity.Email{UserID: userID, Address: address}
-		assert.Nil(t, r.AddEmail(ctx, tx, &email))
-		assert.Equal(t, 3, int(email.UserID))
-		assert.Nil(t, tx.Commit())
-	}
-
-	// fail
-	{
-		userID := int64(3)
-		address := "user@example.com"
-		myErr := fmt.Errorf("opz")
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO emails (user_id, address, created) VALUES (?, ?, ?)"),
-		).WithArgs(userID, address, sqlmock.AnyArg()).WillReturnError(myErr)
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		email := entity.Email{UserID: userID, Address: address}
-		assert.Equal(t, r.AddEmail(ctx, tx, &email).Error(), "could not insert; opz")
-		assert.Equal(t, 0, int(email.ID))
-		assert.Nil(t, tx.Commit())
-	}
-
-	// fails if duplicate
-	{
-		userID := int64(3)
-		address := "a@a.com"
-
-		myErr := sqlite3.Error{
-			ExtendedCode: sqlite3.ErrConstraintUnique,
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		insertQuery := "INSERT INTO emails (user_id, address, created) VALUES (?, ?, ?)"
+
+		const address = "user@example.com"
+
+		// succeed
+		{
+			userID := int64(3)
+
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, []driver.Value{userID, address, sqlmock.AnyArg()}, 3, nil)
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			email := entity.Email{UserID: userID, Address: address}
+			assert.Nil(t, r.AddEmail(ctx, tx, &email))
+			assert.Equal(t, 3, int(email.UserID))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fail
+		{
+			userID := int64(3)
+			address := "user@example.com"
+			myErr := fmt.Errorf("opz")
+
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, []driver.Value{userID, address, sqlmock.AnyArg()}, 0, myErr)
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			email := entity.Email{UserID: userID, Address: address}
+			assert.Equal(t, r.AddEmail(ctx, tx, &email).Error(), "could not insert; opz")
+			assert.Equal(t, 0, int(email.ID))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if duplicate
+		{
+			userID := int64(3)
+			address := "a@a.com"
+
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, []driver.Value{userID, address, sqlmock.AnyArg()}, 0, errUnique(d))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			email := entity.Email{UserID: userID, Address: address}
+			assert.Equal(t, r.AddEmail(ctx, tx, &email), errors.ErrAlreadyExists)
+			assert.Equal(t, 0, int(email.ID))
+			assert.Nil(t, tx.Commit())
+		}
+
+		if d != database.Sqlite3 {
+			return
 		}
 
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO emails (user_id, address, created) VALUES (?, ?, ?)"),
-		).WithArgs(userID, address, sqlmock.AnyArg()).WillReturnError(myErr)
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		email := entity.Email{UserID: userID, Address: address}
-		assert.Equal(t, r.AddEmail(ctx, tx, &email), errors.ErrAlreadyExists)
-		assert.Equal(t, 0, int(email.ID))
-		assert.Nil(t, tx.Commit())
-	}
-
-	// last insert failed
-	{
-		userID := int64(3)
-		address := "user@example.com"
-		myErr := fmt.Errorf("opz")
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO emails (user_id, address, created) VALUES (?, ?, ?)"),
-		).WithArgs(userID, address, sqlmock.AnyArg()).
-			WillReturnResult(sqlmock.NewResult(3, 1)).WillReturnResult(sqlmock.NewErrorResult(myErr))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		email := entity.Email{UserID: userID, Address: address}
-		assert.Equal(t, r.AddEmail(ctx, tx, &email).Error(), "fail to retrieve last inserted ID; opz")
-		assert.Equal(t, 0, int(email.ID))
-		assert.Nil(t, tx.Commit())
-	}
+		// last insert failed
+		{
+			userID := int64(3)
+			address := "user@example.com"
+			myErr := fmt.Errorf("opz")
+
+			mock.ExpectBegin()
+			mock.ExpectExec(query(d, insertQuery)).WithArgs(userID, address, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(3, 1)).WillReturnResult(sqlmock.NewErrorResult(myErr))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			email := entity.Email{UserID: userID, Address: address}
+			assert.Equal(t, r.AddEmail(ctx, tx, &email).Error(), "fail to retrieve last inserted ID; opz")
+			assert.Equal(t, 0, int(email.ID))
+			assert.Nil(t, tx.Commit())
+		}
+	})
 }
 
 func TestDeleteEmail(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		emailID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM emails WHERE id = ?"),
-		).WithArgs(emailID).WillReturnResult(sqlmock.NewResult(3, 1))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		err = r.DeleteEmail(ctx, tx, emailID)
-		assert.Nil(t, err)
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		deleteQuery := query(d, "UPDATE emails SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL")
 
-	// fails if exec fails
-	{
-		emailID := int64(3)
+		// succeed
+		{
+			emailID := int64(3)
 
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM emails WHERE id = ?"),
-		).WithArgs(emailID).WillReturnError(fmt.Errorf("opz"))
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), emailID).WillReturnResult(sqlmock.NewResult(3, 1))
+			mock.ExpectCommit()
 
-		r := database.New(mdb)
+			r := database.NewWithDialect(mdb, d)
 
-		tx, err := r.Tx()
-		assert.Nil(t, err)
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
 
-		err = r.DeleteEmail(ctx, tx, emailID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not remove; opz")
-	}
+			err = r.DeleteEmail(ctx, tx, emailID)
+			assert.Nil(t, err)
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if exec fails
+		{
+			emailID := int64(3)
 
-	// fails if rows affected fails
-	{
-		emailID := int64(3)
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), emailID).WillReturnError(fmt.Errorf("opz"))
 
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM emails WHERE id = ?"),
-		).WithArgs(emailID).
-			WillReturnResult(sqlmock.NewResult(1, 1)).
-			WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("opz")))
+			r := database.NewWithDialect(mdb, d)
 
-		mock.ExpectCommit()
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
 
-		r := database.New(mdb)
+			err = r.DeleteEmail(ctx, tx, emailID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err.Error(), "could not update; opz")
+		}
 
-		tx, err := r.Tx()
-		assert.Nil(t, err)
+		// fails if rows affected fails
+		{
+			emailID := int64(3)
 
-		err = r.DeleteEmail(ctx, tx, emailID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not fetch rows affected; opz")
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), emailID).
+				WillReturnResult(sqlmock.NewResult(1, 1)).
+				WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("opz")))
 
-	// fails if no rows affected
-	{
-		emailID := int64(3)
+			mock.ExpectCommit()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM emails WHERE id = ?"),
-		).WithArgs(emailID).
-			WillReturnResult(sqlmock.NewResult(0, 0))
+			r := database.NewWithDialect(mdb, d)
 
-		mock.ExpectCommit()
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
 
-		r := database.New(mdb)
+			err = r.DeleteEmail(ctx, tx, emailID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err.Error(), "could not fetch rows affected; opz")
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
 
-		tx, err := r.Tx()
-		assert.Nil(t, err)
+		// fails if no rows affected
+		{
+			emailID := int64(3)
 
-		err = r.DeleteEmail(ctx, tx, emailID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err, errors.ErrNotFound)
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), emailID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.DeleteEmail(ctx, tx, emailID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err, errors.ErrNotFound)
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+	})
 }
 
 func TestDeleteEmailsByUserID(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		userID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM emails WHERE user_id = ?"),
-		).WithArgs(userID).WillReturnResult(sqlmock.NewResult(3, 1))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		err = r.DeleteEmailsByUserID(ctx, tx, userID)
-		assert.Nil(t, err)
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		deleteQuery := query(d, "UPDATE emails SET deleted_at = ? WHERE user_id = ? AND deleted_at IS NULL")
 
-	// fails
-	{
-		userID := int64(3)
+		// succeed
+		{
+			userID := int64(3)
 
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM emails WHERE user_id = ?"),
-		).WithArgs(userID).WillReturnError(fmt.Errorf("opz"))
-		mock.ExpectCommit()
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), userID).WillReturnResult(sqlmock.NewResult(3, 1))
+			mock.ExpectCommit()
 
-		r := database.New(mdb)
+			r := database.NewWithDialect(mdb, d)
 
-		tx, err := r.Tx()
-		assert.Nil(t, err)
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
 
-		err = r.DeleteEmailsByUserID(ctx, tx, userID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not remove; opz")
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
+			err = r.DeleteEmailsByUserID(ctx, tx, userID)
+			assert.Nil(t, err)
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails
+		{
+			userID := int64(3)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), userID).WillReturnError(fmt.Errorf("opz"))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.DeleteEmailsByUserID(ctx, tx, userID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err.Error(), "could not update; opz")
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+	})
 }
 
 func TestFilterEmails(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		userID := int64(3)
-
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id, user_id, address, created FROM emails WHERE user_id = ?"),
-		).WithArgs(userID).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "user_id", "address", "created"}).
-				AddRow(3, userID, "user@example.com", time.Time{}),
-		)
-
-		r := database.New(mdb)
-		emails := new([]entity.Email)
-		err := r.FilterEmails(ctx, store.FilterEmails{UserID: userID}, emails)
-		assert.Nil(t, err)
-		assert.Len(t, *emails, 1)
-	}
-
-	// filter by emailID
-	{
-		emailID := int64(3)
-
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id, user_id, address, created FROM emails WHERE id = ?"),
-		).WithArgs(emailID).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "user_id", "address", "created"}).
-				AddRow(3, emailID, "user@example.com", time.Time{}),
-		)
-
-		r := database.New(mdb)
-		emails := new([]entity.Email)
-		err := r.FilterEmails(ctx, store.FilterEmails{EmailID: emailID}, emails)
-		assert.Nil(t, err)
-		assert.Len(t, *emails, 1)
-	}
-
-	// scan fail
-	{
-		userID := int64(3)
-
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id, user_id, address, created FROM emails WHERE user_id = ?"),
-		).WithArgs(userID).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "user_id", "address", "created"}).
-				AddRow("opz", userID, "user@example.com", 0),
-		)
-
-		r := database.New(mdb)
-		emails := new([]entity.Email)
-		err := r.FilterEmails(ctx, store.FilterEmails{UserID: userID}, emails)
-		assert.NotNil(t, err)
-		assert.Contains(t, err.Error(), "invalid syntax")
-		assert.Len(t, *emails, 0)
-	}
-
-	// fail
-	{
-		userID := int64(3)
-		myErr := fmt.Errorf("opz")
-
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id, user_id, address, created FROM emails WHERE user_id = ?"),
-		).WithArgs(userID).WillReturnError(myErr)
-
-		r := database.New(mdb)
-		emails := new([]entity.Email)
-		err := r.FilterEmails(ctx, store.FilterEmails{UserID: userID}, emails)
-		assert.Equal(t, err.Error(), "could not fetch rows; opz")
-		assert.Len(t, *emails, 0)
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		byUserQuery := query(d, "SELECT id, user_id, address, created, verified_at, deleted_at FROM emails"+
+			" WHERE deleted_at IS NULL AND user_id = ? ORDER BY id ASC")
+
+		// succeed
+		{
+			userID := int64(3)
+
+			mock.ExpectQuery(byUserQuery).WithArgs(userID).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "address", "created", "verified_at", "deleted_at"}).
+					AddRow(3, userID, "user@example.com", time.Time{}, nil, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			err := r.FilterEmails(ctx, store.FilterEmails{UserID: userID, AllOrganizations: true}, emails)
+			assert.Nil(t, err)
+			assert.Len(t, *emails, 1)
+		}
+
+		// before cursor
+		{
+			userID := int64(3)
+			created := time.Unix(10, 0).UTC()
+			before := store.NewCursor(store.SortByCreated, 7, created).String()
+
+			mock.ExpectQuery(query(d, "SELECT id, user_id, address, created, verified_at, deleted_at FROM emails"+
+				" WHERE deleted_at IS NULL AND user_id = ? AND (created < ? OR (created = ? AND id < ?))"+
+				" ORDER BY created DESC, id DESC LIMIT ?"),
+			).WithArgs(userID, created, created, 7, 2).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "address", "created", "verified_at", "deleted_at"}).
+					AddRow(6, userID, "b@example.com", time.Time{}, nil, nil).
+					AddRow(5, userID, "a@example.com", time.Time{}, nil, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			filter := store.FilterEmails{
+				UserID: userID, Before: before, SortBy: store.SortByCreated, Limit: 2, AllOrganizations: true,
+			}
+			err := r.FilterEmails(ctx, filter, emails)
+			assert.Nil(t, err)
+			if assert.Len(t, *emails, 2) {
+				assert.Equal(t, int64(5), (*emails)[0].ID)
+				assert.Equal(t, int64(6), (*emails)[1].ID)
+			}
+		}
+
+		// fails if cursor is invalid
+		{
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			err := r.FilterEmails(ctx, store.FilterEmails{UserID: 3, After: "opz", AllOrganizations: true}, emails)
+			assert.Equal(t, errors.ErrInvalidCursor, err)
+		}
+
+		// filter by emailID
+		{
+			emailID := int64(3)
+
+			mock.ExpectQuery(
+				query(d, "SELECT id, user_id, address, created, verified_at, deleted_at FROM emails"+
+					" WHERE deleted_at IS NULL AND id = ?"),
+			).WithArgs(emailID).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "address", "created", "verified_at", "deleted_at"}).
+					AddRow(3, emailID, "user@example.com", time.Time{}, nil, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			err := r.FilterEmails(ctx, store.FilterEmails{EmailID: emailID, AllOrganizations: true}, emails)
+			assert.Nil(t, err)
+			assert.Len(t, *emails, 1)
+		}
+
+		// filter by address
+		{
+			verified := time.Unix(20, 0).UTC()
+
+			mock.ExpectQuery(
+				query(d, "SELECT id, user_id, address, created, verified_at, deleted_at FROM emails"+
+					" WHERE deleted_at IS NULL AND address = ?"),
+			).WithArgs("user@example.com").WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "address", "created", "verified_at", "deleted_at"}).
+					AddRow(3, 4, "user@example.com", time.Time{}, verified, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			err := r.FilterEmails(ctx, store.FilterEmails{Address: "user@example.com", AllOrganizations: true}, emails)
+			assert.Nil(t, err)
+			if assert.Len(t, *emails, 1) {
+				assert.Equal(t, verified, *(*emails)[0].VerifiedAt)
+			}
+		}
+
+		// scan fail
+		{
+			userID := int64(3)
+
+			mock.ExpectQuery(byUserQuery).WithArgs(userID).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "address", "created", "verified_at", "deleted_at"}).
+					AddRow("opz", userID, "user@example.com", 0, nil, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			err := r.FilterEmails(ctx, store.FilterEmails{UserID: userID, AllOrganizations: true}, emails)
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), "invalid syntax")
+			assert.Len(t, *emails, 0)
+		}
+
+		// fail
+		{
+			userID := int64(3)
+			myErr := fmt.Errorf("opz")
+
+			mock.ExpectQuery(byUserQuery).WithArgs(userID).WillReturnError(myErr)
+
+			r := database.NewWithDialect(mdb, d)
+			emails := new([]entity.Email)
+			err := r.FilterEmails(ctx, store.FilterEmails{UserID: userID, AllOrganizations: true}, emails)
+			assert.Equal(t, err.Error(), "could not fetch rows; opz")
+			assert.Len(t, *emails, 0)
+		}
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		at := time.Unix(10, 0).UTC()
+		updateQuery := query(d, "UPDATE emails SET verified_at = ? WHERE id = ? AND verified_at IS NULL")
+		countQuery := query(d, "SELECT COUNT(*) FROM emails WHERE id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(at, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.VerifyEmail(ctx, tx, 3, at))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if already verified
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(at, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrUpdateConflict, r.VerifyEmail(ctx, tx, 3, at))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
 }
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddJob insert a new job in the database
+func (s *Database) AddJob(ctx context.Context, tx store.Tx, job *entity.Job) error {
+	now := store.Now()
+	err := s.update(ctx, tx,
+		"INSERT INTO jobs (id, name, user_id, status, error, created, updated) VALUES (?, ?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT DO NOTHING",
+		job.ID, job.Name, job.UserID, job.Status, job.Error, now, now,
+	)
+	if err == errors.ErrNotFound {
+		return errors.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+
+	job.Created = now
+	job.Updated = now
+	return nil
+}
+
+// FetchJob find a job by ID
+func (s *Database) FetchJob(ctx context.Context, jobID string, job *entity.Job) error {
+	rows, err := s.fetch(ctx, scanJob,
+		"SELECT id, name, user_id, status, error, created, updated FROM jobs WHERE id = ?",
+		jobID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*job = *rows[0].(*entity.Job)
+	return nil
+}
+
+// SetJobStatus set the status and the error of a job
+func (s *Database) SetJobStatus(ctx context.Context, tx store.Tx, jobID, status, errText string) error {
+	return s.update(ctx, tx,
+		"UPDATE jobs SET status = ?, error = ?, updated = ? WHERE id = ?",
+		status, errText, store.Now(), jobID,
+	)
+}
+
+func scanJob(sc func(dest ...interface{}) error) (interface{}, error) {
+	var job entity.Job
+
+	err := sc(&job.ID, &job.Name, &job.UserID, &job.Status, &job.Error, &job.Created, &job.Updated)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan job; %w", err)
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,142 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddJob(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		insertQuery := query(d, "INSERT INTO jobs (id, name, user_id, status, error, created, updated) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(insertQuery).
+				WithArgs("j1", "delete_user", 4, entity.JobQueued, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			job := entity.Job{ID: "j1", Name: "delete_user", UserID: 4, Status: entity.JobQueued}
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.AddJob(ctx, tx, &job))
+			assert.Nil(t, tx.Commit())
+			assert.False(t, job.Created.IsZero())
+			assert.Equal(t, job.Created, job.Updated)
+		}
+
+		// fails if the ID is taken
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(insertQuery).
+				WithArgs("j1", "delete_user", 4, entity.JobQueued, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			err = r.AddJob(ctx, tx, &entity.Job{ID: "j1", Name: "delete_user", UserID: 4, Status: entity.JobQueued})
+			assert.Equal(t, errors.ErrAlreadyExists, err)
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchJob(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		selectQuery := query(d, "SELECT id, name, user_id, status, error, created, updated FROM jobs WHERE id = ?")
+		columns := []string{"id", "name", "user_id", "status", "error", "created", "updated"}
+
+		// succeed
+		{
+			now := time.Now()
+			mock.ExpectQuery(selectQuery).WithArgs("j1").
+				WillReturnRows(sqlmock.NewRows(columns).AddRow("j1", "delete_user", 4, entity.JobFailed, "opz", now, now))
+
+			var job entity.Job
+			assert.Nil(t, r.FetchJob(ctx, "j1", &job))
+			assert.Equal(t, entity.Job{
+				ID: "j1", Name: "delete_user", UserID: 4, Status: entity.JobFailed, Error: "opz", Created: now, Updated: now,
+			}, job)
+		}
+
+		// fails if not found
+		{
+			mock.ExpectQuery(selectQuery).WithArgs("j2").WillReturnRows(sqlmock.NewRows(columns))
+
+			var job entity.Job
+			assert.Equal(t, errors.ErrNotFound, r.FetchJob(ctx, "j2", &job))
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSetJobStatus(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		updateQuery := query(d, "UPDATE jobs SET status = ?, error = ?, updated = ? WHERE id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(entity.JobRunning, "", sqlmock.AnyArg(), "j1").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.SetJobStatus(ctx, tx, "j1", entity.JobRunning, ""))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if not found
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(entity.JobFailed, "opz", sqlmock.AnyArg(), "j2").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrNotFound, r.SetJobStatus(ctx, tx, "j2", entity.JobFailed, "opz"))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		// fails if database fails
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(entity.JobSucceeded, "", sqlmock.AnyArg(), "j1").
+				WillReturnError(fmt.Errorf("opz"))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			err = r.SetJobStatus(ctx, tx, "j1", entity.JobSucceeded, "")
+			assert.Equal(t, "could not update; opz", err.Error())
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS emails (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  address TEXT UNIQUE NOT NULL,
+  created TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS roles (
+  name TEXT PRIMARY KEY,
+  permissions TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id BIGINT NOT NULL,
+  role TEXT NOT NULL REFERENCES roles (name),
+  PRIMARY KEY (user_id, role)
+);
+INSERT INTO roles (name, permissions) VALUES
+  ('admin', '*'),
+  ('support', 'list_users read_user update_user delete_user list_emails read_email delete_email'),
+  ('member', '');
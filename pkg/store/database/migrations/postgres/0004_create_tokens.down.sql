@@ -0,0 +1,2 @@
+DROP TABLE denied_tokens;
+DROP TABLE refresh_tokens;
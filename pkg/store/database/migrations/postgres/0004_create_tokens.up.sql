@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  family TEXT NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  created TIMESTAMPTZ NOT NULL,
+  expires TIMESTAMPTZ NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE,
+  revoked BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_family ON refresh_tokens (family);
+CREATE TABLE IF NOT EXISTS denied_tokens (
+  jti TEXT PRIMARY KEY,
+  expires TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE emails ADD COLUMN verified_at TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  created TIMESTAMPTZ NOT NULL,
+  expires TIMESTAMPTZ NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id ON refresh_tokens (user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS totp (
+  user_id BIGINT PRIMARY KEY,
+  secret TEXT NOT NULL,
+  confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+  counter BIGINT NOT NULL DEFAULT 0,
+  created TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  hash TEXT NOT NULL,
+  created TIMESTAMPTZ NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id ON recovery_codes (user_id);
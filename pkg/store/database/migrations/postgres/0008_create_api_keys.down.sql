@@ -0,0 +1,2 @@
+DROP INDEX api_keys_user_id;
+DROP TABLE api_keys;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  name TEXT NOT NULL,
+  prefix TEXT UNIQUE NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  scopes TEXT NOT NULL,
+  created TIMESTAMPTZ NOT NULL,
+  expires TIMESTAMPTZ,
+  last_used TIMESTAMPTZ,
+  revoked BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE refresh_tokens DROP COLUMN organization_id;
+DROP INDEX members_user_id;
+DROP TABLE members;
+DROP TABLE organizations;
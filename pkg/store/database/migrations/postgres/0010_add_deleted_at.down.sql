@@ -0,0 +1,8 @@
+DELETE FROM emails WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX emails_address;
+ALTER TABLE emails ADD CONSTRAINT emails_address_key UNIQUE (address);
+DROP INDEX emails_deleted_at;
+DROP INDEX users_deleted_at;
+ALTER TABLE emails DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
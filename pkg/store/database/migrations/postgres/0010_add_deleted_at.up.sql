@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+ALTER TABLE emails ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS users_deleted_at ON users (deleted_at);
+CREATE INDEX IF NOT EXISTS emails_deleted_at ON emails (deleted_at);
+ALTER TABLE emails DROP CONSTRAINT emails_address_key;
+CREATE UNIQUE INDEX IF NOT EXISTS emails_address ON emails (address) WHERE deleted_at IS NULL;
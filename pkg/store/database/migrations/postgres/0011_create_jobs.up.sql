@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS jobs (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  user_id BIGINT NOT NULL,
+  status TEXT NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  created TIMESTAMPTZ NOT NULL,
+  updated TIMESTAMPTZ NOT NULL
+);
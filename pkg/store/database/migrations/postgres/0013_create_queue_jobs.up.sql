@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS queue_jobs (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  args TEXT NOT NULL,
+  fails BIGINT NOT NULL DEFAULT 0,
+  last_err TEXT NOT NULL DEFAULT '',
+  enqueued_at TIMESTAMPTZ NOT NULL,
+  failed_at TIMESTAMPTZ,
+  visible_at TIMESTAMPTZ NOT NULL,
+  dead_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS queue_jobs_name_visible_at ON queue_jobs (name, visible_at);
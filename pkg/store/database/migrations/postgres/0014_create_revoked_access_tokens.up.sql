@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+  user_id BIGINT PRIMARY KEY,
+  issued_before TIMESTAMPTZ NOT NULL,
+  expires TIMESTAMPTZ NOT NULL
+);
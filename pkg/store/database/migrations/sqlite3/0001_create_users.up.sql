@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  password TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS emails (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  address TEXT UNIQUE NOT NULL,
+  created DATETIME NOT NULL
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  family TEXT NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE,
+  revoked BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_family ON refresh_tokens (family);
+CREATE TABLE IF NOT EXISTS denied_tokens (
+  jti TEXT PRIMARY KEY,
+  expires DATETIME NOT NULL
+);
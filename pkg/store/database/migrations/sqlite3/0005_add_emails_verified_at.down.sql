@@ -0,0 +1,9 @@
+CREATE TABLE emails_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  address TEXT UNIQUE NOT NULL,
+  created DATETIME NOT NULL
+);
+INSERT INTO emails_old (id, user_id, address, created) SELECT id, user_id, address, created FROM emails;
+DROP TABLE emails;
+ALTER TABLE emails_old RENAME TO emails;
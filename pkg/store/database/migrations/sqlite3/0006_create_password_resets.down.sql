@@ -0,0 +1,2 @@
+DROP INDEX refresh_tokens_user_id;
+DROP TABLE password_resets;
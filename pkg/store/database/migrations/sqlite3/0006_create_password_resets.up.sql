@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id ON refresh_tokens (user_id);
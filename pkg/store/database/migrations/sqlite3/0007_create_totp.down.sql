@@ -0,0 +1,3 @@
+DROP INDEX recovery_codes_user_id;
+DROP TABLE recovery_codes;
+DROP TABLE totp;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS totp (
+  user_id INTEGER PRIMARY KEY,
+  secret TEXT NOT NULL,
+  confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+  counter INTEGER NOT NULL DEFAULT 0,
+  created DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  hash TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id ON recovery_codes (user_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  prefix TEXT UNIQUE NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  scopes TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME,
+  last_used DATETIME,
+  revoked BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS api_keys_user_id ON api_keys (user_id);
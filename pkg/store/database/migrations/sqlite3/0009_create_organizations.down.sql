@@ -0,0 +1,18 @@
+CREATE TABLE refresh_tokens_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  family TEXT NOT NULL,
+  hash TEXT UNIQUE NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME NOT NULL,
+  used BOOLEAN NOT NULL DEFAULT FALSE,
+  revoked BOOLEAN NOT NULL DEFAULT FALSE
+);
+INSERT INTO refresh_tokens_old (id, user_id, family, hash, created, expires, used, revoked)
+  SELECT id, user_id, family, hash, created, expires, used, revoked FROM refresh_tokens;
+DROP TABLE refresh_tokens;
+ALTER TABLE refresh_tokens_old RENAME TO refresh_tokens;
+CREATE INDEX IF NOT EXISTS refresh_tokens_family ON refresh_tokens (family);
+DROP INDEX members_user_id;
+DROP TABLE members;
+DROP TABLE organizations;
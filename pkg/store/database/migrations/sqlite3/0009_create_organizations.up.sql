@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS organizations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  created DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS members (
+  organization_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  role TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (organization_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS members_user_id ON members (user_id);
+ALTER TABLE refresh_tokens ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,26 @@
+DELETE FROM emails WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX emails_deleted_at;
+DROP INDEX emails_address;
+DROP INDEX users_deleted_at;
+CREATE TABLE users_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  password TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME NOT NULL
+);
+INSERT INTO users_old (id, name, password, created, updated) SELECT id, name, password, created, updated FROM users;
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+CREATE TABLE emails_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  address TEXT UNIQUE NOT NULL,
+  created DATETIME NOT NULL,
+  verified_at DATETIME
+);
+INSERT INTO emails_old (id, user_id, address, created, verified_at)
+  SELECT id, user_id, address, created, verified_at FROM emails;
+DROP TABLE emails;
+ALTER TABLE emails_old RENAME TO emails;
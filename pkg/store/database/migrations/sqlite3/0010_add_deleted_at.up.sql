@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+CREATE INDEX IF NOT EXISTS users_deleted_at ON users (deleted_at);
+CREATE TABLE emails_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  address TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  verified_at DATETIME,
+  deleted_at DATETIME
+);
+INSERT INTO emails_new (id, user_id, address, created, verified_at)
+  SELECT id, user_id, address, created, verified_at FROM emails;
+DROP TABLE emails;
+ALTER TABLE emails_new RENAME TO emails;
+CREATE UNIQUE INDEX IF NOT EXISTS emails_address ON emails (address) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS emails_deleted_at ON emails (deleted_at);
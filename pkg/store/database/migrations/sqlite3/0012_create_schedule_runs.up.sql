@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS schedule_runs (
+  job TEXT PRIMARY KEY,
+  last_run DATETIME NOT NULL,
+  status TEXT NOT NULL
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS queue_jobs (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  args TEXT NOT NULL,
+  fails INTEGER NOT NULL DEFAULT 0,
+  last_err TEXT NOT NULL DEFAULT '',
+  enqueued_at DATETIME NOT NULL,
+  failed_at DATETIME,
+  visible_at DATETIME NOT NULL,
+  dead_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS queue_jobs_name_visible_at ON queue_jobs (name, visible_at);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+  user_id INTEGER PRIMARY KEY,
+  issued_before DATETIME NOT NULL,
+  expires DATETIME NOT NULL
+);
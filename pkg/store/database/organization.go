@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddOrganization insert a new organization in the database
+func (s *Database) AddOrganization(ctx context.Context, tx store.Tx, org *entity.Organization) error {
+	now := store.Now()
+	id, err := s.insert(ctx, tx, "INSERT INTO organizations (name, created) VALUES (?, ?)", org.Name, now)
+	if err != nil {
+		return err
+	}
+
+	org.ID = id
+	org.Created = now
+	return nil
+}
+
+// FetchOrganization find an organization by ID
+func (s *Database) FetchOrganization(ctx context.Context, orgID int64, org *entity.Organization) error {
+	rows, err := s.fetch(ctx, scanOrganization, "SELECT id, name, created FROM organizations WHERE id = ?", orgID)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*org = *rows[0].(*entity.Organization)
+	return nil
+}
+
+// FilterOrganizations find the organizations of the user
+func (s *Database) FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error {
+	rows, err := s.fetch(ctx, scanOrganization,
+		"SELECT o.id, o.name, o.created FROM organizations o INNER JOIN members m ON(m.organization_id = o.id) "+
+			"WHERE m.user_id = ? ORDER BY o.id",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	*orgs = make([]entity.Organization, 0, len(rows))
+	for _, row := range rows {
+		*orgs = append(*orgs, *row.(*entity.Organization))
+	}
+
+	return nil
+}
+
+// AddMember insert a new member of an organization in the database
+func (s *Database) AddMember(ctx context.Context, tx store.Tx, member *entity.Member) error {
+	now := store.Now()
+	err := s.update(ctx, tx,
+		"INSERT INTO members (organization_id, user_id, role, created) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		member.OrganizationID, member.UserID, member.Role, now,
+	)
+	if err == errors.ErrNotFound {
+		return errors.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+
+	member.Created = now
+	return nil
+}
+
+// FetchMember find the membership of the user in an organization
+func (s *Database) FetchMember(ctx context.Context, orgID, userID int64, member *entity.Member) error {
+	rows, err := s.fetch(ctx, scanMember,
+		"SELECT organization_id, user_id, role, created FROM members WHERE organization_id = ? AND user_id = ?",
+		orgID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*member = *rows[0].(*entity.Member)
+	return nil
+}
+
+// FilterMembers find the members of an organization
+func (s *Database) FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error {
+	rows, err := s.fetch(ctx, scanMember,
+		"SELECT organization_id, user_id, role, created FROM members WHERE organization_id = ? ORDER BY user_id",
+		orgID,
+	)
+	if err != nil {
+		return err
+	}
+
+	*members = make([]entity.Member, 0, len(rows))
+	for _, row := range rows {
+		*members = append(*members, *row.(*entity.Member))
+	}
+
+	return nil
+}
+
+// DeleteMembersByUserID remove the user from its organizations
+func (s *Database) DeleteMembersByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	return s.exec(ctx, tx, "DELETE FROM members WHERE user_id = ?", userID)
+}
+
+func scanOrganization(sc func(dest ...interface{}) error) (interface{}, error) {
+	var org entity.Organization
+	err := sc(&org.ID, &org.Name, &org.Created)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan organization; %w", err)
+	}
+
+	return &org, nil
+}
+
+func scanMember(sc func(dest ...interface{}) error) (interface{}, error) {
+	var member entity.Member
+	err := sc(&member.OrganizationID, &member.UserID, &member.Role, &member.Created)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan member; %w", err)
+	}
+
+	return &member, nil
+}
@@ -0,0 +1,103 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddMember(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		insertQuery := query(d, "INSERT INTO members (organization_id, user_id, role, created) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(insertQuery).WithArgs(2, 4, "owner", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			member := entity.Member{OrganizationID: 2, UserID: 4, Role: entity.OrgRoleOwner}
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.AddMember(ctx, tx, &member))
+			assert.Nil(t, tx.Commit())
+			assert.False(t, member.Created.IsZero())
+		}
+
+		// fails if already a member
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(insertQuery).WithArgs(2, 4, "owner", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrAlreadyExists, r.AddMember(ctx, tx, &entity.Member{OrganizationID: 2, UserID: 4, Role: entity.OrgRoleOwner}))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		// fails if database fails
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(insertQuery).WithArgs(2, 4, "owner", sqlmock.AnyArg()).WillReturnError(fmt.Errorf("opz"))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			err = r.AddMember(ctx, tx, &entity.Member{OrganizationID: 2, UserID: 4, Role: entity.OrgRoleOwner})
+			assert.Equal(t, "could not update; opz", err.Error())
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFilterUsersIDByOrganization(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+
+		// succeed
+		{
+			mock.ExpectQuery(query(d, "SELECT id FROM users WHERE deleted_at IS NULL AND "+
+				"id IN (SELECT user_id FROM members WHERE organization_id = ?) "+
+				"ORDER BY id ASC LIMIT ?")).
+				WithArgs(2, 10).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(5))
+
+			var IDs []int64
+			assert.Nil(t, r.FilterUsersID(ctx, store.FilterUsers{OrganizationID: 2, Limit: 10}, &IDs))
+			assert.Equal(t, []int64{4, 5}, IDs)
+		}
+
+		// succeed after a cursor
+		{
+			mock.ExpectQuery(query(d, "SELECT id FROM users WHERE deleted_at IS NULL AND "+
+				"id IN (SELECT user_id FROM members WHERE organization_id = ?) "+
+				"AND id > ? ORDER BY id ASC LIMIT ?")).
+				WithArgs(2, 4, 10).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+			after := store.NewCursor(store.SortByID, 4, time.Time{}).String()
+			var IDs []int64
+			assert.Nil(t, r.FilterUsersID(ctx, store.FilterUsers{OrganizationID: 2, After: after, Limit: 10}, &IDs))
+			assert.Equal(t, []int64{5}, IDs)
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"boiler/pkg/store"
+)
+
+// keyset return the condition, order and args selecting the rows after, or before, the seek cursor
+// cond is empty for the first page, a backward seek is ordered descending and must be reversed
+func keyset(seek store.Seek) (cond, order string, args []interface{}) {
+	op, dir := ">", "ASC"
+	if seek.Backward {
+		op, dir = "<", "DESC"
+	}
+
+	c := seek.Cursor
+	if seek.SortBy == store.SortByCreated {
+		if c != nil {
+			cond = "(created " + op + " ? OR (created = ? AND id " + op + " ?))"
+			args = []interface{}{c.Created, c.Created, c.ID}
+		}
+
+		return cond, "created " + dir + ", id " + dir, args
+	}
+
+	if c != nil {
+		cond = "id " + op + " ?"
+		args = []interface{}{c.ID}
+	}
+
+	return cond, "id " + dir, args
+}
+
+// reverse reverse rows in place
+func reverse(rows []interface{}) {
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+}
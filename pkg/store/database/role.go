@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// FetchRoles find roles by name
+func (s *Database) FetchRoles(ctx context.Context, names []string, roles *[]entity.Role) error {
+	query := "SELECT name, permissions FROM roles"
+	var args []interface{}
+
+	if len(names) != 0 {
+		query += " WHERE name IN (?" + strings.Repeat(", ?", len(names)-1) + ")"
+		for _, name := range names {
+			args = append(args, name)
+		}
+	}
+
+	query += " ORDER BY name"
+
+	rows, err := s.fetch(ctx, scanRole, query, args...)
+	if err != nil {
+		return err
+	}
+
+	*roles = make([]entity.Role, 0, len(rows))
+	for _, row := range rows {
+		*roles = append(*roles, *row.(*entity.Role))
+	}
+
+	return nil
+}
+
+// SetUserRoles replace the roles of an user
+func (s *Database) SetUserRoles(ctx context.Context, tx store.Tx, userID int64, roles []string) error {
+	roles = uniqueRoles(roles)
+
+	err := s.exec(ctx, tx, "DELETE FROM user_roles WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	if len(roles) == 0 {
+		return nil
+	}
+
+	t, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	args := make([]interface{}, 0, len(roles))
+	for _, role := range roles {
+		args = append(args, role)
+	}
+
+	var n int
+	err = t.QueryRowContext(ctx, s.dialect.Rebind(
+		"SELECT COUNT(*) FROM roles WHERE name IN (?"+strings.Repeat(", ?", len(roles)-1)+")",
+	), args...).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("could not count roles; %w", err)
+	}
+
+	if n != len(roles) {
+		return errors.ErrInvalidRole
+	}
+
+	for _, role := range roles {
+		err = s.exec(ctx, tx, "INSERT INTO user_roles (user_id, role) VALUES (?, ?)", userID, role)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FetchUserRoles find the roles of an user
+func (s *Database) FetchUserRoles(ctx context.Context, userID int64, roles *[]string) error {
+	rows, err := s.fetch(ctx, scanString, "SELECT role FROM user_roles WHERE user_id = ? ORDER BY role", userID)
+	if err != nil {
+		return err
+	}
+
+	*roles = make([]string, 0, len(rows))
+	for _, row := range rows {
+		*roles = append(*roles, row.(string))
+	}
+
+	return nil
+}
+
+// uniqueRoles return the sorted roles without duplicates
+func uniqueRoles(roles []string) []string {
+	set := make(map[string]struct{}, len(roles))
+	unique := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if _, ok := set[role]; ok {
+			continue
+		}
+		set[role] = struct{}{}
+		unique = append(unique, role)
+	}
+
+	sort.Strings(unique)
+	return unique
+}
+
+func scanRole(sc func(dest ...interface{}) error) (interface{}, error) {
+	var name string
+	var permissions string
+
+	err := sc(&name, &permissions)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan role; %w", err)
+	}
+
+	return &entity.Role{
+		Name:        name,
+		Permissions: strings.Fields(permissions),
+	}, nil
+}
+
+func scanString(sc func(dest ...interface{}) error) (interface{}, error) {
+	var s string
+
+	err := sc(&s)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan string; %w", err)
+	}
+
+	return s, nil
+}
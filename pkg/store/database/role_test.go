@@ -0,0 +1,118 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchRoles(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+
+		// succeed
+		{
+			mock.ExpectQuery(query(d, "SELECT name, permissions FROM roles WHERE name IN (?, ?) ORDER BY name")).
+				WithArgs("admin", "support").
+				WillReturnRows(sqlmock.NewRows([]string{"name", "permissions"}).
+					AddRow("admin", "*").
+					AddRow("support", "read_user list_users"))
+
+			var roles []entity.Role
+			assert.Nil(t, r.FetchRoles(ctx, []string{"admin", "support"}, &roles))
+			assert.Equal(t, []entity.Role{
+				{Name: "admin", Permissions: []string{"*"}},
+				{Name: "support", Permissions: []string{"read_user", "list_users"}},
+			}, roles)
+		}
+
+		// fails if query fails
+		{
+			mock.ExpectQuery(query(d, "SELECT name, permissions FROM roles ORDER BY name")).
+				WillReturnError(fmt.Errorf("opz"))
+
+			var roles []entity.Role
+			assert.Equal(t, "could not fetch rows; opz", r.FetchRoles(ctx, nil, &roles).Error())
+		}
+	})
+}
+
+func TestSetUserRoles(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+
+		deleteQuery := query(d, "DELETE FROM user_roles WHERE user_id = ?")
+		countQuery := query(d, "SELECT COUNT(*) FROM roles WHERE name IN (?, ?)")
+		insertQuery := query(d, "INSERT INTO user_roles (user_id, role) VALUES (?, ?)")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(countQuery).WithArgs("member", "support").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			mock.ExpectExec(insertQuery).WithArgs(4, "member").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(insertQuery).WithArgs(4, "support").WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.SetUserRoles(ctx, tx, 4, []string{"support", "member", "support"}))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if a role does not exist
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery(countQuery).WithArgs("member", "unknown").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrInvalidRole, r.SetUserRoles(ctx, tx, 4, []string{"unknown", "member"}))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		// fails if delete fails
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(4).WillReturnError(fmt.Errorf("opz"))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, "could not execute; opz", r.SetUserRoles(ctx, tx, 4, nil).Error())
+			assert.Nil(t, tx.Rollback())
+		}
+	})
+}
+
+func TestFetchUserRoles(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+
+		// succeed
+		{
+			mock.ExpectQuery(query(d, "SELECT role FROM user_roles WHERE user_id = ? ORDER BY role")).
+				WithArgs(4).
+				WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("admin").AddRow("member"))
+
+			var roles []string
+			assert.Nil(t, r.FetchUserRoles(ctx, 4, &roles))
+			assert.Equal(t, []string{"admin", "member"}, roles)
+		}
+	})
+}
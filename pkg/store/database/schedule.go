@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store"
+)
+
+// SetScheduleRun insert or replace the last run of a scheduled job in the database
+func (s *Database) SetScheduleRun(ctx context.Context, tx store.Tx, job string, at time.Time, status string) error {
+	return s.exec(ctx, tx,
+		"INSERT INTO schedule_runs (job, last_run, status) VALUES (?, ?, ?) "+
+			"ON CONFLICT (job) DO UPDATE SET last_run = excluded.last_run, status = excluded.status",
+		job, at.UTC(), status,
+	)
+}
+
+// FetchScheduleRuns retrieve the last runs of the scheduled jobs from the database
+func (s *Database) FetchScheduleRuns(ctx context.Context, schedules *[]entity.Schedule) error {
+	rows, err := s.fetch(ctx, scanScheduleRun, "SELECT job, last_run, status FROM schedule_runs ORDER BY job")
+	if err != nil {
+		return err
+	}
+
+	*schedules = make([]entity.Schedule, 0, len(rows))
+	for _, row := range rows {
+		*schedules = append(*schedules, *row.(*entity.Schedule))
+	}
+
+	return nil
+}
+
+func scanScheduleRun(sc func(dest ...interface{}) error) (interface{}, error) {
+	var schedule entity.Schedule
+	var lastRun time.Time
+
+	err := sc(&schedule.Job, &lastRun, &schedule.LastStatus)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan schedule run; %w", err)
+	}
+
+	schedule.LastRun = &lastRun
+	return &schedule, nil
+}
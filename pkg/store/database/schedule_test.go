@@ -0,0 +1,84 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetScheduleRun(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		upsertQuery := query(d, "INSERT INTO schedule_runs (job, last_run, status) VALUES (?, ?, ?) "+
+			"ON CONFLICT (job) DO UPDATE SET last_run = excluded.last_run, status = excluded.status")
+
+		// succeed
+		{
+			at := time.Now()
+			mock.ExpectBegin()
+			mock.ExpectExec(upsertQuery).WithArgs("purge_tokens", at.UTC(), entity.JobSucceeded).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.SetScheduleRun(ctx, tx, "purge_tokens", at, entity.JobSucceeded))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if database fails
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(upsertQuery).WillReturnError(fmt.Errorf("opz"))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			err = r.SetScheduleRun(ctx, tx, "purge_tokens", time.Now(), entity.JobFailed)
+			assert.Equal(t, "could not execute; opz", err.Error())
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFetchScheduleRuns(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		selectQuery := query(d, "SELECT job, last_run, status FROM schedule_runs ORDER BY job")
+
+		// succeed
+		{
+			at := time.Now()
+			mock.ExpectQuery(selectQuery).WillReturnRows(
+				sqlmock.NewRows([]string{"job", "last_run", "status"}).AddRow("purge_tokens", at, entity.JobFailed),
+			)
+
+			var schedules []entity.Schedule
+			assert.Nil(t, r.FetchScheduleRuns(ctx, &schedules))
+			assert.Equal(t, []entity.Schedule{{Job: "purge_tokens", LastRun: &at, LastStatus: entity.JobFailed}}, schedules)
+		}
+
+		// fails if database fails
+		{
+			mock.ExpectQuery(selectQuery).WillReturnError(fmt.Errorf("opz"))
+
+			var schedules []entity.Schedule
+			err := r.FetchScheduleRuns(ctx, &schedules)
+			assert.Equal(t, "could not fetch rows; opz", err.Error())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
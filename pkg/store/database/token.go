@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddRefreshToken insert a new refresh token in the database
+func (s *Database) AddRefreshToken(ctx context.Context, tx store.Tx, token *entity.RefreshToken) error {
+	now := store.Now()
+	id, err := s.insert(ctx, tx,
+		"INSERT INTO refresh_tokens (user_id, family, hash, created, expires, organization_id) VALUES (?, ?, ?, ?, ?, ?)",
+		token.UserID, token.Family, token.Hash, now, token.Expires.UTC(), token.OrganizationID,
+	)
+	if err != nil {
+		return err
+	}
+
+	token.ID = id
+	token.Created = now
+	return nil
+}
+
+// FetchRefreshToken find a refresh token by hash
+func (s *Database) FetchRefreshToken(ctx context.Context, hash string, token *entity.RefreshToken) error {
+	rows, err := s.fetch(ctx, scanRefreshToken,
+		"SELECT id, user_id, family, hash, created, expires, used, revoked, organization_id "+
+			"FROM refresh_tokens WHERE hash = ?",
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*token = *rows[0].(*entity.RefreshToken)
+	return nil
+}
+
+// UseRefreshToken mark a refresh token as used
+func (s *Database) UseRefreshToken(ctx context.Context, tx store.Tx, tokenID int64) error {
+	err := s.update(ctx, tx, "UPDATE refresh_tokens SET used = ? WHERE id = ? AND used = ?", true, tokenID, false)
+	if err == errors.ErrNotFound {
+		return s.conflict(ctx, tx, "SELECT COUNT(*) FROM refresh_tokens WHERE id = ?", tokenID)
+	}
+
+	return err
+}
+
+// RevokeRefreshFamily revoke all the refresh tokens of a family
+func (s *Database) RevokeRefreshFamily(ctx context.Context, tx store.Tx, family string) error {
+	return s.exec(ctx, tx, "UPDATE refresh_tokens SET revoked = ? WHERE family = ?", true, family)
+}
+
+// RevokeUserRefreshTokens revoke all the refresh tokens of the user
+func (s *Database) RevokeUserRefreshTokens(ctx context.Context, tx store.Tx, userID int64) error {
+	return s.exec(ctx, tx, "UPDATE refresh_tokens SET revoked = ? WHERE user_id = ?", true, userID)
+}
+
+// DenyToken add an access token to the deny list
+func (s *Database) DenyToken(ctx context.Context, tx store.Tx, jti string, expires time.Time) error {
+	return s.exec(ctx, tx,
+		"INSERT INTO denied_tokens (jti, expires) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		jti, expires.UTC(),
+	)
+}
+
+// IsTokenDenied tells if an access token is in the deny list
+func (s *Database) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	rows, err := s.fetch(ctx, scanInt, "SELECT COUNT(*) FROM denied_tokens WHERE jti = ?", jti)
+	if err != nil {
+		return false, err
+	}
+
+	return len(rows) == 1 && rows[0].(int64) > 0, nil
+}
+
+// RevokeAccessTokens insert or replace the revocation of the access tokens of the user
+func (s *Database) RevokeAccessTokens(ctx context.Context, tx store.Tx, userID int64, before, expires time.Time) error {
+	return s.exec(ctx, tx,
+		"INSERT INTO revoked_access_tokens (user_id, issued_before, expires) VALUES (?, ?, ?) "+
+			"ON CONFLICT (user_id) DO UPDATE SET issued_before = excluded.issued_before, expires = excluded.expires",
+		userID, before.UTC(), expires.UTC(),
+	)
+}
+
+// IsTokenRevoked tells if an access token of the user issued at a time is revoked
+func (s *Database) IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	rows, err := s.fetch(ctx, scanInt,
+		"SELECT COUNT(*) FROM revoked_access_tokens WHERE user_id = ? AND issued_before > ?",
+		userID, issuedAt.UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return len(rows) == 1 && rows[0].(int64) > 0, nil
+}
+
+// PurgeExpiredTokens remove from the database the refresh tokens, the denied and revoked tokens and the password
+// resets expired before a time
+func (s *Database) PurgeExpiredTokens(ctx context.Context, tx store.Tx, before time.Time) error {
+	for _, table := range []string{"refresh_tokens", "denied_tokens", "revoked_access_tokens", "password_resets"} {
+		err := s.exec(ctx, tx, "DELETE FROM "+table+" WHERE expires < ?", before.UTC())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddPasswordReset insert a new password reset in the database
+func (s *Database) AddPasswordReset(ctx context.Context, tx store.Tx, reset *entity.PasswordReset) error {
+	now := store.Now()
+	id, err := s.insert(ctx, tx,
+		"INSERT INTO password_resets (user_id, hash, created, expires) VALUES (?, ?, ?, ?)",
+		reset.UserID, reset.Hash, now, reset.Expires.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	reset.ID = id
+	reset.Created = now
+	return nil
+}
+
+// FetchPasswordReset find a password reset by hash
+func (s *Database) FetchPasswordReset(ctx context.Context, hash string, reset *entity.PasswordReset) error {
+	rows, err := s.fetch(ctx, scanPasswordReset,
+		"SELECT id, user_id, hash, created, expires, used FROM password_resets WHERE hash = ?",
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*reset = *rows[0].(*entity.PasswordReset)
+	return nil
+}
+
+// UsePasswordReset mark a password reset as used
+func (s *Database) UsePasswordReset(ctx context.Context, tx store.Tx, resetID int64) error {
+	err := s.update(ctx, tx, "UPDATE password_resets SET used = ? WHERE id = ? AND used = ?", true, resetID, false)
+	if err == errors.ErrNotFound {
+		return s.conflict(ctx, tx, "SELECT COUNT(*) FROM password_resets WHERE id = ?", resetID)
+	}
+
+	return err
+}
+
+func scanRefreshToken(sc func(dest ...interface{}) error) (interface{}, error) {
+	var token entity.RefreshToken
+
+	err := sc(&token.ID, &token.UserID, &token.Family, &token.Hash, &token.Created, &token.Expires,
+		&token.Used, &token.Revoked, &token.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan refresh token; %w", err)
+	}
+
+	return &token, nil
+}
+
+func scanPasswordReset(sc func(dest ...interface{}) error) (interface{}, error) {
+	var reset entity.PasswordReset
+
+	err := sc(&reset.ID, &reset.UserID, &reset.Hash, &reset.Created, &reset.Expires, &reset.Used)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan password reset; %w", err)
+	}
+
+	return &reset, nil
+}
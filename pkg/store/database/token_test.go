@@ -0,0 +1,222 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddRefreshToken(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		insertQuery := "INSERT INTO refresh_tokens (user_id, family, hash, created, expires, organization_id) " +
+			"VALUES (?, ?, ?, ?, ?, ?)"
+		args := []driver.Value{4, "f", "h", sqlmock.AnyArg(), sqlmock.AnyArg(), 2}
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, args, 7, nil)
+			mock.ExpectCommit()
+
+			token := entity.RefreshToken{UserID: 4, Family: "f", Hash: "h", Expires: time.Now(), OrganizationID: 2}
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.AddRefreshToken(ctx, tx, &token))
+			assert.Nil(t, tx.Commit())
+			assert.Equal(t, int64(7), token.ID)
+			assert.False(t, token.Created.IsZero())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUseRefreshToken(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		updateQuery := query(d, "UPDATE refresh_tokens SET used = ? WHERE id = ? AND used = ?")
+		countQuery := query(d, "SELECT COUNT(*) FROM refresh_tokens WHERE id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, false).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.UseRefreshToken(ctx, tx, 3))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if already used
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, false).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrUpdateConflict, r.UseRefreshToken(ctx, tx, 3))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		// fails if not found
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, false).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrNotFound, r.UseRefreshToken(ctx, tx, 3))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIsTokenDenied(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+
+		// succeed
+		{
+			mock.ExpectQuery(query(d, "SELECT COUNT(*) FROM denied_tokens WHERE jti = ?")).WithArgs("jti").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+			denied, err := r.IsTokenDenied(ctx, "jti")
+			assert.Nil(t, err)
+			assert.True(t, denied)
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIsTokenRevoked(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		issued := time.Now()
+
+		// succeed
+		{
+			mock.ExpectQuery(query(d, "SELECT COUNT(*) FROM revoked_access_tokens WHERE user_id = ? AND issued_before > ?")).
+				WithArgs(4, issued.UTC()).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+			revoked, err := r.IsTokenRevoked(ctx, 4, issued)
+			assert.Nil(t, err)
+			assert.True(t, revoked)
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestAddPasswordReset(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		insertQuery := "INSERT INTO password_resets (user_id, hash, created, expires) VALUES (?, ?, ?, ?)"
+		args := []driver.Value{4, "h", sqlmock.AnyArg(), sqlmock.AnyArg()}
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery, args, 7, nil)
+			mock.ExpectCommit()
+
+			reset := entity.PasswordReset{UserID: 4, Hash: "h", Expires: time.Now()}
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.AddPasswordReset(ctx, tx, &reset))
+			assert.Nil(t, tx.Commit())
+			assert.Equal(t, int64(7), reset.ID)
+			assert.False(t, reset.Created.IsZero())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUsePasswordReset(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		updateQuery := query(d, "UPDATE password_resets SET used = ? WHERE id = ? AND used = ?")
+		countQuery := query(d, "SELECT COUNT(*) FROM password_resets WHERE id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, false).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.UsePasswordReset(ctx, tx, 3))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if already used
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 3, false).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrUpdateConflict, r.UsePasswordReset(ctx, tx, 3))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		before := time.Now()
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			tables := []string{"refresh_tokens", "denied_tokens", "revoked_access_tokens", "password_resets"}
+			for _, table := range tables {
+				mock.ExpectExec(query(d, "DELETE FROM "+table+" WHERE expires < ?")).WithArgs(before.UTC()).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			}
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.PurgeExpiredTokens(ctx, tx, before))
+			assert.Nil(t, tx.Commit())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// SetTOTP replace the unconfirmed TOTP of the user
+func (s *Database) SetTOTP(ctx context.Context, tx store.Tx, totp *entity.TOTP) error {
+	err := s.exec(ctx, tx, "DELETE FROM totp WHERE user_id = ? AND confirmed = ?", totp.UserID, false)
+	if err != nil {
+		return err
+	}
+
+	// nothing is inserted if the confirmed TOTP is still there
+	now := store.Now()
+	err = s.update(ctx, tx,
+		"INSERT INTO totp (user_id, secret, confirmed, counter, created) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		totp.UserID, totp.Secret, false, 0, now,
+	)
+	if err == errors.ErrNotFound {
+		return errors.ErrUpdateConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	totp.Confirmed = false
+	totp.Counter = 0
+	totp.Created = now
+	return nil
+}
+
+// FetchTOTP find the TOTP of the user
+func (s *Database) FetchTOTP(ctx context.Context, userID int64, totp *entity.TOTP) error {
+	rows, err := s.fetch(ctx, scanTOTP,
+		"SELECT user_id, secret, confirmed, counter, created FROM totp WHERE user_id = ?",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.ErrNotFound
+	}
+
+	*totp = *rows[0].(*entity.TOTP)
+	return nil
+}
+
+// ConfirmTOTP confirm the TOTP of the user
+func (s *Database) ConfirmTOTP(ctx context.Context, tx store.Tx, userID, counter int64) error {
+	err := s.update(ctx, tx,
+		"UPDATE totp SET confirmed = ?, counter = ? WHERE user_id = ? AND confirmed = ?",
+		true, counter, userID, false,
+	)
+	if err == errors.ErrNotFound {
+		return s.conflict(ctx, tx, "SELECT COUNT(*) FROM totp WHERE user_id = ?", userID)
+	}
+
+	return err
+}
+
+// UseTOTP record the step of a used code
+func (s *Database) UseTOTP(ctx context.Context, tx store.Tx, userID, counter int64) error {
+	err := s.update(ctx, tx,
+		"UPDATE totp SET counter = ? WHERE user_id = ? AND counter < ?",
+		counter, userID, counter,
+	)
+	if err == errors.ErrNotFound {
+		return s.conflict(ctx, tx, "SELECT COUNT(*) FROM totp WHERE user_id = ?", userID)
+	}
+
+	return err
+}
+
+// DeleteTOTP remove the TOTP and the recovery codes of the user
+func (s *Database) DeleteTOTP(ctx context.Context, tx store.Tx, userID int64) error {
+	err := s.exec(ctx, tx, "DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	return s.exec(ctx, tx, "DELETE FROM totp WHERE user_id = ?", userID)
+}
+
+// SetRecoveryCodes replace the recovery codes of the user
+func (s *Database) SetRecoveryCodes(ctx context.Context, tx store.Tx, userID int64, hashes []string) error {
+	err := s.exec(ctx, tx, "DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	now := store.Now()
+	for _, hash := range hashes {
+		_, err = s.insert(ctx, tx,
+			"INSERT INTO recovery_codes (user_id, hash, created) VALUES (?, ?, ?)",
+			userID, hash, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode mark the recovery code of the user as used
+func (s *Database) UseRecoveryCode(ctx context.Context, tx store.Tx, userID int64, hash string) error {
+	return s.update(ctx, tx,
+		"UPDATE recovery_codes SET used = ? WHERE user_id = ? AND hash = ? AND used = ?",
+		true, userID, hash, false,
+	)
+}
+
+func scanTOTP(sc func(dest ...interface{}) error) (interface{}, error) {
+	var totp entity.TOTP
+
+	err := sc(&totp.UserID, &totp.Secret, &totp.Confirmed, &totp.Counter, &totp.Created)
+	if err != nil {
+		return nil, fmt.Errorf("could not scan totp; %w", err)
+	}
+
+	return &totp, nil
+}
@@ -0,0 +1,124 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTOTP(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		deleteQuery := query(d, "DELETE FROM totp WHERE user_id = ? AND confirmed = ?")
+		insertQuery := query(d, "INSERT INTO totp (user_id, secret, confirmed, counter, created) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(1, false).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(insertQuery).WithArgs(1, "s", false, 0, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			totp := entity.TOTP{UserID: 1, Secret: "s"}
+			assert.Nil(t, r.SetTOTP(ctx, tx, &totp))
+			assert.False(t, totp.Created.IsZero())
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if a confirmed TOTP exists
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(1, false).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(insertQuery).WithArgs(1, "s", false, 0, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrUpdateConflict, r.SetTOTP(ctx, tx, &entity.TOTP{UserID: 1, Secret: "s"}))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUseTOTP(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		updateQuery := query(d, "UPDATE totp SET counter = ? WHERE user_id = ? AND counter < ?")
+		countQuery := query(d, "SELECT COUNT(*) FROM totp WHERE user_id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(5, 1, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.UseTOTP(ctx, tx, 1, 5))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if the step was used
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(5, 1, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrUpdateConflict, r.UseTOTP(ctx, tx, 1, 5))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		r := database.NewWithDialect(mdb, d)
+		updateQuery := query(d, "UPDATE recovery_codes SET used = ? WHERE user_id = ? AND hash = ? AND used = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 1, "h", false).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Nil(t, r.UseRecoveryCode(ctx, tx, 1, "h"))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fails if there is no unused code
+		{
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WithArgs(true, 1, "h", false).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, errors.ErrNotFound, r.UseRecoveryCode(ctx, tx, 1, "h"))
+			assert.Nil(t, tx.Rollback())
+		}
+
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
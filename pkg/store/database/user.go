@@ -8,13 +8,14 @@ import (
 	"time"
 
 	"boiler/pkg/entity"
+	"boiler/pkg/errors"
 	"boiler/pkg/store"
 )
 
 // AddUser create a new user in the database
-func (s *Database) AddUser(ctx context.Context, tx *sql.Tx, user *entity.User) error {
-	now := time.Now()
-	id, err := Insert(
+func (s *Database) AddUser(ctx context.Context, tx store.Tx, user *entity.User) error {
+	now := store.Now()
+	id, err := s.insert(
 		ctx, tx,
 		"INSERT INTO users (name, password, created, updated) VALUES (?, ?, ?, ?)",
 		user.Name, user.Password, now, now,
@@ -23,13 +24,86 @@ func (s *Database) AddUser(ctx context.Context, tx *sql.Tx, user *entity.User) e
 	return err
 }
 
-// DeleteUser remove an user from the database
-func (s *Database) DeleteUser(ctx context.Context, tx *sql.Tx, userID int64) error {
-	return Delete(ctx, tx, "DELETE FROM users WHERE id = ?", userID)
+// UpdateUser update the profile of an user in the database
+func (s *Database) UpdateUser(ctx context.Context, tx store.Tx, user *entity.User) error {
+	now := store.Touch(user.Updated)
+	err := s.update(ctx, tx,
+		"UPDATE users SET name = ?, updated = ? WHERE id = ? AND updated = ?",
+		user.Name, now, user.ID, user.Updated.UTC(),
+	)
+	if err == errors.ErrNotFound {
+		return s.conflict(ctx, tx, "SELECT COUNT(*) FROM users WHERE id = ?", user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	user.Updated = now
+	return nil
+}
+
+// UpdateUserPassword replace the password of an user in the database
+func (s *Database) UpdateUserPassword(ctx context.Context, tx store.Tx, userID int64, password string) error {
+	t, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	var updated time.Time
+	err = t.QueryRowContext(ctx, s.dialect.Rebind("SELECT updated FROM users WHERE id = ?"), userID).Scan(&updated)
+	if err == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch user; %w", err)
+	}
+
+	return s.update(ctx, tx,
+		"UPDATE users SET password = ?, updated = ? WHERE id = ?",
+		password, store.Touch(updated), userID,
+	)
+}
+
+// DeleteUser mark an user deleted in the database
+func (s *Database) DeleteUser(ctx context.Context, tx store.Tx, userID int64) error {
+	return s.update(ctx, tx, "UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", store.Now(), userID)
+}
+
+// RestoreUser undelete an user and the emails deleted since it was
+func (s *Database) RestoreUser(ctx context.Context, tx store.Tx, userID int64) error {
+	err := s.exec(ctx, tx,
+		"UPDATE emails SET deleted_at = NULL WHERE user_id = ? AND deleted_at >= "+
+			"(SELECT deleted_at FROM users WHERE id = ?) "+
+			"AND address NOT IN (SELECT address FROM emails WHERE deleted_at IS NULL)",
+		userID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.update(ctx, tx, "UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", userID)
+}
+
+// PurgeUser remove an user and its emails from the database
+func (s *Database) PurgeUser(ctx context.Context, tx store.Tx, userID int64) error {
+	err := s.exec(ctx, tx, "DELETE FROM emails WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	return s.delete(ctx, tx, "DELETE FROM users WHERE id = ?", userID)
 }
 
 // FilterUsersID retrieve usersID from the database for a given filter
 func (s *Database) FilterUsersID(ctx context.Context, filter store.FilterUsers, IDs *[]int64) error {
+	if err := filter.CheckOrganization(); err != nil {
+		return err
+	}
+
+	seek, err := filter.Seek()
+	if err != nil {
+		return err
+	}
 
 	var args []interface{}
 	var query string
@@ -37,16 +111,45 @@ func (s *Database) FilterUsersID(ctx context.Context, filter store.FilterUsers,
 	if len(filter.Email) != 0 {
 		query = "SELECT u.id FROM users u INNER JOIN emails e ON(e.user_id = u.id) WHERE e.address = ?"
 		args = append(args, filter.Email)
+		if !filter.IncludeDeleted {
+			query += " AND u.deleted_at IS NULL AND e.deleted_at IS NULL"
+		}
+		if filter.OrganizationID != 0 {
+			query += " AND " + memberOf("u.id")
+			args = append(args, filter.OrganizationID)
+		}
 	} else {
-		query = "SELECT id FROM users LIMIT ?"
+		cond, order, kargs := keyset(seek)
+		var conds []string
+		if !filter.IncludeDeleted {
+			conds = append(conds, "deleted_at IS NULL")
+		}
+		if filter.OrganizationID != 0 {
+			conds = append(conds, memberOf("id"))
+			args = append(args, filter.OrganizationID)
+		}
+		if len(cond) != 0 {
+			conds = append(conds, cond)
+			args = append(args, kargs...)
+		}
+
+		query = "SELECT id FROM users"
+		if len(conds) != 0 {
+			query += " WHERE " + strings.Join(conds, " AND ")
+		}
+		query += " ORDER BY " + order + " LIMIT ?"
 		args = append(args, filter.Limit)
 	}
 
-	rows, err := Select(ctx, s.sql, scanInt, query, args...)
+	rows, err := s.fetch(ctx, scanInt, query, args...)
 	if err != nil {
 		return err
 	}
 
+	if seek.Backward {
+		reverse(rows)
+	}
+
 	*IDs = make([]int64, 0, len(rows))
 	for _, row := range rows {
 		if row != nil {
@@ -57,22 +160,43 @@ func (s *Database) FilterUsersID(ctx context.Context, filter store.FilterUsers,
 	return nil
 }
 
+// FilterDeletedUsersID retrieve from the database the IDs of users deleted before a time
+func (s *Database) FilterDeletedUsersID(ctx context.Context, before time.Time, limit uint, IDs *[]int64) error {
+	rows, err := s.fetch(ctx, scanInt,
+		"SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id LIMIT ?",
+		before.UTC(), limit,
+	)
+	if err != nil {
+		return err
+	}
+
+	*IDs = make([]int64, 0, len(rows))
+	for _, row := range rows {
+		*IDs = append(*IDs, row.(int64))
+	}
+
+	return nil
+}
+
 // FetchUsers retrieve users from the database
-func (s *Database) FetchUsers(ctx context.Context, IDs []int64, users *[]entity.User) error {
+func (s *Database) FetchUsers(ctx context.Context, IDs []int64, includeDeleted bool, users *[]entity.User) error {
 	if len(IDs) == 0 {
 		return nil
 	}
 
 	query := fmt.Sprintf(
-		"SELECT id, name, password, created, updated "+
+		"SELECT id, name, password, created, updated, deleted_at "+
 			"FROM users WHERE id IN (%s)",
 		strings.Repeat("?,", len(IDs))[0:len(IDs)*2-1])
+	if !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
 
 	args := make([]interface{}, 0, len(IDs))
 	for _, ID := range IDs {
 		args = append(args, ID)
 	}
-	rows, err := Select(ctx, s.sql, scanUser, query, args...)
+	rows, err := s.fetch(ctx, scanUser, query, args...)
 	if err != nil {
 		return err
 	}
@@ -85,23 +209,35 @@ func (s *Database) FetchUsers(ctx context.Context, IDs []int64, users *[]entity.
 	return nil
 }
 
+// memberOf is the condition of a column being the ID of a member of the organization given as argument
+func memberOf(column string) string {
+	return column + " IN (SELECT user_id FROM members WHERE organization_id = ?)"
+}
+
 func scanUser(sc func(dest ...interface{}) error) (interface{}, error) {
 	var id int64
 	var name string
 	var password string
 	var created time.Time
 	var updated time.Time
+	var deletedAt sql.NullTime
 
-	err := sc(&id, &name, &password, &created, &updated)
+	err := sc(&id, &name, &password, &created, &updated, &deletedAt)
 	if err != nil {
 		return nil, fmt.Errorf("could not scan user; %w", err)
 	}
 
-	return &entity.User{
+	user := &entity.User{
 		ID:       id,
 		Name:     name,
 		Password: password,
 		Created:  created,
 		Updated:  updated,
-	}, nil
+	}
+
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+
+	return user, nil
 }
@@ -2,8 +2,9 @@ package database_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"regexp"
 	"testing"
 	"time"
 
@@ -17,412 +18,546 @@ import (
 )
 
 func TestAddUser(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		name := "user"
-		password := "pass"
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO users (name, password, created, updated) VALUES (?, ?, ?, ?)"),
-		).WithArgs(name, password, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(3, 1))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		user := entity.User{Name: name, Password: password}
-		assert.Nil(t, r.AddUser(ctx, tx, &user))
-		assert.Equal(t, 3, int(user.ID))
-		assert.Nil(t, tx.Commit())
-	}
-
-	// fail
-	{
-		name := "user"
-		password := "pass"
-
-		myErr := fmt.Errorf("err")
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO users (name, password, created, updated) VALUES (?, ?, ?, ?)"),
-		).WithArgs(name, password, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnError(myErr)
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		user := entity.User{Name: name, Password: password}
-		assert.Equal(t, r.AddUser(ctx, tx, &user).Error(), "could not insert; err")
-		assert.Equal(t, 0, int(user.ID))
-		assert.Nil(t, tx.Commit())
-	}
-
-	// last inserted failed
-	{
-		name := "user"
-		password := "pass"
-
-		myErr := fmt.Errorf("err")
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("INSERT INTO users (name, password, created, updated) VALUES (?, ?, ?, ?)"),
-		).WithArgs(name, password, sqlmock.AnyArg(), sqlmock.AnyArg()).
-			WillReturnResult(sqlmock.NewResult(3, 1)).WillReturnResult(sqlmock.NewErrorResult(myErr))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		user := entity.User{Name: name, Password: password}
-		assert.Equal(t, r.AddUser(ctx, tx, &user).Error(), "fail to retrieve last inserted ID; err")
-		assert.Equal(t, 0, int(user.ID))
-		assert.Nil(t, tx.Commit())
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		insertQuery := "INSERT INTO users (name, password, created, updated) VALUES (?, ?, ?, ?)"
+
+		// succeed
+		{
+			name := "user"
+			password := "pass"
+
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery,
+				[]driver.Value{name, password, sqlmock.AnyArg(), sqlmock.AnyArg()}, 3, nil)
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			user := entity.User{Name: name, Password: password}
+			assert.Nil(t, r.AddUser(ctx, tx, &user))
+			assert.Equal(t, 3, int(user.ID))
+			assert.Nil(t, tx.Commit())
+		}
+
+		// fail
+		{
+			name := "user"
+			password := "pass"
+
+			myErr := fmt.Errorf("err")
+			mock.ExpectBegin()
+			expectInsert(mock, d, insertQuery,
+				[]driver.Value{name, password, sqlmock.AnyArg(), sqlmock.AnyArg()}, 0, myErr)
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			user := entity.User{Name: name, Password: password}
+			assert.Equal(t, r.AddUser(ctx, tx, &user).Error(), "could not insert; err")
+			assert.Equal(t, 0, int(user.ID))
+			assert.Nil(t, tx.Commit())
+		}
+
+		if d != database.Sqlite3 {
+			return
+		}
+
+		// last inserted failed
+		{
+			name := "user"
+			password := "pass"
+
+			myErr := fmt.Errorf("err")
+			mock.ExpectBegin()
+			mock.ExpectExec(query(d, insertQuery)).
+				WithArgs(name, password, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(3, 1)).WillReturnResult(sqlmock.NewErrorResult(myErr))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			user := entity.User{Name: name, Password: password}
+			assert.Equal(t, r.AddUser(ctx, tx, &user).Error(), "fail to retrieve last inserted ID; err")
+			assert.Equal(t, 0, int(user.ID))
+			assert.Nil(t, tx.Commit())
+		}
+	})
 }
 
 func TestDeleteUser(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		userID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM users WHERE id = ?"),
-		).WithArgs(userID).WillReturnResult(sqlmock.NewResult(3, 1))
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		err = r.DeleteUser(ctx, tx, userID)
-		assert.Nil(t, err)
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
-
-	// fails if exec fails
-	{
-		userID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM users WHERE id = ?"),
-		).WithArgs(userID).WillReturnError(fmt.Errorf("opz"))
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		err = r.DeleteUser(ctx, tx, userID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not remove; opz")
-	}
-
-	// fails if rows affected fails
-	{
-		userID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM users WHERE id = ?"),
-		).WithArgs(userID).
-			WillReturnResult(sqlmock.NewResult(1, 1)).
-			WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("opz")))
-
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		err = r.DeleteUser(ctx, tx, userID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err.Error(), "could not fetch rows affected; opz")
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
-
-	// fails if no rows affected
-	{
-		userID := int64(3)
-
-		mock.ExpectBegin()
-		mock.ExpectExec(
-			regexp.QuoteMeta("DELETE FROM users WHERE id = ?"),
-		).WithArgs(userID).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-
-		mock.ExpectCommit()
-
-		r := database.New(mdb)
-
-		tx, err := r.Tx()
-		assert.Nil(t, err)
-
-		err = r.DeleteUser(ctx, tx, userID)
-		assert.NotNil(t, err)
-		assert.Equal(t, err, errors.ErrNotFound)
-		assert.Nil(t, tx.Commit())
-		assert.Nil(t, mock.ExpectationsWereMet())
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		deleteQuery := query(d, "UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL")
+
+		// succeed
+		{
+			userID := int64(3)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), userID).WillReturnResult(sqlmock.NewResult(3, 1))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.DeleteUser(ctx, tx, userID)
+			assert.Nil(t, err)
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if exec fails
+		{
+			userID := int64(3)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), userID).WillReturnError(fmt.Errorf("opz"))
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.DeleteUser(ctx, tx, userID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err.Error(), "could not update; opz")
+		}
+
+		// fails if rows affected fails
+		{
+			userID := int64(3)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), userID).
+				WillReturnResult(sqlmock.NewResult(1, 1)).
+				WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("opz")))
+
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.DeleteUser(ctx, tx, userID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err.Error(), "could not fetch rows affected; opz")
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if no rows affected
+		{
+			userID := int64(3)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(deleteQuery).WithArgs(sqlmock.AnyArg(), userID).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.DeleteUser(ctx, tx, userID)
+			assert.NotNil(t, err)
+			assert.Equal(t, err, errors.ErrNotFound)
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+	})
+}
+
+func TestUpdateUser(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		updateQuery := query(d, "UPDATE users SET name = ?, updated = ? WHERE id = ? AND updated = ?")
+		countQuery := query(d, "SELECT COUNT(*) FROM users WHERE id = ?")
+		updated := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+		// succeed
+		{
+			user := entity.User{ID: 3, Name: "name", Updated: updated}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).
+				WithArgs(user.Name, sqlmock.AnyArg(), user.ID, updated).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.UpdateUser(ctx, tx, &user)
+			assert.Nil(t, err)
+			assert.True(t, user.Updated.After(updated))
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if modified
+		{
+			user := entity.User{ID: 3, Name: "name", Updated: updated}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(user.ID).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.UpdateUser(ctx, tx, &user)
+			assert.Equal(t, errors.ErrUpdateConflict, err)
+			assert.Equal(t, updated, user.Updated)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if not found
+		{
+			user := entity.User{ID: 3, Name: "name", Updated: updated}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(countQuery).WithArgs(user.ID).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.UpdateUser(ctx, tx, &user)
+			assert.Equal(t, errors.ErrNotFound, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if exec fails
+		{
+			user := entity.User{ID: 3, Name: "name", Updated: updated}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("opz"))
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			err = r.UpdateUser(ctx, tx, &user)
+			assert.Equal(t, "could not update; opz", err.Error())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+	})
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		selectQuery := query(d, "SELECT updated FROM users WHERE id = ?")
+		updateQuery := query(d, "UPDATE users SET password = ?, updated = ? WHERE id = ?")
+
+		// succeed
+		{
+			mock.ExpectBegin()
+			mock.ExpectQuery(selectQuery).WithArgs(3).
+				WillReturnRows(sqlmock.NewRows([]string{"updated"}).AddRow(time.Now()))
+			mock.ExpectExec(updateQuery).WithArgs("hash", sqlmock.AnyArg(), 3).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			assert.Nil(t, r.UpdateUserPassword(ctx, tx, 3, "hash"))
+			assert.Nil(t, tx.Commit())
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+
+		// fails if not found
+		{
+			mock.ExpectBegin()
+			mock.ExpectQuery(selectQuery).WithArgs(3).
+				WillReturnRows(sqlmock.NewRows([]string{"updated"}))
+
+			r := database.NewWithDialect(mdb, d)
+
+			tx, err := r.Tx(ctx)
+			assert.Nil(t, err)
+
+			assert.Equal(t, errors.ErrNotFound, r.UpdateUserPassword(ctx, tx, 3, "hash"))
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+	})
 }
 
 func TestFilterUsersID(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		var limit uint = 3
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id FROM users LIMIT ?"),
-		).WithArgs(limit).WillReturnRows(
-			sqlmock.NewRows([]string{"id"}).
-				AddRow(3),
-		)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Limit: limit}, IDs)
-		assert.Nil(t, err)
-		assert.Len(t, *IDs, 1)
-		assert.Equal(t, 3, int((*IDs)[0]))
-	}
-
-	// fail scan
-	{
-		var limit uint = 2
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id FROM users LIMIT ?"),
-		).WithArgs(limit).WillReturnRows(
-			sqlmock.NewRows([]string{"id"}).
-				AddRow("err"),
-		)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Limit: limit}, IDs)
-		assert.NotNil(t, err)
-		assert.Contains(t, err.Error(), "invalid syntax")
-		assert.Len(t, *IDs, 0)
-	}
-
-	// fail
-	{
-		var limit uint = 4
-		myErr := fmt.Errorf("err")
-
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT id FROM users LIMIT ?"),
-		).WithArgs(limit).WillReturnError(myErr)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Limit: limit}, IDs)
-		assert.Equal(t, "could not fetch rows; err", err.Error())
-		assert.Len(t, *IDs, 0)
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		selectQuery := query(d, "SELECT id FROM users WHERE deleted_at IS NULL ORDER BY id ASC LIMIT ?")
+
+		// succeed
+		{
+			var limit uint = 3
+			mock.ExpectQuery(selectQuery).WithArgs(limit).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}).
+					AddRow(3),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Limit: limit, AllOrganizations: true}, IDs)
+			assert.Nil(t, err)
+			assert.Len(t, *IDs, 1)
+			assert.Equal(t, 3, int((*IDs)[0]))
+		}
+
+		// after cursor
+		{
+			after := store.NewCursor(store.SortByID, 3, time.Time{}).String()
+			mock.ExpectQuery(query(d, "SELECT id FROM users WHERE deleted_at IS NULL AND id > ? ORDER BY id ASC LIMIT ?")).
+				WithArgs(3, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(5))
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{After: after, Limit: 2, AllOrganizations: true}, IDs)
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{4, 5}, *IDs)
+		}
+
+		// before cursor
+		{
+			before := store.NewCursor(store.SortByID, 3, time.Time{}).String()
+			mock.ExpectQuery(query(d, "SELECT id FROM users WHERE deleted_at IS NULL AND id < ? ORDER BY id DESC LIMIT ?")).
+				WithArgs(3, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Before: before, Limit: 2, AllOrganizations: true}, IDs)
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{1, 2}, *IDs)
+		}
+
+		// sort by created
+		{
+			mock.ExpectQuery(query(d, "SELECT id FROM users WHERE deleted_at IS NULL ORDER BY created ASC, id ASC LIMIT ?")).
+				WithArgs(2).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{SortBy: store.SortByCreated, Limit: 2, AllOrganizations: true}, IDs)
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{2, 1}, *IDs)
+		}
+
+		// fails if cursor is from another sort
+		{
+			after := store.NewCursor(store.SortByID, 3, time.Time{}).String()
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{
+				After: after, SortBy: store.SortByCreated, Limit: 2, AllOrganizations: true,
+			}, IDs)
+			assert.Equal(t, errors.ErrInvalidCursor, err)
+		}
+
+		// fail scan
+		{
+			var limit uint = 2
+			mock.ExpectQuery(selectQuery).WithArgs(limit).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}).
+					AddRow("err"),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Limit: limit, AllOrganizations: true}, IDs)
+			assert.NotNil(t, err)
+			assert.Contains(t, err.Error(), "invalid syntax")
+			assert.Len(t, *IDs, 0)
+		}
+
+		// fail
+		{
+			var limit uint = 4
+			myErr := fmt.Errorf("err")
+
+			mock.ExpectQuery(selectQuery).WithArgs(limit).WillReturnError(myErr)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Limit: limit, AllOrganizations: true}, IDs)
+			assert.Equal(t, "could not fetch rows; err", err.Error())
+			assert.Len(t, *IDs, 0)
+		}
+	})
 }
 
 func TestFetchUsers(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		userID := int64(3)
-		mock.ExpectQuery(
-			regexp.QuoteMeta(
-				"SELECT id, name, password, created, updated " +
-					"FROM users WHERE id IN (?)"),
-		).WithArgs(userID).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "name", "password", "created", "updated"}).
-				AddRow(userID, "user", "pass", time.Time{}, time.Time{}),
-		)
-
-		r := database.New(mdb)
-		users := new([]entity.User)
-		err := r.FetchUsers(ctx, []int64{userID}, users)
-		assert.Nil(t, err)
-		assert.Len(t, *users, 1)
-		assert.Equal(t, userID, (*users)[0].ID)
-		assert.Equal(t, "user", (*users)[0].Name)
-		assert.Equal(t, "pass", (*users)[0].Password)
-		assert.Equal(t, time.Time{}, (*users)[0].Created)
-		assert.Equal(t, time.Time{}, (*users)[0].Updated)
-	}
-
-	// succeed with no row
-	{
-		userID := int64(3)
-		mock.ExpectQuery(
-			regexp.QuoteMeta(
-				"SELECT id, name, password, created, updated " +
-					"FROM users WHERE id IN (?)"),
-		).WithArgs(userID).WillReturnRows(
-			sqlmock.NewRows([]string{"id"}),
-		)
-
-		r := database.New(mdb)
-		users := new([]entity.User)
-		err := r.FetchUsers(ctx, []int64{userID}, users)
-		assert.Nil(t, err)
-		assert.Len(t, *users, 0)
-	}
-
-	// empty
-	{
-		r := database.New(mdb)
-		users := new([]entity.User)
-		err := r.FetchUsers(ctx, []int64{}, users)
-		assert.Nil(t, err)
-		assert.Len(t, *users, 0)
-	}
-
-	// scan fail
-	{
-		userID := int64(3)
-		mock.ExpectQuery(
-			regexp.QuoteMeta(
-				"SELECT id, name, password, created, updated " +
-					"FROM users WHERE id IN (?)"),
-		).WithArgs(userID).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "name", "password", "created", "updated"}).
-				AddRow("err", "user", "pass", 1, 2),
-		)
-
-		r := database.New(mdb)
-		users := new([]entity.User)
-		err := r.FetchUsers(ctx, []int64{userID}, users)
-		assert.Contains(t, err.Error(), "invalid syntax")
-		assert.Len(t, *users, 0)
-	}
-
-	// fail
-	{
-		myErr := fmt.Errorf("opz")
-		userID := int64(3)
-		mock.ExpectQuery(
-			regexp.QuoteMeta(
-				"SELECT id, name, password, created, updated " +
-					"FROM users WHERE id IN (?)"),
-		).WithArgs(userID).WillReturnError(myErr)
-
-		r := database.New(mdb)
-		users := new([]entity.User)
-		err := r.FetchUsers(ctx, []int64{userID}, users)
-		assert.Equal(t, err.Error(), "could not fetch rows; opz")
-		assert.Len(t, *users, 0)
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		selectQuery := query(d, "SELECT id, name, password, created, updated, deleted_at "+
+			"FROM users WHERE id IN (?) AND deleted_at IS NULL")
+
+		// succeed
+		{
+			userID := int64(3)
+			mock.ExpectQuery(selectQuery).WithArgs(userID).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "name", "password", "created", "updated", "deleted_at"}).
+					AddRow(userID, "user", "pass", time.Time{}, time.Time{}, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			users := new([]entity.User)
+			err := r.FetchUsers(ctx, []int64{userID}, false, users)
+			assert.Nil(t, err)
+			assert.Len(t, *users, 1)
+			assert.Equal(t, userID, (*users)[0].ID)
+			assert.Equal(t, "user", (*users)[0].Name)
+			assert.Equal(t, "pass", (*users)[0].Password)
+			assert.Equal(t, time.Time{}, (*users)[0].Created)
+			assert.Equal(t, time.Time{}, (*users)[0].Updated)
+		}
+
+		// succeed with no row
+		{
+			userID := int64(3)
+			mock.ExpectQuery(selectQuery).WithArgs(userID).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			users := new([]entity.User)
+			err := r.FetchUsers(ctx, []int64{userID}, false, users)
+			assert.Nil(t, err)
+			assert.Len(t, *users, 0)
+		}
+
+		// empty
+		{
+			r := database.NewWithDialect(mdb, d)
+			users := new([]entity.User)
+			err := r.FetchUsers(ctx, []int64{}, false, users)
+			assert.Nil(t, err)
+			assert.Len(t, *users, 0)
+		}
+
+		// scan fail
+		{
+			userID := int64(3)
+			mock.ExpectQuery(selectQuery).WithArgs(userID).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "name", "password", "created", "updated", "deleted_at"}).
+					AddRow("err", "user", "pass", 1, 2, nil),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			users := new([]entity.User)
+			err := r.FetchUsers(ctx, []int64{userID}, false, users)
+			assert.Contains(t, err.Error(), "invalid syntax")
+			assert.Len(t, *users, 0)
+		}
+
+		// fail
+		{
+			myErr := fmt.Errorf("opz")
+			userID := int64(3)
+			mock.ExpectQuery(selectQuery).WithArgs(userID).WillReturnError(myErr)
+
+			r := database.NewWithDialect(mdb, d)
+			users := new([]entity.User)
+			err := r.FetchUsers(ctx, []int64{userID}, false, users)
+			assert.Equal(t, err.Error(), "could not fetch rows; opz")
+			assert.Len(t, *users, 0)
+		}
+	})
 }
 
 func TestFilterUsersByMail(t *testing.T) {
-	ctx := context.Background()
-	mdb, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer mdb.Close()
-
-	// succeed
-	{
-		email := "example@example.com"
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT u.id FROM users u" +
-				" INNER JOIN emails e ON(e.user_id = u.id) WHERE e.address = ?"),
-		).WithArgs(email).WillReturnRows(
-			sqlmock.NewRows([]string{"id"}).
-				AddRow(3),
-		)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Email: email}, IDs)
-		assert.Nil(t, err)
-		assert.Equal(t, 3, int((*IDs)[0]))
-	}
-
-	// succeed with no row
-	{
-		email := "example@example.com"
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT u.id FROM users u" +
-				" INNER JOIN emails e ON(e.user_id = u.id) WHERE e.address = ?"),
-		).WithArgs(email).WillReturnRows(
-			sqlmock.NewRows([]string{"id"}),
-		)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Email: email}, IDs)
-		assert.Nil(t, err)
-		assert.Len(t, *IDs, 0)
-	}
-
-	// scan fail
-	{
-		email := "example@example.com"
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT u.id FROM users u" +
-				" INNER JOIN emails e ON(e.user_id = u.id) WHERE e.address = ?"),
-		).WithArgs(email).WillReturnRows(
-			sqlmock.NewRows([]string{"id"}).
-				AddRow("err"),
-		)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Email: email}, IDs)
-		assert.Contains(t, err.Error(), "invalid syntax")
-		assert.Len(t, *IDs, 0)
-	}
-
-	// fail
-	{
-		myErr := fmt.Errorf("opz")
-		email := "example@example.com"
-		mock.ExpectQuery(
-			regexp.QuoteMeta("SELECT u.id FROM users u" +
-				" INNER JOIN emails e ON(e.user_id = u.id) WHERE e.address = ?"),
-		).WithArgs(email).WillReturnError(myErr)
-
-		r := database.New(mdb)
-		IDs := new([]int64)
-		err := r.FilterUsersID(ctx, store.FilterUsers{Email: email}, IDs)
-		assert.Equal(t, err.Error(), "could not fetch rows; opz")
-		assert.Len(t, *IDs, 0)
-	}
+	forEachDialect(t, func(t *testing.T, d database.Dialect, mdb *sql.DB, mock sqlmock.Sqlmock) {
+		ctx := context.Background()
+		selectQuery := query(d, "SELECT u.id FROM users u"+
+			" INNER JOIN emails e ON(e.user_id = u.id) WHERE e.address = ?"+
+			" AND u.deleted_at IS NULL AND e.deleted_at IS NULL")
+
+		// succeed
+		{
+			email := "example@example.com"
+			mock.ExpectQuery(selectQuery).WithArgs(email).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}).
+					AddRow(3),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Email: email, AllOrganizations: true}, IDs)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, int((*IDs)[0]))
+		}
+
+		// succeed with no row
+		{
+			email := "example@example.com"
+			mock.ExpectQuery(selectQuery).WithArgs(email).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Email: email, AllOrganizations: true}, IDs)
+			assert.Nil(t, err)
+			assert.Len(t, *IDs, 0)
+		}
+
+		// scan fail
+		{
+			email := "example@example.com"
+			mock.ExpectQuery(selectQuery).WithArgs(email).WillReturnRows(
+				sqlmock.NewRows([]string{"id"}).
+					AddRow("err"),
+			)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Email: email, AllOrganizations: true}, IDs)
+			assert.Contains(t, err.Error(), "invalid syntax")
+			assert.Len(t, *IDs, 0)
+		}
+
+		// fail
+		{
+			myErr := fmt.Errorf("opz")
+			email := "example@example.com"
+			mock.ExpectQuery(selectQuery).WithArgs(email).WillReturnError(myErr)
+
+			r := database.NewWithDialect(mdb, d)
+			IDs := new([]int64)
+			err := r.FilterUsersID(ctx, store.FilterUsers{Email: email, AllOrganizations: true}, IDs)
+			assert.Equal(t, err.Error(), "could not fetch rows; opz")
+			assert.Len(t, *IDs, 0)
+		}
+	})
 }
@@ -3,40 +3,248 @@ package store
 
 import (
 	"context"
-	"database/sql"
+	"time"
 
 	"boiler/pkg/entity"
+	"boiler/pkg/errors"
 )
 
+// Now return the current time as kept by the stores, in UTC with microsecond precision
+// so it can be compared with the stored value
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// Touch return the new update time of a row last updated at prev, it is always after prev
+// so a conditional update on the old value can not succeed twice
+func Touch(prev time.Time) time.Time {
+	now := Now()
+	if !now.After(prev) {
+		now = prev.UTC().Truncate(time.Microsecond).Add(time.Microsecond)
+	}
+
+	return now
+}
+
+// ErrTxDone is returned by a transaction that was already committed or rolled back
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx is a store transaction, every write must happen inside one
+// prefer RunInTx over handling it directly
+type Tx interface {
+	Commit() error
+	Rollback() error
+
+	// Savepoint mark a point the transaction can be rolled back to
+	Savepoint(ctx context.Context, name string) error
+	// RollbackTo discard the changes made after the savepoint
+	RollbackTo(ctx context.Context, name string) error
+	// Release forget the savepoint, keeping its changes
+	Release(ctx context.Context, name string) error
+}
+
 // FilterUsers is the input for filter users
+// After and Before are cursors of a previous page, the page lists at most Limit users, Backward lists the
+// last ones without Before
+// a non zero OrganizationID only lists the members of the organization, AllOrganizations lists the users of
+// every organization, the stores fail with errors.ErrMissingOrganization without one of them
+// the deleted users are only listed with IncludeDeleted
 type FilterUsers struct {
-	Email  string
-	Offset uint
-	Limit  uint
+	OrganizationID   int64
+	AllOrganizations bool
+	Email            string
+	After            string
+	Before           string
+	Backward         bool
+	SortBy           SortBy
+	Limit            uint
+	IncludeDeleted   bool
+}
+
+// Seek return the keyset position of the filter
+func (f FilterUsers) Seek() (Seek, error) {
+	return NewSeek(f.SortBy, f.After, f.Before, f.Backward)
+}
+
+// CheckOrganization fail with errors.ErrMissingOrganization if the filter is not scoped
+func (f FilterUsers) CheckOrganization() error {
+	return checkOrganization(f.OrganizationID, f.AllOrganizations)
 }
 
 // FilterEmails is the input for filter emails
+// After and Before are cursors of a previous page, a zero Limit lists every email, Backward lists the last
+// ones without Before
+// a non zero OrganizationID only lists the emails of the members of the organization, AllOrganizations lists
+// the emails of every organization, the stores fail with errors.ErrMissingOrganization without one of them
+// the deleted emails are only listed with IncludeDeleted
 type FilterEmails struct {
-	EmailID int64
-	UserID  int64
-	Offset  uint
-	Limit   uint
+	OrganizationID   int64
+	AllOrganizations bool
+	EmailID          int64
+	UserID           int64
+	// Address find the email of an address, it takes precedence over UserID
+	Address        string
+	After          string
+	Before         string
+	Backward       bool
+	SortBy         SortBy
+	Limit          uint
+	IncludeDeleted bool
+}
+
+// Seek return the keyset position of the filter
+func (f FilterEmails) Seek() (Seek, error) {
+	return NewSeek(f.SortBy, f.After, f.Before, f.Backward)
+}
+
+// CheckOrganization fail with errors.ErrMissingOrganization if the filter is not scoped
+func (f FilterEmails) CheckOrganization() error {
+	return checkOrganization(f.OrganizationID, f.AllOrganizations)
+}
+
+// checkOrganization fail if a filter would read across the organizations without asking for it
+func checkOrganization(orgID int64, all bool) error {
+	if orgID == 0 && !all {
+		return errors.ErrMissingOrganization
+	}
+
+	return nil
 }
 
 // Interface
 type Interface interface {
 	// begin transaction
-	Tx() (*sql.Tx, error)
+	Tx(ctx context.Context) (Tx, error)
 
 	// user
-	AddUser(ctx context.Context, tx *sql.Tx, user *entity.User) error
-	DeleteUser(ctx context.Context, tx *sql.Tx, userID int64) error
+	AddUser(ctx context.Context, tx Tx, user *entity.User) error
+	// UpdateUser write the profile of the user if user.Updated is still the stored one
+	// user.Updated is set to the new update time, a stale user fails with ErrUpdateConflict
+	UpdateUser(ctx context.Context, tx Tx, user *entity.User) error
+	UpdateUserPassword(ctx context.Context, tx Tx, userID int64, password string) error
+	// DeleteUser mark the user deleted, it fails with ErrNotFound if it is missing or already deleted
+	DeleteUser(ctx context.Context, tx Tx, userID int64) error
+	// RestoreUser undelete the user and the emails deleted with it, it fails with ErrNotFound if it is not deleted
+	// the emails whose address was added again meanwhile stay deleted
+	RestoreUser(ctx context.Context, tx Tx, userID int64) error
+	// PurgeUser remove the user and its emails for good
+	PurgeUser(ctx context.Context, tx Tx, userID int64) error
+	// FilterUsersID return the IDs in the order of filter.SortBy
 	FilterUsersID(ctx context.Context, filter FilterUsers, IDs *[]int64) error
-	FetchUsers(ctx context.Context, ID []int64, users *[]entity.User) error
+	// FilterDeletedUsersID return the IDs of at most limit users deleted before a time
+	FilterDeletedUsersID(ctx context.Context, before time.Time, limit uint, IDs *[]int64) error
+	// FetchUsers skip the deleted users unless includeDeleted
+	FetchUsers(ctx context.Context, ID []int64, includeDeleted bool, users *[]entity.User) error
 
 	// email
-	AddEmail(ctx context.Context, tx *sql.Tx, email *entity.Email) error
-	DeleteEmail(ctx context.Context, tx *sql.Tx, email int64) error
-	DeleteEmailsByUserID(ctx context.Context, tx *sql.Tx, userID int64) error
+	AddEmail(ctx context.Context, tx Tx, email *entity.Email) error
+	// VerifyEmail fails with ErrUpdateConflict if the email is already verified
+	VerifyEmail(ctx context.Context, tx Tx, emailID int64, at time.Time) error
+	// DeleteEmail mark the email deleted, it fails with ErrNotFound if it is missing or already deleted
+	DeleteEmail(ctx context.Context, tx Tx, email int64) error
+	DeleteEmailsByUserID(ctx context.Context, tx Tx, userID int64) error
+	// RestoreEmail undelete the email, it fails with ErrNotFound if it is not deleted
+	// and ErrAlreadyExists if its address was added again meanwhile
+	RestoreEmail(ctx context.Context, tx Tx, emailID int64) error
+	// PurgeEmails remove for good the emails deleted before a time
+	PurgeEmails(ctx context.Context, tx Tx, before time.Time) error
+	// PurgeOrphanedEmails remove the emails of the users which do not exist
+	PurgeOrphanedEmails(ctx context.Context, tx Tx) error
+	// FilterEmails return the emails in the order of filter.SortBy
 	FilterEmails(ctx context.Context, filter FilterEmails, emails *[]entity.Email) error
+
+	// role
+	// FetchRoles return the roles sorted by name, all of them if names is empty
+	FetchRoles(ctx context.Context, names []string, roles *[]entity.Role) error
+	// SetUserRoles replace the roles of the user, it fails with ErrInvalidRole if a role does not exist
+	SetUserRoles(ctx context.Context, tx Tx, userID int64, roles []string) error
+	// FetchUserRoles return the role names of the user sorted by name
+	FetchUserRoles(ctx context.Context, userID int64, roles *[]string) error
+
+	// token
+	AddRefreshToken(ctx context.Context, tx Tx, token *entity.RefreshToken) error
+	// FetchRefreshToken find a refresh token by hash, it fails with ErrNotFound
+	FetchRefreshToken(ctx context.Context, hash string, token *entity.RefreshToken) error
+	// UseRefreshToken mark a refresh token as used, it fails with ErrUpdateConflict if it already was
+	UseRefreshToken(ctx context.Context, tx Tx, tokenID int64) error
+	RevokeRefreshFamily(ctx context.Context, tx Tx, family string) error
+	// RevokeUserRefreshTokens revoke all the refresh tokens of the user, ending its sessions
+	RevokeUserRefreshTokens(ctx context.Context, tx Tx, userID int64) error
+	// DenyToken deny an access token until it expires, denying it twice is not an error
+	DenyToken(ctx context.Context, tx Tx, jti string, expires time.Time) error
+	IsTokenDenied(ctx context.Context, jti string) (bool, error)
+	// RevokeAccessTokens deny the access tokens of the user issued before a time until expires, it replaces
+	// the previous revocation of the user
+	RevokeAccessTokens(ctx context.Context, tx Tx, userID int64, before, expires time.Time) error
+	// IsTokenRevoked tells if an access token of the user issued at a time was revoked by RevokeAccessTokens
+	IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error)
+	// PurgeExpiredTokens remove the refresh tokens, the denied and revoked tokens and the password resets expired
+	// before a time
+	PurgeExpiredTokens(ctx context.Context, tx Tx, before time.Time) error
+
+	// password reset
+	AddPasswordReset(ctx context.Context, tx Tx, reset *entity.PasswordReset) error
+	// FetchPasswordReset find a password reset by hash, it fails with ErrNotFound
+	FetchPasswordReset(ctx context.Context, hash string, reset *entity.PasswordReset) error
+	// UsePasswordReset mark a password reset as used, it fails with ErrUpdateConflict if it already was
+	UsePasswordReset(ctx context.Context, tx Tx, resetID int64) error
+
+	// totp
+	// SetTOTP replace the unconfirmed TOTP of the user, it fails with ErrUpdateConflict if it is confirmed
+	SetTOTP(ctx context.Context, tx Tx, totp *entity.TOTP) error
+	// FetchTOTP find the TOTP of the user, it fails with ErrNotFound
+	FetchTOTP(ctx context.Context, userID int64, totp *entity.TOTP) error
+	// ConfirmTOTP confirm the TOTP of the user with the step of its first code, it fails with
+	// ErrUpdateConflict if it already was
+	ConfirmTOTP(ctx context.Context, tx Tx, userID, counter int64) error
+	// UseTOTP record the step of a used code, it fails with ErrUpdateConflict if the step is not after
+	// the last one used
+	UseTOTP(ctx context.Context, tx Tx, userID, counter int64) error
+	// DeleteTOTP remove the TOTP and the recovery codes of the user
+	DeleteTOTP(ctx context.Context, tx Tx, userID int64) error
+	// SetRecoveryCodes replace the recovery codes of the user by the hashes
+	SetRecoveryCodes(ctx context.Context, tx Tx, userID int64, hashes []string) error
+	// UseRecoveryCode mark the recovery code of the user as used, it fails with ErrNotFound if the user
+	// has no such unused code
+	UseRecoveryCode(ctx context.Context, tx Tx, userID int64, hash string) error
+
+	// api key
+	AddAPIKey(ctx context.Context, tx Tx, key *entity.APIKey) error
+	// FetchAPIKey find an API key by hash, it fails with ErrNotFound
+	FetchAPIKey(ctx context.Context, hash string, key *entity.APIKey) error
+	// FilterAPIKeys return the API keys of the user sorted by ID
+	FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error
+	// RevokeAPIKey revoke an API key of the user, it fails with ErrNotFound if the user has no such key
+	RevokeAPIKey(ctx context.Context, tx Tx, userID, keyID int64) error
+	// TouchAPIKey set the last use of an API key
+	TouchAPIKey(ctx context.Context, tx Tx, keyID int64, at time.Time) error
+	DeleteAPIKeysByUserID(ctx context.Context, tx Tx, userID int64) error
+
+	// organization
+	AddOrganization(ctx context.Context, tx Tx, org *entity.Organization) error
+	// FetchOrganization find an organization by ID, it fails with ErrNotFound
+	FetchOrganization(ctx context.Context, orgID int64, org *entity.Organization) error
+	// FilterOrganizations return the organizations of the user sorted by ID
+	FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error
+	// AddMember add an user to an organization, it fails with ErrAlreadyExists if it is a member
+	AddMember(ctx context.Context, tx Tx, member *entity.Member) error
+	// FetchMember find the membership of the user, it fails with ErrNotFound if it is not a member
+	FetchMember(ctx context.Context, orgID, userID int64, member *entity.Member) error
+	// FilterMembers return the members of the organization sorted by user ID
+	FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error
+	DeleteMembersByUserID(ctx context.Context, tx Tx, userID int64) error
+
+	// job
+	// AddJob record a job enqueued with job.ID, it fails with ErrAlreadyExists if the ID is taken
+	AddJob(ctx context.Context, tx Tx, job *entity.Job) error
+	// FetchJob find a job by ID, it fails with ErrNotFound
+	FetchJob(ctx context.Context, jobID string, job *entity.Job) error
+	// SetJobStatus set the status and the error of a job, it fails with ErrNotFound if the job is not recorded
+	SetJobStatus(ctx context.Context, tx Tx, jobID, status, errText string) error
+
+	// schedule
+	// SetScheduleRun record the last run of a scheduled job, replacing the previous one
+	SetScheduleRun(ctx context.Context, tx Tx, job string, at time.Time, status string) error
+	// FetchScheduleRuns return the job, LastRun and LastStatus of the scheduled jobs which ran, sorted by job
+	FetchScheduleRuns(ctx context.Context, schedules *[]entity.Schedule) error
 }
@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Maildir is a Mailer dropping the messages in a maildir instead of sending them, handy in
+// development to read the messages with a mail client
+type Maildir struct {
+	Dir  string
+	From string
+
+	count uint64
+}
+
+// NewMaildir return a Maildir mailer, creating the tmp, new and cur folders of dir
+func NewMaildir(dir, from string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0o700)
+		if err != nil {
+			return nil, fmt.Errorf("could not create maildir; %w", err)
+		}
+	}
+
+	return &Maildir{Dir: dir, From: from}, nil
+}
+
+// Send write msg to the tmp folder and move it to new once complete, so the readers never
+// see a partial message
+func (m *Maildir) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+
+	raw, err := encode(msg, sender(msg, m.From), now)
+	if err != nil {
+		return fmt.Errorf("could not encode message; %w", err)
+	}
+
+	name := m.name(now)
+	tmp := filepath.Join(m.Dir, "tmp", name)
+
+	err = ioutil.WriteFile(tmp, raw, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not write message; %w", err)
+	}
+
+	err = os.Rename(tmp, filepath.Join(m.Dir, "new", name))
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not deliver message; %w", err)
+	}
+
+	return nil
+}
+
+// name return a unique file name following the maildir convention time.pid_count.host
+func (m *Maildir) name(now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	count := atomic.AddUint64(&m.count, 1)
+	return strconv.FormatInt(now.Unix(), 10) + "." + strconv.Itoa(os.Getpid()) + "_" +
+		strconv.FormatUint(count, 10) + "." + host
+}
@@ -0,0 +1,32 @@
+// Package mailer sends the emails of the service
+package mailer
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is an email to send, HTML is optional
+type Message struct {
+	// From override the sender of the mailer
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Log is a Mailer writing the messages to the log instead of sending them, handy in development
+type Log struct{}
+
+// Send log msg
+func (Log) Send(ctx context.Context, msg Message) error {
+	log.Info().Str("to", msg.To).Str("subject", msg.Subject).Msg(msg.Text)
+	return nil
+}
@@ -0,0 +1,284 @@
+package mailer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"boiler/pkg/errors"
+	"boiler/pkg/store/mailer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// received is a message accepted by the fake SMTP server
+type received struct {
+	auth string
+	from string
+	to   string
+	data []byte
+}
+
+// fakeSMTP serve a minimal SMTP server on a local port, it sends the messages it accepts on
+// the returned channel, code is the reply to RCPT
+func fakeSMTP(t *testing.T, code int) (string, <-chan received) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	ch := make(chan received, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		c := textproto.NewConn(conn)
+		var msg received
+		_ = c.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO":
+				_ = c.PrintfLine("250-localhost")
+				_ = c.PrintfLine("250 AUTH PLAIN")
+			case cmd == "AUTH":
+				msg.auth = line
+				_ = c.PrintfLine("235 authenticated")
+			case cmd == "MAIL":
+				msg.from = line
+				_ = c.PrintfLine("250 ok")
+			case cmd == "RCPT":
+				msg.to = line
+				_ = c.PrintfLine("%d rcpt", code)
+			case cmd == "DATA":
+				_ = c.PrintfLine("354 go ahead")
+				msg.data, err = c.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				_ = c.PrintfLine("250 queued")
+				ch <- msg
+			case cmd == "QUIT":
+				_ = c.PrintfLine("221 bye")
+				return
+			default:
+				_ = c.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	return l.Addr().String(), ch
+}
+
+// parts return the decoded text and HTML bodies of a raw message
+func parts(t *testing.T, raw []byte) (*mail.Message, string, string) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	assert.Nil(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+
+	if mediaType == "text/plain" {
+		text, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+		assert.Nil(t, err)
+		return msg, string(text), ""
+	}
+
+	assert.Equal(t, "multipart/alternative", mediaType)
+	bodies := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(p)
+		assert.Nil(t, err)
+		bodies[contentType] = string(body)
+	}
+
+	return msg, bodies["text/plain"], bodies["text/html"]
+}
+
+func TestSMTP(t *testing.T) {
+	ctx := context.Background()
+
+	// succeed
+	{
+		addr, ch := fakeSMTP(t, 250)
+
+		m, err := mailer.NewSMTP(addr, "user", "pass", "Boiler <noreply@example.com>")
+		assert.Nil(t, err)
+
+		err = m.Send(ctx, mailer.Message{To: "a@b.c", Subject: "Héllo", Text: "text\n", HTML: "<p>html</p>"})
+		assert.Nil(t, err)
+
+		got := <-ch
+		assert.Equal(t, "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00user\x00pass")), got.auth)
+		assert.Equal(t, "MAIL FROM:<noreply@example.com>", got.from)
+		assert.Equal(t, "RCPT TO:<a@b.c>", got.to)
+
+		msg, text, html := parts(t, got.data)
+		assert.Equal(t, "Boiler <noreply@example.com>", msg.Header.Get("From"))
+		assert.Equal(t, "a@b.c", msg.Header.Get("To"))
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Héllo", subject)
+		assert.Contains(t, msg.Header.Get("Message-ID"), "@example.com>")
+		assert.Equal(t, "text", strings.TrimSpace(text))
+		assert.Equal(t, "<p>html</p>", html)
+	}
+
+	// succeed with the sender of the message
+	{
+		addr, ch := fakeSMTP(t, 250)
+
+		m, err := mailer.NewSMTP(addr, "", "", "noreply@example.com")
+		assert.Nil(t, err)
+
+		err = m.Send(ctx, mailer.Message{From: "other@example.com", To: "a@b.c", Subject: "s", Text: "text"})
+		assert.Nil(t, err)
+
+		got := <-ch
+		assert.Empty(t, got.auth)
+		assert.Equal(t, "MAIL FROM:<other@example.com>", got.from)
+
+		_, text, html := parts(t, got.data)
+		assert.Equal(t, "text", strings.TrimSpace(text))
+		assert.Empty(t, html)
+	}
+
+	// fails if the server rejects the recipient
+	{
+		addr, _ := fakeSMTP(t, 550)
+
+		m, err := mailer.NewSMTP(addr, "", "", "noreply@example.com")
+		assert.Nil(t, err)
+
+		err = m.Send(ctx, mailer.Message{To: "a@b.c", Subject: "s", Text: "text"})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "550")
+	}
+
+	// fails if the recipient is invalid
+	{
+		m, err := mailer.NewSMTP("127.0.0.1:25", "", "", "noreply@example.com")
+		assert.Nil(t, err)
+
+		err = m.Send(ctx, mailer.Message{To: "nope", Subject: "s", Text: "text"})
+		assert.NotNil(t, err)
+	}
+
+	// fails if the sender is invalid
+	{
+		_, err := mailer.NewSMTP("127.0.0.1:25", "", "", "nope")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMaildir(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := mailer.NewMaildir(dir, "noreply@example.com")
+	assert.Nil(t, err)
+
+	// succeed
+	{
+		assert.Nil(t, m.Send(context.Background(), mailer.Message{To: "a@b.c", Subject: "s", Text: "one"}))
+		assert.Nil(t, m.Send(context.Background(), mailer.Message{To: "a@b.c", Subject: "s", Text: "two"}))
+
+		files, err := filepath.Glob(filepath.Join(dir, "new", "*"))
+		assert.Nil(t, err)
+		assert.Len(t, files, 2)
+
+		tmp, err := filepath.Glob(filepath.Join(dir, "tmp", "*"))
+		assert.Nil(t, err)
+		assert.Len(t, tmp, 0)
+
+		raw, err := ioutil.ReadFile(files[0])
+		assert.Nil(t, err)
+
+		msg, text, _ := parts(t, raw)
+		assert.Equal(t, "noreply@example.com", msg.Header.Get("From"))
+		assert.Contains(t, []string{"one", "two"}, text)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"t/hello.txt":  {Data: []byte(`{{define "subject"}}Hello {{.Name}}{{end}}Hi {{.Name}}`)},
+		"t/hello.html": {Data: []byte(`<p>Hi {{.Name}}</p>`)},
+		"t/plain.txt":  {Data: []byte(`{{define "subject"}}Plain{{end}}plain`)},
+	}
+
+	tpl, err := mailer.NewTemplates(fsys, "t")
+	assert.Nil(t, err)
+
+	// succeed
+	{
+		msg, err := tpl.Render("hello", "a@b.c", map[string]interface{}{"Name": "<Jo>"})
+		assert.Nil(t, err)
+		assert.Equal(t, mailer.Message{
+			To:      "a@b.c",
+			Subject: "Hello <Jo>",
+			Text:    "Hi <Jo>",
+			HTML:    "<p>Hi &lt;Jo&gt;</p>",
+		}, msg)
+	}
+
+	// succeed without HTML
+	{
+		msg, err := tpl.Render("plain", "a@b.c", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, mailer.Message{To: "a@b.c", Subject: "Plain", Text: "plain"}, msg)
+	}
+
+	// fails if the template does not exist
+	{
+		_, err := tpl.Render("nope", "a@b.c", nil)
+		assert.Equal(t, errors.ErrUnknownTemplate, err)
+	}
+
+	// fails without a subject
+	{
+		_, err := mailer.NewTemplates(fstest.MapFS{"t/a.txt": {Data: []byte(`a`)}}, "t")
+		assert.NotNil(t, err)
+	}
+
+	// fails with an HTML template without text
+	{
+		_, err := mailer.NewTemplates(fstest.MapFS{"t/a.html": {Data: []byte(`a`)}}, "t")
+		assert.NotNil(t, err)
+	}
+
+	// the default templates render
+	{
+		msg, err := mailer.Default.Render("verify_email", "a@b.c", map[string]interface{}{
+			"Address": "a@b.c", "Token": "tok", "ExpireIn": "24h0m0s",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "Verify your email", msg.Subject)
+		assert.Contains(t, msg.Text, "tok")
+		assert.Contains(t, msg.HTML, "tok")
+	}
+}
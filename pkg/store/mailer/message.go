@@ -0,0 +1,126 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// encode write msg as a MIME message sent by from, a message with an HTML body is a
+// multipart/alternative of the text and the HTML
+func encode(msg Message, from string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	id, err := messageID(from)
+	if err != nil {
+		return nil, err
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", from)
+	header.Set("To", msg.To)
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header.Set("Date", now.Format(time.RFC1123Z))
+	header.Set("Message-ID", id)
+	header.Set("MIME-Version", "1.0")
+
+	if len(msg.HTML) == 0 {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, header)
+
+		err = writeQuoted(&buf, msg.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	writeHeader(&buf, header)
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create part; %w", err)
+		}
+
+		err = writeQuoted(w, part.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not close multipart; %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeHeader write the header in a stable order followed by the blank line ending it
+func writeHeader(w io.Writer, header textproto.MIMEHeader) {
+	for _, k := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version",
+		"Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(k); len(v) != 0 {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	fmt.Fprint(w, "\r\n")
+}
+
+// writeQuoted write body in quoted-printable with CRLF line endings
+func writeQuoted(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	_, err := io.WriteString(qw, strings.ReplaceAll(body, "\n", "\r\n"))
+	if err != nil {
+		return fmt.Errorf("could not write body; %w", err)
+	}
+
+	return qw.Close()
+}
+
+// messageID return a random Message-ID in the domain of from
+func messageID(from string) (string, error) {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i != -1 {
+			domain = addr.Address[i+1:]
+		}
+	}
+
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("could not generate message ID; %w", err)
+	}
+
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">", nil
+}
+
+// sender return the sender of msg, from unless the message overrides it
+func sender(msg Message, from string) string {
+	if len(msg.From) != 0 {
+		return msg.From
+	}
+
+	return from
+}
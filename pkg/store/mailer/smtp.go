@@ -0,0 +1,132 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTP is a Mailer sending the messages to an SMTP server, it uses STARTTLS when the server
+// offers it
+type SMTP struct {
+	// Addr is the host:port of the server
+	Addr string
+	// Auth authenticate to the server, nil to send anonymously
+	Auth smtp.Auth
+	From string
+}
+
+// NewSMTP return a SMTP mailer authenticating with username and password if username is set
+func NewSMTP(addr, username, password, from string) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address; %w", err)
+	}
+
+	_, err = mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender; %w", err)
+	}
+
+	s := &SMTP{Addr: addr, From: from}
+	if len(username) != 0 {
+		s.Auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s, nil
+}
+
+// Send send msg to the server, the deadline of ctx bounds the whole exchange
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	from := sender(msg, s.From)
+
+	envelopeFrom, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid sender; %w", err)
+	}
+
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient; %w", err)
+	}
+
+	raw, err := encode(msg, from, time.Now())
+	if err != nil {
+		return fmt.Errorf("could not encode message; %w", err)
+	}
+
+	err = s.send(ctx, envelopeFrom.Address, to.Address, raw)
+	if err != nil {
+		return fmt.Errorf("could not send message; %w", err)
+	}
+
+	return nil
+}
+
+// send run the SMTP exchange delivering raw to to
+func (s *SMTP) send(ctx context.Context, from, to string, raw []byte) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := new(net.Dialer).DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Auth != nil {
+		err = c.Auth(s.Auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(raw)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	ttemplate "text/template"
+
+	"boiler/pkg/errors"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// Default are the templates of the messages sent by the service
+var Default = MustTemplates(embedded, "templates")
+
+// Templates render the messages, a message name.txt is a text template defining its subject
+// in a subject block, an optional name.html is the HTML alternative
+type Templates struct {
+	text map[string]*ttemplate.Template
+	html map[string]*htemplate.Template
+}
+
+// NewTemplates parse the templates of the dir of fsys
+func NewTemplates(fsys fs.FS, dir string) (*Templates, error) {
+	t := &Templates{
+		text: map[string]*ttemplate.Template{},
+		html: map[string]*htemplate.Template{},
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read templates; %w", err)
+	}
+
+	for _, entry := range entries {
+		file := path.Join(dir, entry.Name())
+		ext := path.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+
+		switch ext {
+		case ".txt":
+			tpl, err := ttemplate.ParseFS(fsys, file)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse template %s; %w", file, err)
+			}
+			if tpl.Lookup("subject") == nil {
+				return nil, fmt.Errorf("template %s has no subject", file)
+			}
+			t.text[name] = tpl
+		case ".html":
+			tpl, err := htemplate.ParseFS(fsys, file)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse template %s; %w", file, err)
+			}
+			t.html[name] = tpl
+		}
+	}
+
+	for name := range t.html {
+		if _, ok := t.text[name]; !ok {
+			return nil, fmt.Errorf("template %s has no text version", name)
+		}
+	}
+
+	return t, nil
+}
+
+// MustTemplates is like NewTemplates but panics if the templates can not be parsed
+func MustTemplates(fsys fs.FS, dir string) *Templates {
+	t, err := NewTemplates(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Render return the message name to to, it fails with ErrUnknownTemplate if name does not exist
+func (t *Templates) Render(name, to string, data interface{}) (Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return Message{}, errors.ErrUnknownTemplate
+	}
+
+	msg := Message{To: to}
+
+	var buf bytes.Buffer
+	err := text.ExecuteTemplate(&buf, "subject", data)
+	if err != nil {
+		return Message{}, fmt.Errorf("could not render subject; %w", err)
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	err = text.Execute(&buf, data)
+	if err != nil {
+		return Message{}, fmt.Errorf("could not render text; %w", err)
+	}
+	msg.Text = buf.String()
+
+	if html, ok := t.html[name]; ok {
+		buf.Reset()
+		err = html.Execute(&buf, data)
+		if err != nil {
+			return Message{}, fmt.Errorf("could not render html; %w", err)
+		}
+		msg.HTML = buf.String()
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddAPIKey insert a new API key, prefixes and hashes are unique
+func (s *Memory) AddAPIKey(ctx context.Context, tx store.Tx, key *entity.APIKey) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range d.apiKeys {
+		if k.Prefix == key.Prefix || k.Hash == key.Hash {
+			return errors.ErrAlreadyExists
+		}
+	}
+
+	d.lastAPIKeyID++
+
+	key.ID = d.lastAPIKeyID
+	key.Created = store.Now()
+	if key.Expires != nil {
+		utc := key.Expires.UTC()
+		key.Expires = &utc
+	}
+	key.Scopes = append([]string(nil), key.Scopes...)
+	d.apiKeys[key.ID] = *key
+
+	return nil
+}
+
+// FetchAPIKey find an API key by hash
+func (s *Memory) FetchAPIKey(ctx context.Context, hash string, key *entity.APIKey) error {
+	found := false
+	s.read(func(d *data) {
+		for _, k := range d.apiKeys {
+			if k.Hash == hash {
+				*key = k
+				found = true
+				return
+			}
+		}
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// FilterAPIKeys find the API keys of the user
+func (s *Memory) FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error {
+	s.read(func(d *data) {
+		*keys = make([]entity.APIKey, 0)
+		for _, k := range d.apiKeys {
+			if k.UserID == userID {
+				*keys = append(*keys, k)
+			}
+		}
+	})
+
+	sort.Slice(*keys, func(i, j int) bool { return (*keys)[i].ID < (*keys)[j].ID })
+	return nil
+}
+
+// RevokeAPIKey revoke an API key of the user
+func (s *Memory) RevokeAPIKey(ctx context.Context, tx store.Tx, userID, keyID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	key, ok := d.apiKeys[keyID]
+	if !ok || key.UserID != userID {
+		return errors.ErrNotFound
+	}
+
+	key.Revoked = true
+	d.apiKeys[keyID] = key
+	return nil
+}
+
+// TouchAPIKey set the last use of an API key
+func (s *Memory) TouchAPIKey(ctx context.Context, tx store.Tx, keyID int64, at time.Time) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	key, ok := d.apiKeys[keyID]
+	if !ok {
+		return nil
+	}
+
+	at = at.UTC()
+	key.LastUsed = &at
+	d.apiKeys[keyID] = key
+	return nil
+}
+
+// DeleteAPIKeysByUserID remove the API keys of the user
+func (s *Memory) DeleteAPIKeysByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, key := range d.apiKeys {
+		if key.UserID == userID {
+			delete(d.apiKeys, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddEmail insert a new email, addresses are unique among the emails not deleted
+func (s *Memory) AddEmail(ctx context.Context, tx store.Tx, email *entity.Email) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	if d.addressTaken(email.Address) {
+		return errors.ErrAlreadyExists
+	}
+
+	d.lastEmailID++
+
+	email.ID = d.lastEmailID
+	email.Created = store.Now()
+	d.emails[email.ID] = *email
+
+	return nil
+}
+
+// VerifyEmail set when an email was verified, it fails with ErrUpdateConflict if it is already verified
+func (s *Memory) VerifyEmail(ctx context.Context, tx store.Tx, emailID int64, at time.Time) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	email, ok := d.emails[emailID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	if email.VerifiedAt != nil {
+		return errors.ErrUpdateConflict
+	}
+
+	at = at.UTC()
+	email.VerifiedAt = &at
+	d.emails[emailID] = email
+	return nil
+}
+
+// DeleteEmail mark an email deleted
+func (s *Memory) DeleteEmail(ctx context.Context, tx store.Tx, emailID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	e, ok := d.emails[emailID]
+	if !ok || e.DeletedAt != nil {
+		return errors.ErrNotFound
+	}
+
+	now := store.Now()
+	e.DeletedAt = &now
+	d.emails[emailID] = e
+	return nil
+}
+
+// DeleteEmailsByUserID mark the emails of an user deleted
+func (s *Memory) DeleteEmailsByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	now := store.Now()
+	var n int
+	for id, e := range d.emails {
+		if e.UserID == userID && e.DeletedAt == nil {
+			e.DeletedAt = &now
+			d.emails[id] = e
+			n++
+		}
+	}
+
+	if n == 0 {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreEmail undelete an email
+func (s *Memory) RestoreEmail(ctx context.Context, tx store.Tx, emailID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	e, ok := d.emails[emailID]
+	if !ok || e.DeletedAt == nil {
+		return errors.ErrNotFound
+	}
+
+	if d.addressTaken(e.Address) {
+		return errors.ErrAlreadyExists
+	}
+
+	e.DeletedAt = nil
+	d.emails[emailID] = e
+	return nil
+}
+
+// PurgeEmails remove the emails deleted before a time
+func (s *Memory) PurgeEmails(ctx context.Context, tx store.Tx, before time.Time) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, e := range d.emails {
+		if e.DeletedAt != nil && e.DeletedAt.Before(before) {
+			delete(d.emails, id)
+		}
+	}
+
+	return nil
+}
+
+// PurgeOrphanedEmails remove the emails of the users which do not exist
+func (s *Memory) PurgeOrphanedEmails(ctx context.Context, tx store.Tx) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, e := range d.emails {
+		if _, ok := d.users[e.UserID]; !ok {
+			delete(d.emails, id)
+		}
+	}
+
+	return nil
+}
+
+// addressTaken tells if an email not deleted has the address
+func (d *data) addressTaken(address string) bool {
+	for _, e := range d.emails {
+		if e.Address == address && e.DeletedAt == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterEmails find for emails
+func (s *Memory) FilterEmails(ctx context.Context, filter store.FilterEmails, emails *[]entity.Email) error {
+	if err := filter.CheckOrganization(); err != nil {
+		return err
+	}
+
+	seek, err := filter.Seek()
+	if err != nil {
+		return err
+	}
+
+	*emails = make([]entity.Email, 0)
+	visible := func(e entity.Email) bool { return filter.IncludeDeleted || e.DeletedAt == nil }
+
+	s.read(func(d *data) {
+		if filter.EmailID > 0 {
+			if e, ok := d.emails[filter.EmailID]; ok && visible(e) && d.isMember(filter.OrganizationID, e.UserID) {
+				*emails = append(*emails, e)
+			}
+			return
+		}
+
+		if len(filter.Address) != 0 {
+			for _, e := range d.emails {
+				if e.Address == filter.Address && visible(e) && d.isMember(filter.OrganizationID, e.UserID) {
+					*emails = append(*emails, e)
+				}
+			}
+			return
+		}
+
+		var rows []row
+		for _, e := range d.emails {
+			if e.UserID == filter.UserID && visible(e) && d.isMember(filter.OrganizationID, e.UserID) {
+				rows = append(rows, row{id: e.ID, created: e.Created})
+			}
+		}
+
+		for _, r := range paginate(rows, seek, filter.Limit) {
+			*emails = append(*emails, d.emails[r.id])
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddJob insert a new job, the IDs are unique
+func (s *Memory) AddJob(ctx context.Context, tx store.Tx, job *entity.Job) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := d.jobs[job.ID]; ok {
+		return errors.ErrAlreadyExists
+	}
+
+	now := store.Now()
+	job.Created = now
+	job.Updated = now
+	d.jobs[job.ID] = *job
+
+	return nil
+}
+
+// FetchJob find a job by ID
+func (s *Memory) FetchJob(ctx context.Context, jobID string, job *entity.Job) error {
+	found := false
+	s.read(func(d *data) {
+		*job, found = d.jobs[jobID]
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// SetJobStatus set the status and the error of a job
+func (s *Memory) SetJobStatus(ctx context.Context, tx store.Tx, jobID, status, errText string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	job, ok := d.jobs[jobID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	job.Status = status
+	job.Error = errText
+	job.Updated = store.Touch(job.Updated)
+	d.jobs[jobID] = job
+	return nil
+}
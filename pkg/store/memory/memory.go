@@ -0,0 +1,305 @@
+// Package memory is a store.Interface kept in memory, meant for development and tests
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store"
+)
+
+// Memory is a thread-safe store kept in memory
+// transactions are serialized, a second Tx waits until the first one is committed or rolled back
+type Memory struct {
+	// mu guards data, the committed state
+	mu   sync.RWMutex
+	data *data
+
+	// write is held by the running transaction
+	write chan struct{}
+}
+
+// New return a new and empty store
+func New() *Memory {
+	s := &Memory{
+		write: make(chan struct{}, 1),
+		data: &data{
+			users:     make(map[int64]entity.User),
+			emails:    make(map[int64]entity.Email),
+			roles:     make(map[string]entity.Role, len(store.DefaultRoles)),
+			userRoles: make(map[int64][]string),
+			refresh:   make(map[int64]entity.RefreshToken),
+			denied:    make(map[string]time.Time),
+			revoked:   make(map[int64]revocation),
+			resets:    make(map[int64]entity.PasswordReset),
+			totp:      make(map[int64]entity.TOTP),
+			recovery:  make(map[int64]entity.RecoveryCode),
+			apiKeys:   make(map[int64]entity.APIKey),
+			orgs:      make(map[int64]entity.Organization),
+			members:   make(map[int64]map[int64]entity.Member),
+			jobs:      make(map[string]entity.Job),
+			// scheduleRuns are indexed by job
+			scheduleRuns: make(map[string]entity.Schedule),
+		},
+	}
+
+	for _, role := range store.DefaultRoles {
+		s.data.roles[role.Name] = role
+	}
+
+	return s
+}
+
+type data struct {
+	users     map[int64]entity.User
+	emails    map[int64]entity.Email
+	roles     map[string]entity.Role
+	userRoles map[int64][]string
+	refresh   map[int64]entity.RefreshToken
+	denied    map[string]time.Time
+	revoked   map[int64]revocation
+	resets    map[int64]entity.PasswordReset
+	totp      map[int64]entity.TOTP
+	recovery  map[int64]entity.RecoveryCode
+	apiKeys   map[int64]entity.APIKey
+	orgs      map[int64]entity.Organization
+	members   map[int64]map[int64]entity.Member
+	jobs      map[string]entity.Job
+	// scheduleRuns are the last runs of the scheduled jobs
+	scheduleRuns map[string]entity.Schedule
+	lastUserID   int64
+	lastEmailID  int64
+	lastTokenID  int64
+	lastResetID  int64
+	// lastRecoveryID is the last ID of a recovery code
+	lastRecoveryID int64
+	lastAPIKeyID   int64
+	lastOrgID      int64
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		users:     make(map[int64]entity.User, len(d.users)),
+		emails:    make(map[int64]entity.Email, len(d.emails)),
+		roles:     make(map[string]entity.Role, len(d.roles)),
+		userRoles: make(map[int64][]string, len(d.userRoles)),
+		refresh:   make(map[int64]entity.RefreshToken, len(d.refresh)),
+		denied:    make(map[string]time.Time, len(d.denied)),
+		revoked:   make(map[int64]revocation, len(d.revoked)),
+		resets:    make(map[int64]entity.PasswordReset, len(d.resets)),
+		totp:      make(map[int64]entity.TOTP, len(d.totp)),
+		recovery:  make(map[int64]entity.RecoveryCode, len(d.recovery)),
+		apiKeys:   make(map[int64]entity.APIKey, len(d.apiKeys)),
+		orgs:      make(map[int64]entity.Organization, len(d.orgs)),
+		members:   make(map[int64]map[int64]entity.Member, len(d.members)),
+		jobs:      make(map[string]entity.Job, len(d.jobs)),
+
+		scheduleRuns: make(map[string]entity.Schedule, len(d.scheduleRuns)),
+		lastUserID:   d.lastUserID,
+		lastEmailID:  d.lastEmailID,
+		lastTokenID:  d.lastTokenID,
+		lastResetID:  d.lastResetID,
+
+		lastRecoveryID: d.lastRecoveryID,
+		lastAPIKeyID:   d.lastAPIKeyID,
+		lastOrgID:      d.lastOrgID,
+	}
+
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+
+	for k, v := range d.emails {
+		c.emails[k] = v
+	}
+
+	// the slices are replaced, never modified
+	for k, v := range d.roles {
+		c.roles[k] = v
+	}
+
+	for k, v := range d.userRoles {
+		c.userRoles[k] = v
+	}
+
+	for k, v := range d.refresh {
+		c.refresh[k] = v
+	}
+
+	for k, v := range d.denied {
+		c.denied[k] = v
+	}
+
+	for k, v := range d.revoked {
+		c.revoked[k] = v
+	}
+
+	for k, v := range d.resets {
+		c.resets[k] = v
+	}
+
+	for k, v := range d.totp {
+		c.totp[k] = v
+	}
+
+	for k, v := range d.recovery {
+		c.recovery[k] = v
+	}
+
+	// the scopes are replaced, never modified
+	for k, v := range d.apiKeys {
+		c.apiKeys[k] = v
+	}
+
+	for k, v := range d.orgs {
+		c.orgs[k] = v
+	}
+
+	// the members are indexed by organization then user, the inner maps are modified
+	for k, v := range d.members {
+		members := make(map[int64]entity.Member, len(v))
+		for u, m := range v {
+			members[u] = m
+		}
+		c.members[k] = members
+	}
+
+	for k, v := range d.jobs {
+		c.jobs[k] = v
+	}
+
+	// the run times are replaced, never modified
+	for k, v := range d.scheduleRuns {
+		c.scheduleRuns[k] = v
+	}
+
+	return c
+}
+
+// Tx start a new transaction working on a copy of the store
+// it fails if ctx is done while waiting the running transaction
+func (s *Memory) Tx(ctx context.Context) (store.Tx, error) {
+	select {
+	case s.write <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &tx{store: s, data: s.data.clone()}, nil
+}
+
+// read run fn with the committed state
+func (s *Memory) read(fn func(*data)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fn(s.data)
+}
+
+type tx struct {
+	store *Memory
+	data  *data
+	done  bool
+
+	// savepoints is a stack of copies of data
+	savepoints []savepoint
+}
+
+type savepoint struct {
+	name string
+	data *data
+}
+
+// Commit replace the committed state by the transaction copy
+func (t *tx) Commit() error {
+	if t.done {
+		return store.ErrTxDone
+	}
+
+	t.store.mu.Lock()
+	t.store.data = t.data
+	t.store.mu.Unlock()
+
+	t.done = true
+	<-t.store.write
+	return nil
+}
+
+// Rollback discard the transaction copy
+func (t *tx) Rollback() error {
+	if t.done {
+		return store.ErrTxDone
+	}
+
+	t.done = true
+	<-t.store.write
+	return nil
+}
+
+// Savepoint keep a copy of the transaction data
+func (t *tx) Savepoint(ctx context.Context, name string) error {
+	if t.done {
+		return store.ErrTxDone
+	}
+
+	t.savepoints = append(t.savepoints, savepoint{name: name, data: t.data.clone()})
+	return nil
+}
+
+// RollbackTo restore the copy kept by the savepoint, the savepoint is kept
+func (t *tx) RollbackTo(ctx context.Context, name string) error {
+	i, err := t.savepoint(name)
+	if err != nil {
+		return err
+	}
+
+	t.data = t.savepoints[i].data.clone()
+	t.savepoints = t.savepoints[:i+1]
+	return nil
+}
+
+// Release forget the savepoint and the ones created after it
+func (t *tx) Release(ctx context.Context, name string) error {
+	i, err := t.savepoint(name)
+	if err != nil {
+		return err
+	}
+
+	t.savepoints = t.savepoints[:i]
+	return nil
+}
+
+// savepoint return the index of the most recent savepoint with name
+func (t *tx) savepoint(name string) (int, error) {
+	if t.done {
+		return 0, store.ErrTxDone
+	}
+
+	for i := len(t.savepoints) - 1; i >= 0; i-- {
+		if t.savepoints[i].name == name {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("savepoint %s not found", name)
+}
+
+// writable return the data of a running transaction of this store
+func (s *Memory) writable(t store.Tx) (*data, error) {
+	mt, ok := t.(*tx)
+	if !ok || mt.store != s {
+		return nil, fmt.Errorf("unsupported transaction %T", t)
+	}
+
+	if mt.done {
+		return nil, store.ErrTxDone
+	}
+
+	return mt.data, nil
+}
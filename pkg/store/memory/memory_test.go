@@ -0,0 +1,132 @@
+package memory_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+	"boiler/pkg/store/memory"
+	"boiler/pkg/store/storetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.RunConformance(t, func() store.Interface {
+		return memory.New()
+	})
+}
+
+func TestTx(t *testing.T) {
+	ctx := context.Background()
+
+	// fails if done
+	{
+		m := memory.New()
+		tx, err := m.Tx(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Commit())
+
+		assert.Equal(t, store.ErrTxDone, tx.Commit())
+		assert.Equal(t, store.ErrTxDone, tx.Rollback())
+		assert.Equal(t, store.ErrTxDone, m.AddUser(ctx, tx, &entity.User{Name: "a"}))
+	}
+
+	// fails if tx is from another store
+	{
+		m := memory.New()
+		other := memory.New()
+		tx, err := other.Tx(ctx)
+		assert.Nil(t, err)
+
+		err = m.AddUser(ctx, tx, &entity.User{Name: "a"})
+		assert.NotNil(t, err)
+		assert.Equal(t, "unsupported transaction *memory.tx", err.Error())
+		assert.Nil(t, tx.Rollback())
+	}
+
+	// uncommitted changes are not visible
+	{
+		m := memory.New()
+		tx, err := m.Tx(ctx)
+		assert.Nil(t, err)
+
+		user := entity.User{Name: "a"}
+		assert.Nil(t, m.AddUser(ctx, tx, &user))
+
+		var users []entity.User
+		assert.Nil(t, m.FetchUsers(ctx, []int64{user.ID}, false, &users))
+		assert.Len(t, users, 0)
+
+		assert.Nil(t, tx.Commit())
+		assert.Nil(t, m.FetchUsers(ctx, []int64{user.ID}, false, &users))
+		assert.Len(t, users, 1)
+	}
+
+	// rollback keeps the committed state
+	{
+		m := memory.New()
+		tx, err := m.Tx(ctx)
+		assert.Nil(t, err)
+		email := entity.Email{UserID: 1, Address: "a@example.com"}
+		assert.Nil(t, m.AddEmail(ctx, tx, &email))
+		assert.Nil(t, tx.Commit())
+
+		tx, err = m.Tx(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, m.DeleteEmail(ctx, tx, email.ID))
+		assert.Nil(t, tx.Rollback())
+
+		var emails []entity.Email
+		assert.Nil(t, m.FilterEmails(ctx, store.FilterEmails{EmailID: email.ID, AllOrganizations: true}, &emails))
+		assert.Len(t, emails, 1)
+	}
+
+	// fails if ctx is done while waiting the running transaction
+	{
+		m := memory.New()
+		tx, err := m.Tx(ctx)
+		assert.Nil(t, err)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err = m.Tx(cctx)
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, tx.Rollback())
+	}
+}
+
+func TestConcurrentTx(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tx, err := m.Tx(ctx)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			assert.Nil(t, m.AddUser(ctx, tx, &entity.User{Name: "a"}))
+			assert.Nil(t, tx.Commit())
+		}()
+	}
+	wg.Wait()
+
+	var IDs []int64
+	assert.Nil(t, m.FilterUsersID(ctx, store.FilterUsers{Limit: 100, AllOrganizations: true}, &IDs))
+	assert.Len(t, IDs, 50)
+
+	tx, err := m.Tx(ctx)
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(m.DeleteUser(ctx, tx, 51), errors.ErrNotFound))
+	assert.Nil(t, tx.Rollback())
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddOrganization insert a new organization
+func (s *Memory) AddOrganization(ctx context.Context, tx store.Tx, org *entity.Organization) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	d.lastOrgID++
+
+	org.ID = d.lastOrgID
+	org.Created = store.Now()
+	d.orgs[org.ID] = *org
+
+	return nil
+}
+
+// FetchOrganization find an organization by ID
+func (s *Memory) FetchOrganization(ctx context.Context, orgID int64, org *entity.Organization) error {
+	found := false
+	s.read(func(d *data) {
+		*org, found = d.orgs[orgID]
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// FilterOrganizations find the organizations of the user
+func (s *Memory) FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error {
+	*orgs = make([]entity.Organization, 0)
+
+	s.read(func(d *data) {
+		for orgID, members := range d.members {
+			if _, ok := members[userID]; ok {
+				*orgs = append(*orgs, d.orgs[orgID])
+			}
+		}
+	})
+
+	sort.Slice(*orgs, func(i, j int) bool { return (*orgs)[i].ID < (*orgs)[j].ID })
+	return nil
+}
+
+// AddMember insert a new member of an organization
+func (s *Memory) AddMember(ctx context.Context, tx store.Tx, member *entity.Member) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	members, ok := d.members[member.OrganizationID]
+	if !ok {
+		members = make(map[int64]entity.Member)
+		d.members[member.OrganizationID] = members
+	}
+
+	if _, ok := members[member.UserID]; ok {
+		return errors.ErrAlreadyExists
+	}
+
+	member.Created = store.Now()
+	members[member.UserID] = *member
+
+	return nil
+}
+
+// FetchMember find the membership of the user in an organization
+func (s *Memory) FetchMember(ctx context.Context, orgID, userID int64, member *entity.Member) error {
+	found := false
+	s.read(func(d *data) {
+		*member, found = d.members[orgID][userID]
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// FilterMembers find the members of an organization
+func (s *Memory) FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error {
+	*members = make([]entity.Member, 0)
+
+	s.read(func(d *data) {
+		for _, m := range d.members[orgID] {
+			*members = append(*members, m)
+		}
+	})
+
+	sort.Slice(*members, func(i, j int) bool { return (*members)[i].UserID < (*members)[j].UserID })
+	return nil
+}
+
+// DeleteMembersByUserID remove the user from its organizations
+func (s *Memory) DeleteMembersByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, members := range d.members {
+		delete(members, userID)
+	}
+
+	return nil
+}
+
+// isMember tells if the user is a member of the organization, every user is a member of the zero organization
+func (d *data) isMember(orgID, userID int64) bool {
+	if orgID == 0 {
+		return true
+	}
+
+	_, ok := d.members[orgID][userID]
+	return ok
+}
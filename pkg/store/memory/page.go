@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"boiler/pkg/store"
+)
+
+// row is the sort key of a stored entity
+type row struct {
+	id      int64
+	created time.Time
+}
+
+// less compare two rows sorted by sortBy
+func less(sortBy store.SortBy, a, b row) bool {
+	if sortBy == store.SortByCreated && !a.created.Equal(b.created) {
+		return a.created.Before(b.created)
+	}
+
+	return a.id < b.id
+}
+
+// paginate sort rows and return the page selected by seek, a zero limit returns every row
+func paginate(rows []row, seek store.Seek, limit uint) []row {
+	sort.Slice(rows, func(i, j int) bool { return less(seek.SortBy, rows[i], rows[j]) })
+
+	if c := seek.Cursor; c != nil {
+		at := row{id: c.ID, created: c.Created}
+
+		// first row after the cursor
+		i := sort.Search(len(rows), func(i int) bool { return less(seek.SortBy, at, rows[i]) })
+		if seek.Backward {
+			// first row not before the cursor
+			i = sort.Search(len(rows), func(i int) bool { return !less(seek.SortBy, rows[i], at) })
+			rows = rows[:i]
+		} else {
+			rows = rows[i:]
+		}
+	}
+
+	if limit > 0 && uint(len(rows)) > limit {
+		if seek.Backward {
+			return rows[uint(len(rows))-limit:]
+		}
+		return rows[:limit]
+	}
+
+	return rows
+}
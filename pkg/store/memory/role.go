@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// FetchRoles find roles by name
+func (s *Memory) FetchRoles(ctx context.Context, names []string, roles *[]entity.Role) error {
+	s.read(func(d *data) {
+		*roles = make([]entity.Role, 0, len(d.roles))
+
+		if len(names) == 0 {
+			for _, role := range d.roles {
+				*roles = append(*roles, role)
+			}
+		} else {
+			seen := make(map[string]struct{}, len(names))
+			for _, name := range names {
+				if _, ok := seen[name]; ok {
+					continue
+				}
+				seen[name] = struct{}{}
+
+				if role, ok := d.roles[name]; ok {
+					*roles = append(*roles, role)
+				}
+			}
+		}
+	})
+
+	sort.Slice(*roles, func(i, j int) bool { return (*roles)[i].Name < (*roles)[j].Name })
+	return nil
+}
+
+// SetUserRoles replace the roles of an user
+func (s *Memory) SetUserRoles(ctx context.Context, tx store.Tx, userID int64, roles []string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]struct{}, len(roles))
+	unique := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if _, ok := d.roles[role]; !ok {
+			return errors.ErrInvalidRole
+		}
+		if _, ok := set[role]; ok {
+			continue
+		}
+		set[role] = struct{}{}
+		unique = append(unique, role)
+	}
+
+	if len(unique) == 0 {
+		delete(d.userRoles, userID)
+		return nil
+	}
+
+	sort.Strings(unique)
+	d.userRoles[userID] = unique
+	return nil
+}
+
+// FetchUserRoles find the roles of an user
+func (s *Memory) FetchUserRoles(ctx context.Context, userID int64, roles *[]string) error {
+	s.read(func(d *data) {
+		*roles = append(make([]string, 0, len(d.userRoles[userID])), d.userRoles[userID]...)
+	})
+
+	return nil
+}
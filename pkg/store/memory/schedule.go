@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/store"
+)
+
+// SetScheduleRun insert or replace the last run of a scheduled job
+func (s *Memory) SetScheduleRun(ctx context.Context, tx store.Tx, job string, at time.Time, status string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	at = at.UTC()
+	d.scheduleRuns[job] = entity.Schedule{Job: job, LastRun: &at, LastStatus: status}
+	return nil
+}
+
+// FetchScheduleRuns find the last runs of the scheduled jobs
+func (s *Memory) FetchScheduleRuns(ctx context.Context, schedules *[]entity.Schedule) error {
+	s.read(func(d *data) {
+		*schedules = make([]entity.Schedule, 0, len(d.scheduleRuns))
+		for _, run := range d.scheduleRuns {
+			*schedules = append(*schedules, run)
+		}
+	})
+
+	sort.Slice(*schedules, func(i, j int) bool { return (*schedules)[i].Job < (*schedules)[j].Job })
+	return nil
+}
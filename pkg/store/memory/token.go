@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddRefreshToken insert a new refresh token, hashes are unique
+func (s *Memory) AddRefreshToken(ctx context.Context, tx store.Tx, token *entity.RefreshToken) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range d.refresh {
+		if t.Hash == token.Hash {
+			return errors.ErrAlreadyExists
+		}
+	}
+
+	d.lastTokenID++
+
+	token.ID = d.lastTokenID
+	token.Created = store.Now()
+	token.Expires = token.Expires.UTC()
+	d.refresh[token.ID] = *token
+
+	return nil
+}
+
+// FetchRefreshToken find a refresh token by hash
+func (s *Memory) FetchRefreshToken(ctx context.Context, hash string, token *entity.RefreshToken) error {
+	found := false
+	s.read(func(d *data) {
+		for _, t := range d.refresh {
+			if t.Hash == hash {
+				*token = t
+				found = true
+				return
+			}
+		}
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// UseRefreshToken mark a refresh token as used
+func (s *Memory) UseRefreshToken(ctx context.Context, tx store.Tx, tokenID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	token, ok := d.refresh[tokenID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	if token.Used {
+		return errors.ErrUpdateConflict
+	}
+
+	token.Used = true
+	d.refresh[tokenID] = token
+	return nil
+}
+
+// RevokeRefreshFamily revoke all the refresh tokens of a family
+func (s *Memory) RevokeRefreshFamily(ctx context.Context, tx store.Tx, family string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, token := range d.refresh {
+		if token.Family == family {
+			token.Revoked = true
+			d.refresh[id] = token
+		}
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revoke all the refresh tokens of the user
+func (s *Memory) RevokeUserRefreshTokens(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, token := range d.refresh {
+		if token.UserID == userID {
+			token.Revoked = true
+			d.refresh[id] = token
+		}
+	}
+
+	return nil
+}
+
+// DenyToken add an access token to the deny list
+func (s *Memory) DenyToken(ctx context.Context, tx store.Tx, jti string, expires time.Time) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := d.denied[jti]; !ok {
+		d.denied[jti] = expires.UTC()
+	}
+
+	return nil
+}
+
+// IsTokenDenied tells if an access token is in the deny list
+func (s *Memory) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	denied := false
+	s.read(func(d *data) {
+		_, denied = d.denied[jti]
+	})
+
+	return denied, nil
+}
+
+// revocation is the revocation of the access tokens of a user
+type revocation struct {
+	issuedBefore time.Time
+	expires      time.Time
+}
+
+// RevokeAccessTokens replace the revocation of the access tokens of the user
+func (s *Memory) RevokeAccessTokens(ctx context.Context, tx store.Tx, userID int64, before, expires time.Time) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	d.revoked[userID] = revocation{issuedBefore: before.UTC(), expires: expires.UTC()}
+	return nil
+}
+
+// IsTokenRevoked tells if an access token of the user issued at a time is revoked
+func (s *Memory) IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	revoked := false
+	s.read(func(d *data) {
+		r, ok := d.revoked[userID]
+		revoked = ok && r.issuedBefore.After(issuedAt)
+	})
+
+	return revoked, nil
+}
+
+// PurgeExpiredTokens remove the refresh tokens, the denied and revoked tokens and the password resets expired
+// before a time
+func (s *Memory) PurgeExpiredTokens(ctx context.Context, tx store.Tx, before time.Time) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, token := range d.refresh {
+		if token.Expires.Before(before) {
+			delete(d.refresh, id)
+		}
+	}
+
+	for jti, expires := range d.denied {
+		if expires.Before(before) {
+			delete(d.denied, jti)
+		}
+	}
+
+	for userID, r := range d.revoked {
+		if r.expires.Before(before) {
+			delete(d.revoked, userID)
+		}
+	}
+
+	for id, reset := range d.resets {
+		if reset.Expires.Before(before) {
+			delete(d.resets, id)
+		}
+	}
+
+	return nil
+}
+
+// AddPasswordReset insert a new password reset, hashes are unique
+func (s *Memory) AddPasswordReset(ctx context.Context, tx store.Tx, reset *entity.PasswordReset) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range d.resets {
+		if r.Hash == reset.Hash {
+			return errors.ErrAlreadyExists
+		}
+	}
+
+	d.lastResetID++
+
+	reset.ID = d.lastResetID
+	reset.Created = store.Now()
+	reset.Expires = reset.Expires.UTC()
+	d.resets[reset.ID] = *reset
+
+	return nil
+}
+
+// FetchPasswordReset find a password reset by hash
+func (s *Memory) FetchPasswordReset(ctx context.Context, hash string, reset *entity.PasswordReset) error {
+	found := false
+	s.read(func(d *data) {
+		for _, r := range d.resets {
+			if r.Hash == hash {
+				*reset = r
+				found = true
+				return
+			}
+		}
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// UsePasswordReset mark a password reset as used
+func (s *Memory) UsePasswordReset(ctx context.Context, tx store.Tx, resetID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	reset, ok := d.resets[resetID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	if reset.Used {
+		return errors.ErrUpdateConflict
+	}
+
+	reset.Used = true
+	d.resets[resetID] = reset
+	return nil
+}
@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// SetTOTP replace the unconfirmed TOTP of the user
+func (s *Memory) SetTOTP(ctx context.Context, tx store.Tx, totp *entity.TOTP) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	if t, ok := d.totp[totp.UserID]; ok && t.Confirmed {
+		return errors.ErrUpdateConflict
+	}
+
+	totp.Confirmed = false
+	totp.Counter = 0
+	totp.Created = store.Now()
+	d.totp[totp.UserID] = *totp
+
+	return nil
+}
+
+// FetchTOTP find the TOTP of the user
+func (s *Memory) FetchTOTP(ctx context.Context, userID int64, totp *entity.TOTP) error {
+	found := false
+	s.read(func(d *data) {
+		*totp, found = d.totp[userID]
+	})
+
+	if !found {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// ConfirmTOTP confirm the TOTP of the user
+func (s *Memory) ConfirmTOTP(ctx context.Context, tx store.Tx, userID, counter int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	totp, ok := d.totp[userID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	if totp.Confirmed {
+		return errors.ErrUpdateConflict
+	}
+
+	totp.Confirmed = true
+	totp.Counter = counter
+	d.totp[userID] = totp
+	return nil
+}
+
+// UseTOTP record the step of a used code
+func (s *Memory) UseTOTP(ctx context.Context, tx store.Tx, userID, counter int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	totp, ok := d.totp[userID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	if totp.Counter >= counter {
+		return errors.ErrUpdateConflict
+	}
+
+	totp.Counter = counter
+	d.totp[userID] = totp
+	return nil
+}
+
+// DeleteTOTP remove the TOTP and the recovery codes of the user
+func (s *Memory) DeleteTOTP(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	delete(d.totp, userID)
+	for id, code := range d.recovery {
+		if code.UserID == userID {
+			delete(d.recovery, id)
+		}
+	}
+
+	return nil
+}
+
+// SetRecoveryCodes replace the recovery codes of the user
+func (s *Memory) SetRecoveryCodes(ctx context.Context, tx store.Tx, userID int64, hashes []string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, code := range d.recovery {
+		if code.UserID == userID {
+			delete(d.recovery, id)
+		}
+	}
+
+	now := store.Now()
+	for _, hash := range hashes {
+		d.lastRecoveryID++
+		d.recovery[d.lastRecoveryID] = entity.RecoveryCode{
+			ID:      d.lastRecoveryID,
+			UserID:  userID,
+			Hash:    hash,
+			Created: now,
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode mark the recovery code of the user as used
+func (s *Memory) UseRecoveryCode(ctx context.Context, tx store.Tx, userID int64, hash string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	for id, code := range d.recovery {
+		if code.UserID == userID && code.Hash == hash && !code.Used {
+			code.Used = true
+			d.recovery[id] = code
+			return nil
+		}
+	}
+
+	return errors.ErrNotFound
+}
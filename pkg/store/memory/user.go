@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+)
+
+// AddUser create a new user
+func (s *Memory) AddUser(ctx context.Context, tx store.Tx, user *entity.User) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	now := store.Now()
+	d.lastUserID++
+
+	user.ID = d.lastUserID
+	user.Created = now
+	user.Updated = now
+	d.users[user.ID] = *user
+
+	return nil
+}
+
+// UpdateUser update the profile of an user
+func (s *Memory) UpdateUser(ctx context.Context, tx store.Tx, user *entity.User) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	u, ok := d.users[user.ID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	if !u.Updated.Equal(user.Updated) {
+		return errors.ErrUpdateConflict
+	}
+
+	u.Name = user.Name
+	u.Updated = store.Touch(u.Updated)
+	d.users[u.ID] = u
+
+	user.Updated = u.Updated
+	return nil
+}
+
+// UpdateUserPassword replace the password of an user
+func (s *Memory) UpdateUserPassword(ctx context.Context, tx store.Tx, userID int64, password string) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	u, ok := d.users[userID]
+	if !ok {
+		return errors.ErrNotFound
+	}
+
+	u.Password = password
+	u.Updated = store.Touch(u.Updated)
+	d.users[u.ID] = u
+
+	return nil
+}
+
+// DeleteUser mark an user deleted
+func (s *Memory) DeleteUser(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	u, ok := d.users[userID]
+	if !ok || u.DeletedAt != nil {
+		return errors.ErrNotFound
+	}
+
+	now := store.Now()
+	u.DeletedAt = &now
+	d.users[userID] = u
+	return nil
+}
+
+// RestoreUser undelete an user and the emails deleted since it was
+func (s *Memory) RestoreUser(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	u, ok := d.users[userID]
+	if !ok || u.DeletedAt == nil {
+		return errors.ErrNotFound
+	}
+
+	for id, e := range d.emails {
+		if e.UserID == userID && e.DeletedAt != nil && !e.DeletedAt.Before(*u.DeletedAt) && !d.addressTaken(e.Address) {
+			e.DeletedAt = nil
+			d.emails[id] = e
+		}
+	}
+
+	u.DeletedAt = nil
+	d.users[userID] = u
+	return nil
+}
+
+// PurgeUser remove an user and its emails
+func (s *Memory) PurgeUser(ctx context.Context, tx store.Tx, userID int64) error {
+	d, err := s.writable(tx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := d.users[userID]; !ok {
+		return errors.ErrNotFound
+	}
+
+	for id, e := range d.emails {
+		if e.UserID == userID {
+			delete(d.emails, id)
+		}
+	}
+
+	delete(d.users, userID)
+	return nil
+}
+
+// FilterUsersID retrieve usersID for a given filter
+func (s *Memory) FilterUsersID(ctx context.Context, filter store.FilterUsers, IDs *[]int64) error {
+	if err := filter.CheckOrganization(); err != nil {
+		return err
+	}
+
+	seek, err := filter.Seek()
+	if err != nil {
+		return err
+	}
+
+	*IDs = make([]int64, 0)
+
+	s.read(func(d *data) {
+		if len(filter.Email) != 0 {
+			for _, e := range d.emails {
+				u, ok := d.users[e.UserID]
+				if ok && e.Address == filter.Email && d.isMember(filter.OrganizationID, e.UserID) &&
+					(filter.IncludeDeleted || (u.DeletedAt == nil && e.DeletedAt == nil)) {
+					*IDs = append(*IDs, e.UserID)
+				}
+			}
+			return
+		}
+
+		if filter.Limit == 0 {
+			return
+		}
+
+		rows := make([]row, 0, len(d.users))
+		for _, u := range d.users {
+			if d.isMember(filter.OrganizationID, u.ID) && (filter.IncludeDeleted || u.DeletedAt == nil) {
+				rows = append(rows, row{id: u.ID, created: u.Created})
+			}
+		}
+
+		for _, r := range paginate(rows, seek, filter.Limit) {
+			*IDs = append(*IDs, r.id)
+		}
+	})
+
+	return nil
+}
+
+// FilterDeletedUsersID retrieve the IDs of users deleted before a time
+func (s *Memory) FilterDeletedUsersID(ctx context.Context, before time.Time, limit uint, IDs *[]int64) error {
+	*IDs = make([]int64, 0)
+
+	s.read(func(d *data) {
+		for _, u := range d.users {
+			if u.DeletedAt != nil && u.DeletedAt.Before(before) {
+				*IDs = append(*IDs, u.ID)
+			}
+		}
+	})
+
+	sort.Slice(*IDs, func(i, j int) bool { return (*IDs)[i] < (*IDs)[j] })
+	if uint(len(*IDs)) > limit {
+		*IDs = (*IDs)[:limit]
+	}
+
+	return nil
+}
+
+// FetchUsers retrieve users
+func (s *Memory) FetchUsers(ctx context.Context, IDs []int64, includeDeleted bool, users *[]entity.User) error {
+	if len(IDs) == 0 {
+		return nil
+	}
+
+	*users = make([]entity.User, 0, len(IDs))
+
+	s.read(func(d *data) {
+		seen := make(map[int64]struct{}, len(IDs))
+		for _, id := range IDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+
+			if u, ok := d.users[id]; ok && (includeDeleted || u.DeletedAt == nil) {
+				*users = append(*users, u)
+			}
+		}
+	})
+
+	sort.Slice(*users, func(i, j int) bool { return (*users)[i].ID < (*users)[j].ID })
+
+	return nil
+}
@@ -0,0 +1,351 @@
+// Package migration apply versioned schema changes to a database
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"boiler/pkg/errors"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration was changed after being applied
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownVersion is returned when the database has a version that is not in the source
+	ErrUnknownVersion = errors.New("unknown migration version")
+	// ErrLocked is returned when the lock could not be acquired in time
+	ErrLocked = errors.New("migrations locked")
+)
+
+var filename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status is the state of a migration in the database
+type Status struct {
+	Migration
+	Applied time.Time
+}
+
+// IsApplied returns true if the migration was applied
+func (s *Status) IsApplied() bool {
+	return !s.Applied.IsZero()
+}
+
+// Migrator apply migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// LockTimeout is how long to wait for another process to release the lock
+	LockTimeout time.Duration
+	// LockStaleAfter is how old a lock must be to be considered abandoned
+	LockStaleAfter time.Duration
+	// Rebind replace the ? placeholders of the bookkeeping queries, e.g. by $1 on postgres
+	Rebind func(query string) string
+}
+
+// New return a new Migrator with the migrations found in source
+func New(db *sql.DB, source fs.FS) (*Migrator, error) {
+	migrations, err := Load(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:             db,
+		migrations:     migrations,
+		LockTimeout:    time.Minute,
+		LockStaleAfter: 10 * time.Minute,
+		Rebind:         func(query string) string { return query },
+	}, nil
+}
+
+// Load read the migrations from source sorted by version
+func Load(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations; %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := filename.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version %q", entry.Name())
+		}
+
+		content, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %q; %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("duplicated migration version %d", version)
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.Checksum) == 0 {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up apply all pending migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := m.run(ctx, migration.Up,
+				"INSERT INTO schema_migrations (version, name, checksum, applied) VALUES (?, ?, ?, ?)",
+				migration.Version, migration.Name, migration.Checksum, time.Now(),
+			)
+			if err != nil {
+				return fmt.Errorf("could not apply migration %d_%s; %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down revert the last applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			err := m.run(ctx, migration.Down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				return fmt.Errorf("could not revert migration %d_%s; %w", migration.Version, migration.Name, err)
+			}
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status retrieve the state of each migration
+func (m *Migrator) Status(ctx context.Context, status *[]Status) error {
+	if err := m.init(ctx); err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	*status = make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		*status = append(*status, Status{Migration: migration, Applied: applied[migration.Version]})
+	}
+
+	return nil
+}
+
+// Create write a new pair of empty up and down migrations into dir
+func Create(dir, name string) (string, string, error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return "", "", fmt.Errorf("invalid migration name %q", name)
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, strings.ToLower(name)))
+	up, down := base+".up.sql", base+".down.sql"
+
+	for _, path := range []string{up, down} {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			return "", "", fmt.Errorf("could not create migration; %w", err)
+		}
+	}
+
+	return up, down, nil
+}
+
+func (m *Migrator) init(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS schema_migrations ("+
+			"version INTEGER PRIMARY KEY, name TEXT NOT NULL, checksum TEXT NOT NULL, applied TIMESTAMP NOT NULL)",
+	)
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations; %w", err)
+	}
+
+	_, err = m.db.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS schema_migrations_lock (id INTEGER PRIMARY KEY, locked TIMESTAMP NOT NULL)",
+	)
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations_lock; %w", err)
+	}
+
+	return nil
+}
+
+// applied return the applied versions and verify their checksums
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT version, checksum, applied FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch applied migrations; %w", err)
+	}
+	defer rows.Close()
+
+	checksums := make(map[int64]string, len(m.migrations))
+	for _, migration := range m.migrations {
+		checksums[migration.Version] = migration.Checksum
+	}
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var checksum string
+		var at time.Time
+		if err := rows.Scan(&version, &checksum, &at); err != nil {
+			return nil, fmt.Errorf("could not scan migration; %w", err)
+		}
+
+		expected, ok := checksums[version]
+		if !ok {
+			return nil, fmt.Errorf("%w %d", ErrUnknownVersion, version)
+		}
+
+		if expected != checksum {
+			return nil, fmt.Errorf("%w on version %d", ErrChecksumMismatch, version)
+		}
+
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// run execute the migration script and the bookkeeping statement in a single transaction
+func (m *Migrator) run(ctx context.Context, script, query string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction; %w", err)
+	}
+
+	if strings.TrimSpace(script) != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			if er := tx.Rollback(); er != nil {
+				err = fmt.Errorf("%s; %w", er, err)
+			}
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, m.Rebind(query), args...); err != nil {
+		if er := tx.Rollback(); er != nil {
+			err = fmt.Errorf("%s; %w", er, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// locked run fn holding the migrations lock, so concurrent processes don't apply the same migration twice
+func (m *Migrator) locked(ctx context.Context, fn func() error) error {
+	if err := m.init(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.LockTimeout)
+	defer cancel()
+
+	for {
+		_, err := m.db.ExecContext(ctx,
+			m.Rebind("INSERT INTO schema_migrations_lock (id, locked) VALUES (1, ?)"), time.Now())
+		if err == nil {
+			break
+		}
+
+		// remove locks left behind by a process that died while migrating
+		_, _ = m.db.ExecContext(ctx,
+			m.Rebind("DELETE FROM schema_migrations_lock WHERE id = 1 AND locked < ?"),
+			time.Now().Add(-m.LockStaleAfter),
+		)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w; %s", ErrLocked, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	defer func() {
+		_, _ = m.db.Exec("DELETE FROM schema_migrations_lock WHERE id = 1")
+	}()
+
+	return fn()
+}
@@ -0,0 +1,231 @@
+package migration_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"boiler/pkg/errors"
+	"boiler/pkg/store/migration"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func source() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER PRIMARY KEY);")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY);")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"README.md":              {Data: []byte("ignored")},
+	}
+}
+
+func tableExists(db *sql.DB, name string) bool {
+	var n int
+	_ = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	return n == 1
+}
+
+func TestLoad(t *testing.T) {
+	// succeed
+	{
+		migrations, err := migration.Load(source())
+		assert.Nil(t, err)
+		assert.Len(t, migrations, 2)
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "create_a", migrations[0].Name)
+		assert.Equal(t, "DROP TABLE a;", migrations[0].Down)
+		assert.Len(t, migrations[0].Checksum, 64)
+		assert.Equal(t, int64(2), migrations[1].Version)
+	}
+
+	// fails if up is missing
+	{
+		_, err := migration.Load(fstest.MapFS{"0001_a.down.sql": {Data: []byte("")}})
+		assert.NotNil(t, err)
+		assert.Equal(t, "migration 1_a has no up file", err.Error())
+	}
+
+	// fails if version is duplicated
+	{
+		_, err := migration.Load(fstest.MapFS{
+			"0001_a.up.sql": {Data: []byte("")},
+			"0001_b.up.sql": {Data: []byte("")},
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, "duplicated migration version 1", err.Error())
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	defer db.Close()
+
+	m, err := migration.New(db, source())
+	assert.Nil(t, err)
+
+	// up
+	{
+		assert.Nil(t, m.Up(ctx))
+		assert.True(t, tableExists(db, "a"))
+		assert.True(t, tableExists(db, "b"))
+
+		var status []migration.Status
+		assert.Nil(t, m.Status(ctx, &status))
+		assert.Len(t, status, 2)
+		assert.True(t, status[0].IsApplied())
+		assert.True(t, status[1].IsApplied())
+	}
+
+	// up is idempotent
+	{
+		assert.Nil(t, m.Up(ctx))
+	}
+
+	// down
+	{
+		assert.Nil(t, m.Down(ctx, 1))
+		assert.True(t, tableExists(db, "a"))
+		assert.False(t, tableExists(db, "b"))
+
+		var status []migration.Status
+		assert.Nil(t, m.Status(ctx, &status))
+		assert.True(t, status[0].IsApplied())
+		assert.False(t, status[1].IsApplied())
+	}
+
+	// down more steps than applied
+	{
+		assert.Nil(t, m.Down(ctx, 5))
+		assert.False(t, tableExists(db, "a"))
+	}
+}
+
+func TestUpFails(t *testing.T) {
+	ctx := context.Background()
+
+	// rollback failed migration
+	{
+		db := newDB(t)
+		defer db.Close()
+
+		src := source()
+		src["0002_create_b.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY); opz;")}
+
+		m, err := migration.New(db, src)
+		assert.Nil(t, err)
+
+		err = m.Up(ctx)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "could not apply migration 2_create_b")
+		assert.True(t, tableExists(db, "a"))
+		assert.False(t, tableExists(db, "b"))
+
+		var status []migration.Status
+		assert.Nil(t, m.Status(ctx, &status))
+		assert.True(t, status[0].IsApplied())
+		assert.False(t, status[1].IsApplied())
+	}
+
+	// checksum mismatch
+	{
+		db := newDB(t)
+		defer db.Close()
+
+		m, err := migration.New(db, source())
+		assert.Nil(t, err)
+		assert.Nil(t, m.Up(ctx))
+
+		src := source()
+		src["0001_create_a.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE a (id INTEGER);")}
+		m, err = migration.New(db, src)
+		assert.Nil(t, err)
+
+		err = m.Up(ctx)
+		assert.True(t, errors.Is(err, migration.ErrChecksumMismatch))
+	}
+
+	// unknown version
+	{
+		db := newDB(t)
+		defer db.Close()
+
+		m, err := migration.New(db, source())
+		assert.Nil(t, err)
+		assert.Nil(t, m.Up(ctx))
+
+		src := source()
+		delete(src, "0002_create_b.up.sql")
+		delete(src, "0002_create_b.down.sql")
+		m, err = migration.New(db, src)
+		assert.Nil(t, err)
+
+		err = m.Up(ctx)
+		assert.True(t, errors.Is(err, migration.ErrUnknownVersion))
+	}
+
+	// locked
+	{
+		db := newDB(t)
+		defer db.Close()
+
+		m, err := migration.New(db, source())
+		assert.Nil(t, err)
+		assert.Nil(t, m.Up(ctx))
+
+		_, err = db.Exec("INSERT INTO schema_migrations_lock (id, locked) VALUES (1, ?)", time.Now())
+		assert.Nil(t, err)
+
+		m.LockTimeout = 200 * time.Millisecond
+		err = m.Up(ctx)
+		assert.True(t, errors.Is(err, migration.ErrLocked))
+
+		// stale lock is released
+		m.LockStaleAfter = time.Millisecond
+		assert.Nil(t, m.Up(ctx))
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	// first
+	{
+		up, down, err := migration.Create(dir, "create_users")
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.Join(dir, "0001_create_users.up.sql"), up)
+		assert.Equal(t, filepath.Join(dir, "0001_create_users.down.sql"), down)
+
+		_, err = os.Stat(up)
+		assert.Nil(t, err)
+	}
+
+	// next version
+	{
+		up, _, err := migration.Create(dir, "add_phone")
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.Join(dir, "0002_add_phone.up.sql"), up)
+	}
+
+	// invalid name
+	{
+		_, _, err := migration.Create(dir, "add phone")
+		assert.NotNil(t, err)
+	}
+}
@@ -8,12 +8,105 @@ import (
 	entity "boiler/pkg/entity"
 	store "boiler/pkg/store"
 	context "context"
-	sql "database/sql"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
 
+// MockTx is a mock of Tx interface.
+type MockTx struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxMockRecorder
+}
+
+// MockTxMockRecorder is the mock recorder for MockTx.
+type MockTxMockRecorder struct {
+	mock *MockTx
+}
+
+// NewMockTx creates a new mock instance.
+func NewMockTx(ctrl *gomock.Controller) *MockTx {
+	mock := &MockTx{ctrl: ctrl}
+	mock.recorder = &MockTxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTx) EXPECT() *MockTxMockRecorder {
+	return m.recorder
+}
+
+// Commit mocks base method.
+func (m *MockTx) Commit() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockTxMockRecorder) Commit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockTx)(nil).Commit))
+}
+
+// Release mocks base method.
+func (m *MockTx) Release(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockTxMockRecorder) Release(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockTx)(nil).Release), ctx, name)
+}
+
+// Rollback mocks base method.
+func (m *MockTx) Rollback() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rollback indicates an expected call of Rollback.
+func (mr *MockTxMockRecorder) Rollback() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockTx)(nil).Rollback))
+}
+
+// RollbackTo mocks base method.
+func (m *MockTx) RollbackTo(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollbackTo", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollbackTo indicates an expected call of RollbackTo.
+func (mr *MockTxMockRecorder) RollbackTo(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackTo", reflect.TypeOf((*MockTx)(nil).RollbackTo), ctx, name)
+}
+
+// Savepoint mocks base method.
+func (m *MockTx) Savepoint(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Savepoint", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Savepoint indicates an expected call of Savepoint.
+func (mr *MockTxMockRecorder) Savepoint(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Savepoint", reflect.TypeOf((*MockTx)(nil).Savepoint), ctx, name)
+}
+
 // MockInterface is a mock of Interface interface.
 type MockInterface struct {
 	ctrl     *gomock.Controller
@@ -37,8 +130,22 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// AddAPIKey mocks base method.
+func (m *MockInterface) AddAPIKey(ctx context.Context, tx store.Tx, key *entity.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAPIKey", ctx, tx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAPIKey indicates an expected call of AddAPIKey.
+func (mr *MockInterfaceMockRecorder) AddAPIKey(ctx, tx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAPIKey", reflect.TypeOf((*MockInterface)(nil).AddAPIKey), ctx, tx, key)
+}
+
 // AddEmail mocks base method.
-func (m *MockInterface) AddEmail(ctx context.Context, tx *sql.Tx, email *entity.Email) error {
+func (m *MockInterface) AddEmail(ctx context.Context, tx store.Tx, email *entity.Email) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddEmail", ctx, tx, email)
 	ret0, _ := ret[0].(error)
@@ -51,8 +158,78 @@ func (mr *MockInterfaceMockRecorder) AddEmail(ctx, tx, email interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEmail", reflect.TypeOf((*MockInterface)(nil).AddEmail), ctx, tx, email)
 }
 
+// AddJob mocks base method.
+func (m *MockInterface) AddJob(ctx context.Context, tx store.Tx, job *entity.Job) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddJob", ctx, tx, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddJob indicates an expected call of AddJob.
+func (mr *MockInterfaceMockRecorder) AddJob(ctx, tx, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddJob", reflect.TypeOf((*MockInterface)(nil).AddJob), ctx, tx, job)
+}
+
+// AddMember mocks base method.
+func (m *MockInterface) AddMember(ctx context.Context, tx store.Tx, member *entity.Member) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", ctx, tx, member)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember.
+func (mr *MockInterfaceMockRecorder) AddMember(ctx, tx, member interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockInterface)(nil).AddMember), ctx, tx, member)
+}
+
+// AddOrganization mocks base method.
+func (m *MockInterface) AddOrganization(ctx context.Context, tx store.Tx, org *entity.Organization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrganization", ctx, tx, org)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOrganization indicates an expected call of AddOrganization.
+func (mr *MockInterfaceMockRecorder) AddOrganization(ctx, tx, org interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrganization", reflect.TypeOf((*MockInterface)(nil).AddOrganization), ctx, tx, org)
+}
+
+// AddPasswordReset mocks base method.
+func (m *MockInterface) AddPasswordReset(ctx context.Context, tx store.Tx, reset *entity.PasswordReset) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPasswordReset", ctx, tx, reset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddPasswordReset indicates an expected call of AddPasswordReset.
+func (mr *MockInterfaceMockRecorder) AddPasswordReset(ctx, tx, reset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPasswordReset", reflect.TypeOf((*MockInterface)(nil).AddPasswordReset), ctx, tx, reset)
+}
+
+// AddRefreshToken mocks base method.
+func (m *MockInterface) AddRefreshToken(ctx context.Context, tx store.Tx, token *entity.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRefreshToken", ctx, tx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRefreshToken indicates an expected call of AddRefreshToken.
+func (mr *MockInterfaceMockRecorder) AddRefreshToken(ctx, tx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRefreshToken", reflect.TypeOf((*MockInterface)(nil).AddRefreshToken), ctx, tx, token)
+}
+
 // AddUser mocks base method.
-func (m *MockInterface) AddUser(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+func (m *MockInterface) AddUser(ctx context.Context, tx store.Tx, user *entity.User) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddUser", ctx, tx, user)
 	ret0, _ := ret[0].(error)
@@ -65,8 +242,36 @@ func (mr *MockInterfaceMockRecorder) AddUser(ctx, tx, user interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockInterface)(nil).AddUser), ctx, tx, user)
 }
 
+// ConfirmTOTP mocks base method.
+func (m *MockInterface) ConfirmTOTP(ctx context.Context, tx store.Tx, userID, counter int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, tx, userID, counter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockInterfaceMockRecorder) ConfirmTOTP(ctx, tx, userID, counter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockInterface)(nil).ConfirmTOTP), ctx, tx, userID, counter)
+}
+
+// DeleteAPIKeysByUserID mocks base method.
+func (m *MockInterface) DeleteAPIKeysByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKeysByUserID", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKeysByUserID indicates an expected call of DeleteAPIKeysByUserID.
+func (mr *MockInterfaceMockRecorder) DeleteAPIKeysByUserID(ctx, tx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKeysByUserID", reflect.TypeOf((*MockInterface)(nil).DeleteAPIKeysByUserID), ctx, tx, userID)
+}
+
 // DeleteEmail mocks base method.
-func (m *MockInterface) DeleteEmail(ctx context.Context, tx *sql.Tx, email int64) error {
+func (m *MockInterface) DeleteEmail(ctx context.Context, tx store.Tx, email int64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DeleteEmail", ctx, tx, email)
 	ret0, _ := ret[0].(error)
@@ -80,7 +285,7 @@ func (mr *MockInterfaceMockRecorder) DeleteEmail(ctx, tx, email interface{}) *go
 }
 
 // DeleteEmailsByUserID mocks base method.
-func (m *MockInterface) DeleteEmailsByUserID(ctx context.Context, tx *sql.Tx, userID int64) error {
+func (m *MockInterface) DeleteEmailsByUserID(ctx context.Context, tx store.Tx, userID int64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DeleteEmailsByUserID", ctx, tx, userID)
 	ret0, _ := ret[0].(error)
@@ -93,8 +298,36 @@ func (mr *MockInterfaceMockRecorder) DeleteEmailsByUserID(ctx, tx, userID interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEmailsByUserID", reflect.TypeOf((*MockInterface)(nil).DeleteEmailsByUserID), ctx, tx, userID)
 }
 
+// DeleteMembersByUserID mocks base method.
+func (m *MockInterface) DeleteMembersByUserID(ctx context.Context, tx store.Tx, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMembersByUserID", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMembersByUserID indicates an expected call of DeleteMembersByUserID.
+func (mr *MockInterfaceMockRecorder) DeleteMembersByUserID(ctx, tx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMembersByUserID", reflect.TypeOf((*MockInterface)(nil).DeleteMembersByUserID), ctx, tx, userID)
+}
+
+// DeleteTOTP mocks base method.
+func (m *MockInterface) DeleteTOTP(ctx context.Context, tx store.Tx, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTP", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTP indicates an expected call of DeleteTOTP.
+func (mr *MockInterfaceMockRecorder) DeleteTOTP(ctx, tx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTP", reflect.TypeOf((*MockInterface)(nil).DeleteTOTP), ctx, tx, userID)
+}
+
 // DeleteUser mocks base method.
-func (m *MockInterface) DeleteUser(ctx context.Context, tx *sql.Tx, userID int64) error {
+func (m *MockInterface) DeleteUser(ctx context.Context, tx store.Tx, userID int64) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DeleteUser", ctx, tx, userID)
 	ret0, _ := ret[0].(error)
@@ -107,18 +340,200 @@ func (mr *MockInterfaceMockRecorder) DeleteUser(ctx, tx, userID interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockInterface)(nil).DeleteUser), ctx, tx, userID)
 }
 
+// DenyToken mocks base method.
+func (m *MockInterface) DenyToken(ctx context.Context, tx store.Tx, jti string, expires time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DenyToken", ctx, tx, jti, expires)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DenyToken indicates an expected call of DenyToken.
+func (mr *MockInterfaceMockRecorder) DenyToken(ctx, tx, jti, expires interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DenyToken", reflect.TypeOf((*MockInterface)(nil).DenyToken), ctx, tx, jti, expires)
+}
+
+// FetchAPIKey mocks base method.
+func (m *MockInterface) FetchAPIKey(ctx context.Context, hash string, key *entity.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchAPIKey", ctx, hash, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchAPIKey indicates an expected call of FetchAPIKey.
+func (mr *MockInterfaceMockRecorder) FetchAPIKey(ctx, hash, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchAPIKey", reflect.TypeOf((*MockInterface)(nil).FetchAPIKey), ctx, hash, key)
+}
+
+// FetchJob mocks base method.
+func (m *MockInterface) FetchJob(ctx context.Context, jobID string, job *entity.Job) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchJob", ctx, jobID, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchJob indicates an expected call of FetchJob.
+func (mr *MockInterfaceMockRecorder) FetchJob(ctx, jobID, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchJob", reflect.TypeOf((*MockInterface)(nil).FetchJob), ctx, jobID, job)
+}
+
+// FetchMember mocks base method.
+func (m *MockInterface) FetchMember(ctx context.Context, orgID, userID int64, member *entity.Member) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchMember", ctx, orgID, userID, member)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchMember indicates an expected call of FetchMember.
+func (mr *MockInterfaceMockRecorder) FetchMember(ctx, orgID, userID, member interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchMember", reflect.TypeOf((*MockInterface)(nil).FetchMember), ctx, orgID, userID, member)
+}
+
+// FetchOrganization mocks base method.
+func (m *MockInterface) FetchOrganization(ctx context.Context, orgID int64, org *entity.Organization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchOrganization", ctx, orgID, org)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchOrganization indicates an expected call of FetchOrganization.
+func (mr *MockInterfaceMockRecorder) FetchOrganization(ctx, orgID, org interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchOrganization", reflect.TypeOf((*MockInterface)(nil).FetchOrganization), ctx, orgID, org)
+}
+
+// FetchPasswordReset mocks base method.
+func (m *MockInterface) FetchPasswordReset(ctx context.Context, hash string, reset *entity.PasswordReset) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchPasswordReset", ctx, hash, reset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchPasswordReset indicates an expected call of FetchPasswordReset.
+func (mr *MockInterfaceMockRecorder) FetchPasswordReset(ctx, hash, reset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchPasswordReset", reflect.TypeOf((*MockInterface)(nil).FetchPasswordReset), ctx, hash, reset)
+}
+
+// FetchRefreshToken mocks base method.
+func (m *MockInterface) FetchRefreshToken(ctx context.Context, hash string, token *entity.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchRefreshToken", ctx, hash, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchRefreshToken indicates an expected call of FetchRefreshToken.
+func (mr *MockInterfaceMockRecorder) FetchRefreshToken(ctx, hash, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchRefreshToken", reflect.TypeOf((*MockInterface)(nil).FetchRefreshToken), ctx, hash, token)
+}
+
+// FetchRoles mocks base method.
+func (m *MockInterface) FetchRoles(ctx context.Context, names []string, roles *[]entity.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchRoles", ctx, names, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchRoles indicates an expected call of FetchRoles.
+func (mr *MockInterfaceMockRecorder) FetchRoles(ctx, names, roles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchRoles", reflect.TypeOf((*MockInterface)(nil).FetchRoles), ctx, names, roles)
+}
+
+// FetchScheduleRuns mocks base method.
+func (m *MockInterface) FetchScheduleRuns(ctx context.Context, schedules *[]entity.Schedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchScheduleRuns", ctx, schedules)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchScheduleRuns indicates an expected call of FetchScheduleRuns.
+func (mr *MockInterfaceMockRecorder) FetchScheduleRuns(ctx, schedules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchScheduleRuns", reflect.TypeOf((*MockInterface)(nil).FetchScheduleRuns), ctx, schedules)
+}
+
+// FetchTOTP mocks base method.
+func (m *MockInterface) FetchTOTP(ctx context.Context, userID int64, totp *entity.TOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchTOTP", ctx, userID, totp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchTOTP indicates an expected call of FetchTOTP.
+func (mr *MockInterfaceMockRecorder) FetchTOTP(ctx, userID, totp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchTOTP", reflect.TypeOf((*MockInterface)(nil).FetchTOTP), ctx, userID, totp)
+}
+
+// FetchUserRoles mocks base method.
+func (m *MockInterface) FetchUserRoles(ctx context.Context, userID int64, roles *[]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchUserRoles", ctx, userID, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchUserRoles indicates an expected call of FetchUserRoles.
+func (mr *MockInterfaceMockRecorder) FetchUserRoles(ctx, userID, roles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchUserRoles", reflect.TypeOf((*MockInterface)(nil).FetchUserRoles), ctx, userID, roles)
+}
+
 // FetchUsers mocks base method.
-func (m *MockInterface) FetchUsers(ctx context.Context, ID []int64, users *[]entity.User) error {
+func (m *MockInterface) FetchUsers(ctx context.Context, ID []int64, includeDeleted bool, users *[]entity.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchUsers", ctx, ID, users)
+	ret := m.ctrl.Call(m, "FetchUsers", ctx, ID, includeDeleted, users)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // FetchUsers indicates an expected call of FetchUsers.
-func (mr *MockInterfaceMockRecorder) FetchUsers(ctx, ID, users interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) FetchUsers(ctx, ID, includeDeleted, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchUsers", reflect.TypeOf((*MockInterface)(nil).FetchUsers), ctx, ID, includeDeleted, users)
+}
+
+// FilterAPIKeys mocks base method.
+func (m *MockInterface) FilterAPIKeys(ctx context.Context, userID int64, keys *[]entity.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterAPIKeys", ctx, userID, keys)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterAPIKeys indicates an expected call of FilterAPIKeys.
+func (mr *MockInterfaceMockRecorder) FilterAPIKeys(ctx, userID, keys interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchUsers", reflect.TypeOf((*MockInterface)(nil).FetchUsers), ctx, ID, users)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterAPIKeys", reflect.TypeOf((*MockInterface)(nil).FilterAPIKeys), ctx, userID, keys)
+}
+
+// FilterDeletedUsersID mocks base method.
+func (m *MockInterface) FilterDeletedUsersID(ctx context.Context, before time.Time, limit uint, IDs *[]int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterDeletedUsersID", ctx, before, limit, IDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterDeletedUsersID indicates an expected call of FilterDeletedUsersID.
+func (mr *MockInterfaceMockRecorder) FilterDeletedUsersID(ctx, before, limit, IDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterDeletedUsersID", reflect.TypeOf((*MockInterface)(nil).FilterDeletedUsersID), ctx, before, limit, IDs)
 }
 
 // FilterEmails mocks base method.
@@ -135,6 +550,34 @@ func (mr *MockInterfaceMockRecorder) FilterEmails(ctx, filter, emails interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterEmails", reflect.TypeOf((*MockInterface)(nil).FilterEmails), ctx, filter, emails)
 }
 
+// FilterMembers mocks base method.
+func (m *MockInterface) FilterMembers(ctx context.Context, orgID int64, members *[]entity.Member) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterMembers", ctx, orgID, members)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterMembers indicates an expected call of FilterMembers.
+func (mr *MockInterfaceMockRecorder) FilterMembers(ctx, orgID, members interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterMembers", reflect.TypeOf((*MockInterface)(nil).FilterMembers), ctx, orgID, members)
+}
+
+// FilterOrganizations mocks base method.
+func (m *MockInterface) FilterOrganizations(ctx context.Context, userID int64, orgs *[]entity.Organization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FilterOrganizations", ctx, userID, orgs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FilterOrganizations indicates an expected call of FilterOrganizations.
+func (mr *MockInterfaceMockRecorder) FilterOrganizations(ctx, userID, orgs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterOrganizations", reflect.TypeOf((*MockInterface)(nil).FilterOrganizations), ctx, userID, orgs)
+}
+
 // FilterUsersID mocks base method.
 func (m *MockInterface) FilterUsersID(ctx context.Context, filter store.FilterUsers, IDs *[]int64) error {
 	m.ctrl.T.Helper()
@@ -149,17 +592,369 @@ func (mr *MockInterfaceMockRecorder) FilterUsersID(ctx, filter, IDs interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilterUsersID", reflect.TypeOf((*MockInterface)(nil).FilterUsersID), ctx, filter, IDs)
 }
 
+// IsTokenDenied mocks base method.
+func (m *MockInterface) IsTokenDenied(ctx context.Context, jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenDenied", ctx, jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenDenied indicates an expected call of IsTokenDenied.
+func (mr *MockInterfaceMockRecorder) IsTokenDenied(ctx, jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenDenied", reflect.TypeOf((*MockInterface)(nil).IsTokenDenied), ctx, jti)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockInterface) IsTokenRevoked(ctx context.Context, userID int64, issuedAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", ctx, userID, issuedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockInterfaceMockRecorder) IsTokenRevoked(ctx, userID, issuedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockInterface)(nil).IsTokenRevoked), ctx, userID, issuedAt)
+}
+
+// PurgeEmails mocks base method.
+func (m *MockInterface) PurgeEmails(ctx context.Context, tx store.Tx, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeEmails", ctx, tx, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeEmails indicates an expected call of PurgeEmails.
+func (mr *MockInterfaceMockRecorder) PurgeEmails(ctx, tx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeEmails", reflect.TypeOf((*MockInterface)(nil).PurgeEmails), ctx, tx, before)
+}
+
+// PurgeExpiredTokens mocks base method.
+func (m *MockInterface) PurgeExpiredTokens(ctx context.Context, tx store.Tx, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpiredTokens", ctx, tx, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeExpiredTokens indicates an expected call of PurgeExpiredTokens.
+func (mr *MockInterfaceMockRecorder) PurgeExpiredTokens(ctx, tx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpiredTokens", reflect.TypeOf((*MockInterface)(nil).PurgeExpiredTokens), ctx, tx, before)
+}
+
+// PurgeOrphanedEmails mocks base method.
+func (m *MockInterface) PurgeOrphanedEmails(ctx context.Context, tx store.Tx) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeOrphanedEmails", ctx, tx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeOrphanedEmails indicates an expected call of PurgeOrphanedEmails.
+func (mr *MockInterfaceMockRecorder) PurgeOrphanedEmails(ctx, tx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeOrphanedEmails", reflect.TypeOf((*MockInterface)(nil).PurgeOrphanedEmails), ctx, tx)
+}
+
+// PurgeUser mocks base method.
+func (m *MockInterface) PurgeUser(ctx context.Context, tx store.Tx, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeUser", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeUser indicates an expected call of PurgeUser.
+func (mr *MockInterfaceMockRecorder) PurgeUser(ctx, tx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeUser", reflect.TypeOf((*MockInterface)(nil).PurgeUser), ctx, tx, userID)
+}
+
+// RestoreEmail mocks base method.
+func (m *MockInterface) RestoreEmail(ctx context.Context, tx store.Tx, emailID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreEmail", ctx, tx, emailID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreEmail indicates an expected call of RestoreEmail.
+func (mr *MockInterfaceMockRecorder) RestoreEmail(ctx, tx, emailID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreEmail", reflect.TypeOf((*MockInterface)(nil).RestoreEmail), ctx, tx, emailID)
+}
+
+// RestoreUser mocks base method.
+func (m *MockInterface) RestoreUser(ctx context.Context, tx store.Tx, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockInterfaceMockRecorder) RestoreUser(ctx, tx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockInterface)(nil).RestoreUser), ctx, tx, userID)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockInterface) RevokeAPIKey(ctx context.Context, tx store.Tx, userID, keyID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, tx, userID, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockInterfaceMockRecorder) RevokeAPIKey(ctx, tx, userID, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockInterface)(nil).RevokeAPIKey), ctx, tx, userID, keyID)
+}
+
+// RevokeAccessTokens mocks base method.
+func (m *MockInterface) RevokeAccessTokens(ctx context.Context, tx store.Tx, userID int64, before, expires time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAccessTokens", ctx, tx, userID, before, expires)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAccessTokens indicates an expected call of RevokeAccessTokens.
+func (mr *MockInterfaceMockRecorder) RevokeAccessTokens(ctx, tx, userID, before, expires interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAccessTokens", reflect.TypeOf((*MockInterface)(nil).RevokeAccessTokens), ctx, tx, userID, before, expires)
+}
+
+// RevokeRefreshFamily mocks base method.
+func (m *MockInterface) RevokeRefreshFamily(ctx context.Context, tx store.Tx, family string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshFamily", ctx, tx, family)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshFamily indicates an expected call of RevokeRefreshFamily.
+func (mr *MockInterfaceMockRecorder) RevokeRefreshFamily(ctx, tx, family interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshFamily", reflect.TypeOf((*MockInterface)(nil).RevokeRefreshFamily), ctx, tx, family)
+}
+
+// RevokeUserRefreshTokens mocks base method.
+func (m *MockInterface) RevokeUserRefreshTokens(ctx context.Context, tx store.Tx, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserRefreshTokens", ctx, tx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserRefreshTokens indicates an expected call of RevokeUserRefreshTokens.
+func (mr *MockInterfaceMockRecorder) RevokeUserRefreshTokens(ctx, tx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserRefreshTokens", reflect.TypeOf((*MockInterface)(nil).RevokeUserRefreshTokens), ctx, tx, userID)
+}
+
+// SetJobStatus mocks base method.
+func (m *MockInterface) SetJobStatus(ctx context.Context, tx store.Tx, jobID, status, errText string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetJobStatus", ctx, tx, jobID, status, errText)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetJobStatus indicates an expected call of SetJobStatus.
+func (mr *MockInterfaceMockRecorder) SetJobStatus(ctx, tx, jobID, status, errText interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetJobStatus", reflect.TypeOf((*MockInterface)(nil).SetJobStatus), ctx, tx, jobID, status, errText)
+}
+
+// SetRecoveryCodes mocks base method.
+func (m *MockInterface) SetRecoveryCodes(ctx context.Context, tx store.Tx, userID int64, hashes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRecoveryCodes", ctx, tx, userID, hashes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRecoveryCodes indicates an expected call of SetRecoveryCodes.
+func (mr *MockInterfaceMockRecorder) SetRecoveryCodes(ctx, tx, userID, hashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRecoveryCodes", reflect.TypeOf((*MockInterface)(nil).SetRecoveryCodes), ctx, tx, userID, hashes)
+}
+
+// SetScheduleRun mocks base method.
+func (m *MockInterface) SetScheduleRun(ctx context.Context, tx store.Tx, job string, at time.Time, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetScheduleRun", ctx, tx, job, at, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetScheduleRun indicates an expected call of SetScheduleRun.
+func (mr *MockInterfaceMockRecorder) SetScheduleRun(ctx, tx, job, at, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetScheduleRun", reflect.TypeOf((*MockInterface)(nil).SetScheduleRun), ctx, tx, job, at, status)
+}
+
+// SetTOTP mocks base method.
+func (m *MockInterface) SetTOTP(ctx context.Context, tx store.Tx, totp *entity.TOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTP", ctx, tx, totp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTP indicates an expected call of SetTOTP.
+func (mr *MockInterfaceMockRecorder) SetTOTP(ctx, tx, totp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTP", reflect.TypeOf((*MockInterface)(nil).SetTOTP), ctx, tx, totp)
+}
+
+// SetUserRoles mocks base method.
+func (m *MockInterface) SetUserRoles(ctx context.Context, tx store.Tx, userID int64, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRoles", ctx, tx, userID, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserRoles indicates an expected call of SetUserRoles.
+func (mr *MockInterfaceMockRecorder) SetUserRoles(ctx, tx, userID, roles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRoles", reflect.TypeOf((*MockInterface)(nil).SetUserRoles), ctx, tx, userID, roles)
+}
+
+// TouchAPIKey mocks base method.
+func (m *MockInterface) TouchAPIKey(ctx context.Context, tx store.Tx, keyID int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", ctx, tx, keyID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey.
+func (mr *MockInterfaceMockRecorder) TouchAPIKey(ctx, tx, keyID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockInterface)(nil).TouchAPIKey), ctx, tx, keyID, at)
+}
+
 // Tx mocks base method.
-func (m *MockInterface) Tx() (*sql.Tx, error) {
+func (m *MockInterface) Tx(ctx context.Context) (store.Tx, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Tx")
-	ret0, _ := ret[0].(*sql.Tx)
+	ret := m.ctrl.Call(m, "Tx", ctx)
+	ret0, _ := ret[0].(store.Tx)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Tx indicates an expected call of Tx.
-func (mr *MockInterfaceMockRecorder) Tx() *gomock.Call {
+func (mr *MockInterfaceMockRecorder) Tx(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tx", reflect.TypeOf((*MockInterface)(nil).Tx), ctx)
+}
+
+// UpdateUser mocks base method.
+func (m *MockInterface) UpdateUser(ctx context.Context, tx store.Tx, user *entity.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, tx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockInterfaceMockRecorder) UpdateUser(ctx, tx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockInterface)(nil).UpdateUser), ctx, tx, user)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockInterface) UpdateUserPassword(ctx context.Context, tx store.Tx, userID int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, tx, userID, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockInterfaceMockRecorder) UpdateUserPassword(ctx, tx, userID, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockInterface)(nil).UpdateUserPassword), ctx, tx, userID, password)
+}
+
+// UsePasswordReset mocks base method.
+func (m *MockInterface) UsePasswordReset(ctx context.Context, tx store.Tx, resetID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordReset", ctx, tx, resetID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UsePasswordReset indicates an expected call of UsePasswordReset.
+func (mr *MockInterfaceMockRecorder) UsePasswordReset(ctx, tx, resetID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordReset", reflect.TypeOf((*MockInterface)(nil).UsePasswordReset), ctx, tx, resetID)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockInterface) UseRecoveryCode(ctx context.Context, tx store.Tx, userID int64, hash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, tx, userID, hash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockInterfaceMockRecorder) UseRecoveryCode(ctx, tx, userID, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockInterface)(nil).UseRecoveryCode), ctx, tx, userID, hash)
+}
+
+// UseRefreshToken mocks base method.
+func (m *MockInterface) UseRefreshToken(ctx context.Context, tx store.Tx, tokenID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRefreshToken", ctx, tx, tokenID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRefreshToken indicates an expected call of UseRefreshToken.
+func (mr *MockInterfaceMockRecorder) UseRefreshToken(ctx, tx, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRefreshToken", reflect.TypeOf((*MockInterface)(nil).UseRefreshToken), ctx, tx, tokenID)
+}
+
+// UseTOTP mocks base method.
+func (m *MockInterface) UseTOTP(ctx context.Context, tx store.Tx, userID, counter int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTP", ctx, tx, userID, counter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTP indicates an expected call of UseTOTP.
+func (mr *MockInterfaceMockRecorder) UseTOTP(ctx, tx, userID, counter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTP", reflect.TypeOf((*MockInterface)(nil).UseTOTP), ctx, tx, userID, counter)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockInterface) VerifyEmail(ctx context.Context, tx store.Tx, emailID int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, tx, emailID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockInterfaceMockRecorder) VerifyEmail(ctx, tx, emailID, at interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tx", reflect.TypeOf((*MockInterface)(nil).Tx))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockInterface)(nil).VerifyEmail), ctx, tx, emailID, at)
 }
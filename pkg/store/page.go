@@ -0,0 +1,158 @@
+package store
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"boiler/pkg/errors"
+)
+
+// SortBy is the field a filter is sorted by, ties are broken by ID
+type SortBy string
+
+const (
+	// SortByID sort by ID, it is the default
+	SortByID SortBy = "id"
+	// SortByCreated sort by creation time
+	SortByCreated SortBy = "created"
+)
+
+// Cursor is the position of a row in a sorted filter
+type Cursor struct {
+	SortBy  SortBy
+	ID      int64
+	Created time.Time
+}
+
+// NewCursor return the cursor of a row
+func NewCursor(sortBy SortBy, ID int64, created time.Time) Cursor {
+	if sortBy == "" {
+		sortBy = SortByID
+	}
+
+	return Cursor{SortBy: sortBy, ID: ID, Created: created}
+}
+
+// String encode the cursor in an opaque string
+func (c Cursor) String() string {
+	raw := string(c.SortBy) + ":" + strconv.FormatInt(c.ID, 10)
+	if c.SortBy == SortByCreated {
+		raw += ":" + strconv.FormatInt(c.Created.UnixNano(), 10)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decode a cursor encoded by Cursor.String
+func ParseCursor(raw string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(b), ":")
+
+	c := Cursor{SortBy: SortBy(parts[0])}
+	switch {
+	case c.SortBy == SortByID && len(parts) == 2:
+	case c.SortBy == SortByCreated && len(parts) == 3:
+		nsec, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, errors.ErrInvalidCursor
+		}
+		c.Created = time.Unix(0, nsec).UTC()
+	default:
+		return nil, errors.ErrInvalidCursor
+	}
+
+	c.ID, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Seek is the keyset position a filter starts from
+type Seek struct {
+	SortBy SortBy
+	// Cursor is nil for the first page
+	Cursor *Cursor
+	// Backward is true when the rows before Cursor, or the last rows without it, are requested
+	// stores walk them in reverse order but always return them ascending
+	Backward bool
+}
+
+// NewSeek parse the pagination of a filter, after and before can not be used together
+// backward seeks the rows before the end when before is empty
+func NewSeek(sortBy SortBy, after, before string, backward bool) (Seek, error) {
+	if sortBy == "" {
+		sortBy = SortByID
+	}
+
+	if sortBy != SortByID && sortBy != SortByCreated {
+		return Seek{}, errors.ErrInvalidSort
+	}
+
+	seek := Seek{SortBy: sortBy}
+
+	raw := after
+	if len(before) != 0 || backward {
+		if len(after) != 0 {
+			return Seek{}, errors.ErrInvalidCursor
+		}
+
+		raw = before
+		seek.Backward = true
+	}
+
+	if len(raw) == 0 {
+		return seek, nil
+	}
+
+	c, err := ParseCursor(raw)
+	if err != nil {
+		return Seek{}, err
+	}
+
+	if c.SortBy != sortBy {
+		return Seek{}, errors.ErrInvalidCursor
+	}
+
+	seek.Cursor = c
+	return seek, nil
+}
+
+// PageInfo describe a page returned by a filter
+type PageInfo struct {
+	StartCursor     string
+	EndCursor       string
+	HasNextPage     bool
+	HasPreviousPage bool
+}
+
+// NewPageInfo describe the page found by seek
+// more tells if rows were found beyond the page, first and last are nil for an empty page
+func NewPageInfo(seek Seek, more bool, first, last *Cursor) PageInfo {
+	var page PageInfo
+
+	if seek.Backward {
+		page.HasPreviousPage = more
+		page.HasNextPage = seek.Cursor != nil
+	} else {
+		page.HasNextPage = more
+		page.HasPreviousPage = seek.Cursor != nil
+	}
+
+	if first != nil {
+		page.StartCursor = first.String()
+	}
+
+	if last != nil {
+		page.EndCursor = last.String()
+	}
+
+	return page
+}
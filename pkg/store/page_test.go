@@ -0,0 +1,123 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	// sort by ID
+	{
+		c, err := store.ParseCursor(store.NewCursor("", 42, time.Now()).String())
+		assert.Nil(t, err)
+		assert.Equal(t, store.SortByID, c.SortBy)
+		assert.Equal(t, int64(42), c.ID)
+		assert.True(t, c.Created.IsZero())
+	}
+
+	// sort by created
+	{
+		created := time.Now()
+		c, err := store.ParseCursor(store.NewCursor(store.SortByCreated, 42, created).String())
+		assert.Nil(t, err)
+		assert.Equal(t, store.SortByCreated, c.SortBy)
+		assert.Equal(t, int64(42), c.ID)
+		assert.True(t, created.Equal(c.Created))
+	}
+
+	// fails if invalid
+	for _, raw := range []string{"", "!", "aWQ", "aWQ6YQ", "Y3JlYXRlZDox", "bmFtZToxOjE"} {
+		_, err := store.ParseCursor(raw)
+		assert.Equal(t, errors.ErrInvalidCursor, err, raw)
+	}
+}
+
+func TestNewSeek(t *testing.T) {
+	after := store.NewCursor(store.SortByID, 1, time.Time{}).String()
+
+	// first page
+	{
+		seek, err := store.NewSeek("", "", "", false)
+		assert.Nil(t, err)
+		assert.Equal(t, store.Seek{SortBy: store.SortByID}, seek)
+	}
+
+	// after
+	{
+		seek, err := store.NewSeek(store.SortByID, after, "", false)
+		assert.Nil(t, err)
+		assert.False(t, seek.Backward)
+		assert.Equal(t, int64(1), seek.Cursor.ID)
+	}
+
+	// before
+	{
+		seek, err := store.NewSeek(store.SortByID, "", after, false)
+		assert.Nil(t, err)
+		assert.True(t, seek.Backward)
+		assert.Equal(t, int64(1), seek.Cursor.ID)
+	}
+
+	// last page
+	{
+		seek, err := store.NewSeek(store.SortByID, "", "", true)
+		assert.Nil(t, err)
+		assert.Equal(t, store.Seek{SortBy: store.SortByID, Backward: true}, seek)
+	}
+
+	// fails if sort is invalid
+	{
+		_, err := store.NewSeek("name", "", "", false)
+		assert.Equal(t, errors.ErrInvalidSort, err)
+	}
+
+	// fails if after and before are used together
+	{
+		_, err := store.NewSeek(store.SortByID, after, after, false)
+		assert.Equal(t, errors.ErrInvalidCursor, err)
+
+		_, err = store.NewSeek(store.SortByID, after, "", true)
+		assert.Equal(t, errors.ErrInvalidCursor, err)
+	}
+
+	// fails if cursor is from another sort
+	{
+		_, err := store.NewSeek(store.SortByCreated, after, "", false)
+		assert.Equal(t, errors.ErrInvalidCursor, err)
+	}
+}
+
+func TestNewPageInfo(t *testing.T) {
+	first := store.NewCursor(store.SortByID, 1, time.Time{})
+	last := store.NewCursor(store.SortByID, 2, time.Time{})
+
+	// first page
+	{
+		page := store.NewPageInfo(store.Seek{}, true, &first, &last)
+		assert.True(t, page.HasNextPage)
+		assert.False(t, page.HasPreviousPage)
+		assert.Equal(t, first.String(), page.StartCursor)
+		assert.Equal(t, last.String(), page.EndCursor)
+	}
+
+	// last page walking backward
+	{
+		page := store.NewPageInfo(store.Seek{Cursor: &last, Backward: true}, false, nil, nil)
+		assert.True(t, page.HasNextPage)
+		assert.False(t, page.HasPreviousPage)
+		assert.Empty(t, page.StartCursor)
+		assert.Empty(t, page.EndCursor)
+	}
+
+	// last page from the end
+	{
+		page := store.NewPageInfo(store.Seek{Backward: true}, true, &first, &last)
+		assert.False(t, page.HasNextPage)
+		assert.True(t, page.HasPreviousPage)
+	}
+}
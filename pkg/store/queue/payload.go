@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/gocraft/work"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// PayloadArg is the arg of a job holding its payload encoded with msgp, in base64 to go through the JSON of the
+// queues, the other args of the job are left untyped
+const PayloadArg = "payload"
+
+// ErrDead is matched by the errors of Dead
+var ErrDead = errors.New("dead job")
+
+// Dead wrap the error of a run so the job is not retried, it goes straight to the dead jobs or is deleted
+// with SkipDead
+func Dead(err error) error {
+	return &deadError{err}
+}
+
+type deadError struct {
+	err error
+}
+
+func (e *deadError) Error() string        { return e.err.Error() }
+func (e *deadError) Unwrap() error        { return e.err }
+func (e *deadError) Is(target error) bool { return target == ErrDead }
+
+// Payload is the typed args of a job, the pointers to the structs of pkg/entity with a generated msgp codec
+// implement it
+type Payload interface {
+	msgp.Marshaler
+	msgp.Unmarshaler
+	// Upgrade bring a payload decoded from an older version to the current one, the jobs enqueued before the
+	// payloads have no PayloadArg and are upgraded from their untyped args
+	Upgrade(args map[string]interface{}) error
+	// Validate fails if the payload can not run
+	Validate() error
+}
+
+// EncodePayload return the args of a job holding p, it fails if p is invalid
+func EncodePayload(p Payload) (map[string]interface{}, error) {
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid payload; %w", err)
+	}
+
+	b, err := p.MarshalMsg(nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode payload; %w", err)
+	}
+
+	return map[string]interface{}{PayloadArg: base64.StdEncoding.EncodeToString(b)}, nil
+}
+
+// DecodePayload decode the payload of j into p then upgrade and validate it, the errors are Dead ones as
+// retrying the job would fail the same way
+func DecodePayload(j *work.Job, p Payload) error {
+	if raw, ok := j.Args[PayloadArg]; ok {
+		s, _ := raw.(string)
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return Dead(fmt.Errorf("could not decode payload; %w", err))
+		}
+
+		if _, err := p.UnmarshalMsg(b); err != nil {
+			return Dead(fmt.Errorf("could not decode payload; %w", err))
+		}
+	}
+
+	if err := p.Upgrade(j.Args); err != nil {
+		return Dead(fmt.Errorf("could not upgrade payload; %w", err))
+	}
+
+	if err := p.Validate(); err != nil {
+		return Dead(fmt.Errorf("invalid payload; %w", err))
+	}
+
+	return nil
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	payloadType = reflect.TypeOf((*Payload)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// PayloadHandler return the handler running fn with the decoded payload of the jobs, fn is a
+// func(ctx context.Context, p P) error with P a pointer implementing Payload, the type of the payload of the jobs
+// it panics if fn is not one, like gocraft/work with an invalid handler
+func PayloadHandler(fn interface{}) Handler {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 2 || t.NumOut() != 1 || t.In(0) != contextType ||
+		t.In(1).Kind() != reflect.Ptr || !t.In(1).Implements(payloadType) || t.Out(0) != errorType {
+		panic(fmt.Sprintf("queue: invalid payload handler %T, want func(context.Context, P) error", fn))
+	}
+
+	elem := t.In(1).Elem()
+	return func(ctx context.Context, j *work.Job) error {
+		p := reflect.New(elem)
+		if err := DecodePayload(j, p.Interface().(Payload)); err != nil {
+			return err
+		}
+
+		err, _ := v.Call([]reflect.Value{reflect.ValueOf(ctx), p})[0].Interface().(error)
+		return err
+	}
+}
+
+// JobWithPayload register fn on pool for the jobs of name, binding the name to the payload type of fn
+// see PayloadHandler
+func JobWithPayload(pool Pool, name string, opts JobOptions, fn interface{}) {
+	pool.JobWithOptions(name, opts, PayloadHandler(fn))
+}
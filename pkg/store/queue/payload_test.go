@@ -0,0 +1,115 @@
+package queue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayload(t *testing.T) {
+	// succeed to encode then decode a payload
+	{
+		payload := entity.SendEmailPayload{
+			Version:  entity.SendEmailPayloadVersion,
+			To:       "a@b.c",
+			Template: "t",
+			Data:     map[string]interface{}{"Token": "tok"},
+		}
+		args, err := queue.EncodePayload(&payload)
+		assert.Nil(t, err)
+		assert.IsType(t, "", args[queue.PayloadArg])
+
+		var decoded entity.SendEmailPayload
+		assert.Nil(t, queue.DecodePayload(&work.Job{Args: args}, &decoded))
+		assert.Equal(t, payload, decoded)
+	}
+
+	// succeed to upgrade the untyped args of a job enqueued before the payloads
+	{
+		var decoded entity.DeleteUserPayload
+		assert.Nil(t, queue.DecodePayload(&work.Job{Args: map[string]interface{}{"id": float64(6)}}, &decoded))
+		assert.Equal(t, entity.DeleteUserPayload{Version: entity.DeleteUserPayloadVersion, ID: 6}, decoded)
+	}
+
+	// fails to encode an invalid payload
+	{
+		_, err := queue.EncodePayload(&entity.DeleteUserPayload{Version: entity.DeleteUserPayloadVersion})
+		assert.True(t, errors.Is(err, errors.ErrInvalidUserID))
+
+		_, err = queue.EncodePayload(&entity.DeleteUserPayload{ID: 6})
+		assert.True(t, errors.Is(err, entity.ErrInvalidPayloadVersion))
+	}
+
+	// fails with a dead error if the payload can not be decoded or is invalid
+	{
+		var decoded entity.DeleteUserPayload
+		err := queue.DecodePayload(&work.Job{Args: map[string]interface{}{queue.PayloadArg: "nope"}}, &decoded)
+		assert.True(t, errors.Is(err, queue.ErrDead))
+
+		err = queue.DecodePayload(&work.Job{Args: map[string]interface{}{}}, &decoded)
+		assert.True(t, errors.Is(err, queue.ErrDead))
+		assert.True(t, errors.Is(err, errors.ErrInvalidUserID))
+	}
+
+	// fails if the handler does not take a payload
+	{
+		assert.Panics(t, func() { queue.PayloadHandler(func(ctx context.Context, id int64) error { return nil }) })
+		assert.Panics(t, func() { queue.PayloadHandler(func(p *entity.DeleteUserPayload) error { return nil }) })
+	}
+}
+
+func TestJobWithPayload(t *testing.T) {
+	// succeed to run the handler with the payload of the job
+	{
+		q, db := newSQL(t)
+
+		args, err := queue.EncodePayload(&entity.DeleteUserPayload{Version: entity.DeleteUserPayloadVersion, ID: 6})
+		assert.Nil(t, err)
+		_, err = q.Enqueue("delete", args)
+		assert.Nil(t, err)
+
+		done := make(chan int64, 1)
+		pool := q.NewPool(1)
+		queue.JobWithPayload(pool, "delete", queue.JobOptions{}, func(_ context.Context, p *entity.DeleteUserPayload) error {
+			done <- p.ID
+			return nil
+		})
+		pool.Start()
+		assert.Equal(t, int64(6), <-done)
+		pool.Stop()
+
+		assert.Equal(t, 0, count(t, db, "dead_at IS NULL"))
+	}
+
+	// succeed to kill a job with an invalid payload without retrying it
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("delete", map[string]interface{}{queue.PayloadArg: "nope"})
+		assert.Nil(t, err)
+
+		var runs int
+		pool := q.NewPool(1)
+		opts := queue.JobOptions{JobOptions: work.JobOptions{MaxFails: 5}}
+		queue.JobWithPayload(pool, "delete", opts, func(_ context.Context, p *entity.DeleteUserPayload) error {
+			runs++
+			return fmt.Errorf("opz")
+		})
+		pool.Start()
+		for i := 0; i < 100 && count(t, db, "dead_at IS NOT NULL") == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		pool.Stop()
+
+		assert.Equal(t, 0, runs)
+		assert.Equal(t, 1, count(t, db, "dead_at IS NOT NULL AND fails = 1"))
+	}
+}
@@ -0,0 +1,115 @@
+// Package queue enqueues the jobs of the worker and runs them, in redis with gocraft/work or in the database
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocraft/work"
+)
+
+// Enqueuer enqueue a job of name with its args
+type Enqueuer interface {
+	Enqueue(jobName string, args map[string]interface{}) (*work.Job, error)
+}
+
+// Handler run a job, the job fails if it returns an error
+// ctx is done once the timeout of the job is over or the pool stops
+type Handler func(ctx context.Context, j *work.Job) error
+
+// Middleware wrap the runs of the jobs, it runs the job by calling next, with a derived context if needed
+type Middleware func(ctx context.Context, j *work.Job, next Handler) error
+
+// DefaultMaxFails is the number of failed runs making a job dead when its MaxFails is zero, like gocraft/work
+const DefaultMaxFails = 4
+
+// JobOptions are the options of gocraft/work with the timeout of a run, zero never times out
+type JobOptions struct {
+	work.JobOptions
+	Timeout time.Duration
+}
+
+// ExponentialBackoff return the backoff retrying a job after base then doubling at each retry, in seconds like
+// gocraft/work, a base under a second is rounded up to a second
+func ExponentialBackoff(base time.Duration) work.BackoffCalculator {
+	seconds := int64((base + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return func(j *work.Job) int64 {
+		if j.Fails < 1 {
+			return seconds
+		}
+		return seconds << uint(j.Fails-1)
+	}
+}
+
+// Pool run the jobs of the registered names
+type Pool interface {
+	// Middleware wrap the runs of the jobs, the first one registered is the outermost
+	Middleware(fn Middleware)
+	// JobWithOptions run the jobs of name with fn
+	JobWithOptions(name string, opts JobOptions, fn Handler)
+	// PeriodicallyEnqueue enqueue a job of name at the times of spec, a cron spec with seconds
+	PeriodicallyEnqueue(spec, name string)
+	Start()
+	// Stop stop taking jobs, cancel the context of the running ones and wait for them
+	Stop()
+}
+
+// runner run the jobs through the middlewares in a context cancelled when the pool stops
+type runner struct {
+	middlewares []Middleware
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+func newRunner() runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return runner{ctx: ctx, cancel: cancel}
+}
+
+func (r *runner) Middleware(fn Middleware) {
+	r.middlewares = append(r.middlewares, fn)
+}
+
+// run call the middlewares then fn with the context of the pool, limited to opts.Timeout
+func (r *runner) run(j *work.Job, opts JobOptions, fn Handler) error {
+	ctx := r.ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	handler := fn
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		middleware, next := r.middlewares[i], handler
+		handler = func(ctx context.Context, j *work.Job) error {
+			return middleware(ctx, j, next)
+		}
+	}
+
+	return handler(ctx, j)
+}
+
+// Client manage the dead jobs, the jobs which failed their last retry, *work.Client implements it
+// a dead job is found by the time it died and its ID, the retry and the delete of a missing one fail with
+// work.ErrNotRetried and work.ErrNotDeleted
+type Client interface {
+	// DeadJobs return a page of 20 dead jobs, the first page is 1, and the number of dead jobs
+	DeadJobs(page uint) ([]*work.DeadJob, int64, error)
+	RetryDeadJob(diedAt int64, jobID string) error
+	RetryAllDeadJobs() error
+	DeleteDeadJob(diedAt int64, jobID string) error
+	DeleteAllDeadJobs() error
+}
+
+// Queue keeps the enqueued jobs until a pool runs them
+type Queue interface {
+	Enqueuer
+	// NewPool return a pool running up to concurrency jobs at a time
+	NewPool(concurrency uint) Pool
+	NewClient() Client
+}
@@ -0,0 +1,34 @@
+package queue_test
+
+import (
+	"testing"
+	"time"
+
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	// succeed to double the backoff at each retry
+	{
+		backoff := queue.ExponentialBackoff(10 * time.Second)
+		assert.Equal(t, int64(10), backoff(&work.Job{Fails: 1}))
+		assert.Equal(t, int64(20), backoff(&work.Job{Fails: 2}))
+		assert.Equal(t, int64(40), backoff(&work.Job{Fails: 3}))
+	}
+
+	// succeed to round a backoff under a second up to a second
+	{
+		backoff := queue.ExponentialBackoff(300 * time.Millisecond)
+		assert.Equal(t, int64(1), backoff(&work.Job{Fails: 1}))
+		assert.Equal(t, int64(4), backoff(&work.Job{Fails: 3}))
+	}
+
+	// succeed to round a backoff up to the next second
+	{
+		backoff := queue.ExponentialBackoff(1500 * time.Millisecond)
+		assert.Equal(t, int64(2), backoff(&work.Job{Fails: 1}))
+	}
+}
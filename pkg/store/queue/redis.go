@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"errors"
+
+	"github.com/gocraft/work"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis queue the jobs in redis with gocraft/work
+type Redis struct {
+	*work.Enqueuer
+	namespace string
+	pool      *redis.Pool
+}
+
+// NewRedis return a queue keeping the jobs under the namespace of the redis pool
+func NewRedis(namespace string, pool *redis.Pool) *Redis {
+	return &Redis{
+		Enqueuer:  work.NewEnqueuer(namespace, pool),
+		namespace: namespace,
+		pool:      pool,
+	}
+}
+
+// NewPool return a gocraft/work worker pool
+func (q *Redis) NewPool(concurrency uint) Pool {
+	return &redisPool{
+		WorkerPool: work.NewWorkerPool(struct{}{}, concurrency, q.namespace, q.pool),
+		runner:     newRunner(),
+	}
+}
+
+// NewClient return a gocraft/work client
+func (q *Redis) NewClient() Client {
+	return work.NewClient(q.namespace, q.pool)
+}
+
+// redisPool runs the middlewares itself, the ones of gocraft/work can not pass a context
+type redisPool struct {
+	*work.WorkerPool
+	runner
+}
+
+func (p *redisPool) Middleware(fn Middleware) {
+	p.runner.Middleware(fn)
+}
+
+func (p *redisPool) JobWithOptions(name string, opts JobOptions, fn Handler) {
+	maxFails := int64(opts.MaxFails)
+	if maxFails == 0 {
+		maxFails = DefaultMaxFails
+	}
+
+	p.WorkerPool.JobWithOptions(name, opts.JobOptions, func(j *work.Job) error {
+		err := p.run(j, opts, fn)
+		if errors.Is(err, ErrDead) && j.Fails < maxFails {
+			// gocraft/work counts this fail after the run, the job is then out of retries
+			j.Fails = maxFails - 1
+		}
+
+		return err
+	})
+}
+
+func (p *redisPool) PeriodicallyEnqueue(spec, name string) {
+	p.WorkerPool.PeriodicallyEnqueue(spec, name)
+}
+
+func (p *redisPool) Stop() {
+	p.cancel()
+	p.WorkerPool.Stop()
+}
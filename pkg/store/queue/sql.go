@@ -0,0 +1,496 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"boiler/pkg/store"
+
+	"github.com/gocraft/work"
+	"github.com/robfig/cron"
+	"github.com/rs/zerolog/log"
+)
+
+// deadJobsPage is the number of dead jobs of a page, like gocraft/work
+const deadJobsPage = 20
+
+// MinVisibility is the shortest visibility of the SQL queue, a shorter one is raised to it
+const MinVisibility = time.Second
+
+// SQL queue the jobs in the queue_jobs table of the database
+// a pool claims a job by hiding it from the other pools for Visibility, extending it while the job runs, so
+// the job runs again once a crashed pool stops extending it
+type SQL struct {
+	db *sql.DB
+	// Rebind rewrite the ? placeholders of the queries for the database
+	Rebind func(string) string
+	// Visibility is how long a claimed job is hidden from the other pools, at least MinVisibility
+	Visibility time.Duration
+	// Poll is how long an idle pool waits before looking for jobs again
+	Poll time.Duration
+	Now  func() time.Time
+}
+
+// NewSQL return a queue in the database, it needs the queue_jobs table of the migrations
+func NewSQL(db *sql.DB) *SQL {
+	return &SQL{
+		db:         db,
+		Rebind:     func(query string) string { return query },
+		Visibility: 5 * time.Minute,
+		Poll:       time.Second,
+		Now:        store.Now,
+	}
+}
+
+// Enqueue insert a job visible right away
+func (q *SQL) Enqueue(jobName string, args map[string]interface{}) (*work.Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.enqueue(context.Background(), id, jobName, args)
+}
+
+// enqueue insert a job with id, it does nothing if the job is still queued
+func (q *SQL) enqueue(ctx context.Context, id, name string, args map[string]interface{}) (*work.Job, error) {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode args; %w", err)
+	}
+
+	now := q.Now()
+	_, err = q.db.ExecContext(ctx, q.Rebind(`INSERT INTO queue_jobs (id, name, args, enqueued_at, visible_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`), id, name, string(raw), now, now)
+	if err != nil {
+		return nil, fmt.Errorf("could not insert job; %w", err)
+	}
+
+	return &work.Job{ID: id, Name: name, EnqueuedAt: now.Unix(), Args: args}, nil
+}
+
+// claim hide the oldest visible job of the first name having one and return it, nil if there is none
+func (q *SQL) claim(ctx context.Context, names []string) (*work.Job, error) {
+	for _, name := range names {
+		j, err := q.claimName(ctx, name)
+		if err != nil || j != nil {
+			return j, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (q *SQL) claimName(ctx context.Context, name string) (*work.Job, error) {
+	var (
+		j        = &work.Job{Name: name}
+		args     string
+		enqueued time.Time
+		failed   sql.NullTime
+	)
+
+	now := q.Now()
+	err := q.db.QueryRowContext(ctx, q.Rebind(`SELECT id, args, fails, last_err, enqueued_at, failed_at
+		FROM queue_jobs WHERE name = ? AND dead_at IS NULL AND visible_at <= ?
+		ORDER BY visible_at, id LIMIT 1`), name, now).
+		Scan(&j.ID, &args, &j.Fails, &j.LastErr, &enqueued, &failed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not select job; %w", err)
+	}
+
+	// another pool claimed it since the select when no row is updated
+	res, err := q.db.ExecContext(ctx, q.Rebind(`UPDATE queue_jobs SET visible_at = ? WHERE id = ? AND visible_at <= ?`),
+		now.Add(q.visibility()), j.ID, now)
+	if err != nil {
+		return nil, fmt.Errorf("could not claim job; %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(args), &j.Args); err != nil {
+		return nil, fmt.Errorf("could not decode args of job %s; %w", j.ID, err)
+	}
+
+	j.EnqueuedAt = enqueued.Unix()
+	if failed.Valid {
+		j.FailedAt = failed.Time.Unix()
+	}
+
+	return j, nil
+}
+
+// extend keep a claimed job hidden for Visibility
+func (q *SQL) extend(ctx context.Context, jobID string) error {
+	_, err := q.db.ExecContext(ctx, q.Rebind(`UPDATE queue_jobs SET visible_at = ? WHERE id = ?`),
+		q.Now().Add(q.visibility()), jobID)
+	return err
+}
+
+// visibility return Visibility raised to MinVisibility
+func (q *SQL) visibility() time.Duration {
+	if q.Visibility < MinVisibility {
+		return MinVisibility
+	}
+	return q.Visibility
+}
+
+// ack remove a job which succeeded
+func (q *SQL) ack(ctx context.Context, jobID string) error {
+	_, err := q.db.ExecContext(ctx, q.Rebind(`DELETE FROM queue_jobs WHERE id = ?`), jobID)
+	if err != nil {
+		return fmt.Errorf("could not delete job; %w", err)
+	}
+
+	return nil
+}
+
+// fail retry a job after its backoff until it failed opts.MaxFails times or with a Dead error, then keep it
+// as dead or remove it with opts.SkipDead
+func (q *SQL) fail(ctx context.Context, j *work.Job, jobErr error, opts work.JobOptions) error {
+	now := q.Now()
+	j.Fails++
+	j.LastErr = jobErr.Error()
+	j.FailedAt = now.Unix()
+
+	var err error
+	switch {
+	case j.Fails < int64(opts.MaxFails) && !errors.Is(jobErr, ErrDead):
+		backoff := defaultBackoff
+		if opts.Backoff != nil {
+			backoff = opts.Backoff
+		}
+
+		_, err = q.db.ExecContext(ctx, q.Rebind(`UPDATE queue_jobs SET fails = ?, last_err = ?, failed_at = ?, visible_at = ?
+			WHERE id = ?`), j.Fails, j.LastErr, now, now.Add(time.Duration(backoff(j))*time.Second), j.ID)
+	case opts.SkipDead:
+		_, err = q.db.ExecContext(ctx, q.Rebind(`DELETE FROM queue_jobs WHERE id = ?`), j.ID)
+	default:
+		_, err = q.db.ExecContext(ctx, q.Rebind(`UPDATE queue_jobs SET fails = ?, last_err = ?, failed_at = ?, dead_at = ?
+			WHERE id = ?`), j.Fails, j.LastErr, now, now, j.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not fail job; %w", err)
+	}
+
+	return nil
+}
+
+// NewClient return the queue, it manages its dead jobs
+func (q *SQL) NewClient() Client {
+	return q
+}
+
+// DeadJobs return the dead jobs by the time they died
+func (q *SQL) DeadJobs(page uint) ([]*work.DeadJob, int64, error) {
+	if page == 0 {
+		page = 1
+	}
+
+	ctx := context.Background()
+
+	var total int64
+	err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM queue_jobs WHERE dead_at IS NOT NULL`).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not count dead jobs; %w", err)
+	}
+
+	rows, err := q.db.QueryContext(ctx, q.Rebind(`SELECT id, name, args, fails, last_err, enqueued_at, failed_at, dead_at
+		FROM queue_jobs WHERE dead_at IS NOT NULL ORDER BY dead_at, id LIMIT ? OFFSET ?`),
+		deadJobsPage, (page-1)*deadJobsPage)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not select dead jobs; %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []*work.DeadJob{}
+	for rows.Next() {
+		var (
+			j        = &work.Job{}
+			args     string
+			enqueued time.Time
+			failed   sql.NullTime
+			died     time.Time
+		)
+		err := rows.Scan(&j.ID, &j.Name, &args, &j.Fails, &j.LastErr, &enqueued, &failed, &died)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not scan dead job; %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(args), &j.Args); err != nil {
+			return nil, 0, fmt.Errorf("could not decode args of job %s; %w", j.ID, err)
+		}
+
+		j.EnqueuedAt = enqueued.Unix()
+		if failed.Valid {
+			j.FailedAt = failed.Time.Unix()
+		}
+		jobs = append(jobs, &work.DeadJob{DiedAt: died.Unix(), Job: j})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("could not read dead jobs; %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// RetryDeadJob enqueue again a dead job forgetting its fails, diedAt is not needed as the IDs are unique
+func (q *SQL) RetryDeadJob(diedAt int64, jobID string) error {
+	res, err := q.db.ExecContext(context.Background(), q.Rebind(`UPDATE queue_jobs
+		SET fails = 0, last_err = '', failed_at = NULL, dead_at = NULL, visible_at = ?
+		WHERE id = ? AND dead_at IS NOT NULL`), q.Now(), jobID)
+	if err != nil {
+		return fmt.Errorf("could not retry dead job; %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return work.ErrNotRetried
+	}
+
+	return nil
+}
+
+// RetryAllDeadJobs enqueue again every dead job forgetting its fails
+func (q *SQL) RetryAllDeadJobs() error {
+	_, err := q.db.ExecContext(context.Background(), q.Rebind(`UPDATE queue_jobs
+		SET fails = 0, last_err = '', failed_at = NULL, dead_at = NULL, visible_at = ?
+		WHERE dead_at IS NOT NULL`), q.Now())
+	if err != nil {
+		return fmt.Errorf("could not retry dead jobs; %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDeadJob remove a dead job, diedAt is not needed as the IDs are unique
+func (q *SQL) DeleteDeadJob(diedAt int64, jobID string) error {
+	res, err := q.db.ExecContext(context.Background(),
+		q.Rebind(`DELETE FROM queue_jobs WHERE id = ? AND dead_at IS NOT NULL`), jobID)
+	if err != nil {
+		return fmt.Errorf("could not delete dead job; %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return work.ErrNotDeleted
+	}
+
+	return nil
+}
+
+// DeleteAllDeadJobs remove every dead job
+func (q *SQL) DeleteAllDeadJobs() error {
+	_, err := q.db.ExecContext(context.Background(), `DELETE FROM queue_jobs WHERE dead_at IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("could not delete dead jobs; %w", err)
+	}
+
+	return nil
+}
+
+// defaultBackoff is the backoff of gocraft/work in seconds
+func defaultBackoff(j *work.Job) int64 {
+	return j.Fails*j.Fails*j.Fails*j.Fails + 15 + mathrand.Int63n(30)*(j.Fails+1)
+}
+
+// newID return a random job ID
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate job ID; %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// NewPool return a pool claiming the jobs of its names by priority, the jobs of a same priority by name
+// opts.MaxConcurrency is not supported
+func (q *SQL) NewPool(concurrency uint) Pool {
+	return &sqlPool{
+		runner:      newRunner(),
+		queue:       q,
+		concurrency: concurrency,
+		jobs:        map[string]sqlJob{},
+	}
+}
+
+type sqlJob struct {
+	opts JobOptions
+	fn   Handler
+}
+
+type periodic struct {
+	name     string
+	schedule cron.Schedule
+}
+
+type sqlPool struct {
+	runner
+	queue       *SQL
+	concurrency uint
+	jobs        map[string]sqlJob
+	// names are the registered names by priority
+	names     []string
+	periodics []periodic
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// JobWithOptions register a job, a zero priority or MaxFails are the defaults of gocraft/work
+func (p *sqlPool) JobWithOptions(name string, opts JobOptions, fn Handler) {
+	if opts.Priority == 0 {
+		opts.Priority = 1
+	}
+	if opts.MaxFails == 0 {
+		opts.MaxFails = DefaultMaxFails
+	}
+
+	if _, ok := p.jobs[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.jobs[name] = sqlJob{opts, fn}
+
+	sort.SliceStable(p.names, func(i, j int) bool {
+		return p.jobs[p.names[i]].opts.Priority > p.jobs[p.names[j]].opts.Priority
+	})
+}
+
+// PeriodicallyEnqueue panics if spec is invalid, like gocraft/work
+// each pool enqueues the job with an ID of its time so the pools sharing the queue enqueue it once
+func (p *sqlPool) PeriodicallyEnqueue(spec, name string) {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	p.periodics = append(p.periodics, periodic{name, schedule})
+}
+
+func (p *sqlPool) Start() {
+	p.stop = make(chan struct{})
+
+	p.wg.Add(1)
+	go p.dispatch()
+
+	for _, periodic := range p.periodics {
+		p.wg.Add(1)
+		go p.enqueuePeriodically(periodic)
+	}
+}
+
+func (p *sqlPool) Stop() {
+	close(p.stop)
+	p.cancel()
+	p.wg.Wait()
+}
+
+// dispatch claim a job for each free slot and run it, waiting Poll when there is none
+func (p *sqlPool) dispatch() {
+	defer p.wg.Done()
+
+	slots := make(chan struct{}, p.concurrency)
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-p.stop:
+			return
+		}
+
+		j, err := p.queue.claim(context.Background(), p.names)
+		if err != nil {
+			log.Error().Err(err).Msg("could not claim job")
+		}
+		if j == nil {
+			<-slots
+			select {
+			case <-time.After(p.queue.Poll):
+			case <-p.stop:
+				return
+			}
+			continue
+		}
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer func() { <-slots }()
+			p.process(j)
+		}()
+	}
+}
+
+// process run a claimed job, extending its visibility until it ends, then ack or fail it
+func (p *sqlPool) process(j *work.Job) {
+	ctx := context.Background()
+	registered := p.jobs[j.Name]
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(p.queue.visibility() / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.queue.extend(ctx, j.ID); err != nil {
+					log.Error().Err(err).Str("job", j.Name).Str("id", j.ID).Msg("could not extend job")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := p.safeRun(j, registered)
+	close(done)
+
+	if err == nil {
+		err = p.queue.ack(ctx, j.ID)
+	} else {
+		err = p.queue.fail(ctx, j, err, registered.opts.JobOptions)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("job", j.Name).Str("id", j.ID).Msg("could not end job")
+	}
+}
+
+// safeRun run the job, a panic fails it
+func (p *sqlPool) safeRun(j *work.Job, registered sqlJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return p.run(j, registered.opts, registered.fn)
+}
+
+// enqueuePeriodically enqueue the job at each time of its schedule until the pool stops
+func (p *sqlPool) enqueuePeriodically(periodic periodic) {
+	defer p.wg.Done()
+
+	for {
+		at := periodic.schedule.Next(time.Now())
+		select {
+		case <-time.After(time.Until(at)):
+		case <-p.stop:
+			return
+		}
+
+		id := fmt.Sprintf("periodic:%s:%d", periodic.name, at.Unix())
+		if _, err := p.queue.enqueue(context.Background(), id, periodic.name, nil); err != nil {
+			log.Error().Err(err).Str("job", periodic.name).Msg("could not enqueue periodic job")
+		}
+	}
+}
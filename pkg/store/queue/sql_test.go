@@ -0,0 +1,358 @@
+package queue_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"boiler/pkg/store/database"
+	"boiler/pkg/store/migration"
+	"boiler/pkg/store/queue"
+
+	"github.com/gocraft/work"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+var memoryDBs int64
+
+// once kill the jobs at their first fail
+var once = queue.JobOptions{JobOptions: work.JobOptions{MaxFails: 1}}
+
+// newSQL return a queue in a new and migrated sqlite3 in memory database
+func newSQL(t *testing.T) (*queue.SQL, *sql.DB) {
+	dsn := fmt.Sprintf("file:queue%d?mode=memory&cache=shared", atomic.AddInt64(&memoryDBs, 1))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migration.New(db, database.Sqlite3.Migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	q := queue.NewSQL(db)
+	q.Poll = 10 * time.Millisecond
+	return q, db
+}
+
+// count return the number of jobs in the queue matching where
+func count(t *testing.T, db *sql.DB, where string) int {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM queue_jobs WHERE ` + where).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestSQL(t *testing.T) {
+	// succeed to run the jobs by priority through the middlewares
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("low", map[string]interface{}{"id": 1})
+		assert.Nil(t, err)
+		_, err = q.Enqueue("high", map[string]interface{}{"id": 2})
+		assert.Nil(t, err)
+
+		var (
+			mu   sync.Mutex
+			runs []string
+			done = make(chan struct{}, 2)
+		)
+		handler := func(_ context.Context, j *work.Job) error {
+			mu.Lock()
+			runs = append(runs, fmt.Sprintf("%s %d", j.Name, j.ArgInt64("id")))
+			mu.Unlock()
+			done <- struct{}{}
+			return j.ArgError()
+		}
+
+		pool := q.NewPool(1)
+		pool.Middleware(func(ctx context.Context, j *work.Job, next queue.Handler) error {
+			mu.Lock()
+			runs = append(runs, "middleware")
+			mu.Unlock()
+			return next(ctx, j)
+		})
+		pool.JobWithOptions("low", queue.JobOptions{JobOptions: work.JobOptions{Priority: 1}}, handler)
+		pool.JobWithOptions("high", queue.JobOptions{JobOptions: work.JobOptions{Priority: 10}}, handler)
+		pool.Start()
+		<-done
+		<-done
+		pool.Stop()
+
+		assert.Equal(t, []string{"middleware", "high 2", "middleware", "low 1"}, runs)
+		assert.Equal(t, 0, count(t, db, "1 = 1"))
+	}
+
+	// succeed to retry a failed job then keep it as dead
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("fail", nil)
+		assert.Nil(t, err)
+
+		var fails int64
+		done := make(chan struct{}, 2)
+		pool := q.NewPool(2)
+		opts := queue.JobOptions{JobOptions: work.JobOptions{MaxFails: 2, Backoff: func(*work.Job) int64 { return 0 }}}
+		pool.JobWithOptions("fail", opts, func(_ context.Context, j *work.Job) error {
+			atomic.AddInt64(&fails, 1)
+			done <- struct{}{}
+			return fmt.Errorf("opz")
+		})
+		pool.Start()
+		<-done
+		<-done
+		pool.Stop()
+
+		assert.Equal(t, int64(2), atomic.LoadInt64(&fails))
+		assert.Equal(t, 1, count(t, db, "dead_at IS NOT NULL AND fails = 2 AND last_err = 'opz'"))
+	}
+
+	// succeed to fail a job which panics
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("panic", nil)
+		assert.Nil(t, err)
+
+		done := make(chan struct{}, 1)
+		pool := q.NewPool(1)
+		pool.Middleware(func(ctx context.Context, j *work.Job, next queue.Handler) error {
+			defer func() { done <- struct{}{} }()
+			return next(ctx, j)
+		})
+		pool.JobWithOptions("panic", once, func(_ context.Context, j *work.Job) error {
+			panic("opz")
+		})
+		pool.Start()
+		<-done
+		pool.Stop()
+
+		assert.Equal(t, 1, count(t, db, "dead_at IS NOT NULL AND last_err = 'panic: opz'"))
+	}
+
+	// succeed to time out a job
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("slow", nil)
+		assert.Nil(t, err)
+
+		opts := once
+		opts.Timeout = 10 * time.Millisecond
+		pool := q.NewPool(1)
+		pool.JobWithOptions("slow", opts, func(ctx context.Context, j *work.Job) error {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		pool.Start()
+		for i := 0; i < 100 && count(t, db, "dead_at IS NOT NULL") == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		pool.Stop()
+
+		assert.Equal(t, 1, count(t, db, "dead_at IS NOT NULL AND last_err = 'context deadline exceeded'"))
+	}
+
+	// succeed to cancel the running jobs when the pool stops
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("slow", nil)
+		assert.Nil(t, err)
+
+		started := make(chan struct{})
+		pool := q.NewPool(1)
+		pool.JobWithOptions("slow", once, func(ctx context.Context, j *work.Job) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		pool.Start()
+		<-started
+		pool.Stop()
+
+		assert.Equal(t, 1, count(t, db, "dead_at IS NOT NULL AND last_err = 'context canceled'"))
+	}
+
+	// succeed to hide a claimed job from the other pools
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("slow", nil)
+		assert.Nil(t, err)
+
+		var runs int64
+		started, release := make(chan struct{}), make(chan struct{})
+		handler := func(_ context.Context, j *work.Job) error {
+			atomic.AddInt64(&runs, 1)
+			started <- struct{}{}
+			<-release
+			return nil
+		}
+
+		first, second := q.NewPool(1), q.NewPool(1)
+		first.JobWithOptions("slow", queue.JobOptions{}, handler)
+		second.JobWithOptions("slow", queue.JobOptions{}, handler)
+		first.Start()
+		<-started
+		second.Start()
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		first.Stop()
+		second.Stop()
+
+		assert.Equal(t, int64(1), atomic.LoadInt64(&runs))
+		assert.Equal(t, 0, count(t, db, "1 = 1"))
+	}
+
+	// succeed to run a job with a visibility below the minimum
+	{
+		q, db := newSQL(t)
+		q.Visibility = 0
+
+		_, err := q.Enqueue("job", nil)
+		assert.Nil(t, err)
+
+		done := make(chan struct{})
+		pool := q.NewPool(1)
+		pool.JobWithOptions("job", queue.JobOptions{}, func(_ context.Context, j *work.Job) error {
+			close(done)
+			return nil
+		})
+		pool.Start()
+		<-done
+		pool.Stop()
+
+		assert.Equal(t, 0, count(t, db, "1 = 1"))
+	}
+
+	// succeed to leave the jobs of the names not registered
+	{
+		q, db := newSQL(t)
+
+		_, err := q.Enqueue("other", nil)
+		assert.Nil(t, err)
+
+		pool := q.NewPool(1)
+		pool.JobWithOptions("job", queue.JobOptions{}, func(_ context.Context, j *work.Job) error { return nil })
+		pool.Start()
+		time.Sleep(50 * time.Millisecond)
+		pool.Stop()
+
+		assert.Equal(t, 1, count(t, db, "name = 'other' AND dead_at IS NULL"))
+	}
+
+	// succeed to enqueue periodically
+	{
+		q, _ := newSQL(t)
+
+		done := make(chan struct{}, 1)
+		pool := q.NewPool(1)
+		pool.JobWithOptions("periodic", queue.JobOptions{}, func(_ context.Context, j *work.Job) error {
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+		pool.PeriodicallyEnqueue("* * * * * *", "periodic")
+		pool.Start()
+		select {
+		case <-done:
+		case <-time.After(3 * time.Second):
+			t.Error("periodic job not run")
+		}
+		pool.Stop()
+	}
+
+	// fails if the spec is invalid
+	{
+		q, _ := newSQL(t)
+
+		assert.Panics(t, func() { q.NewPool(1).PeriodicallyEnqueue("nope", "periodic") })
+	}
+}
+
+func TestSQLDeadJobs(t *testing.T) {
+	q, db := newSQL(t)
+
+	// kill a job through a pool
+	kill := func(name string) {
+		_, err := q.Enqueue(name, map[string]interface{}{"id": 6})
+		assert.Nil(t, err)
+
+		done := make(chan struct{}, 1)
+		pool := q.NewPool(1)
+		pool.JobWithOptions(name, once, func(_ context.Context, j *work.Job) error {
+			done <- struct{}{}
+			return fmt.Errorf("opz")
+		})
+		pool.Start()
+		<-done
+		pool.Stop()
+	}
+	kill("first")
+	kill("second")
+
+	client := q.NewClient()
+
+	// succeed to list the dead jobs
+	jobs, total, err := client.DeadJobs(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, "first", jobs[0].Name)
+		assert.Equal(t, "opz", jobs[0].LastErr)
+		assert.Equal(t, int64(1), jobs[0].Fails)
+		assert.Equal(t, float64(6), jobs[0].Args["id"])
+		assert.NotZero(t, jobs[0].DiedAt)
+	}
+
+	jobs, total, err = client.DeadJobs(2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, jobs, 0)
+
+	// succeed to retry a dead job
+	jobs, _, err = client.DeadJobs(1)
+	assert.Nil(t, err)
+	first, second := jobs[0], jobs[1]
+	assert.Nil(t, client.RetryDeadJob(first.DiedAt, first.ID))
+	assert.Equal(t, 1, count(t, db, "dead_at IS NULL AND fails = 0 AND last_err = ''"))
+
+	// fails if the job is not dead
+	assert.Equal(t, work.ErrNotRetried, client.RetryDeadJob(first.DiedAt, first.ID))
+	assert.Equal(t, work.ErrNotDeleted, client.DeleteDeadJob(first.DiedAt, first.ID))
+
+	// succeed to delete a dead job
+	assert.Nil(t, client.DeleteDeadJob(second.DiedAt, second.ID))
+	assert.Equal(t, 1, count(t, db, "1 = 1"))
+
+	// succeed to retry and delete every dead job
+	kill("third")
+	kill("fourth")
+	assert.Nil(t, client.RetryAllDeadJobs())
+	assert.Equal(t, 0, count(t, db, "dead_at IS NOT NULL"))
+
+	_, err = db.Exec(`UPDATE queue_jobs SET dead_at = visible_at`)
+	assert.Nil(t, err)
+	assert.Nil(t, client.DeleteAllDeadJobs())
+	assert.Equal(t, 0, count(t, db, "1 = 1"))
+}
@@ -0,0 +1,13 @@
+package store
+
+import "boiler/pkg/entity"
+
+// DefaultRoles are the roles created by the migrations
+// support manage the users and their emails but can not act as them nor grant roles
+var DefaultRoles = []entity.Role{
+	{Name: entity.RoleAdmin, Permissions: []string{entity.PermissionAll}},
+	{Name: entity.RoleMember, Permissions: []string{}},
+	{Name: entity.RoleSupport, Permissions: []string{
+		"list_users", "read_user", "update_user", "delete_user", "list_emails", "read_email", "delete_email",
+	}},
+}
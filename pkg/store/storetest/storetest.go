@@ -0,0 +1,1560 @@
+// Package storetest contains a conformance suite for the implementations of store.Interface
+package storetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"boiler/pkg/entity"
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Factory return a new and empty store
+type Factory func() store.Interface
+
+// RunConformance verify that the store behaves as expected by the service layer
+// every test receives a new store from factory
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, store.Interface)
+	}{
+		{"AddUser", testAddUser},
+		{"UpdateUser", testUpdateUser},
+		{"UpdateUserPassword", testUpdateUserPassword},
+		{"DeleteUser", testDeleteUser},
+		{"FilterUsersID", testFilterUsersID},
+		{"FetchUsers", testFetchUsers},
+		{"PaginateUsers", testPaginateUsers},
+		{"AddEmail", testAddEmail},
+		{"DeleteEmail", testDeleteEmail},
+		{"DeleteEmailsByUserID", testDeleteEmailsByUserID},
+		{"RestoreUser", testRestoreUser},
+		{"RestoreEmail", testRestoreEmail},
+		{"Purge", testPurge},
+		{"FilterEmails", testFilterEmails},
+		{"VerifyEmail", testVerifyEmail},
+		{"PaginateEmails", testPaginateEmails},
+		{"FetchRoles", testFetchRoles},
+		{"SetUserRoles", testSetUserRoles},
+		{"RefreshToken", testRefreshToken},
+		{"RevokeRefreshFamily", testRevokeRefreshFamily},
+		{"DenyToken", testDenyToken},
+		{"RevokeAccessTokens", testRevokeAccessTokens},
+		{"RevokeUserRefreshTokens", testRevokeUserRefreshTokens},
+		{"PasswordReset", testPasswordReset},
+		{"TOTP", testTOTP},
+		{"RecoveryCodes", testRecoveryCodes},
+		{"APIKeys", testAPIKeys},
+		{"Organizations", testOrganizations},
+		{"OrganizationFilters", testOrganizationFilters},
+		{"OrganizationIsolation", testOrganizationIsolation},
+		{"Jobs", testJobs},
+		{"PurgeExpiredTokens", testPurgeExpiredTokens},
+		{"PurgeOrphanedEmails", testPurgeOrphanedEmails},
+		{"ScheduleRuns", testScheduleRuns},
+		{"Rollback", testRollback},
+		{"RunInTx", testRunInTx},
+		{"Savepoint", testSavepoint},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, factory())
+		})
+	}
+}
+
+// inTx run fn inside a committed transaction
+func inTx(t *testing.T, st store.Interface, fn func(tx store.Tx) error) error {
+	t.Helper()
+
+	tx, err := st.Tx(context.Background())
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	if err := fn(tx); err != nil {
+		assert.Nil(t, tx.Rollback())
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func addUser(t *testing.T, st store.Interface, name string) entity.User {
+	t.Helper()
+
+	user := entity.User{Name: name, Password: "pass"}
+	err := inTx(t, st, func(tx store.Tx) error {
+		return st.AddUser(context.Background(), tx, &user)
+	})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	return user
+}
+
+func addEmail(t *testing.T, st store.Interface, userID int64, address string) entity.Email {
+	t.Helper()
+
+	email := entity.Email{UserID: userID, Address: address}
+	err := inTx(t, st, func(tx store.Tx) error {
+		return st.AddEmail(context.Background(), tx, &email)
+	})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	return email
+}
+
+func fetchUser(t *testing.T, st store.Interface, userID int64) []entity.User {
+	t.Helper()
+
+	var users []entity.User
+	assert.Nil(t, st.FetchUsers(context.Background(), []int64{userID}, false, &users))
+	return users
+}
+
+func testAddUser(t *testing.T, st store.Interface) {
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	assert.NotZero(t, a.ID)
+	assert.NotZero(t, b.ID)
+	assert.NotEqual(t, a.ID, b.ID)
+
+	users := fetchUser(t, st, a.ID)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, a.ID, users[0].ID)
+		assert.Equal(t, "a", users[0].Name)
+		assert.Equal(t, "pass", users[0].Password)
+		assert.False(t, users[0].Created.IsZero())
+		assert.False(t, users[0].Updated.IsZero())
+	}
+}
+
+func testUpdateUser(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := fetchUser(t, st, addUser(t, st, "a").ID)[0]
+	b := addUser(t, st, "b")
+
+	// succeed
+	user := a
+	user.Name = "c"
+	err := inTx(t, st, func(tx store.Tx) error { return st.UpdateUser(ctx, tx, &user) })
+	assert.Nil(t, err)
+	assert.True(t, user.Updated.After(a.Updated))
+
+	users := fetchUser(t, st, a.ID)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "c", users[0].Name)
+		assert.Equal(t, "pass", users[0].Password)
+		assert.True(t, users[0].Created.Equal(a.Created))
+		assert.True(t, users[0].Updated.Equal(user.Updated))
+	}
+	assert.Equal(t, "b", fetchUser(t, st, b.ID)[0].Name)
+
+	// updated can be round-tripped
+	again := users[0]
+	again.Name = "d"
+	err = inTx(t, st, func(tx store.Tx) error { return st.UpdateUser(ctx, tx, &again) })
+	assert.Nil(t, err)
+
+	// fails if stale
+	{
+		stale := a
+		stale.Name = "e"
+		err := inTx(t, st, func(tx store.Tx) error { return st.UpdateUser(ctx, tx, &stale) })
+		assert.True(t, errors.Is(err, errors.ErrUpdateConflict))
+		assert.Equal(t, "d", fetchUser(t, st, a.ID)[0].Name)
+	}
+
+	// fails if not found
+	{
+		missing := entity.User{ID: b.ID + 1000, Name: "x"}
+		err := inTx(t, st, func(tx store.Tx) error { return st.UpdateUser(ctx, tx, &missing) })
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+	}
+}
+
+func testUpdateUserPassword(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := fetchUser(t, st, addUser(t, st, "a").ID)[0]
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.UpdateUserPassword(ctx, tx, a.ID, "new") })
+	assert.Nil(t, err)
+
+	users := fetchUser(t, st, a.ID)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "new", users[0].Password)
+		assert.Equal(t, "a", users[0].Name)
+		assert.True(t, users[0].Updated.After(a.Updated))
+	}
+
+	// a profile read before the change is stale
+	{
+		stale := a
+		err := inTx(t, st, func(tx store.Tx) error { return st.UpdateUser(ctx, tx, &stale) })
+		assert.True(t, errors.Is(err, errors.ErrUpdateConflict))
+	}
+
+	// fails if not found
+	{
+		err := inTx(t, st, func(tx store.Tx) error { return st.UpdateUserPassword(ctx, tx, a.ID+1000, "new") })
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+	}
+}
+
+func testDeleteUser(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.DeleteUser(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	assert.Len(t, fetchUser(t, st, a.ID), 0)
+	assert.Len(t, fetchUser(t, st, b.ID), 1)
+
+	// fails if not found
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteUser(ctx, tx, a.ID) })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
+
+func testRestoreUser(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	before := addEmail(t, st, a.ID, "before@example.com")
+	with := addEmail(t, st, a.ID, "with@example.com")
+	taken := addEmail(t, st, a.ID, "taken@example.com")
+
+	err := inTx(t, st, func(tx store.Tx) error { return st.DeleteEmail(ctx, tx, before.ID) })
+	assert.Nil(t, err)
+	time.Sleep(time.Millisecond)
+	err = inTx(t, st, func(tx store.Tx) error {
+		if err := st.DeleteUser(ctx, tx, a.ID); err != nil {
+			return err
+		}
+		return st.DeleteEmailsByUserID(ctx, tx, a.ID)
+	})
+	assert.Nil(t, err)
+
+	// the deleted user is hidden unless asked for
+	var IDs []int64
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 10, AllOrganizations: true}, &IDs))
+	assert.NotContains(t, IDs, a.ID)
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Email: with.Address, AllOrganizations: true}, &IDs))
+	assert.Len(t, IDs, 0)
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 10, IncludeDeleted: true, AllOrganizations: true}, &IDs))
+	assert.Contains(t, IDs, a.ID)
+
+	var users []entity.User
+	assert.Nil(t, st.FetchUsers(ctx, []int64{a.ID}, true, &users))
+	if assert.Len(t, users, 1) {
+		assert.NotNil(t, users[0].DeletedAt)
+	}
+
+	// the address of a deleted email can be added again
+	b := addUser(t, st, "b")
+	addEmail(t, st, b.ID, taken.Address)
+
+	// succeed, restoring the emails deleted with the user and still free
+	err = inTx(t, st, func(tx store.Tx) error { return st.RestoreUser(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	if users := fetchUser(t, st, a.ID); assert.Len(t, users, 1) {
+		assert.Nil(t, users[0].DeletedAt)
+	}
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: a.ID, AllOrganizations: true}, &emails))
+	if assert.Len(t, emails, 1) {
+		assert.Equal(t, with.ID, emails[0].ID)
+	}
+
+	// fails if not deleted
+	err = inTx(t, st, func(tx store.Tx) error { return st.RestoreUser(ctx, tx, a.ID) })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
+
+func testRestoreEmail(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	email := addEmail(t, st, a.ID, "a@example.com")
+	other := addEmail(t, st, a.ID, "other@example.com")
+
+	err := inTx(t, st, func(tx store.Tx) error { return st.DeleteEmail(ctx, tx, email.ID) })
+	assert.Nil(t, err)
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteEmail(ctx, tx, other.ID) })
+	assert.Nil(t, err)
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{
+		UserID: a.ID, IncludeDeleted: true, AllOrganizations: true,
+	}, &emails))
+	assert.Len(t, emails, 2)
+
+	// succeed
+	err = inTx(t, st, func(tx store.Tx) error { return st.RestoreEmail(ctx, tx, email.ID) })
+	assert.Nil(t, err)
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{EmailID: email.ID, AllOrganizations: true}, &emails))
+	assert.Len(t, emails, 1)
+
+	// fails if not deleted
+	err = inTx(t, st, func(tx store.Tx) error { return st.RestoreEmail(ctx, tx, email.ID) })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+
+	// fails if the address was added again
+	addEmail(t, st, a.ID, other.Address)
+	err = inTx(t, st, func(tx store.Tx) error { return st.RestoreEmail(ctx, tx, other.ID) })
+	assert.True(t, errors.Is(err, errors.ErrAlreadyExists))
+}
+
+func testPurge(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	addEmail(t, st, a.ID, "a@example.com")
+	email := addEmail(t, st, b.ID, "b@example.com")
+	kept := addEmail(t, st, b.ID, "kept@example.com")
+
+	err := inTx(t, st, func(tx store.Tx) error { return st.DeleteUser(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteEmail(ctx, tx, email.ID) })
+	assert.Nil(t, err)
+
+	// only the users deleted before the time are listed
+	var IDs []int64
+	assert.Nil(t, st.FilterDeletedUsersID(ctx, time.Now().Add(-time.Hour), 10, &IDs))
+	assert.Len(t, IDs, 0)
+	assert.Nil(t, st.FilterDeletedUsersID(ctx, time.Now().Add(time.Hour), 10, &IDs))
+	assert.Equal(t, []int64{a.ID}, IDs)
+
+	// succeed
+	err = inTx(t, st, func(tx store.Tx) error { return st.PurgeUser(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	var users []entity.User
+	assert.Nil(t, st.FetchUsers(ctx, []int64{a.ID}, true, &users))
+	assert.Len(t, users, 0)
+
+	err = inTx(t, st, func(tx store.Tx) error { return st.PurgeEmails(ctx, tx, time.Now().Add(time.Hour)) })
+	assert.Nil(t, err)
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{
+		UserID: b.ID, IncludeDeleted: true, AllOrganizations: true,
+	}, &emails))
+	if assert.Len(t, emails, 1) {
+		assert.Equal(t, kept.ID, emails[0].ID)
+	}
+
+	// fails if not found
+	err = inTx(t, st, func(tx store.Tx) error { return st.PurgeUser(ctx, tx, a.ID) })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
+
+func testFilterUsersID(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	addUser(t, st, "c")
+	addEmail(t, st, b.ID, "b@example.com")
+
+	// limit
+	{
+		var IDs []int64
+		assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 2, AllOrganizations: true}, &IDs))
+		assert.Len(t, IDs, 2)
+
+		IDs = nil
+		assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 10, AllOrganizations: true}, &IDs))
+		assert.Len(t, IDs, 3)
+		assert.Contains(t, IDs, a.ID)
+	}
+
+	// by email
+	{
+		var IDs []int64
+		assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{
+			Email: "b@example.com", Limit: 10, AllOrganizations: true,
+		}, &IDs))
+		assert.Equal(t, []int64{b.ID}, IDs)
+	}
+
+	// unknown email
+	{
+		var IDs []int64
+		assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{
+			Email: "x@example.com", Limit: 10, AllOrganizations: true,
+		}, &IDs))
+		assert.Len(t, IDs, 0)
+	}
+}
+
+func testFetchUsers(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	// succeed
+	{
+		var users []entity.User
+		assert.Nil(t, st.FetchUsers(ctx, []int64{a.ID, b.ID, b.ID + 1000}, false, &users))
+		assert.Len(t, users, 2)
+
+		names := []string{}
+		for _, u := range users {
+			names = append(names, u.Name)
+		}
+		sort.Strings(names)
+		assert.Equal(t, []string{"a", "b"}, names)
+	}
+
+	// empty
+	{
+		var users []entity.User
+		assert.Nil(t, st.FetchUsers(ctx, nil, false, &users))
+		assert.Len(t, users, 0)
+	}
+}
+
+func testPaginateUsers(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+
+	var users []entity.User
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		users = append(users, fetchUser(t, st, addUser(t, st, name).ID)...)
+	}
+
+	for _, sortBy := range []store.SortBy{store.SortByID, store.SortByCreated} {
+		cursor := func(u entity.User) string { return store.NewCursor(sortBy, u.ID, u.Created).String() }
+
+		// walk forward
+		var IDs []int64
+		assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{SortBy: sortBy, Limit: 2, AllOrganizations: true}, &IDs))
+		assert.Equal(t, []int64{users[0].ID, users[1].ID}, IDs, sortBy)
+
+		filter := store.FilterUsers{After: cursor(users[1]), SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Equal(t, []int64{users[2].ID, users[3].ID}, IDs, sortBy)
+
+		filter = store.FilterUsers{After: cursor(users[3]), SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Equal(t, []int64{users[4].ID}, IDs, sortBy)
+
+		// walk backward
+		filter = store.FilterUsers{Before: cursor(users[4]), SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Equal(t, []int64{users[2].ID, users[3].ID}, IDs, sortBy)
+
+		filter = store.FilterUsers{Before: cursor(users[1]), SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Equal(t, []int64{users[0].ID}, IDs, sortBy)
+
+		// walk backward from the end
+		filter = store.FilterUsers{Backward: true, SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Equal(t, []int64{users[3].ID, users[4].ID}, IDs, sortBy)
+
+		// cursors of deleted users still work
+		filter = store.FilterUsers{
+			After: store.NewCursor(sortBy, 0, time.Time{}).String(), SortBy: sortBy, Limit: 1, AllOrganizations: true,
+		}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Equal(t, []int64{users[0].ID}, IDs, sortBy)
+	}
+
+	// fails if cursor is invalid
+	{
+		var IDs []int64
+		err := st.FilterUsersID(ctx, store.FilterUsers{After: "opz", Limit: 2, AllOrganizations: true}, &IDs)
+		assert.True(t, errors.Is(err, errors.ErrInvalidCursor))
+	}
+}
+
+func testAddEmail(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	// succeed
+	email := addEmail(t, st, a.ID, "a@example.com")
+	assert.NotZero(t, email.ID)
+
+	// fails if duplicated
+	{
+		dup := entity.Email{UserID: b.ID, Address: "a@example.com"}
+		err := inTx(t, st, func(tx store.Tx) error { return st.AddEmail(ctx, tx, &dup) })
+		assert.True(t, errors.Is(err, errors.ErrAlreadyExists))
+	}
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: b.ID, AllOrganizations: true}, &emails))
+	assert.Len(t, emails, 0)
+}
+
+func testDeleteEmail(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	email := addEmail(t, st, a.ID, "a@example.com")
+	other := addEmail(t, st, a.ID, "other@example.com")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.DeleteEmail(ctx, tx, email.ID) })
+	assert.Nil(t, err)
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: a.ID, AllOrganizations: true}, &emails))
+	if assert.Len(t, emails, 1) {
+		assert.Equal(t, other.ID, emails[0].ID)
+	}
+
+	// fails if not found
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteEmail(ctx, tx, email.ID) })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+
+	// address can be reused once deleted
+	addEmail(t, st, a.ID, "a@example.com")
+}
+
+func testDeleteEmailsByUserID(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	addEmail(t, st, a.ID, "a1@example.com")
+	addEmail(t, st, a.ID, "a2@example.com")
+	addEmail(t, st, b.ID, "b@example.com")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.DeleteEmailsByUserID(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: a.ID, AllOrganizations: true}, &emails))
+	assert.Len(t, emails, 0)
+
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: b.ID, AllOrganizations: true}, &emails))
+	assert.Len(t, emails, 1)
+
+	// fails if user has no emails
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteEmailsByUserID(ctx, tx, a.ID) })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
+
+func testFilterEmails(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	a1 := addEmail(t, st, a.ID, "a1@example.com")
+	addEmail(t, st, a.ID, "a2@example.com")
+	addEmail(t, st, b.ID, "b@example.com")
+
+	// by user
+	{
+		var emails []entity.Email
+		assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: a.ID, AllOrganizations: true}, &emails))
+		assert.Len(t, emails, 2)
+		for _, e := range emails {
+			assert.Equal(t, a.ID, e.UserID)
+			assert.False(t, e.Created.IsZero())
+		}
+	}
+
+	// by ID
+	{
+		var emails []entity.Email
+		assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{EmailID: a1.ID, AllOrganizations: true}, &emails))
+		if assert.Len(t, emails, 1) {
+			assert.Equal(t, a1.ID, emails[0].ID)
+			assert.Equal(t, a.ID, emails[0].UserID)
+			assert.Equal(t, "a1@example.com", emails[0].Address)
+		}
+	}
+
+	// not found
+	{
+		var emails []entity.Email
+		assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{EmailID: a1.ID + 1000, AllOrganizations: true}, &emails))
+		assert.Len(t, emails, 0)
+	}
+}
+
+func testVerifyEmail(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	email := addEmail(t, st, a.ID, "a@example.com")
+	at := time.Now().Truncate(time.Second)
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{Address: "a@example.com", AllOrganizations: true}, &emails))
+	if assert.Len(t, emails, 1) {
+		assert.Equal(t, email.ID, emails[0].ID)
+		assert.Nil(t, emails[0].VerifiedAt)
+	}
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.VerifyEmail(ctx, tx, email.ID, at) })
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{EmailID: email.ID, AllOrganizations: true}, &emails))
+	if assert.Len(t, emails, 1) && assert.NotNil(t, emails[0].VerifiedAt) {
+		assert.True(t, at.Equal(*emails[0].VerifiedAt))
+	}
+
+	// fails if already verified
+	err = inTx(t, st, func(tx store.Tx) error { return st.VerifyEmail(ctx, tx, email.ID, at) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	// fails if not found
+	err = inTx(t, st, func(tx store.Tx) error { return st.VerifyEmail(ctx, tx, email.ID+100, at) })
+	assert.Equal(t, errors.ErrNotFound, err)
+
+	// empty if the address is unknown
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{
+		Address: "unknown@example.com", AllOrganizations: true,
+	}, &emails))
+	assert.Len(t, emails, 0)
+}
+
+func testPaginateEmails(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	addEmail(t, st, b.ID, "b@example.com")
+
+	var emails []entity.Email
+	for _, address := range []string{"a1@example.com", "a2@example.com", "a3@example.com"} {
+		addEmail(t, st, a.ID, address)
+	}
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{UserID: a.ID, AllOrganizations: true}, &emails))
+	if !assert.Len(t, emails, 3) {
+		return
+	}
+
+	addresses := func(emails []entity.Email) []string {
+		list := []string{}
+		for _, e := range emails {
+			list = append(list, e.Address)
+		}
+		return list
+	}
+
+	for _, sortBy := range []store.SortBy{store.SortByID, store.SortByCreated} {
+		cursor := func(e entity.Email) string { return store.NewCursor(sortBy, e.ID, e.Created).String() }
+
+		var page []entity.Email
+		assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{
+			UserID: a.ID, SortBy: sortBy, Limit: 2, AllOrganizations: true,
+		}, &page))
+		assert.Equal(t, []string{"a1@example.com", "a2@example.com"}, addresses(page), sortBy)
+
+		filter := store.FilterEmails{UserID: a.ID, After: cursor(emails[1]), SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterEmails(ctx, filter, &page))
+		assert.Equal(t, []string{"a3@example.com"}, addresses(page), sortBy)
+
+		filter = store.FilterEmails{UserID: a.ID, Before: cursor(emails[2]), SortBy: sortBy, Limit: 1, AllOrganizations: true}
+		assert.Nil(t, st.FilterEmails(ctx, filter, &page))
+		assert.Equal(t, []string{"a2@example.com"}, addresses(page), sortBy)
+
+		filter = store.FilterEmails{UserID: a.ID, Backward: true, SortBy: sortBy, Limit: 2, AllOrganizations: true}
+		assert.Nil(t, st.FilterEmails(ctx, filter, &page))
+		assert.Equal(t, []string{"a2@example.com", "a3@example.com"}, addresses(page), sortBy)
+	}
+}
+
+func testFetchRoles(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+
+	// succeed with the default roles
+	var roles []entity.Role
+	assert.Nil(t, st.FetchRoles(ctx, nil, &roles))
+	assert.Equal(t, store.DefaultRoles, roles)
+
+	// succeed by names, unknown names are ignored
+	assert.Nil(t, st.FetchRoles(ctx, []string{entity.RoleSupport, "unknown", entity.RoleAdmin, entity.RoleAdmin}, &roles))
+	if assert.Len(t, roles, 2) {
+		assert.Equal(t, entity.RoleAdmin, roles[0].Name)
+		assert.Equal(t, entity.RoleSupport, roles[1].Name)
+		assert.True(t, roles[0].Can("delete_user"))
+		assert.True(t, roles[1].Can("delete_user"))
+		assert.False(t, roles[1].Can("change_password"))
+	}
+}
+
+func testSetUserRoles(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error {
+		return st.SetUserRoles(ctx, tx, a.ID, []string{entity.RoleSupport, entity.RoleMember, entity.RoleSupport})
+	})
+	assert.Nil(t, err)
+
+	var roles []string
+	assert.Nil(t, st.FetchUserRoles(ctx, a.ID, &roles))
+	assert.Equal(t, []string{entity.RoleMember, entity.RoleSupport}, roles)
+
+	assert.Nil(t, st.FetchUserRoles(ctx, b.ID, &roles))
+	assert.Len(t, roles, 0)
+
+	// succeed replacing the roles
+	err = inTx(t, st, func(tx store.Tx) error {
+		return st.SetUserRoles(ctx, tx, a.ID, []string{entity.RoleAdmin})
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FetchUserRoles(ctx, a.ID, &roles))
+	assert.Equal(t, []string{entity.RoleAdmin}, roles)
+
+	// fails if a role does not exist
+	err = inTx(t, st, func(tx store.Tx) error {
+		return st.SetUserRoles(ctx, tx, a.ID, []string{entity.RoleMember, "unknown"})
+	})
+	assert.True(t, errors.Is(err, errors.ErrInvalidRole))
+
+	assert.Nil(t, st.FetchUserRoles(ctx, a.ID, &roles))
+	assert.Equal(t, []string{entity.RoleAdmin}, roles)
+
+	// succeed removing the roles
+	err = inTx(t, st, func(tx store.Tx) error { return st.SetUserRoles(ctx, tx, a.ID, nil) })
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FetchUserRoles(ctx, a.ID, &roles))
+	assert.Len(t, roles, 0)
+}
+
+func addRefreshToken(t *testing.T, st store.Interface, userID int64, family, hash string) entity.RefreshToken {
+	t.Helper()
+
+	token := entity.RefreshToken{UserID: userID, Family: family, Hash: hash, Expires: time.Now().Add(time.Hour)}
+	err := inTx(t, st, func(tx store.Tx) error {
+		return st.AddRefreshToken(context.Background(), tx, &token)
+	})
+	assert.Nil(t, err)
+	return token
+}
+
+func testRefreshToken(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+
+	// succeed
+	added := addRefreshToken(t, st, a.ID, "f1", "h1")
+	assert.NotEqual(t, int64(0), added.ID)
+	assert.False(t, added.Created.IsZero())
+
+	var token entity.RefreshToken
+	assert.Nil(t, st.FetchRefreshToken(ctx, "h1", &token))
+	assert.Equal(t, added.ID, token.ID)
+	assert.Equal(t, a.ID, token.UserID)
+	assert.Equal(t, "f1", token.Family)
+	assert.WithinDuration(t, added.Expires, token.Expires, time.Second)
+	assert.False(t, token.Used)
+	assert.False(t, token.Revoked)
+	assert.Zero(t, token.OrganizationID)
+
+	// succeed to keep the organization
+	err := inTx(t, st, func(tx store.Tx) error {
+		return st.AddRefreshToken(ctx, tx, &entity.RefreshToken{UserID: a.ID, Family: "f3", Hash: "h3",
+			OrganizationID: 5, Expires: time.Now()})
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, st.FetchRefreshToken(ctx, "h3", &token))
+	assert.Equal(t, int64(5), token.OrganizationID)
+
+	// fails if the hash already exists
+	err = inTx(t, st, func(tx store.Tx) error {
+		return st.AddRefreshToken(ctx, tx, &entity.RefreshToken{UserID: a.ID, Family: "f2", Hash: "h1"})
+	})
+	assert.Equal(t, errors.ErrAlreadyExists, err)
+
+	// fails if not found
+	assert.Equal(t, errors.ErrNotFound, st.FetchRefreshToken(ctx, "unknown", &token))
+
+	// succeed using the token
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseRefreshToken(ctx, tx, added.ID) })
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FetchRefreshToken(ctx, "h1", &token))
+	assert.True(t, token.Used)
+
+	// fails if already used
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseRefreshToken(ctx, tx, added.ID) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	// fails if the token does not exist
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseRefreshToken(ctx, tx, added.ID+100) })
+	assert.Equal(t, errors.ErrNotFound, err)
+}
+
+func testRevokeRefreshFamily(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+
+	addRefreshToken(t, st, a.ID, "f1", "h1")
+	addRefreshToken(t, st, a.ID, "f1", "h2")
+	addRefreshToken(t, st, a.ID, "f2", "h3")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.RevokeRefreshFamily(ctx, tx, "f1") })
+	assert.Nil(t, err)
+
+	var token entity.RefreshToken
+	for hash, revoked := range map[string]bool{"h1": true, "h2": true, "h3": false} {
+		assert.Nil(t, st.FetchRefreshToken(ctx, hash, &token))
+		assert.Equal(t, revoked, token.Revoked, hash)
+	}
+
+	// succeed if the family does not exist
+	err = inTx(t, st, func(tx store.Tx) error { return st.RevokeRefreshFamily(ctx, tx, "unknown") })
+	assert.Nil(t, err)
+}
+
+func testRevokeUserRefreshTokens(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	addRefreshToken(t, st, a.ID, "f1", "h1")
+	addRefreshToken(t, st, a.ID, "f2", "h2")
+	addRefreshToken(t, st, b.ID, "f3", "h3")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.RevokeUserRefreshTokens(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+
+	var token entity.RefreshToken
+	for hash, revoked := range map[string]bool{"h1": true, "h2": true, "h3": false} {
+		assert.Nil(t, st.FetchRefreshToken(ctx, hash, &token))
+		assert.Equal(t, revoked, token.Revoked, hash)
+	}
+}
+
+func testPasswordReset(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+
+	// succeed
+	reset := entity.PasswordReset{UserID: a.ID, Hash: "h", Expires: time.Now().Add(time.Hour)}
+	err := inTx(t, st, func(tx store.Tx) error { return st.AddPasswordReset(ctx, tx, &reset) })
+	assert.Nil(t, err)
+	assert.NotZero(t, reset.ID)
+
+	var got entity.PasswordReset
+	assert.Nil(t, st.FetchPasswordReset(ctx, "h", &got))
+	assert.Equal(t, reset.ID, got.ID)
+	assert.Equal(t, a.ID, got.UserID)
+	assert.False(t, got.Used)
+	assert.WithinDuration(t, reset.Expires, got.Expires, time.Second)
+
+	// fails if the hash exists
+	err = inTx(t, st, func(tx store.Tx) error {
+		return st.AddPasswordReset(ctx, tx, &entity.PasswordReset{UserID: a.ID, Hash: "h", Expires: time.Now()})
+	})
+	assert.True(t, errors.Is(err, errors.ErrAlreadyExists))
+
+	// fails if the hash does not exist
+	assert.Equal(t, errors.ErrNotFound, st.FetchPasswordReset(ctx, "unknown", &got))
+
+	// succeed to use it once
+	err = inTx(t, st, func(tx store.Tx) error { return st.UsePasswordReset(ctx, tx, reset.ID) })
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FetchPasswordReset(ctx, "h", &got))
+	assert.True(t, got.Used)
+
+	err = inTx(t, st, func(tx store.Tx) error { return st.UsePasswordReset(ctx, tx, reset.ID) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	// fails if it does not exist
+	err = inTx(t, st, func(tx store.Tx) error { return st.UsePasswordReset(ctx, tx, reset.ID+100) })
+	assert.Equal(t, errors.ErrNotFound, err)
+}
+
+func testTOTP(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+
+	var got entity.TOTP
+	assert.Equal(t, errors.ErrNotFound, st.FetchTOTP(ctx, a.ID, &got))
+
+	// succeed
+	totp := entity.TOTP{UserID: a.ID, Secret: "s1"}
+	err := inTx(t, st, func(tx store.Tx) error { return st.SetTOTP(ctx, tx, &totp) })
+	assert.Nil(t, err)
+	assert.False(t, totp.Created.IsZero())
+
+	assert.Nil(t, st.FetchTOTP(ctx, a.ID, &got))
+	assert.Equal(t, "s1", got.Secret)
+	assert.False(t, got.Confirmed)
+
+	// succeed to replace it while unconfirmed
+	err = inTx(t, st, func(tx store.Tx) error { return st.SetTOTP(ctx, tx, &entity.TOTP{UserID: a.ID, Secret: "s2"}) })
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FetchTOTP(ctx, a.ID, &got))
+	assert.Equal(t, "s2", got.Secret)
+
+	// succeed to confirm it once
+	err = inTx(t, st, func(tx store.Tx) error { return st.ConfirmTOTP(ctx, tx, a.ID, 10) })
+	assert.Nil(t, err)
+
+	assert.Nil(t, st.FetchTOTP(ctx, a.ID, &got))
+	assert.True(t, got.Confirmed)
+	assert.Equal(t, int64(10), got.Counter)
+
+	err = inTx(t, st, func(tx store.Tx) error { return st.ConfirmTOTP(ctx, tx, a.ID, 11) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	// fails to replace it once confirmed
+	err = inTx(t, st, func(tx store.Tx) error { return st.SetTOTP(ctx, tx, &entity.TOTP{UserID: a.ID, Secret: "s3"}) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	// succeed to use a later step once
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseTOTP(ctx, tx, a.ID, 11) })
+	assert.Nil(t, err)
+
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseTOTP(ctx, tx, a.ID, 11) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseTOTP(ctx, tx, a.ID, 9) })
+	assert.Equal(t, errors.ErrUpdateConflict, err)
+
+	// fails if it does not exist
+	err = inTx(t, st, func(tx store.Tx) error { return st.UseTOTP(ctx, tx, a.ID+100, 12) })
+	assert.Equal(t, errors.ErrNotFound, err)
+
+	err = inTx(t, st, func(tx store.Tx) error { return st.ConfirmTOTP(ctx, tx, a.ID+100, 12) })
+	assert.Equal(t, errors.ErrNotFound, err)
+
+	// succeed to delete it
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteTOTP(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	assert.Equal(t, errors.ErrNotFound, st.FetchTOTP(ctx, a.ID, &got))
+}
+
+func testRecoveryCodes(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	use := func(userID int64, hash string) error {
+		return inTx(t, st, func(tx store.Tx) error { return st.UseRecoveryCode(ctx, tx, userID, hash) })
+	}
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.SetRecoveryCodes(ctx, tx, a.ID, []string{"h1", "h2"}) })
+	assert.Nil(t, err)
+
+	// succeed to use a code once
+	assert.Nil(t, use(a.ID, "h1"))
+	assert.Equal(t, errors.ErrNotFound, use(a.ID, "h1"))
+
+	// fails with the code of another user
+	assert.Equal(t, errors.ErrNotFound, use(b.ID, "h2"))
+
+	// succeed to replace the codes
+	err = inTx(t, st, func(tx store.Tx) error { return st.SetRecoveryCodes(ctx, tx, a.ID, []string{"h3"}) })
+	assert.Nil(t, err)
+	assert.Equal(t, errors.ErrNotFound, use(a.ID, "h2"))
+
+	// succeed to delete them with the TOTP
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteTOTP(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	assert.Equal(t, errors.ErrNotFound, use(a.ID, "h3"))
+}
+
+func testAPIKeys(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	add := func(key *entity.APIKey) error {
+		return inTx(t, st, func(tx store.Tx) error { return st.AddAPIKey(ctx, tx, key) })
+	}
+
+	// succeed
+	expires := time.Now().Add(time.Hour)
+	key := entity.APIKey{
+		UserID: a.ID, Name: "ci", Prefix: "p1", Hash: "h1",
+		Scopes: []string{entity.ScopeUsersRead, entity.ScopeEmailsWrite}, Expires: &expires,
+	}
+	assert.Nil(t, add(&key))
+	assert.NotZero(t, key.ID)
+	assert.Nil(t, add(&entity.APIKey{UserID: a.ID, Name: "other", Prefix: "p2", Hash: "h2", Scopes: []string{entity.ScopeUsersRead}}))
+	assert.Nil(t, add(&entity.APIKey{UserID: b.ID, Name: "b", Prefix: "p3", Hash: "h3", Scopes: []string{entity.ScopeUsersRead}}))
+
+	var got entity.APIKey
+	assert.Nil(t, st.FetchAPIKey(ctx, "h1", &got))
+	assert.Equal(t, key.ID, got.ID)
+	assert.Equal(t, "ci", got.Name)
+	assert.Equal(t, "p1", got.Prefix)
+	assert.Equal(t, []string{entity.ScopeUsersRead, entity.ScopeEmailsWrite}, got.Scopes)
+	if assert.NotNil(t, got.Expires) {
+		assert.WithinDuration(t, expires, *got.Expires, time.Second)
+	}
+	assert.Nil(t, got.LastUsed)
+	assert.False(t, got.Revoked)
+
+	// fails if the prefix or the hash exists
+	assert.True(t, errors.Is(add(&entity.APIKey{UserID: a.ID, Name: "x", Prefix: "p1", Hash: "h4"}), errors.ErrAlreadyExists))
+	assert.True(t, errors.Is(add(&entity.APIKey{UserID: a.ID, Name: "x", Prefix: "p4", Hash: "h1"}), errors.ErrAlreadyExists))
+
+	// fails if the hash does not exist
+	assert.Equal(t, errors.ErrNotFound, st.FetchAPIKey(ctx, "unknown", &got))
+
+	// succeed to list the keys of the user
+	var keys []entity.APIKey
+	assert.Nil(t, st.FilterAPIKeys(ctx, a.ID, &keys))
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, "ci", keys[0].Name)
+		assert.Equal(t, "other", keys[1].Name)
+	}
+
+	// succeed to touch
+	now := store.Now()
+	err := inTx(t, st, func(tx store.Tx) error { return st.TouchAPIKey(ctx, tx, key.ID, now) })
+	assert.Nil(t, err)
+	assert.Nil(t, st.FetchAPIKey(ctx, "h1", &got))
+	if assert.NotNil(t, got.LastUsed) {
+		assert.WithinDuration(t, now, *got.LastUsed, time.Millisecond)
+	}
+
+	// succeed to revoke
+	err = inTx(t, st, func(tx store.Tx) error { return st.RevokeAPIKey(ctx, tx, a.ID, key.ID) })
+	assert.Nil(t, err)
+	assert.Nil(t, st.FetchAPIKey(ctx, "h1", &got))
+	assert.True(t, got.Revoked)
+
+	// fails to revoke the key of another user
+	err = inTx(t, st, func(tx store.Tx) error { return st.RevokeAPIKey(ctx, tx, b.ID, key.ID) })
+	assert.Equal(t, errors.ErrNotFound, err)
+
+	// succeed to delete the keys of the user
+	err = inTx(t, st, func(tx store.Tx) error { return st.DeleteAPIKeysByUserID(ctx, tx, a.ID) })
+	assert.Nil(t, err)
+	assert.Nil(t, st.FilterAPIKeys(ctx, a.ID, &keys))
+	assert.Len(t, keys, 0)
+	assert.Nil(t, st.FilterAPIKeys(ctx, b.ID, &keys))
+	assert.Len(t, keys, 1)
+}
+
+func testDenyToken(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	expires := time.Now().Add(time.Minute)
+
+	denied, err := st.IsTokenDenied(ctx, "jti")
+	assert.Nil(t, err)
+	assert.False(t, denied)
+
+	// succeed
+	err = inTx(t, st, func(tx store.Tx) error { return st.DenyToken(ctx, tx, "jti", expires) })
+	assert.Nil(t, err)
+
+	denied, err = st.IsTokenDenied(ctx, "jti")
+	assert.Nil(t, err)
+	assert.True(t, denied)
+
+	// succeed if already denied
+	err = inTx(t, st, func(tx store.Tx) error { return st.DenyToken(ctx, tx, "jti", expires) })
+	assert.Nil(t, err)
+
+	denied, err = st.IsTokenDenied(ctx, "other")
+	assert.Nil(t, err)
+	assert.False(t, denied)
+}
+
+func testRevokeAccessTokens(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	expires := now.Add(time.Minute)
+
+	revoked, err := st.IsTokenRevoked(ctx, 4, now)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	// succeed to revoke the tokens issued before
+	err = inTx(t, st, func(tx store.Tx) error { return st.RevokeAccessTokens(ctx, tx, 4, now, expires) })
+	assert.Nil(t, err)
+
+	revoked, err = st.IsTokenRevoked(ctx, 4, now.Add(-time.Second))
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = st.IsTokenRevoked(ctx, 4, now)
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = st.IsTokenRevoked(ctx, 5, now.Add(-time.Second))
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	// succeed to replace the revocation
+	later := now.Add(time.Hour)
+	err = inTx(t, st, func(tx store.Tx) error { return st.RevokeAccessTokens(ctx, tx, 4, later, expires) })
+	assert.Nil(t, err)
+
+	revoked, err = st.IsTokenRevoked(ctx, 4, now)
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+}
+
+func testOrganizations(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+
+	addOrg := func(name string) entity.Organization {
+		org := entity.Organization{Name: name}
+		assert.Nil(t, inTx(t, st, func(tx store.Tx) error { return st.AddOrganization(ctx, tx, &org) }))
+		return org
+	}
+	addMember := func(member *entity.Member) error {
+		return inTx(t, st, func(tx store.Tx) error { return st.AddMember(ctx, tx, member) })
+	}
+
+	// succeed
+	acme := addOrg("acme")
+	assert.NotZero(t, acme.ID)
+	assert.False(t, acme.Created.IsZero())
+	other := addOrg("other")
+
+	var org entity.Organization
+	assert.Nil(t, st.FetchOrganization(ctx, acme.ID, &org))
+	assert.Equal(t, "acme", org.Name)
+
+	// fails if not found
+	assert.Equal(t, errors.ErrNotFound, st.FetchOrganization(ctx, 99, &org))
+
+	// succeed to add members
+	owner := entity.Member{OrganizationID: acme.ID, UserID: a.ID, Role: entity.OrgRoleOwner}
+	assert.Nil(t, addMember(&owner))
+	assert.False(t, owner.Created.IsZero())
+	assert.Nil(t, addMember(&entity.Member{OrganizationID: acme.ID, UserID: b.ID, Role: entity.OrgRoleMember}))
+	assert.Nil(t, addMember(&entity.Member{OrganizationID: other.ID, UserID: a.ID, Role: entity.OrgRoleAdmin}))
+
+	// fails if already a member
+	err := addMember(&entity.Member{OrganizationID: acme.ID, UserID: b.ID, Role: entity.OrgRoleAdmin})
+	assert.Equal(t, errors.ErrAlreadyExists, err)
+
+	var member entity.Member
+	assert.Nil(t, st.FetchMember(ctx, acme.ID, b.ID, &member))
+	assert.Equal(t, entity.OrgRoleMember, member.Role)
+	assert.Equal(t, errors.ErrNotFound, st.FetchMember(ctx, other.ID, b.ID, &member))
+
+	var members []entity.Member
+	assert.Nil(t, st.FilterMembers(ctx, acme.ID, &members))
+	if assert.Len(t, members, 2) {
+		assert.Equal(t, a.ID, members[0].UserID)
+		assert.Equal(t, entity.OrgRoleOwner, members[0].Role)
+		assert.Equal(t, b.ID, members[1].UserID)
+	}
+
+	var orgs []entity.Organization
+	assert.Nil(t, st.FilterOrganizations(ctx, a.ID, &orgs))
+	if assert.Len(t, orgs, 2) {
+		assert.Equal(t, "acme", orgs[0].Name)
+		assert.Equal(t, "other", orgs[1].Name)
+	}
+
+	// succeed to remove the user from its organizations
+	assert.Nil(t, inTx(t, st, func(tx store.Tx) error { return st.DeleteMembersByUserID(ctx, tx, a.ID) }))
+	assert.Nil(t, st.FilterOrganizations(ctx, a.ID, &orgs))
+	assert.Len(t, orgs, 0)
+	assert.Nil(t, st.FilterMembers(ctx, acme.ID, &members))
+	assert.Len(t, members, 1)
+}
+
+func testOrganizationFilters(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	c := addUser(t, st, "c")
+	ea := addEmail(t, st, a.ID, "a@a.com")
+	eb := addEmail(t, st, b.ID, "b@b.com")
+
+	org := entity.Organization{Name: "acme"}
+	err := inTx(t, st, func(tx store.Tx) error {
+		err := st.AddOrganization(ctx, tx, &org)
+		if err != nil {
+			return err
+		}
+
+		for _, userID := range []int64{a.ID, c.ID} {
+			err = st.AddMember(ctx, tx, &entity.Member{OrganizationID: org.ID, UserID: userID, Role: entity.OrgRoleMember})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// succeed to only list the members
+	var IDs []int64
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{OrganizationID: org.ID, Limit: 10}, &IDs))
+	assert.Equal(t, []int64{a.ID, c.ID}, IDs)
+
+	filter := store.FilterUsers{OrganizationID: org.ID, SortBy: store.SortByCreated, Limit: 1}
+	assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+	assert.Equal(t, []int64{a.ID}, IDs)
+
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{OrganizationID: org.ID, Email: "b@b.com", Limit: 10}, &IDs))
+	assert.Len(t, IDs, 0)
+
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{OrganizationID: org.ID, Email: "a@a.com", Limit: 10}, &IDs))
+	assert.Equal(t, []int64{a.ID}, IDs)
+
+	// succeed to only list the emails of the members
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{OrganizationID: org.ID, UserID: a.ID}, &emails))
+	assert.Len(t, emails, 1)
+
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{OrganizationID: org.ID, UserID: b.ID}, &emails))
+	assert.Len(t, emails, 0)
+
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{OrganizationID: org.ID, EmailID: eb.ID}, &emails))
+	assert.Len(t, emails, 0)
+
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{OrganizationID: org.ID, EmailID: ea.ID}, &emails))
+	assert.Len(t, emails, 1)
+
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{OrganizationID: org.ID, Address: "b@b.com"}, &emails))
+	assert.Len(t, emails, 0)
+
+	// succeed to list everything with AllOrganizations
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 10, AllOrganizations: true}, &IDs))
+	assert.Equal(t, []int64{a.ID, b.ID, c.ID}, IDs)
+
+	// fails if the filter has no organization
+	err = st.FilterUsersID(ctx, store.FilterUsers{Limit: 10}, &IDs)
+	assert.True(t, errors.Is(err, errors.ErrMissingOrganization))
+
+	err = st.FilterEmails(ctx, store.FilterEmails{UserID: a.ID}, &emails)
+	assert.True(t, errors.Is(err, errors.ErrMissingOrganization))
+}
+
+func testOrganizationIsolation(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	b := addUser(t, st, "b")
+	ea := addEmail(t, st, a.ID, "a@a.com")
+	eb := addEmail(t, st, b.ID, "b@b.com")
+
+	// a is the only member of first and b the only one of second
+	first, second := entity.Organization{Name: "first"}, entity.Organization{Name: "second"}
+	err := inTx(t, st, func(tx store.Tx) error {
+		for _, m := range []struct {
+			org    *entity.Organization
+			userID int64
+		}{{&first, a.ID}, {&second, b.ID}} {
+			if err := st.AddOrganization(ctx, tx, m.org); err != nil {
+				return err
+			}
+
+			err := st.AddMember(ctx, tx, &entity.Member{OrganizationID: m.org.ID, UserID: m.userID, Role: entity.OrgRoleOwner})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// succeed to only see the users and the emails of its own organization
+	for _, tenant := range []struct {
+		orgID       int64
+		user, other entity.User
+		email       entity.Email
+		otherEmail  entity.Email
+	}{
+		{first.ID, a, b, ea, eb},
+		{second.ID, b, a, eb, ea},
+	} {
+		var IDs []int64
+		assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{OrganizationID: tenant.orgID, Limit: 10}, &IDs))
+		assert.Equal(t, []int64{tenant.user.ID}, IDs)
+
+		filter := store.FilterUsers{OrganizationID: tenant.orgID, Email: tenant.otherEmail.Address, Limit: 10}
+		assert.Nil(t, st.FilterUsersID(ctx, filter, &IDs))
+		assert.Len(t, IDs, 0)
+
+		var emails []entity.Email
+		assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{OrganizationID: tenant.orgID, UserID: tenant.user.ID}, &emails))
+		if assert.Len(t, emails, 1) {
+			assert.Equal(t, tenant.email.ID, emails[0].ID)
+		}
+
+		for _, f := range []store.FilterEmails{
+			{OrganizationID: tenant.orgID, UserID: tenant.other.ID},
+			{OrganizationID: tenant.orgID, EmailID: tenant.otherEmail.ID, IncludeDeleted: true},
+			{OrganizationID: tenant.orgID, Address: tenant.otherEmail.Address},
+		} {
+			assert.Nil(t, st.FilterEmails(ctx, f, &emails))
+			assert.Len(t, emails, 0)
+		}
+	}
+}
+
+func testJobs(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+
+	add := func(job *entity.Job) error {
+		return inTx(t, st, func(tx store.Tx) error { return st.AddJob(ctx, tx, job) })
+	}
+
+	// succeed
+	job := entity.Job{ID: "j1", Name: "delete_user", UserID: 4, Status: entity.JobQueued}
+	assert.Nil(t, add(&job))
+	assert.False(t, job.Created.IsZero())
+
+	var got entity.Job
+	assert.Nil(t, st.FetchJob(ctx, "j1", &got))
+	assert.Equal(t, "delete_user", got.Name)
+	assert.Equal(t, int64(4), got.UserID)
+	assert.Equal(t, entity.JobQueued, got.Status)
+	assert.Empty(t, got.Error)
+
+	// fails if the ID is taken
+	assert.True(t, errors.Is(add(&entity.Job{ID: "j1", Name: "delete_user", Status: entity.JobQueued}),
+		errors.ErrAlreadyExists))
+
+	// succeed to set the status
+	err := inTx(t, st, func(tx store.Tx) error { return st.SetJobStatus(ctx, tx, "j1", entity.JobFailed, "opz") })
+	assert.Nil(t, err)
+	assert.Nil(t, st.FetchJob(ctx, "j1", &got))
+	assert.Equal(t, entity.JobFailed, got.Status)
+	assert.Equal(t, "opz", got.Error)
+	assert.False(t, got.Updated.Before(got.Created))
+
+	// fails if not found
+	assert.Equal(t, errors.ErrNotFound, st.FetchJob(ctx, "j2", &got))
+	err = inTx(t, st, func(tx store.Tx) error { return st.SetJobStatus(ctx, tx, "j2", entity.JobRunning, "") })
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
+
+func testPurgeExpiredTokens(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+
+	expired := entity.RefreshToken{UserID: a.ID, Family: "f1", Hash: "h1", Expires: time.Now().Add(-time.Hour)}
+	reset := entity.PasswordReset{UserID: a.ID, Hash: "r1", Expires: time.Now().Add(-time.Hour)}
+	err := inTx(t, st, func(tx store.Tx) error {
+		if err := st.AddRefreshToken(ctx, tx, &expired); err != nil {
+			return err
+		}
+		if err := st.AddPasswordReset(ctx, tx, &reset); err != nil {
+			return err
+		}
+		if err := st.DenyToken(ctx, tx, "old", time.Now().Add(-time.Hour)); err != nil {
+			return err
+		}
+		if err := st.RevokeAccessTokens(ctx, tx, a.ID, time.Now(), time.Now().Add(-time.Hour)); err != nil {
+			return err
+		}
+		return st.DenyToken(ctx, tx, "new", time.Now().Add(time.Hour))
+	})
+	assert.Nil(t, err)
+	addRefreshToken(t, st, a.ID, "f2", "h2")
+
+	// succeed
+	err = inTx(t, st, func(tx store.Tx) error { return st.PurgeExpiredTokens(ctx, tx, time.Now()) })
+	assert.Nil(t, err)
+
+	var token entity.RefreshToken
+	assert.Equal(t, errors.ErrNotFound, st.FetchRefreshToken(ctx, "h1", &token))
+	assert.Nil(t, st.FetchRefreshToken(ctx, "h2", &token))
+
+	var got entity.PasswordReset
+	assert.Equal(t, errors.ErrNotFound, st.FetchPasswordReset(ctx, "r1", &got))
+
+	denied, err := st.IsTokenDenied(ctx, "old")
+	assert.Nil(t, err)
+	assert.False(t, denied)
+	denied, err = st.IsTokenDenied(ctx, "new")
+	assert.Nil(t, err)
+	assert.True(t, denied)
+
+	revoked, err := st.IsTokenRevoked(ctx, a.ID, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+}
+
+func testPurgeOrphanedEmails(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	a := addUser(t, st, "a")
+	kept := addEmail(t, st, a.ID, "a@example.com")
+	addEmail(t, st, a.ID+100, "orphan@example.com")
+
+	// succeed
+	err := inTx(t, st, func(tx store.Tx) error { return st.PurgeOrphanedEmails(ctx, tx) })
+	assert.Nil(t, err)
+
+	var emails []entity.Email
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{
+		UserID: a.ID, IncludeDeleted: true, AllOrganizations: true,
+	}, &emails))
+	if assert.Len(t, emails, 1) {
+		assert.Equal(t, kept.ID, emails[0].ID)
+	}
+	assert.Nil(t, st.FilterEmails(ctx, store.FilterEmails{
+		UserID: a.ID + 100, IncludeDeleted: true, AllOrganizations: true,
+	}, &emails))
+	assert.Len(t, emails, 0)
+}
+
+func testScheduleRuns(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	set := func(job string, at time.Time, status string) error {
+		return inTx(t, st, func(tx store.Tx) error { return st.SetScheduleRun(ctx, tx, job, at, status) })
+	}
+
+	// succeed
+	first := store.Now().Add(-time.Hour)
+	assert.Nil(t, set("purge_tokens", first, entity.JobFailed))
+	assert.Nil(t, set("purge_deleted", first, entity.JobSucceeded))
+
+	// succeed to replace the last run
+	last := store.Now()
+	assert.Nil(t, set("purge_tokens", last, entity.JobSucceeded))
+
+	var schedules []entity.Schedule
+	assert.Nil(t, st.FetchScheduleRuns(ctx, &schedules))
+	if assert.Len(t, schedules, 2) {
+		assert.Equal(t, "purge_deleted", schedules[0].Job)
+		assert.Equal(t, "purge_tokens", schedules[1].Job)
+		assert.Equal(t, entity.JobSucceeded, schedules[1].LastStatus)
+		if assert.NotNil(t, schedules[1].LastRun) {
+			assert.WithinDuration(t, last, *schedules[1].LastRun, time.Millisecond)
+		}
+	}
+}
+
+func testRollback(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+
+	tx, err := st.Tx(context.Background())
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	user := entity.User{Name: "a", Password: "pass"}
+	assert.Nil(t, st.AddUser(ctx, tx, &user))
+	assert.Nil(t, tx.Rollback())
+
+	assert.Len(t, fetchUser(t, st, user.ID), 0)
+
+	var IDs []int64
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 10, AllOrganizations: true}, &IDs))
+	assert.Len(t, IDs, 0)
+}
+
+func testRunInTx(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+
+	// succeed
+	user := entity.User{Name: "a", Password: "pass"}
+	err := store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+		return st.AddUser(ctx, tx, &user)
+	})
+	assert.Nil(t, err)
+	assert.Len(t, fetchUser(t, st, user.ID), 1)
+
+	// rollback if fn fails
+	{
+		opz := errors.New("opz")
+		other := entity.User{Name: "b", Password: "pass"}
+		err := store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+			if err := st.AddUser(ctx, tx, &other); err != nil {
+				return err
+			}
+			return opz
+		})
+		assert.Equal(t, opz, err)
+		assert.Len(t, fetchUser(t, st, other.ID), 0)
+	}
+
+	// rollback if fn panics
+	{
+		other := entity.User{Name: "b", Password: "pass"}
+		assert.Panics(t, func() {
+			_ = store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+				if err := st.AddUser(ctx, tx, &other); err != nil {
+					return err
+				}
+				panic("opz")
+			})
+		})
+		assert.Len(t, fetchUser(t, st, other.ID), 0)
+	}
+
+	// rollback if ctx is canceled
+	{
+		ctx, cancel := context.WithCancel(ctx)
+		other := entity.User{Name: "b", Password: "pass"}
+		err := store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+			if err := st.AddUser(ctx, tx, &other); err != nil {
+				return err
+			}
+			cancel()
+			return nil
+		})
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Len(t, fetchUser(t, st, other.ID), 0)
+	}
+
+	var IDs []int64
+	assert.Nil(t, st.FilterUsersID(ctx, store.FilterUsers{Limit: 10, AllOrganizations: true}, &IDs))
+	assert.Equal(t, []int64{user.ID}, IDs)
+}
+
+func testSavepoint(t *testing.T, st store.Interface) {
+	ctx := context.Background()
+	opz := errors.New("opz")
+
+	a := entity.User{Name: "a", Password: "pass"}
+	b := entity.User{Name: "b", Password: "pass"}
+	c := entity.User{Name: "c", Password: "pass"}
+
+	err := store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+		if err := st.AddUser(ctx, tx, &a); err != nil {
+			return err
+		}
+
+		// the failure of a nested call discards only its changes
+		err := store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+			if err := st.AddUser(ctx, tx, &b); err != nil {
+				return err
+			}
+			return opz
+		})
+		assert.Equal(t, opz, err)
+
+		return store.RunInTx(ctx, st, func(ctx context.Context, tx store.Tx) error {
+			return st.AddUser(ctx, tx, &c)
+		})
+	})
+	assert.Nil(t, err)
+
+	var users []entity.User
+	assert.Nil(t, st.FetchUsers(ctx, []int64{a.ID, b.ID, c.ID}, false, &users))
+
+	names := []string{}
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"a", "c"}, names)
+}
@@ -0,0 +1,108 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Throttle keeping the failures in the process, each server counts its own
+type Memory struct {
+	// Now is the clock of the throttle, time.Now if nil
+	Now func() time.Time
+
+	mu     sync.Mutex
+	keys   map[string]entry
+	pruned time.Time
+}
+
+type entry struct {
+	failures int
+	last     time.Time
+	window   time.Duration
+	until    time.Time
+}
+
+// NewMemory return an empty Memory throttle
+func NewMemory() *Memory {
+	return &Memory{keys: map[string]entry{}}
+}
+
+func (m *Memory) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+
+	return time.Now()
+}
+
+// Wait return how long key stays locked
+func (m *Memory) Wait(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.keys[key]
+	if !ok {
+		return 0, nil
+	}
+
+	if wait := e.until.Sub(m.now()); wait > 0 {
+		return wait, nil
+	}
+
+	return 0, nil
+}
+
+// Fail count a failure of key
+func (m *Memory) Fail(ctx context.Context, key string, policy Policy) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.prune(now)
+
+	e := m.keys[key]
+	if e.expired(now) {
+		e = entry{}
+	}
+
+	e.failures++
+	e.last = now
+	e.window = policy.Window
+
+	lock := policy.Lock(e.failures)
+	if lock > 0 {
+		e.until = now.Add(lock)
+	}
+
+	m.keys[key] = e
+	return lock, nil
+}
+
+// Reset forget the failures of key
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key)
+	return nil
+}
+
+// expired tells if the key was quiet for its window and is not locked anymore
+func (e entry) expired(now time.Time) bool {
+	return now.Sub(e.last) > e.window && now.After(e.until)
+}
+
+// prune remove the expired keys, at most once a minute
+func (m *Memory) prune(now time.Time) {
+	if now.Sub(m.pruned) < time.Minute {
+		return
+	}
+	m.pruned = now
+
+	for key, e := range m.keys {
+		if e.expired(now) {
+			delete(m.keys, key)
+		}
+	}
+}
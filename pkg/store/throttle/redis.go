@@ -0,0 +1,89 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis is a Throttle keeping the failures in redis, the servers share them
+type Redis struct {
+	Pool *redis.Pool
+	// Prefix namespace the keys of the throttle
+	Prefix string
+}
+
+// NewRedis return a Redis throttle
+func NewRedis(pool *redis.Pool, prefix string) *Redis {
+	return &Redis{Pool: pool, Prefix: prefix}
+}
+
+// Wait return how long key stays locked
+func (r *Redis) Wait(ctx context.Context, key string) (time.Duration, error) {
+	conn, err := r.Pool.GetContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not get redis connection; %w", err)
+	}
+	defer conn.Close()
+
+	// PTTL is -2 if the key does not exist
+	ttl, err := redis.Int64(conn.Do("PTTL", r.Prefix+key+":lock"))
+	if err != nil {
+		return 0, fmt.Errorf("could not read lock; %w", err)
+	}
+
+	if ttl <= 0 {
+		return 0, nil
+	}
+
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// Fail count a failure of key, the counter expires after the window
+func (r *Redis) Fail(ctx context.Context, key string, policy Policy) (time.Duration, error) {
+	conn, err := r.Pool.GetContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not get redis connection; %w", err)
+	}
+	defer conn.Close()
+
+	failures, err := redis.Int(conn.Do("INCR", r.Prefix+key+":failures"))
+	if err != nil {
+		return 0, fmt.Errorf("could not count failure; %w", err)
+	}
+
+	_, err = conn.Do("PEXPIRE", r.Prefix+key+":failures", policy.Window.Milliseconds())
+	if err != nil {
+		return 0, fmt.Errorf("could not expire failures; %w", err)
+	}
+
+	lock := policy.Lock(failures)
+	if lock == 0 {
+		return 0, nil
+	}
+
+	_, err = conn.Do("SET", r.Prefix+key+":lock", failures, "PX", lock.Milliseconds())
+	if err != nil {
+		return 0, fmt.Errorf("could not lock; %w", err)
+	}
+
+	return lock, nil
+}
+
+// Reset forget the failures of key
+func (r *Redis) Reset(ctx context.Context, key string) error {
+	conn, err := r.Pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get redis connection; %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("DEL", r.Prefix+key+":failures", r.Prefix+key+":lock")
+	if err != nil {
+		return fmt.Errorf("could not reset; %w", err)
+	}
+
+	return nil
+}
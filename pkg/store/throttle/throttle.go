@@ -0,0 +1,102 @@
+// Package throttle counts the failed attempts of a key and locks the key for an exponential time
+package throttle
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Policy tells how long the failures lock a key
+type Policy struct {
+	// Free is the number of failures before the first lock
+	Free int
+	// Base is the first lock, each failure after it doubles the lock up to Max
+	Base time.Duration
+	Max  time.Duration
+	// Window is the quiet period after which the failures of a key are forgotten
+	Window time.Duration
+}
+
+// Lock return the lock started by the failure number failures
+func (p Policy) Lock(failures int) time.Duration {
+	if failures <= p.Free {
+		return 0
+	}
+
+	lock := p.Base
+	for i := p.Free + 1; i < failures && lock < p.Max; i++ {
+		lock *= 2
+	}
+
+	if lock > p.Max {
+		return p.Max
+	}
+
+	return lock
+}
+
+// Throttle stores the failures and the locks of the keys
+type Throttle interface {
+	// Wait return how long key stays locked, zero if it is not
+	Wait(ctx context.Context, key string) (time.Duration, error)
+	// Fail count a failure of key and return the lock it starts following policy
+	Fail(ctx context.Context, key string, policy Policy) (time.Duration, error)
+	// Reset forget the failures and the lock of key
+	Reset(ctx context.Context, key string) error
+}
+
+// WithFallback return a Throttle using fallback when primary fails, the failures counted by
+// fallback are not known by primary once it recovers
+func WithFallback(primary, fallback Throttle) Throttle {
+	return &withFallback{primary, fallback}
+}
+
+type withFallback struct {
+	primary  Throttle
+	fallback Throttle
+}
+
+// Wait return how long key stays locked
+func (t *withFallback) Wait(ctx context.Context, key string) (time.Duration, error) {
+	wait, err := t.primary.Wait(ctx, key)
+	if err != nil {
+		log.Error().Err(err).Msg("could not read throttle, using the fallback")
+		return t.fallback.Wait(ctx, key)
+	}
+
+	// a lock taken while the primary was failing still applies
+	fallback, err := t.fallback.Wait(ctx, key)
+	if err == nil && fallback > wait {
+		return fallback, nil
+	}
+
+	return wait, nil
+}
+
+// Fail count a failure of key
+func (t *withFallback) Fail(ctx context.Context, key string, policy Policy) (time.Duration, error) {
+	lock, err := t.primary.Fail(ctx, key, policy)
+	if err != nil {
+		log.Error().Err(err).Msg("could not write throttle, using the fallback")
+		return t.fallback.Fail(ctx, key, policy)
+	}
+
+	return lock, nil
+}
+
+// Reset forget the failures of key in both throttles
+func (t *withFallback) Reset(ctx context.Context, key string) error {
+	err := t.fallback.Reset(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	err = t.primary.Reset(ctx, key)
+	if err != nil {
+		log.Error().Err(err).Msg("could not reset throttle")
+	}
+
+	return nil
+}
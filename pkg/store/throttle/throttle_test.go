@@ -0,0 +1,221 @@
+package throttle_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"boiler/pkg/store/throttle"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+var policy = throttle.Policy{Free: 2, Base: time.Second, Max: 10 * time.Second, Window: time.Hour}
+
+func TestPolicy(t *testing.T) {
+	for failures, lock := range map[int]time.Duration{
+		0: 0,
+		2: 0,
+		3: time.Second,
+		4: 2 * time.Second,
+		5: 4 * time.Second,
+		6: 8 * time.Second,
+		7: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		assert.Equal(t, lock, policy.Lock(failures), failures)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	m := throttle.NewMemory()
+	m.Now = func() time.Time { return now }
+
+	// succeed to count the free failures
+	for i := 0; i < policy.Free; i++ {
+		lock, err := m.Fail(ctx, "k", policy)
+		assert.Nil(t, err)
+		assert.Zero(t, lock)
+	}
+
+	wait, err := m.Wait(ctx, "k")
+	assert.Nil(t, err)
+	assert.Zero(t, wait)
+
+	// succeed to lock
+	lock, err := m.Fail(ctx, "k", policy)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, lock)
+
+	wait, err = m.Wait(ctx, "k")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	wait, err = m.Wait(ctx, "other")
+	assert.Nil(t, err)
+	assert.Zero(t, wait)
+
+	// succeed to double the lock
+	lock, err = m.Fail(ctx, "k", policy)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, lock)
+
+	// succeed to unlock once the lock ended
+	now = now.Add(2 * time.Second)
+	wait, err = m.Wait(ctx, "k")
+	assert.Nil(t, err)
+	assert.Zero(t, wait)
+
+	// succeed to forget the failures after the window
+	now = now.Add(policy.Window + time.Second)
+	lock, err = m.Fail(ctx, "k", policy)
+	assert.Nil(t, err)
+	assert.Zero(t, lock)
+
+	// succeed to reset
+	for i := 0; i < policy.Free; i++ {
+		_, _ = m.Fail(ctx, "k", policy)
+	}
+	assert.Nil(t, m.Reset(ctx, "k"))
+
+	lock, err = m.Fail(ctx, "k", policy)
+	assert.Nil(t, err)
+	assert.Zero(t, lock)
+}
+
+// fakeConn is a redis connection knowing the commands of the throttle, the expirations are
+// recorded but not applied
+type fakeConn struct {
+	values map[string]int
+	ttl    map[string]int64
+	err    error
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+func (c *fakeConn) Send(string, ...interface{}) error {
+	return fmt.Errorf("not supported")
+}
+func (c *fakeConn) Flush() error                  { return nil }
+func (c *fakeConn) Receive() (interface{}, error) { return nil, fmt.Errorf("not supported") }
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if len(cmd) == 0 {
+		return nil, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	key := func(i int) string { return args[i].(string) }
+	switch strings.ToUpper(cmd) {
+	case "INCR":
+		c.values[key(0)]++
+		return int64(c.values[key(0)]), nil
+	case "PEXPIRE":
+		c.ttl[key(0)] = args[1].(int64)
+		return int64(1), nil
+	case "SET":
+		c.values[key(0)] = args[1].(int)
+		c.ttl[key(0)] = args[3].(int64)
+		return "OK", nil
+	case "PTTL":
+		if _, ok := c.values[key(0)]; !ok {
+			return int64(-2), nil
+		}
+		return c.ttl[key(0)], nil
+	case "DEL":
+		for i := range args {
+			delete(c.values, key(i))
+			delete(c.ttl, key(i))
+		}
+		return int64(len(args)), nil
+	}
+
+	return nil, fmt.Errorf("unknown command %s", cmd)
+}
+
+func newRedis(conn *fakeConn) *throttle.Redis {
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return conn, nil }}
+	return throttle.NewRedis(pool, "t:")
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{values: map[string]int{}, ttl: map[string]int64{}}
+	r := newRedis(conn)
+
+	// succeed to count the free failures
+	for i := 0; i < policy.Free; i++ {
+		lock, err := r.Fail(ctx, "k", policy)
+		assert.Nil(t, err)
+		assert.Zero(t, lock)
+	}
+	assert.Equal(t, policy.Window.Milliseconds(), conn.ttl["t:k:failures"])
+
+	wait, err := r.Wait(ctx, "k")
+	assert.Nil(t, err)
+	assert.Zero(t, wait)
+
+	// succeed to lock
+	lock, err := r.Fail(ctx, "k", policy)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, lock)
+	assert.Equal(t, int64(1000), conn.ttl["t:k:lock"])
+
+	wait, err = r.Wait(ctx, "k")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	// succeed to reset
+	assert.Nil(t, r.Reset(ctx, "k"))
+	assert.Len(t, conn.values, 0)
+
+	// fails if redis fails
+	conn.err = fmt.Errorf("opz")
+	_, err = r.Wait(ctx, "k")
+	assert.Equal(t, "could not read lock; opz", err.Error())
+}
+
+func TestWithFallback(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{values: map[string]int{}, ttl: map[string]int64{}}
+	fallback := throttle.NewMemory()
+	thr := throttle.WithFallback(newRedis(conn), fallback)
+
+	// succeed with the primary
+	for i := 0; i <= policy.Free; i++ {
+		_, err := thr.Fail(ctx, "k", policy)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, policy.Free+1, conn.values["t:k:failures"])
+
+	// succeed with the fallback when the primary fails
+	conn.err = fmt.Errorf("opz")
+	for i := 0; i <= policy.Free; i++ {
+		_, err := thr.Fail(ctx, "f", policy)
+		assert.Nil(t, err)
+	}
+
+	wait, err := thr.Wait(ctx, "f")
+	assert.Nil(t, err)
+	assert.NotZero(t, wait)
+
+	// the locks of the fallback apply once the primary recovers
+	conn.err = nil
+	wait, err = thr.Wait(ctx, "f")
+	assert.Nil(t, err)
+	assert.NotZero(t, wait)
+
+	// succeed to reset both
+	assert.Nil(t, thr.Reset(ctx, "f"))
+	wait, err = fallback.Wait(ctx, "f")
+	assert.Nil(t, err)
+	assert.Zero(t, wait)
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+type txKey struct{}
+
+// txState is the transaction RunInTx carries on the context
+type txState struct {
+	store Interface
+	tx    Tx
+	depth int
+}
+
+// RunInTx run fn inside a transaction of st
+// the transaction is committed if fn returns nil and rolled back if fn returns an error, panics
+// or ctx is done before the commit
+// a RunInTx called with the context received by fn runs inside a savepoint of the same transaction,
+// its failure discards only the changes it made
+func RunInTx(ctx context.Context, st Interface, fn func(ctx context.Context, tx Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if parent, ok := ctx.Value(txKey{}).(*txState); ok && parent.store == st {
+		return parent.nested(ctx, fn)
+	}
+
+	tx, err := st.Tx(ctx)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction; %w", err)
+	}
+
+	state := &txState{store: st, tx: tx}
+
+	done := false
+	defer func() {
+		if !done {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txKey{}, state), tx)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	done = true
+	if err != nil {
+		if er := tx.Rollback(); er != nil {
+			err = fmt.Errorf("%s; %w", er, err)
+		}
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction; %w", err)
+	}
+
+	return nil
+}
+
+// nested run fn inside a savepoint of the parent transaction
+func (s *txState) nested(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error {
+	child := &txState{store: s.store, tx: s.tx, depth: s.depth + 1}
+	name := fmt.Sprintf("sp%d", child.depth)
+
+	if err := s.tx.Savepoint(ctx, name); err != nil {
+		return fmt.Errorf("could not create savepoint; %w", err)
+	}
+
+	done := false
+	defer func() {
+		if !done {
+			_ = s.tx.RollbackTo(ctx, name)
+		}
+	}()
+
+	err := fn(context.WithValue(ctx, txKey{}, child), s.tx)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	done = true
+	if err != nil {
+		if er := s.tx.RollbackTo(ctx, name); er != nil {
+			err = fmt.Errorf("%s; %w", er, err)
+		}
+
+		return err
+	}
+
+	if err := s.tx.Release(ctx, name); err != nil {
+		return fmt.Errorf("could not release savepoint; %w", err)
+	}
+
+	return nil
+}
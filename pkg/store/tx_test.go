@@ -0,0 +1,234 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"boiler/pkg/errors"
+	"boiler/pkg/store"
+	"boiler/pkg/store/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunInTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		var got store.Tx
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			got = tx
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, tx, got)
+	}
+
+	// fails if Tx fails
+	{
+		m.EXPECT().Tx(ctx).Return(nil, fmt.Errorf("opz"))
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			t.Fatal("fn should not run")
+			return nil
+		})
+		assert.Equal(t, "could not begin transaction; opz", err.Error())
+	}
+
+	// rollback if fn fails
+	{
+		opz := fmt.Errorf("opz")
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			return opz
+		})
+		assert.Equal(t, opz, err)
+	}
+
+	// fails if fn fails and rollback fails
+	{
+		opz := fmt.Errorf("opz")
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Rollback().Return(fmt.Errorf("rollbackerr"))
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			return opz
+		})
+		assert.True(t, errors.Is(err, opz))
+		assert.Equal(t, "rollbackerr; opz", err.Error())
+	}
+
+	// fails if commit fails
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Commit().Return(fmt.Errorf("commitfail"))
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			return nil
+		})
+		assert.Equal(t, "could not commit transaction; commitfail", err.Error())
+	}
+
+	// rollback if fn panics
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Rollback().Return(nil)
+
+		assert.PanicsWithValue(t, "opz", func() {
+			_ = store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+				panic("opz")
+			})
+		})
+	}
+
+	// rollback if ctx is canceled before the commit
+	{
+		ctx, cancel := context.WithCancel(ctx)
+
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			cancel()
+			return nil
+		})
+		assert.Equal(t, context.Canceled, err)
+	}
+
+	// fails if ctx is already canceled
+	{
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			t.Fatal("fn should not run")
+			return nil
+		})
+		assert.Equal(t, context.Canceled, err)
+	}
+}
+
+func TestRunInTxNested(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mock.NewMockInterface(ctrl)
+	ctx := context.Background()
+
+	// succeed
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		gomock.InOrder(
+			tx.EXPECT().Savepoint(gomock.Any(), "sp1").Return(nil),
+			tx.EXPECT().Savepoint(gomock.Any(), "sp2").Return(nil),
+			tx.EXPECT().Release(gomock.Any(), "sp2").Return(nil),
+			tx.EXPECT().Release(gomock.Any(), "sp1").Return(nil),
+			tx.EXPECT().Commit().Return(nil),
+		)
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, outer store.Tx) error {
+			return store.RunInTx(ctx, m, func(ctx context.Context, inner store.Tx) error {
+				assert.Equal(t, outer, inner)
+				return store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+					return nil
+				})
+			})
+		})
+		assert.Nil(t, err)
+	}
+
+	// inner failure is rolled back to its savepoint
+	{
+		opz := fmt.Errorf("opz")
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		gomock.InOrder(
+			tx.EXPECT().Savepoint(gomock.Any(), "sp1").Return(nil),
+			tx.EXPECT().RollbackTo(gomock.Any(), "sp1").Return(nil),
+			tx.EXPECT().Commit().Return(nil),
+		)
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+				return opz
+			})
+			assert.Equal(t, opz, err)
+			return nil
+		})
+		assert.Nil(t, err)
+	}
+
+	// inner panic is rolled back to its savepoint and the transaction
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		gomock.InOrder(
+			tx.EXPECT().Savepoint(gomock.Any(), "sp1").Return(nil),
+			tx.EXPECT().RollbackTo(gomock.Any(), "sp1").Return(nil),
+			tx.EXPECT().Rollback().Return(nil),
+		)
+
+		assert.Panics(t, func() {
+			_ = store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+				return store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+					panic("opz")
+				})
+			})
+		})
+	}
+
+	// fails if savepoint fails
+	{
+		tx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		tx.EXPECT().Savepoint(gomock.Any(), "sp1").Return(fmt.Errorf("opz"))
+		tx.EXPECT().Rollback().Return(nil)
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			return store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+				t.Fatal("fn should not run")
+				return nil
+			})
+		})
+		assert.Equal(t, "could not create savepoint; opz", err.Error())
+	}
+
+	// another store starts its own transaction
+	{
+		other := mock.NewMockInterface(ctrl)
+
+		tx := mock.NewMockTx(ctrl)
+		otherTx := mock.NewMockTx(ctrl)
+		m.EXPECT().Tx(ctx).Return(tx, nil)
+		other.EXPECT().Tx(gomock.Any()).Return(otherTx, nil)
+		otherTx.EXPECT().Commit().Return(nil)
+		tx.EXPECT().Commit().Return(nil)
+
+		err := store.RunInTx(ctx, m, func(ctx context.Context, tx store.Tx) error {
+			return store.RunInTx(ctx, other, func(ctx context.Context, tx store.Tx) error {
+				assert.Equal(t, otherTx, tx)
+				return nil
+			})
+		})
+		assert.Nil(t, err)
+	}
+}
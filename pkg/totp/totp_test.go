@@ -0,0 +1,96 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"boiler/pkg/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// secret is the SHA1 key of the RFC 6238 test vectors, base32 encoded
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// https://tools.ietf.org/html/rfc6238#appendix-B, the last 6 digits
+	for unix, code := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		got, err := totp.Code(secret, totp.Counter(time.Unix(unix, 0)))
+		assert.Nil(t, err)
+		assert.Equal(t, code, got, unix)
+	}
+
+	// fails if the secret is not base32
+	{
+		_, err := totp.Code("not base32!", 1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	// succeed
+	{
+		counter, ok := totp.Validate(secret, "081804", now)
+		assert.True(t, ok)
+		assert.Equal(t, totp.Counter(now), counter)
+	}
+
+	// succeed with the code of the previous period
+	{
+		counter, ok := totp.Validate(secret, "081804", now.Add(totp.Period))
+		assert.True(t, ok)
+		assert.Equal(t, totp.Counter(now), counter)
+	}
+
+	// fails out of the skew
+	{
+		_, ok := totp.Validate(secret, "081804", now.Add(2*totp.Period))
+		assert.False(t, ok)
+	}
+
+	// fails with a wrong code
+	{
+		_, ok := totp.Validate(secret, "000000", now)
+		assert.False(t, ok)
+
+		_, ok = totp.Validate(secret, "81804", now)
+		assert.False(t, ok)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	s, err := totp.NewSecret()
+	assert.Nil(t, err)
+	assert.Len(t, s, 32)
+
+	code, err := totp.Code(s, totp.Counter(time.Now()))
+	assert.Nil(t, err)
+
+	_, ok := totp.Validate(s, code, time.Now())
+	assert.True(t, ok)
+
+	other, err := totp.NewSecret()
+	assert.Nil(t, err)
+	assert.NotEqual(t, s, other)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(totp.URI("Boiler", "a@b.c", secret))
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Boiler:a@b.c", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "Boiler", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}